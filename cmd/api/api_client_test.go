@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/testutil"
+	"github.com/vladComan0/performance-analyzer/pkg/client"
+)
+
+// TestClient drives the real routes with the Go client of the API.
+func TestClient(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(target.Close)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	c := client.NewClient(server.URL+"/", "")
+
+	environment, err := c.CreateEnvironment(ctx, client.CreateEnvironmentInput{Name: "staging", Endpoint: target.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateEnvironment(ctx, client.CreateEnvironmentInput{Name: "staging", Endpoint: target.URL}); !errors.Is(err, client.ErrDuplicateName) {
+		t.Errorf("creating staging twice = %v, want ErrDuplicateName", err)
+	}
+
+	thinkTime := client.Duration(0)
+	worker, err := c.CreateWorker(ctx, &client.WorkerInput{
+		EnvironmentID:   environment.ID,
+		Concurrency:     2,
+		RequestsPerTask: 5,
+		HTTPMethod:      http.MethodGet,
+		ThinkTime:       &thinkTime,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := c.WaitForWorker(ctx, worker.ID, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.Final || summary.TotalRequests != 10 || summary.FailedRequests != 0 {
+		t.Errorf("summary = %+v, want 10 requests without failure", summary)
+	}
+
+	// No baseline, no comparison.
+	if comparison, err := c.Compare(ctx, worker.ID); err != nil || comparison != nil {
+		t.Errorf("comparison = %v, %v, want none", comparison, err)
+	}
+
+	workers, cursor, err := c.ListWorkers(ctx, "", 10)
+	if err != nil || len(workers) != 1 || workers[0].ID != worker.ID || cursor != "" {
+		t.Errorf("listed %d workers and cursor %q, %v, want the worker alone", len(workers), cursor, err)
+	}
+
+	if _, err := c.GetMetrics(ctx, 999); !errors.Is(err, client.ErrNoRecord) {
+		t.Errorf("metrics of an unknown worker = %v, want ErrNoRecord", err)
+	}
+
+	_, err = c.CreateWorker(ctx, &client.WorkerInput{EnvironmentID: environment.ID, HTTPMethod: "FETCH"})
+	var apiErr *client.APIError
+	if !errors.Is(err, client.ErrInvalidInput) || !errors.As(err, &apiErr) || apiErr.Fields["concurrency"] == "" || apiErr.Fields["http_method"] == "" {
+		t.Errorf("creating an invalid worker = %v, want the violations of its fields", err)
+	}
+}
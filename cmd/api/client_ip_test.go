@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string // X-Forwarded-For, one value per header line
+		realIP     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "203.0.113.7:5123", want: "203.0.113.7"},
+		{name: "spoofed by an untrusted peer", remoteAddr: "203.0.113.7:5123", forwarded: []string{"1.2.3.4"}, realIP: "5.6.7.8", want: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.1:5123", forwarded: []string{"198.51.100.4"}, want: "198.51.100.4"},
+		{name: "chain of trusted proxies", remoteAddr: "10.0.0.1:5123", forwarded: []string{"198.51.100.4, 192.168.1.1, 10.0.0.2"}, want: "198.51.100.4"},
+		{name: "rightmost untrusted hop", remoteAddr: "10.0.0.1:5123", forwarded: []string{"1.2.3.4, 198.51.100.4, 10.0.0.2"}, want: "198.51.100.4"},
+		{name: "several header lines", remoteAddr: "10.0.0.1:5123", forwarded: []string{"1.2.3.4", "198.51.100.4, 10.0.0.2"}, want: "198.51.100.4"},
+		{name: "only trusted hops", remoteAddr: "10.0.0.1:5123", forwarded: []string{"10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+		{name: "malformed hop", remoteAddr: "10.0.0.1:5123", forwarded: []string{"198.51.100.4, garbage, 10.0.0.2"}, want: "10.0.0.2"},
+		{name: "malformed last hop", remoteAddr: "10.0.0.1:5123", forwarded: []string{"198.51.100.4, unknown"}, want: "10.0.0.1"},
+		{name: "spaces and empty hops", remoteAddr: "10.0.0.1:5123", forwarded: []string{" 198.51.100.4 ,"}, want: "10.0.0.1"},
+		{name: "real ip fallback", remoteAddr: "10.0.0.1:5123", realIP: " 198.51.100.9 ", want: "198.51.100.9"},
+		{name: "invalid real ip", remoteAddr: "10.0.0.1:5123", realIP: "nope", want: "10.0.0.1"},
+		{name: "forwarded for preferred", remoteAddr: "10.0.0.1:5123", forwarded: []string{"198.51.100.4"}, realIP: "198.51.100.9", want: "198.51.100.4"},
+		{name: "IPv6 client", remoteAddr: "[fd00::1]:5123", forwarded: []string{"2001:db8::5"}, want: "2001:db8::5"},
+		{name: "IPv4 mapped hop", remoteAddr: "10.0.0.1:5123", forwarded: []string{"::ffff:198.51.100.4"}, want: "198.51.100.4"},
+		{name: "IPv4 mapped trusted peer", remoteAddr: "[::ffff:10.0.0.1]:5123", forwarded: []string{"198.51.100.4"}, want: "198.51.100.4"},
+		{name: "remote address without a port", remoteAddr: "203.0.113.7", want: "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(r, trusted); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		value   string
+		wantErr bool
+	}{
+		{value: "10.0.0.0/8"},
+		{value: "10.1.2.3/8"}, // masked to 10.0.0.0/8
+		{value: "192.168.1.1"},
+		{value: "::1"},
+		{value: "10.0.0.0/33", wantErr: true},
+		{value: "proxy.local", wantErr: true},
+		{value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		_, err := parseTrustedProxies([]string{tt.value})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseTrustedProxies(%q) error = %v, want error: %t", tt.value, err, tt.wantErr)
+		}
+	}
+}
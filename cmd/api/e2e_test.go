@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/internal/testutil"
+)
+
+// TestWorkerLifecycle runs the whole flow of a worker through the API, over
+// in-memory repositories and against a stub target.
+func TestWorkerLifecycle(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	var received atomic.Int32
+	target := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		// Only the requests sent to the endpoint of the environment count.
+		if r.URL.Path == "/orders" {
+			received.Add(1)
+		}
+	}))
+	t.Cleanup(target.Close)
+
+	status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/environments", map[string]any{"name": "staging", "endpoint": target.URL + "/orders"})
+	if status != http.StatusCreated {
+		t.Fatalf("creating the environment answered %d: %v", status, answer)
+	}
+	environment, _ := answer["environment"].(map[string]any)
+	environmentID, _ := environment["id"].(float64)
+
+	payload := map[string]any{
+		"environment_id":    environmentID,
+		"concurrency":       2,
+		"requests_per_task": 5,
+		"http_method":       "GET",
+		"think_time":        "0s",
+		"persist_samples":   true,
+	}
+	id := createTestWorker(t, server.URL, payload)
+	worker := waitForStatus(t, server.URL, id, entity.StatusFinished)
+
+	metrics, _ := worker["metrics"].(map[string]any)
+	if metrics["total_requests"] != 10.0 || metrics["failed_requests"] != 0.0 {
+		t.Errorf("metrics = %v, want 10 requests without failure", metrics)
+	}
+	if got := received.Load(); got != 10 {
+		t.Errorf("target received %d requests, want 10", got)
+	}
+
+	for _, path := range []string{"summary", "latencies", "cdf", "report"} {
+		resp, err := http.Get(fmt.Sprintf("%s/v1/workers/%d/%s", server.URL, id, path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("%s of the finished worker answered %d, want %d", path, resp.StatusCode, http.StatusOK)
+		}
+	}
+
+	// A worker created without starting runs once started.
+	payload["autostart"] = false
+	id = createTestWorker(t, server.URL, payload)
+	waitForStatus(t, server.URL, id, entity.StatusCreated)
+	if status, answer := doJSON(t, http.MethodPost, fmt.Sprintf("%s/v1/workers/%d/start", server.URL, id), nil); status != http.StatusAccepted {
+		t.Fatalf("starting the worker answered %d: %v", status, answer)
+	}
+	waitForStatus(t, server.URL, id, entity.StatusFinished)
+	if got := received.Load(); got != 20 {
+		t.Errorf("target received %d requests, want 20", got)
+	}
+}
+
+// TestExclusiveStartRace starts two exclusive workers of an environment at
+// once, only one of them may run. The race is run on a fresh environment
+// every round, for the starts to overlap at least once.
+func TestExclusiveStartRace(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+
+	// The target holds every request until released, the winners keep running meanwhile.
+	release := make(chan struct{})
+	target := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(target.Close)
+	var once sync.Once
+	releaseTarget := func() { once.Do(func() { close(release) }) }
+	t.Cleanup(releaseTarget)
+
+	const rounds = 20
+	winners := make([]int, 0, rounds)
+	losers := make([]int, 0, rounds)
+	for round := 0; round < rounds; round++ {
+		name := fmt.Sprintf("staging-%d", round)
+		status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/environments", map[string]any{"name": name, "endpoint": target.URL})
+		if status != http.StatusCreated {
+			t.Fatalf("creating the environment answered %d: %v", status, answer)
+		}
+		environment, _ := answer["environment"].(map[string]any)
+		environmentID, _ := environment["id"].(float64)
+
+		payload := map[string]any{
+			"environment_id":    environmentID,
+			"concurrency":       1,
+			"requests_per_task": 1,
+			"http_method":       "GET",
+			"think_time":        "0s",
+			"exclusive":         true,
+			"autostart":         false,
+		}
+		ids := []int{createTestWorker(t, server.URL, payload), createTestWorker(t, server.URL, payload)}
+
+		errs := make([]error, len(ids))
+		start := make(chan struct{})
+		var wg sync.WaitGroup
+		for i, id := range ids {
+			wg.Add(1)
+			go func(i, id int) {
+				defer wg.Done()
+				<-start
+				_, errs[i] = stack.WorkerService.StartWorker(context.Background(), id)
+			}(i, id)
+		}
+		close(start)
+		wg.Wait()
+
+		switch {
+		case errs[0] == nil && errors.Is(errs[1], custom_errors.ErrExclusiveRun):
+			winners, losers = append(winners, ids[0]), append(losers, ids[1])
+		case errs[1] == nil && errors.Is(errs[0], custom_errors.ErrExclusiveRun):
+			winners, losers = append(winners, ids[1]), append(losers, ids[0])
+		default:
+			t.Fatalf("round %d: starting both workers at once returned %v, want one of them to fail with %v", round, errs, custom_errors.ErrExclusiveRun)
+		}
+	}
+
+	releaseTarget()
+	for _, id := range winners {
+		waitForStatus(t, server.URL, id, entity.StatusFinished)
+	}
+	for _, id := range losers {
+		waitForStatus(t, server.URL, id, entity.StatusCreated)
+	}
+}
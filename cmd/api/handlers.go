@@ -1,14 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
 	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/service"
 	"github.com/vladComan0/performance-analyzer/pkg/helpers"
+	"github.com/vladComan0/performance-analyzer/pkg/validator"
 )
 
 func (app *application) ping(w http.ResponseWriter, _ *http.Request) {
@@ -23,24 +33,54 @@ func (app *application) ping(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+func (app *application) health(w http.ResponseWriter, _ *http.Request) {
+	status, code := "available", http.StatusOK
+	if app.degraded.Load() {
+		status, code = "degraded", http.StatusServiceUnavailable
+	}
+
+	if err := app.helper.WriteJSON(w, code, helpers.Envelope{"status": status}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// getCapabilities describes what the instance supports and the limits of its input.
+func (app *application) getCapabilities(w http.ResponseWriter, _ *http.Request) {
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"capabilities": app.workerService.GetCapabilities()}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
 func (app *application) createEnvironment(w http.ResponseWriter, r *http.Request) {
 	var input dto.CreateEnvironmentInput
 
 	if err := app.helper.ReadJSON(w, r, &input); err != nil {
-		app.helper.ClientError(w, http.StatusBadRequest)
+		app.helper.BadJSON(w, err)
 		return
 	}
 
 	environment, err := app.environmentService.CreateEnvironment(input)
 	if err != nil {
-		app.helper.ServerError(w, err)
+		var validationErr *validator.Error
+		switch {
+		case errors.As(err, &validationErr):
+			app.helper.FailedValidation(w, validationErr.Fields)
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		case errors.Is(err, custom_errors.ErrDuplicateName):
+			app.helper.ClientError(w, http.StatusConflict)
+		default:
+			app.helper.ServerError(w, err)
+		}
 		return
 	}
 
 	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("v1/environments/%d", environment.ID))
+	headers.Set("Location", app.helper.AbsoluteURL(r, fmt.Sprintf("/v1/environments/%d", environment.ID)))
 
-	if err = app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"environment": environment}, headers); err != nil {
+	if err = app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"environment": dto.NewEnvironmentResponse(environment)}, headers); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
@@ -50,7 +90,7 @@ func (app *application) createEnvironment(w http.ResponseWriter, r *http.Request
 
 func (app *application) getEnvironment(w http.ResponseWriter, r *http.Request) {
 	id, err := app.helper.GetID(r)
-	if err != nil || id < 1 {
+	if err != nil {
 		app.helper.ClientError(w, http.StatusBadRequest)
 		return
 	}
@@ -66,7 +106,38 @@ func (app *application) getEnvironment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": environment}, nil); err != nil {
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": dto.NewEnvironmentResponse(environment)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// diffEnvironments compares the settings of the environments ?a and ?b, the
+// secrets only by whether they are set and differ.
+func (app *application) diffEnvironments(w http.ResponseWriter, r *http.Request) {
+	a, err := strconv.Atoi(r.URL.Query().Get("a"))
+	if err != nil || a < 1 {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	b, err := strconv.Atoi(r.URL.Query().Get("b"))
+	if err != nil || b < 1 {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	diff, err := app.environmentService.DiffEnvironments(a, b)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"diff": diff}, nil); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
@@ -86,8 +157,9 @@ func (app *application) getAllEnvironments(w http.ResponseWriter, _ *http.Reques
 
 	app.log.Info().Msgf("Environments: %v", environments)
 
-	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environments": environments}, nil); err != nil {
-		app.helper.ServerError(w, err)
+	if err = app.helper.WriteJSONStream(w, http.StatusOK, helpers.Envelope{"environments": dto.NewEnvironmentResponses(environments)}, nil, listFlushEvery); err != nil {
+		// The status line is already sent, the client sees a truncated response.
+		app.log.Error().Err(err).Msg("Error writing the environments")
 		return
 	}
 
@@ -96,29 +168,195 @@ func (app *application) getAllEnvironments(w http.ResponseWriter, _ *http.Reques
 
 func (app *application) updateEnvironment(w http.ResponseWriter, r *http.Request) {
 	id, err := app.helper.GetID(r)
-	if err != nil || id < 1 {
+	if err != nil {
 		app.helper.ClientError(w, http.StatusBadRequest)
 		return
 	}
 
 	var input dto.UpdateEnvironmentInput
 	if err := app.helper.ReadJSON(w, r, &input); err != nil {
-		app.helper.ClientError(w, http.StatusBadRequest)
+		app.helper.BadJSON(w, err)
 		return
 	}
 
 	updatedEnvironment, err := app.environmentService.UpdateEnvironment(id, input)
 	if err != nil {
+		var validationErr *validator.Error
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		case errors.As(err, &validationErr):
+			app.helper.FailedValidation(w, validationErr.Fields)
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		case errors.Is(err, custom_errors.ErrDuplicateName):
+			app.helper.ClientError(w, http.StatusConflict)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": dto.NewEnvironmentResponse(updatedEnvironment)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+func (app *application) setEnvironmentBaseline(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var input dto.SetBaselineInput
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+	if input.WorkerID < 1 {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	environment, err := app.environmentService.SetBaseline(id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		case errors.Is(err, custom_errors.ErrInvalidBaseline):
+			app.helper.ClientError(w, http.StatusUnprocessableEntity)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": dto.NewEnvironmentResponse(environment)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	app.log.Info().Msgf("Set worker %d as the baseline of environment %d", input.WorkerID, id)
+}
+
+func (app *application) setEnvironmentBodySchema(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	var input dto.SetBodySchemaInput
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+
+	environment, err := app.environmentService.SetBodySchema(id, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": dto.NewEnvironmentResponse(environment)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	app.log.Info().Msgf("Set the body schema of environment %d", id)
+}
+
+// getEnvironmentStats reports the requests sent to the environment today against its daily request quota.
+func (app *application) getEnvironmentStats(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	usage, err := app.environmentService.GetRequestUsage(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"stats": helpers.Envelope{"request_usage": usage}}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+func (app *application) sweepEnvironments(w http.ResponseWriter, r *http.Request) {
+	var input dto.SweepInput
+
+	// The body is optional, every field has a default.
+	if r.ContentLength != 0 {
+		if err := app.helper.ReadJSON(w, r, &input); err != nil {
+			app.helper.BadJSON(w, err)
+			return
+		}
+	}
+
+	results, budget, err := app.workerService.SweepEnvironments(r.Context(), input)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	envelope := helpers.Envelope{"results": results}
+	if budget != nil {
+		envelope["budget"] = budget
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, envelope, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// verifyEnvironmentAuth checks the credentials of an environment by fetching
+// a token, without sending anything to its endpoint unlike the sweep.
+func (app *application) verifyEnvironmentAuth(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	check, err := app.workerService.VerifyEnvironmentAuth(r.Context(), id)
+	if err != nil {
+		var validationErr *validator.Error
 		switch {
 		case errors.Is(err, custom_errors.ErrNoRecord):
 			app.helper.ClientError(w, http.StatusNotFound)
+		case errors.As(err, &validationErr):
+			app.helper.FailedValidation(w, validationErr.Fields)
 		default:
 			app.helper.ServerError(w, err)
 		}
 		return
 	}
 
-	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"environment": updatedEnvironment}, nil); err != nil {
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"auth": check}, nil); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
@@ -126,7 +364,7 @@ func (app *application) updateEnvironment(w http.ResponseWriter, r *http.Request
 
 func (app *application) deleteEnvironment(w http.ResponseWriter, r *http.Request) {
 	id, err := app.helper.GetID(r)
-	if err != nil || id < 1 {
+	if err != nil {
 		app.helper.ClientError(w, http.StatusBadRequest)
 		return
 	}
@@ -153,33 +391,200 @@ func (app *application) createWorker(w http.ResponseWriter, r *http.Request) {
 	var input *entity.Worker
 
 	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+
+	wait := false
+	if value := r.URL.Query().Get("wait"); value != "" {
+		var err error
+		if wait, err = strconv.ParseBool(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	// A blocked worker doesn't run, there is nothing to wait for.
+	allowBlocked := false
+	if value := r.URL.Query().Get("allow_blocked"); value != "" {
+		var err error
+		if allowBlocked, err = strconv.ParseBool(value); err != nil || (allowBlocked && wait) {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	var (
+		worker *entity.Worker
+		err    error
+	)
+	if wait {
+		// The run may outlast the server WriteTimeout.
+		deadline := time.Now().Add(service.MaxSyncDuration + streamWriteTimeout)
+		if err = http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+			app.helper.ServerError(w, err)
+			return
+		}
+		worker, err = app.workerService.RunWorker(r.Context(), input)
+	} else {
+		worker, err = app.workerService.CreateWorker(r.Context(), input, allowBlocked) // solve workers not updating status to `failed` in case of failure
+	}
+	if err != nil {
+		app.writeWorkerError(w, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.helper.AbsoluteURL(r, fmt.Sprintf("/v1/workers/%d", worker.ID)))
+
+	if err := app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"worker": dto.NewWorkerResponse(worker)}, headers); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	app.log.Info().Msgf("Created new worker with id: %d", worker.ID)
+}
+
+// writeWorkerError answers with the status matching an error of creating or
+// starting a worker. ErrNoRecord is left to the callers, as it stands for a
+// missing worker or a missing environment depending on the request.
+func (app *application) writeWorkerError(w http.ResponseWriter, err error) {
+	var validationErr *validator.Error
+	switch {
+	case errors.As(err, &validationErr):
+		app.helper.FailedValidation(w, validationErr.Fields)
+	case errors.Is(err, custom_errors.ErrInvalidInput):
 		app.helper.ClientError(w, http.StatusBadRequest)
+	case errors.Is(err, custom_errors.ErrNotStartable):
+		app.helper.ClientError(w, http.StatusConflict)
+	case errors.Is(err, custom_errors.ErrTooLargeForSync):
+		message := fmt.Sprintf("waiting is limited to runs under %d requests and %s, create the worker without wait and poll it instead", service.MaxSyncRequests, service.MaxSyncDuration)
+		if err := app.helper.WriteJSON(w, http.StatusUnprocessableEntity, helpers.Envelope{"error": message}, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	case errors.Is(err, context.Canceled):
+		app.log.Info().Msg("Client went away while waiting for the worker, it keeps running")
+	case errors.Is(err, custom_errors.ErrShuttingDown):
+		app.helper.ClientError(w, http.StatusServiceUnavailable)
+	case errors.Is(err, custom_errors.ErrEnvironmentDisabled):
+		app.helper.ClientError(w, http.StatusForbidden)
+	case errors.Is(err, custom_errors.ErrResolverUnreachable):
+		app.helper.ClientError(w, http.StatusUnprocessableEntity)
+	case errors.Is(err, custom_errors.ErrQuotaExceeded):
+		envelope := helpers.Envelope{"error": err.Error()}
+		var quotaErr *entity.QuotaError
+		if errors.As(err, &quotaErr) {
+			envelope = helpers.Envelope{"error": quotaErr.Error(), "remaining": quotaErr.Remaining}
+		}
+		if err := app.helper.WriteJSON(w, http.StatusTooManyRequests, envelope, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	case errors.Is(err, custom_errors.ErrMaintenanceWindow):
+		var maintenanceErr *entity.MaintenanceError
+		message := err.Error()
+		if errors.As(err, &maintenanceErr) {
+			message = maintenanceErr.Error()
+		}
+		if err := app.helper.WriteJSON(w, http.StatusConflict, helpers.Envelope{"error": message}, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	case errors.Is(err, custom_errors.ErrExclusiveRun):
+		envelope := helpers.Envelope{"error": err.Error()}
+		var exclusiveErr *service.ExclusiveError
+		if errors.As(err, &exclusiveErr) {
+			envelope = helpers.Envelope{"error": exclusiveErr.Error(), "worker_ids": exclusiveErr.WorkerIDs}
+		}
+		if err := app.helper.WriteJSON(w, http.StatusConflict, envelope, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	case errors.Is(err, custom_errors.ErrDoubtfulPlan):
+		envelope := helpers.Envelope{"error": err.Error()}
+		var planErr *service.PlanError
+		if errors.As(err, &planErr) {
+			envelope = helpers.Envelope{"error": "the configuration of the run is doubtful", "warnings": planErr.Warnings}
+		}
+		if err := app.helper.WriteJSON(w, http.StatusUnprocessableEntity, envelope, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	case errors.Is(err, custom_errors.ErrSchemaViolation):
+		var schemaErr *entity.SchemaError
+		message := err.Error()
+		if errors.As(err, &schemaErr) {
+			message = schemaErr.Error()
+		}
+		if err := app.helper.WriteJSON(w, http.StatusUnprocessableEntity, helpers.Envelope{"error": message}, nil); err != nil {
+			app.helper.ServerError(w, err)
+		}
+	default:
+		app.helper.ServerError(w, err)
+	}
+}
+
+// validateWorker checks a create worker payload without inserting or running anything.
+func (app *application) validateWorker(w http.ResponseWriter, r *http.Request) {
+	var input *entity.Worker
+
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
 		return
 	}
 
-	worker, err := app.workerService.CreateWorker(r.Context(), input) // solve workers not updating status to `failed` in case of failure
+	worker, err := app.workerService.ValidateWorker(input)
 	if err != nil {
+		var violations map[string]string
+		var validationErr *validator.Error
+		var schemaErr *entity.SchemaError
 		switch {
+		case errors.As(err, &validationErr):
+			violations = validationErr.Fields
 		case errors.Is(err, custom_errors.ErrInvalidInput):
-			app.helper.ClientError(w, http.StatusBadRequest)
+			violations = map[string]string{"input": "the worker configuration is invalid"}
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			violations = map[string]string{"environment_id": "no such environment"}
 		case errors.Is(err, custom_errors.ErrEnvironmentDisabled):
-			app.helper.ClientError(w, http.StatusForbidden)
+			violations = map[string]string{"environment_id": "the environment is disabled"}
+		case errors.As(err, &schemaErr):
+			violations = map[string]string{"body": schemaErr.Error()}
 		default:
 			app.helper.ServerError(w, err)
+			return
+		}
+
+		if err := app.helper.WriteJSON(w, http.StatusUnprocessableEntity, helpers.Envelope{"valid": false, "errors": violations}, nil); err != nil {
+			app.helper.ServerError(w, err)
 		}
 		return
 	}
 
-	// Make the application aware of that new location -> add the headers to the right json helper function
-	headers := make(http.Header)
-	headers.Set("Location", fmt.Sprintf("v1/workers/%d", worker.ID))
-
-	if err := app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"worker": worker}, nil); err != nil {
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"valid": true, "worker": dto.NewWorkerResponse(worker)}, nil); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
+}
 
-	app.log.Info().Msgf("Created new worker with id: %d", worker.ID)
+// estimateWorker returns what the run of a worker payload would do, nothing is stored nor sent.
+func (app *application) estimateWorker(w http.ResponseWriter, r *http.Request) {
+	var input *entity.Worker
+
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+
+	plan, err := app.workerService.EstimateWorker(input)
+	if errors.Is(err, custom_errors.ErrNoRecord) {
+		app.helper.ClientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		app.writeWorkerError(w, err)
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"plan": plan}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
 }
 
 func (app *application) getWorker(w http.ResponseWriter, r *http.Request) {
@@ -200,14 +605,639 @@ func (app *application) getWorker(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"worker": worker}, nil); err != nil {
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"worker": dto.NewWorkerResponse(worker)}, nil); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
 }
 
-func (app *application) getAllWorkers(w http.ResponseWriter, _ *http.Request) {
-	workers, err := app.workerService.GetWorkers()
+// getWorkerSummary returns the numbers of a run without its configuration,
+// for the dashboards.
+func (app *application) getWorkerSummary(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	worker, err := app.workerService.GetWorkerSummary(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"summary": dto.NewWorkerSummaryResponse(worker)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+func (app *application) getWorkerBreakdown(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	breakdown, err := app.workerService.GetBreakdown(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"breakdown": breakdown}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+func (app *application) getWorkerLatencies(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	samples, err := app.workerService.GetSamples(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSONStream(w, http.StatusOK, helpers.Envelope{"latencies": samples}, nil, listFlushEvery); err != nil {
+		// The status line is already sent, the client sees a truncated response.
+		app.log.Error().Err(err).Msg("Error writing the latencies")
+		return
+	}
+}
+
+// getWorkerCDF returns the latency CDF of a finished worker, ?points sets its number of points.
+func (app *application) getWorkerCDF(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	points := entity.DefaultCDFPoints
+	if value := r.URL.Query().Get("points"); value != "" {
+		if points, err = strconv.Atoi(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	cdf, err := app.workerService.GetCDF(id, points)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"cdf": cdf}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// getWorkerReport renders the report of a finished worker, as JSON by default
+// or as a standalone page with ?format=html.
+func (app *application) getWorkerReport(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "html" {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	workerReport, err := app.workerService.GetReport(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	// The report is rendered before anything is written, an error still ends in a 500.
+	var buf bytes.Buffer
+	if format == "html" {
+		err = workerReport.HTML(&buf)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	} else {
+		var data []byte
+		data, err = workerReport.JSON()
+		buf.Write(data)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		w.Header().Del("Content-Type")
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := buf.WriteTo(w); err != nil {
+		app.log.Debug().Err(err).Msgf("Error writing the report of worker %d", id)
+	}
+}
+
+// getWorkerRecords downloads the CSV file holding every request of the run.
+func (app *application) getWorkerRecords(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	path, err := app.workerService.GetRecordFile(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	// The file may be far too large to be sent within the server WriteTimeout.
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		app.log.Debug().Err(err).Msg("Error clearing the write deadline")
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filepath.Base(path)))
+	http.ServeFile(w, r, path)
+}
+
+// getAllWorkers lists every worker. The workers whose row can't be read are
+// left out with a warning, the response being flagged as partial, unless
+// the strict parameter asks to fail instead. When the query has a cursor or
+// a limit, it lists a page of them instead: ?cursor= starts with the first
+// page, each page answering the next_cursor of the following one.
+func (app *application) getAllWorkers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Has("cursor") || query.Has("limit") {
+		app.getWorkerPage(w, r)
+		return
+	}
+
+	var strict bool
+	if value := r.URL.Query().Get("strict"); value != "" {
+		var err error
+		if strict, err = strconv.ParseBool(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	workers, warnings, err := app.workerService.GetWorkers(strict)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	envelope := helpers.Envelope{"workers": dto.NewWorkerResponses(workers)}
+	var headers http.Header
+	if len(warnings) > 0 {
+		envelope["warnings"] = warnings
+		headers = http.Header{"X-Partial-Result": []string{"true"}}
+	}
+
+	if err = app.helper.WriteJSONStream(w, http.StatusOK, envelope, headers, listFlushEvery); err != nil {
+		// The status line is already sent, the client sees a truncated response.
+		app.log.Error().Err(err).Msg("Error writing the workers")
+		return
+	}
+}
+
+// getWorkerPage lists the page of the workers after ?cursor=, of up to ?limit= workers.
+func (app *application) getWorkerPage(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	workers, next, err := app.workerService.GetWorkerPage(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		var validationErr *validator.Error
+		switch {
+		case errors.As(err, &validationErr):
+			app.helper.FailedValidation(w, validationErr.Fields)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	envelope := helpers.Envelope{"workers": dto.NewWorkerResponses(workers)}
+	if next != "" {
+		envelope["next_cursor"] = next
+	}
+
+	if err = app.helper.WriteJSONStream(w, http.StatusOK, envelope, nil, listFlushEvery); err != nil {
+		// The status line is already sent, the client sees a truncated response.
+		app.log.Error().Err(err).Msg("Error writing the workers")
+		return
+	}
+}
+
+// startWorker runs a worker created without autostart, or blocked once its environment is enabled.
+func (app *application) startWorker(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	worker, err := app.workerService.StartWorker(r.Context(), id)
+	if errors.Is(err, custom_errors.ErrNoRecord) {
+		app.helper.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		app.writeWorkerError(w, err)
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusAccepted, helpers.Envelope{"worker": dto.NewWorkerResponse(worker)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// stopAllWorkers is the emergency stop, it cancels every running worker at once.
+func (app *application) stopAllWorkers(w http.ResponseWriter, r *http.Request) {
+	stopped := app.workerService.StopAll()
+	app.log.Warn().Msgf("EMERGENCY STOP requested by %s, %d workers stopped: %v", app.clientIP(r), len(stopped), stopped)
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"stopped": stopped}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// getRegistry lists the workers tracked in memory by this instance.
+func (app *application) getRegistry(w http.ResponseWriter, _ *http.Request) {
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"registry": app.workerService.GetRegistry()}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// reconcileRegistry repairs the drift between the in-memory registry and the database.
+func (app *application) reconcileRegistry(w http.ResponseWriter, r *http.Request) {
+	reconciliation, err := app.workerService.ReconcileRegistry()
+	if err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+	app.log.Warn().Msgf("Registry reconciliation requested by %s, removed: %v, failed: %v", app.clientIP(r), reconciliation.Removed, reconciliation.Failed)
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"reconciliation": reconciliation}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// getStorageStats reports the bytes stored by the compressed columns
+// against the bytes of the JSON they hold.
+func (app *application) getStorageStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := app.workerService.GetStorageStats()
+	if err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"storage": stats}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// getTrend aggregates the runs of the workers with a tag per bucket of a time range, 1d buckets by default.
+func (app *application) getTrend(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	from, err := parseTrendTime(query.Get("from"))
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	to, err := parseTrendTime(query.Get("to"))
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	bucket := 24 * time.Hour
+	if value := query.Get("bucket"); value != "" {
+		if bucket, err = parseTrendBucket(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	trend, err := app.workerService.GetTrend(query.Get("tag"), from, to, bucket)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"trend": trend}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// parseTrendTime parses a date such as 2024-05-01, taken as midnight UTC, or an RFC 3339 time.
+func parseTrendTime(value string) (time.Time, error) {
+	if date, err := time.Parse(time.DateOnly, value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// parseTrendBucket parses a number of days such as 7d, or a duration such as 6h.
+func parseTrendBucket(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid bucket %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// seedDemoData inserts synthetic environments and finished workers, to see the dashboard with a history.
+func (app *application) seedDemoData(w http.ResponseWriter, r *http.Request) {
+	var input dto.DemoDataInput
+
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+
+	data, err := app.environmentService.SeedDemoData(input)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		case errors.Is(err, custom_errors.ErrDuplicateName):
+			app.helper.ClientError(w, http.StatusConflict)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+	app.log.Info().Msgf("Demo data of seed %d requested by %s, environments: %v, workers: %d", data.Seed, app.clientIP(r), data.EnvironmentIDs, data.Workers)
+
+	if err = app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"demo_data": data}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// pruneDemoData deletes every demo environment along with its workers.
+func (app *application) pruneDemoData(w http.ResponseWriter, r *http.Request) {
+	deleted, err := app.environmentService.PruneDemoData()
+	if err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+	app.log.Warn().Msgf("Demo data pruned by %s, %d environments deleted", app.clientIP(r), deleted)
+
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"deleted_environments": deleted}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// exportWorkers streams every worker as newline delimited JSON, one page at a time.
+func (app *application) exportWorkers(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	exported := 0
+
+	err := app.workerService.ExportWorkers(func(workers []*entity.Worker) error {
+		// The server WriteTimeout covers the whole response, each page gets its own deadline instead.
+		if err := controller.SetWriteDeadline(time.Now().Add(streamWriteTimeout)); err != nil {
+			return err
+		}
+		for _, worker := range workers {
+			if err := encoder.Encode(dto.NewWorkerResponse(worker)); err != nil {
+				return err
+			}
+			exported++
+		}
+		return controller.Flush()
+	})
+	if err != nil {
+		if exported == 0 {
+			app.helper.ServerError(w, err)
+			return
+		}
+		// The status line is already sent, the client sees a truncated stream.
+		app.log.Error().Err(err).Msgf("Error exporting workers after %d workers", exported)
+		return
+	}
+
+	app.log.Info().Msgf("Exported %d workers", exported)
+}
+
+// createCampaign creates one worker per environment from a single template.
+func (app *application) createCampaign(w http.ResponseWriter, r *http.Request) {
+	var input dto.CampaignInput
+
+	if err := app.helper.ReadJSON(w, r, &input); err != nil {
+		app.helper.BadJSON(w, err)
+		return
+	}
+
+	campaign, err := app.workerService.CreateCampaign(r.Context(), input)
+	if errors.Is(err, custom_errors.ErrNoRecord) {
+		app.helper.ClientError(w, http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		app.writeWorkerError(w, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", app.helper.AbsoluteURL(r, fmt.Sprintf("/v1/campaigns/%d", campaign.ID)))
+
+	if err := app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"campaign": dto.NewCampaignResponse(campaign)}, headers); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	app.log.Info().Msgf("Created new campaign with id: %d, %d workers", campaign.ID, len(campaign.Workers))
+}
+
+func (app *application) getCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := app.workerService.GetCampaign(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"campaign": dto.NewCampaignResponse(campaign)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+// cancelCampaign stops every worker of a campaign, the ones being run end as cancelled shortly after.
+func (app *application) cancelCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := app.workerService.CancelCampaign(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, custom_errors.ErrNoRecord):
+			app.helper.ClientError(w, http.StatusNotFound)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err := app.helper.WriteJSON(w, http.StatusAccepted, helpers.Envelope{"campaign": dto.NewCampaignResponse(campaign)}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+
+	app.log.Info().Msgf("Cancelled campaign with id: %d", id)
+}
+
+// maxHARSize bounds the HAR files uploaded by importHAR.
+const maxHARSize = 32 << 20
+
+// importHAR stores the HAR file in the body as a scenario, ?name naming it,
+// ?rebase=true sending its steps to the environment of the workers
+// replaying it and ?shuffle=true replaying them in a new order on every
+// pass. The entries that can't be replayed are listed in the scenario, or
+// in the error if none can be.
+func (app *application) importHAR(w http.ResponseWriter, r *http.Request) {
+	var err error
+	rebase := false
+	if value := r.URL.Query().Get("rebase"); value != "" {
+		if rebase, err = strconv.ParseBool(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	shuffle := false
+	if value := r.URL.Query().Get("shuffle"); value != "" {
+		if shuffle, err = strconv.ParseBool(value); err != nil {
+			app.helper.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHARSize))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			app.helper.ClientError(w, http.StatusRequestEntityTooLarge)
+			return
+		}
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	scenario, err := app.workerService.ImportHAR(r.URL.Query().Get("name"), rebase, shuffle, data)
+	if err != nil {
+		var importErr *service.ImportError
+		switch {
+		case errors.As(err, &importErr):
+			envelope := helpers.Envelope{"error": "the HAR file has no entry that can be replayed", "unsupported": importErr.Unsupported}
+			if err := app.helper.WriteJSON(w, http.StatusUnprocessableEntity, envelope, nil); err != nil {
+				app.helper.ServerError(w, err)
+			}
+		case errors.Is(err, custom_errors.ErrInvalidInput):
+			app.helper.ClientError(w, http.StatusBadRequest)
+		default:
+			app.helper.ServerError(w, err)
+		}
+		return
+	}
+
+	if err = app.helper.WriteJSON(w, http.StatusCreated, helpers.Envelope{"scenario": scenario}, nil); err != nil {
+		app.helper.ServerError(w, err)
+		return
+	}
+}
+
+func (app *application) getScenario(w http.ResponseWriter, r *http.Request) {
+	id, err := app.helper.GetID(r)
+	if err != nil {
+		app.helper.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	scenario, err := app.workerService.GetScenario(id)
 	if err != nil {
 		switch {
 		case errors.Is(err, custom_errors.ErrNoRecord):
@@ -218,7 +1248,7 @@ func (app *application) getAllWorkers(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
-	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"workers": workers}, nil); err != nil {
+	if err = app.helper.WriteJSON(w, http.StatusOK, helpers.Envelope{"scenario": scenario}, nil); err != nil {
 		app.helper.ServerError(w, err)
 		return
 	}
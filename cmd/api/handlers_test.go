@@ -0,0 +1,892 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/config"
+	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/internal/testutil"
+)
+
+// newTestAPI serves the API over in-memory repositories with cfg for the
+// duration of the test.
+func newTestAPI(t *testing.T, cfg config.Config) (*testutil.Stack, *httptest.Server) {
+	t.Helper()
+
+	stack := testutil.NewStack(newHandler, cfg)
+	server := httptest.NewServer(stack.Handler)
+	t.Cleanup(server.Close)
+	return stack, server
+}
+
+// createTestEnvironment creates an environment sending its requests to endpoint and returns its id.
+func createTestEnvironment(t *testing.T, stack *testutil.Stack, name, endpoint string) int {
+	t.Helper()
+
+	environment, err := stack.EnvironmentService.CreateEnvironment(dto.CreateEnvironmentInput{Name: name, Endpoint: endpoint})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return environment.ID
+}
+
+// doJSON sends payload to the API and decodes the JSON object answered.
+func doJSON(t *testing.T, method, url string, payload any) (int, map[string]any) {
+	t.Helper()
+	return doJSONWithHeaders(t, method, url, payload, nil)
+}
+
+// doJSONWithHeaders is doJSON sending headers along.
+func doJSONWithHeaders(t *testing.T, method, url string, payload any, headers http.Header) (int, map[string]any) {
+	t.Helper()
+
+	var body bytes.Buffer
+	if payload != nil {
+		if err := json.NewEncoder(&body).Encode(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, values := range headers {
+		req.Header[key] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	// The errors of the middlewares are plain text, their answer is left nil.
+	var answer map[string]any
+	if resp.Header.Get("Content-Type") == "application/json" {
+		if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+			t.Fatalf("%s %s answered %d with invalid JSON: %s", method, url, resp.StatusCode, err)
+		}
+	}
+	return resp.StatusCode, answer
+}
+
+func TestValidateWorker(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+	environmentID := createTestEnvironment(t, stack, "staging", "http://staging.invalid/orders")
+	disabledID := createTestEnvironment(t, stack, "retired", "http://retired.invalid")
+	disabled := true
+	if _, err := stack.EnvironmentService.UpdateEnvironment(disabledID, dto.UpdateEnvironmentInput{Disabled: &disabled}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		payload    map[string]any
+		wantStatus int
+		wantErrors []string // the fields reported invalid
+	}{
+		{
+			name:       "valid",
+			payload:    map[string]any{"environment_id": environmentID, "concurrency": 2, "requests_per_task": 5, "http_method": "GET"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "several invalid fields",
+			payload:    map[string]any{"environment_id": environmentID, "concurrency": 0, "requests_per_task": 0, "http_method": "FETCH", "ramp_up_jitter": 2},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []string{"concurrency", "requests_per_task", "http_method", "ramp_up_jitter"},
+		},
+		{
+			name:       "unknown environment",
+			payload:    map[string]any{"environment_id": 999, "concurrency": 1, "requests_per_task": 1, "http_method": "GET"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []string{"environment_id"},
+		},
+		{
+			name:       "disabled environment",
+			payload:    map[string]any{"environment_id": disabledID, "concurrency": 1, "requests_per_task": 1, "http_method": "GET"},
+			wantStatus: http.StatusUnprocessableEntity,
+			wantErrors: []string{"environment_id"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/workers/validate", tt.payload)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %v", status, tt.wantStatus, answer)
+			}
+
+			if tt.wantErrors == nil {
+				worker, _ := answer["worker"].(map[string]any)
+				if answer["valid"] != true || worker == nil {
+					t.Fatalf("answer = %v, want the normalized worker", answer)
+				}
+				if worker["concurrency"] != 2.0 || worker["http_method"] != "GET" {
+					t.Errorf("worker = %v, want the payload echoed", worker)
+				}
+				return
+			}
+
+			violations, _ := answer["errors"].(map[string]any)
+			if answer["valid"] != false || len(violations) != len(tt.wantErrors) {
+				t.Fatalf("answer = %v, want errors for %v", answer, tt.wantErrors)
+			}
+			for _, field := range tt.wantErrors {
+				if _, ok := violations[field]; !ok {
+					t.Errorf("no error for %s in %v", field, violations)
+				}
+			}
+		})
+	}
+
+	// Nothing is inserted, valid or not.
+	workers, _, err := stack.WorkerService.GetWorkers(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workers) != 0 {
+		t.Errorf("%d workers inserted, want none", len(workers))
+	}
+}
+
+func TestLocationHeader(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	resp, err := http.Post(server.URL+"/v1/environments", "application/json", strings.NewReader(`{"name":"staging","endpoint":"http://staging.invalid"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var answer struct {
+		Environment struct {
+			ID int `json:"id"`
+		} `json:"environment"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	if want := fmt.Sprintf("%s/v1/environments/%d", server.URL, answer.Environment.ID); resp.Header.Get("Location") != want {
+		t.Errorf("Location = %q, want %q", resp.Header.Get("Location"), want)
+	}
+}
+
+// createTestWorker creates a worker from payload through the API and returns its id.
+func createTestWorker(t *testing.T, serverURL string, payload map[string]any) int {
+	t.Helper()
+
+	status, answer := doJSON(t, http.MethodPost, serverURL+"/v1/workers", payload)
+	if status != http.StatusCreated {
+		t.Fatalf("creating a worker answered %d: %v", status, answer)
+	}
+	worker, _ := answer["worker"].(map[string]any)
+	id, _ := worker["id"].(float64)
+	return int(id)
+}
+
+// waitForStatus polls the worker with the given id until it has the status
+// wanted, failing the test if it doesn't within 5s.
+func waitForStatus(t *testing.T, serverURL string, id int, want entity.Status) map[string]any {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, answer := doJSON(t, http.MethodGet, fmt.Sprintf("%s/v1/workers/%d", serverURL, id), nil)
+		worker, _ := answer["worker"].(map[string]any)
+		if worker["status"] == string(want) {
+			return worker
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("worker %d still %v after 5s, want %s", id, worker["status"], want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestStopAll(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.AdminToken = "s3cret"
+	stack, server := newTestAPI(t, cfg)
+	admin := http.Header{"Authorization": {"Bearer s3cret"}}
+
+	// Stubs holding every request until it is aborted.
+	var ids []int
+	for _, name := range []string{"staging", "qa"} {
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+		t.Cleanup(stub.Close)
+
+		environmentID := createTestEnvironment(t, stack, name, stub.URL)
+		for i := 0; i < 2; i++ {
+			ids = append(ids, createTestWorker(t, server.URL, map[string]any{
+				"environment_id": environmentID, "concurrency": 2, "requests_per_task": 100, "http_method": "GET",
+			}))
+		}
+	}
+	// Run before the stubs are closed, which waits for their requests to end.
+	t.Cleanup(func() { stack.WorkerService.StopAll() })
+	for _, id := range ids {
+		waitForStatus(t, server.URL, id, entity.StatusRunning)
+	}
+
+	if status, _ := doJSONWithHeaders(t, http.MethodPost, server.URL+"/v1/workers/stop-all", nil, http.Header{"Authorization": {"Bearer wrong"}}); status != http.StatusUnauthorized {
+		t.Fatalf("stop-all with a wrong token answered %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	status, answer := doJSONWithHeaders(t, http.MethodPost, server.URL+"/v1/workers/stop-all", nil, admin)
+	if status != http.StatusOK {
+		t.Fatalf("stop-all answered %d: %v", status, answer)
+	}
+	stopped, _ := answer["stopped"].([]any)
+	if len(stopped) != len(ids) {
+		t.Fatalf("stopped %v, want %v", stopped, ids)
+	}
+	for i, id := range ids {
+		if stopped[i] != float64(id) {
+			t.Errorf("stopped %v, want %v", stopped, ids)
+			break
+		}
+	}
+
+	for _, id := range ids {
+		waitForStatus(t, server.URL, id, entity.StatusCancelled)
+	}
+
+	// Stopping again is a no-op.
+	_, answer = doJSONWithHeaders(t, http.MethodPost, server.URL+"/v1/workers/stop-all", nil, admin)
+	if stopped, _ := answer["stopped"].([]any); len(stopped) != 0 {
+		t.Errorf("second stop-all stopped %v, want none", stopped)
+	}
+}
+
+func TestIDValidation(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	environment := map[string]any{"name": "staging", "endpoint": "http://staging.invalid"}
+	routes := []struct {
+		method  string
+		path    string
+		payload any
+	}{
+		{method: http.MethodGet, path: "/v1/environments/%s"},
+		{method: http.MethodPut, path: "/v1/environments/%s", payload: environment},
+		{method: http.MethodDelete, path: "/v1/environments/%s"},
+		{method: http.MethodGet, path: "/v1/environments/%s/stats"},
+		{method: http.MethodGet, path: "/v1/workers/%s"},
+		{method: http.MethodGet, path: "/v1/workers/%s/summary"},
+		{method: http.MethodGet, path: "/v1/workers/%s/report"},
+		{method: http.MethodPost, path: "/v1/workers/%s/start"},
+	}
+	ids := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{name: "zero", id: "0", want: http.StatusBadRequest},
+		{name: "negative", id: "-3", want: http.StatusBadRequest},
+		{name: "non-numeric", id: "abc", want: http.StatusBadRequest},
+		{name: "missing", id: "999", want: http.StatusNotFound},
+	}
+
+	for _, route := range routes {
+		for _, id := range ids {
+			t.Run(fmt.Sprintf("%s %s %s", route.method, route.path, id.name), func(t *testing.T) {
+				url := server.URL + fmt.Sprintf(route.path, id.id)
+				if status, answer := doJSON(t, route.method, url, route.payload); status != id.want {
+					t.Errorf("%s %s answered %d, want %d: %v", route.method, url, status, id.want, answer)
+				}
+			})
+		}
+	}
+}
+
+func TestCDFPoints(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	// The points are checked before the worker is looked up.
+	for _, points := range []string{"0", "-1", fmt.Sprint(entity.MaxCDFPoints + 1), "many"} {
+		url := fmt.Sprintf("%s/v1/workers/1/cdf?points=%s", server.URL, points)
+		if status, _ := doJSON(t, http.MethodGet, url, nil); status != http.StatusBadRequest {
+			t.Errorf("points=%s answered %d, want %d", points, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestReconcileRegistry(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.AdminToken = "s3cret"
+	stack, server := newTestAPI(t, cfg)
+	admin := http.Header{"Authorization": {"Bearer s3cret"}}
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(stub.Close)
+	environmentID := createTestEnvironment(t, stack, "staging", stub.URL)
+
+	payload := map[string]any{"environment_id": environmentID, "concurrency": 1, "requests_per_task": 100, "http_method": "GET"}
+	stale, tracked := createTestWorker(t, server.URL, payload), createTestWorker(t, server.URL, payload)
+	t.Cleanup(func() { stack.WorkerService.StopAll() })
+	waitForStatus(t, server.URL, stale, entity.StatusRunning)
+	waitForStatus(t, server.URL, tracked, entity.StatusRunning)
+
+	// The drift: the database has the first run over while it is still
+	// tracked, and a run nothing tracks, lost with a previous process.
+	workers := stack.Repositories.Workers
+	if err := workers.UpdateStatus(stale, entity.StatusFinished); err != nil {
+		t.Fatal(err)
+	}
+	lost, err := workers.Insert(entity.NewWorker(environmentID, 1, 1, http.MethodGet, nil, nil, zerolog.Nop()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := workers.UpdateStatus(lost, entity.StatusRunning); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, _ := doJSON(t, http.MethodPost, server.URL+"/v1/admin/registry/reconcile", nil); status != http.StatusUnauthorized {
+		t.Fatalf("reconcile without a token answered %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	status, answer := doJSONWithHeaders(t, http.MethodPost, server.URL+"/v1/admin/registry/reconcile", nil, admin)
+	if status != http.StatusOK {
+		t.Fatalf("reconcile answered %d: %v", status, answer)
+	}
+	reconciliation, _ := answer["reconciliation"].(map[string]any)
+	if got := fmt.Sprint(reconciliation["removed"]); got != fmt.Sprint([]any{float64(stale)}) {
+		t.Errorf("removed %s, want [%d]", got, stale)
+	}
+	if got := fmt.Sprint(reconciliation["failed"]); got != fmt.Sprint([]any{float64(lost)}) {
+		t.Errorf("failed %s, want [%d]", got, lost)
+	}
+
+	// Only the run both sides agree on is still tracked.
+	_, answer = doJSONWithHeaders(t, http.MethodGet, server.URL+"/v1/admin/registry", nil, admin)
+	registry, _ := answer["registry"].([]any)
+	if len(registry) != 1 {
+		t.Fatalf("registry = %v, want worker %d only", registry, tracked)
+	}
+	if entry, _ := registry[0].(map[string]any); entry["worker_id"] != float64(tracked) || entry["cancellable"] != true {
+		t.Errorf("registry entry = %v, want worker %d cancellable", entry, tracked)
+	}
+
+	stored, err := workers.Get(lost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != entity.StatusFailed || len(stored.Warnings) != 1 {
+		t.Errorf("lost worker %s with warnings %v, want failed with a warning", stored.Status, stored.Warnings)
+	}
+
+	// Reconciling again finds nothing left to repair.
+	_, answer = doJSONWithHeaders(t, http.MethodPost, server.URL+"/v1/admin/registry/reconcile", nil, admin)
+	reconciliation, _ = answer["reconciliation"].(map[string]any)
+	if removed, _ := reconciliation["removed"].([]any); len(removed) != 0 {
+		t.Errorf("second reconcile removed %v, want none", removed)
+	}
+	if failed, _ := reconciliation["failed"].([]any); len(failed) != 0 {
+		t.Errorf("second reconcile failed %v, want none", failed)
+	}
+}
+
+func TestReplayHAR(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+
+	var mu sync.Mutex
+	var received []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.RequestURI(), r.Header.Get("X-Session"), body))
+	}))
+	defer stub.Close()
+
+	har := `{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://shop.example.com/api/products?page=2", "headers": [{"name": "x-session", "value": "abc"}]}},
+		{"request": {"method": "POST", "url": "https://shop.example.com/api/cart", "headers": [], "postData": {"mimeType": "application/json", "text": "{\"id\":42}"}}},
+		{"request": {"method": "GET", "url": "wss://shop.example.com/live", "headers": []}}
+	]}}`
+	resp, err := http.Post(server.URL+"/v1/scenarios/har?name=checkout&rebase=true", "application/json", strings.NewReader(har))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var answer struct {
+		Scenario entity.Scenario `json:"scenario"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("importing the HAR file answered %d", resp.StatusCode)
+	}
+	if len(answer.Scenario.Steps) != 2 || len(answer.Scenario.Unsupported) != 1 {
+		t.Fatalf("imported %d steps and %d unsupported entries, want 2 and 1", len(answer.Scenario.Steps), len(answer.Scenario.Unsupported))
+	}
+
+	// Rebased, the steps go to the stub instead of the host captured.
+	environmentID := createTestEnvironment(t, stack, "staging", stub.URL)
+	id := createTestWorker(t, server.URL, map[string]any{
+		"environment_id": environmentID, "concurrency": 1, "requests_per_task": 4, "http_method": "GET", "scenario_id": answer.Scenario.ID, "think_time": "0s",
+	})
+	waitForStatus(t, server.URL, id, entity.StatusFinished)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{
+		"GET /api/products?page=2 abc ",
+		`POST /api/cart  {"id":42}`,
+		"GET /api/products?page=2 abc ",
+		`POST /api/cart  {"id":42}`,
+	}
+	if !slices.Equal(received, want) {
+		t.Errorf("received %q, want %q", received, want)
+	}
+}
+
+func TestDuplicateEnvironmentName(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	status, _ := doJSON(t, http.MethodPost, server.URL+"/v1/environments", map[string]any{"name": "staging", "endpoint": "http://staging.invalid"})
+	if status != http.StatusCreated {
+		t.Fatalf("creating staging answered %d, want %d", status, http.StatusCreated)
+	}
+	if status, _ = doJSON(t, http.MethodPost, server.URL+"/v1/environments", map[string]any{"name": "staging", "endpoint": "http://other.invalid"}); status != http.StatusConflict {
+		t.Errorf("creating staging twice answered %d, want %d", status, http.StatusConflict)
+	}
+
+	status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/environments", map[string]any{"name": "qa", "endpoint": "http://qa.invalid"})
+	if status != http.StatusCreated {
+		t.Fatalf("creating qa answered %d, want %d", status, http.StatusCreated)
+	}
+	environment, _ := answer["environment"].(map[string]any)
+	url := fmt.Sprintf("%s/v1/environments/%v", server.URL, environment["id"])
+
+	// Renaming qa to staging is refused, keeping its own name is not.
+	if status, _ = doJSON(t, http.MethodPut, url, map[string]any{"name": "staging", "endpoint": "http://qa.invalid"}); status != http.StatusConflict {
+		t.Errorf("renaming qa to staging answered %d, want %d", status, http.StatusConflict)
+	}
+	if status, _ = doJSON(t, http.MethodPut, url, map[string]any{"name": "qa", "endpoint": "http://qa2.invalid"}); status != http.StatusOK {
+		t.Errorf("updating qa answered %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestDiffEnvironmentsMissing(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+	id := createTestEnvironment(t, stack, "staging", "http://staging.invalid")
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{query: fmt.Sprintf("a=%d&b=%d", id, id), want: http.StatusOK},
+		{query: fmt.Sprintf("a=%d&b=999", id), want: http.StatusNotFound},
+		{query: fmt.Sprintf("a=999&b=%d", id), want: http.StatusNotFound},
+		{query: fmt.Sprintf("a=%d", id), want: http.StatusBadRequest},
+		{query: "a=0&b=1", want: http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		if status, answer := doJSON(t, http.MethodGet, server.URL+"/v1/environments/diff?"+test.query, nil); status != test.want {
+			t.Errorf("diff?%s answered %d, want %d: %v", test.query, status, test.want, answer)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	cfg := testutil.Config()
+	cfg.MaxConcurrency = 10
+	cfg.MaxRequests = 100
+	cfg.GlobalMaxRPS = 50
+	cfg.DefaultRequestTimeout = 3 * time.Second
+	cfg.StrictValidation = true
+	stack, server := newTestAPI(t, cfg)
+	environmentID := createTestEnvironment(t, stack, "staging", "http://staging.invalid")
+
+	resp, err := http.Get(server.URL + "/v1/capabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var answer struct {
+		Capabilities entity.Capabilities `json:"capabilities"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+
+	capabilities := answer.Capabilities
+	limits := capabilities.Limits
+	if limits.MaxConcurrency != cfg.MaxConcurrency || limits.MaxRequests != cfg.MaxRequests || limits.GlobalMaxRPS != cfg.GlobalMaxRPS {
+		t.Errorf("limits = %+v, want the ones of the config", limits)
+	}
+	if time.Duration(limits.DefaultRequestTimeout) != cfg.DefaultRequestTimeout {
+		t.Errorf("default request timeout = %v, want %v", limits.DefaultRequestTimeout, cfg.DefaultRequestTimeout)
+	}
+	if limits.MaxScenarioSteps != entity.MaxScenarioSteps || limits.MaxIdentities != entity.MaxIdentities {
+		t.Errorf("limits = %+v, want the compiled-in ones", limits)
+	}
+	if !capabilities.Features.StrictValidation || capabilities.Features.Admin || capabilities.Features.SQLDataSources {
+		t.Errorf("features = %+v, want only strict validation", capabilities.Features)
+	}
+	if !slices.Equal(capabilities.HTTPMethods, entity.SupportedHTTPMethods) {
+		t.Errorf("http methods = %v, want %v", capabilities.HTTPMethods, entity.SupportedHTTPMethods)
+	}
+
+	// The reported limits are the ones the input is validated against.
+	tests := []struct {
+		name        string
+		concurrency int
+		requests    int
+		method      string
+		want        int
+	}{
+		{name: "at the limits", concurrency: limits.MaxConcurrency, requests: limits.MaxRequests / limits.MaxConcurrency, method: http.MethodGet, want: http.StatusOK},
+		{name: "past max concurrency", concurrency: limits.MaxConcurrency + 1, requests: 1, method: http.MethodGet, want: http.StatusUnprocessableEntity},
+		{name: "past max requests", concurrency: limits.MaxConcurrency, requests: limits.MaxRequests/limits.MaxConcurrency + 1, method: http.MethodGet, want: http.StatusUnprocessableEntity},
+		{name: "unreported method", concurrency: 1, requests: 1, method: "TRACE", want: http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := map[string]any{"environment_id": environmentID, "concurrency": test.concurrency, "requests_per_task": test.requests, "http_method": test.method}
+			if status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/workers/validate", payload); status != test.want {
+				t.Errorf("status = %d, want %d: %v", status, test.want, answer)
+			}
+		})
+	}
+
+	for _, method := range capabilities.HTTPMethods {
+		payload := map[string]any{"environment_id": environmentID, "concurrency": 1, "requests_per_task": 1, "http_method": method}
+		if status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/workers/validate", payload); status != http.StatusOK {
+			t.Errorf("reported method %s answered %d: %v", method, status, answer)
+		}
+	}
+}
+
+// seedRun stores a run of the workers tagged tag finished at finishedAt,
+// with requests of which failed, and samples of latency standing for all
+// of its requests.
+func seedRun(t *testing.T, stack *testutil.Stack, tag string, finishedAt time.Time, requests, failed int, throughput float64, latency float64, samples int) {
+	t.Helper()
+
+	metrics := entity.NewMetrics()
+	metrics.TotalRequests, metrics.FailedRequests, metrics.Throughput = requests, failed, throughput
+	metrics.SamplesSeen = requests
+	worker := &entity.Worker{Status: entity.StatusFinished, Tags: []string{tag}, FinishedAt: &finishedAt, Metrics: metrics}
+	id, err := stack.Repositories.Workers.Insert(worker)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := make([]entity.LatencySample, samples)
+	for i := range kept {
+		kept[i] = entity.LatencySample{SentAt: finishedAt.Add(-time.Minute), Latency: latency, Phase: entity.PhaseMain}
+	}
+	if err := stack.Repositories.Workers.InsertSamples(id, kept); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrendReport(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+	day := func(d, hour int) time.Time { return time.Date(2024, 5, d, hour, 0, 0, 0, time.UTC) }
+
+	// May 1st: a fast run of 100 requests and a slow one of 10, both of 10 samples.
+	seedRun(t, stack, "nightly", day(1, 10), 100, 0, 50, 0.1, 10)
+	seedRun(t, stack, "nightly", day(1, 18), 10, 5, 10, 1.0, 10)
+	// May 2nd: nothing. May 3rd: a run without samples.
+	seedRun(t, stack, "nightly", day(3, 9), 20, 0, 20, 0, 0)
+	// Out of the report: another tag, and runs before and at the end of the range.
+	seedRun(t, stack, "weekly", day(1, 12), 1000, 1000, 1, 9, 10)
+	seedRun(t, stack, "nightly", day(1, 0).Add(-time.Second), 1000, 1000, 1, 9, 10)
+	seedRun(t, stack, "nightly", day(4, 0), 1000, 1000, 1, 9, 10)
+
+	resp, err := http.Get(server.URL + "/v1/reports/trend?tag=nightly&from=2024-05-01&to=2024-05-04&bucket=1d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var answer struct {
+		Trend entity.TrendReport `json:"trend"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := answer.Trend.Buckets
+	if len(buckets) != 3 {
+		t.Fatalf("%d buckets, want one a day", len(buckets))
+	}
+	for i, bucket := range buckets {
+		if !bucket.Start.Equal(day(i+1, 0)) {
+			t.Errorf("bucket %d starts at %v, want %v", i, bucket.Start, day(i+1, 0))
+		}
+	}
+
+	first := buckets[0]
+	if first.Workers != 2 || first.Requests != 110 || first.FailedRequests != 5 {
+		t.Errorf("May 1st = %d workers, %d requests, %d failed, want 2, 110 and 5", first.Workers, first.Requests, first.FailedRequests)
+	}
+	if first.ErrorRate == nil || math.Abs(*first.ErrorRate-5.0/110) > 1e-9 {
+		t.Errorf("May 1st error rate = %v, want 5/110", first.ErrorRate)
+	}
+	if first.Throughput == nil || math.Abs(*first.Throughput-(50*100+10*10)/110.0) > 1e-9 {
+		t.Errorf("May 1st throughput = %v, want weighted by the requests", first.Throughput)
+	}
+	// Weighted by their requests, the slow run is under 10% of the day.
+	if first.P50 == nil || *first.P50 != 0.1 || first.P95 == nil || *first.P95 != 1.0 {
+		t.Errorf("May 1st p50 = %v and p95 = %v, want 0.1 and 1", first.P50, first.P95)
+	}
+
+	empty := buckets[1]
+	if empty.Workers != 0 || empty.ErrorRate != nil || empty.Throughput != nil || empty.P95 != nil {
+		t.Errorf("May 2nd = %+v, want an empty bucket", empty)
+	}
+
+	third := buckets[2]
+	if third.Workers != 1 || third.WithoutSamples != 1 || third.P95 != nil || third.ErrorRate == nil || *third.ErrorRate != 0 {
+		t.Errorf("May 3rd = %+v, want a run without latencies", third)
+	}
+}
+
+func TestTrendReportInvalid(t *testing.T) {
+	_, server := newTestAPI(t, testutil.Config())
+
+	for _, query := range []string{
+		"tag=nightly&from=2024-05-04&to=2024-05-01",
+		"tag=nightly&from=2024-05-01&to=2024-05-04&bucket=30m",
+		"tag=nightly&from=2020-01-01&to=2024-05-04&bucket=1h",
+		"tag=nightly&from=yesterday&to=2024-05-04",
+		"tag=&from=2024-05-01&to=2024-05-04",
+	} {
+		if status, _ := doJSON(t, http.MethodGet, server.URL+"/v1/reports/trend?"+query, nil); status != http.StatusBadRequest {
+			t.Errorf("trend?%s answered %d, want %d", query, status, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestWorkerSummary(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+
+	finishedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	metrics := entity.NewMetrics()
+	metrics.TotalRequests, metrics.FailedRequests, metrics.ErrorRate = 200, 10, 0.05
+	metrics.Throughput, metrics.MaxLatency = 40, 0.9
+	metrics.Percentiles = map[entity.PercentileRank]float64{entity.P50: 0.1, entity.P95: 0.4}
+	finished, err := stack.Repositories.Workers.Insert(&entity.Worker{
+		Concurrency: 4, RequestsPerTask: 50, HTTPMethod: http.MethodGet,
+		Status: entity.StatusFinished, FinishedAt: &finishedAt, Metrics: metrics,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := stack.Repositories.Workers.Insert(&entity.Worker{Concurrency: 1, RequestsPerTask: 1, HTTPMethod: http.MethodGet, Status: entity.StatusCreated})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, answer := doJSON(t, http.MethodGet, fmt.Sprintf("%s/v1/workers/%d/summary", server.URL, finished), nil)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	summary, _ := answer["summary"].(map[string]any)
+	want := map[string]any{
+		"id":                 float64(finished),
+		"status":             string(entity.StatusFinished),
+		"final":              true,
+		"finished_at":        "2024-05-01T10:00:00Z",
+		"total_requests":     200.0,
+		"failed_requests":    10.0,
+		"cancelled_requests": 0.0,
+		"error_rate":         0.05,
+		"throughput":         40.0,
+		"max_latency":        0.9,
+		"percentiles":        map[string]any{"50": 0.1, "95": 0.4},
+		"progress":           map[string]any{"completed": 200.0, "ratio": 1.0},
+	}
+	// Only the numbers, none of the configuration of the worker.
+	if !reflect.DeepEqual(summary, want) {
+		t.Errorf("summary = %v, want %v", summary, want)
+	}
+
+	status, answer = doJSON(t, http.MethodGet, fmt.Sprintf("%s/v1/workers/%d/summary", server.URL, created), nil)
+	if summary, _ := answer["summary"].(map[string]any); status != http.StatusOK || summary["final"] != false || summary["total_requests"] != 0.0 {
+		t.Errorf("summary of a worker not run = %d %v, want not final and without requests", status, answer)
+	}
+
+	if status, _ := doJSON(t, http.MethodGet, server.URL+"/v1/workers/999/summary", nil); status != http.StatusNotFound {
+		t.Errorf("summary of an unknown worker answered %d, want %d", status, http.StatusNotFound)
+	}
+}
+
+func TestCreateWorkerViolations(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+	environmentID := createTestEnvironment(t, stack, "staging", "http://staging.invalid")
+
+	// A soak run sends at the rate of its config for a duration, the count
+	// of requests and the think time conflict with it.
+	payload := map[string]any{
+		"environment_id":       environmentID,
+		"concurrency":          1,
+		"http_method":          "GET",
+		"mode":                 "soak",
+		"requests_per_task":    10,
+		"think_time":           "1s",
+		"min_throughput_ratio": 0.5,
+		"soak_config":          map[string]any{"duration": "1m", "interval": "2m"},
+		"tags":                 []string{"nightly", "not a tag"},
+	}
+	status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/workers", payload)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %v", status, http.StatusUnprocessableEntity, answer)
+	}
+
+	violations, _ := answer["errors"].(map[string]any)
+	for _, field := range []string{"requests_per_task", "think_time", "min_throughput_ratio", "soak_config.interval", "tags[1]"} {
+		if _, ok := violations[field]; !ok {
+			t.Errorf("no error for %s in %v", field, violations)
+		}
+	}
+}
+
+func TestAutoDisable(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+
+	// The target answers without the header the workers require, every request fails.
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	t.Cleanup(target.Close)
+	environment, err := stack.EnvironmentService.CreateEnvironment(dto.CreateEnvironmentInput{
+		Name:        "broken",
+		Endpoint:    target.URL,
+		AutoDisable: &entity.AutoDisablePolicy{AfterRuns: 2, ErrorRate: 0.5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := map[string]any{
+		"environment_id":    environment.ID,
+		"concurrency":       1,
+		"requests_per_task": 3,
+		"http_method":       "GET",
+		"think_time":        "0s",
+		"success_header":    map[string]any{"name": "X-Status", "value": "ok"},
+	}
+
+	// The policy is checked once a run is over, after its status is stored.
+	disabled := func() (bool, string) {
+		stored, err := stack.Repositories.Environments.Get(environment.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored.Disabled, stored.DisabledReason
+	}
+	for run := 1; run <= 2; run++ {
+		id := createTestWorker(t, server.URL, payload)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, answer := doJSON(t, http.MethodGet, fmt.Sprintf("%s/v1/workers/%d/summary", server.URL, id), nil)
+			summary, _ := answer["summary"].(map[string]any)
+			if summary["final"] == true {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("run %d not over after 5s", run)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if run == 1 {
+			// Give the check of the first run the time to disable it wrongly.
+			time.Sleep(50 * time.Millisecond)
+			if ok, reason := disabled(); ok {
+				t.Fatalf("disabled after a single failed run: %s", reason)
+			}
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ok, reason := disabled()
+		if ok {
+			if !strings.Contains(reason, "the last 2 runs") {
+				t.Errorf("disabled because %q, want the failed runs", reason)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("environment still enabled 5s after two failed runs")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// New workers are rejected until someone re-enables it.
+	if status, answer := doJSON(t, http.MethodPost, server.URL+"/v1/workers", payload); status == http.StatusCreated {
+		t.Errorf("worker created against the disabled environment: %v", answer)
+	}
+}
+
+func TestCreateDuringShutdown(t *testing.T) {
+	stack, server := newTestAPI(t, testutil.Config())
+	environmentID := createTestEnvironment(t, stack, "staging", "http://staging.invalid")
+	payload := map[string]any{
+		"environment_id":    environmentID,
+		"concurrency":       1,
+		"requests_per_task": 1,
+		"http_method":       "GET",
+		"autostart":         false,
+	}
+	stored := createTestWorker(t, server.URL, payload)
+
+	stack.WorkerService.BeginShutdown()
+
+	delete(payload, "autostart")
+	requests := []struct {
+		name    string
+		path    string
+		payload any
+	}{
+		{name: "create a worker", path: "/v1/workers", payload: payload},
+		{name: "start a stored worker", path: fmt.Sprintf("/v1/workers/%d/start", stored)},
+		{name: "create a campaign", path: "/v1/campaigns", payload: map[string]any{
+			"template":        map[string]any{"concurrency": 1, "requests_per_task": 1, "http_method": "GET"},
+			"environment_ids": []int{environmentID},
+		}},
+	}
+	for _, request := range requests {
+		if status, answer := doJSON(t, http.MethodPost, server.URL+request.path, request.payload); status != http.StatusServiceUnavailable {
+			t.Errorf("%s during shutdown answered %d, want %d: %v", request.name, status, http.StatusServiceUnavailable, answer)
+		}
+	}
+
+	// Nothing new is stored, and the stored worker never ran.
+	workers, _, err := stack.WorkerService.GetWorkers(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(workers) != 1 || workers[0].ID != stored || workers[0].Status != entity.StatusCreated {
+		t.Errorf("workers = %v, want worker %d alone, never started", workers, stored)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"crypto/tls"
 	"database/sql"
+	"encoding/base64"
 	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,27 +18,51 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/vladComan0/performance-analyzer/internal/config"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
 	"github.com/vladComan0/performance-analyzer/internal/service"
 	"github.com/vladComan0/performance-analyzer/pkg/helpers"
+	"github.com/vladComan0/performance-analyzer/pkg/secrets"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
 type application struct {
 	environmentService service.EnvironmentService
 	workerService      service.WorkerService
-	config             config.Config
+	config             *config.Holder // read anew every time, the config is reloaded on SIGHUP
 	helper             *helpers.Helper
 	log                zerolog.Logger
+	trustedProxies     []netip.Prefix // peers whose X-Forwarded-For and X-Real-IP headers are honored
+	degraded           atomic.Bool    // set while the database could not be reached
 }
 
+// writeTimeout bounds regular responses, streaming handlers push the deadline
+// forward by streamWriteTimeout every time they make progress. They are
+// variables for the tests to shorten them.
+var (
+	writeTimeout       = 10 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// listFlushEvery is how many array elements list endpoints encode between flushes.
+const listFlushEvery = 100
+
+const (
+	defaultConnectAttempts = 10
+	defaultConnectBackoff  = time.Second
+	maxConnectBackoff      = 30 * time.Second
+)
+
+// defaultRecordsDir is where the request record files are written when the config doesn't say.
+const defaultRecordsDir = "records"
+
 func main() {
 	cfg := config.GetConfig()
 	logger := configureLogger(cfg)
 
 	db, err := openDB(cfg.DSN)
 	if err != nil {
-		logger.Fatal().Err(err)
+		logger.Fatal().Err(err).Msg("Error opening the database")
 	}
 	defer func() {
 		if db != nil { // done only to remain consistent, it is taken care of by the cleanup method
@@ -43,17 +70,62 @@ func main() {
 		}
 	}()
 
-	helper := helpers.NewHelper(logger, cfg.DebugEnabled)
+	trustedProxies, err := parseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Error parsing the trusted proxies")
+	}
+
+	helper := helpers.NewHelper(logger, cfg.DebugEnabled, cfg.TrustForwardedHeaders)
+	helper.TrustedProxies = trustedProxies
+	helper.JSONLimits = helpers.JSONLimits{MaxDepth: cfg.JSON.MaxDepth, MaxElements: cfg.JSON.MaxElements}
 
 	environmentRepository := repository.NewEnvironmentRepositoryDB(db)
-	environmentService := service.NewEnvironmentService(environmentRepository)
 	workerRepository := repository.NewWorkerRepositoryDB(db)
-	workerService := service.NewWorkerService(workerRepository, environmentRepository, logger)
+	if workerRepository.Compression, err = repository.ParseCompression(cfg.Database.Compression); err != nil {
+		logger.Fatal().Err(err).Msg("Error configuring the database")
+	}
+	campaignRepository := repository.NewCampaignRepositoryDB(db)
+	scenarioRepository := repository.NewScenarioRepositoryDB(db)
+	environmentService := service.NewEnvironmentService(environmentRepository, workerRepository)
+	recordsDir := cfg.Records.Dir
+	if recordsDir == "" {
+		recordsDir = defaultRecordsDir
+	}
+	sealer, err := newSealer(cfg.SecretsKey)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Error parsing the secrets key")
+	}
+	settings := config.NewHolder(cfg)
+	limiter := entity.NewRateLimiter(cfg.GlobalMaxRPS)
+	var stats *entity.StatsD
+	if cfg.StatsD.Addr != "" {
+		if stats, err = entity.NewStatsD(cfg.StatsD.Addr, cfg.StatsD.Prefix, cfg.StatsD.DogStatsD, cfg.StatsD.Tags, cfg.StatsD.Buffer); err != nil {
+			logger.Fatal().Err(err).Msg("Error configuring the StatsD export")
+		}
+	}
+	workerService := service.NewWorkerService(workerRepository, environmentRepository, campaignRepository, scenarioRepository, recordsDir, cfg.BodyFiles.Dir, settings, sealer, limiter, stats, logger)
 
-	app := newApplication(environmentService, workerService, cfg, helper, logger)
+	app := newApplication(environmentService, workerService, settings, helper, logger)
+	app.trustedProxies = trustedProxies
 	server := newServer(cfg, app)
 
-	go app.cleanup(db, server)
+	if err = waitForDB(db, cfg, cfg.Database.ConnectAttempts, logger); err != nil {
+		if !cfg.Database.DegradedMode {
+			logger.Fatal().Err(err).Msg("Database is unavailable")
+		}
+
+		logger.Warn().Err(err).Msg("Database is unavailable, starting in degraded mode")
+		app.degraded.Store(true)
+		go func() {
+			// Retry until the database appears, the process is stopped through cleanup otherwise.
+			_ = waitForDB(db, cfg, -1, logger)
+			app.degraded.Store(false)
+			logger.Info().Msg("Database is available, leaving degraded mode")
+		}()
+	}
+
+	go app.cleanup(db, server, stats)
+	go app.reloadOnHangup(limiter)
 
 	logger.Info().Msgf("Starting server on port: %s", strings.Split(server.Addr, ":")[1])
 	//err := server.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
@@ -61,16 +133,22 @@ func main() {
 	logger.Fatal().Err(err)
 }
 
-func newApplication(environmentService service.EnvironmentService, workerService service.WorkerService, cfg config.Config, helper *helpers.Helper, log zerolog.Logger) *application {
+func newApplication(environmentService service.EnvironmentService, workerService service.WorkerService, settings *config.Holder, helper *helpers.Helper, log zerolog.Logger) *application {
 	return &application{
 		environmentService: environmentService,
 		workerService:      workerService,
-		config:             cfg,
+		config:             settings,
 		helper:             helper,
 		log:                log,
 	}
 }
 
+// newHandler returns the handler of the API built from its services alone,
+// for the integration tests to serve it over in-memory repositories.
+func newHandler(environmentService service.EnvironmentService, workerService service.WorkerService, settings *config.Holder, helper *helpers.Helper, log zerolog.Logger) http.Handler {
+	return newApplication(environmentService, workerService, settings, helper, log).routes()
+}
+
 func newServer(cfg config.Config, app *application) *http.Server {
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.CurveP521, tls.CurveP384, tls.CurveP256},
@@ -89,55 +167,155 @@ func newServer(cfg config.Config, app *application) *http.Server {
 		Handler:      app.routes(),
 		IdleTimeout:  time.Minute,
 		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		WriteTimeout: writeTimeout,
 		TLSConfig:    tlsConfig,
 	}
 }
 
+// openDB only validates the DSN, the connection itself is established by waitForDB.
 func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+	dsn, err := utcDSN(dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err = db.Ping(); err != nil {
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
-func configureLogger(cfg config.Config) zerolog.Logger {
-	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
+// utcDSN makes the driver parse the DATETIME columns into time.Time in UTC,
+// the zone every timestamp is stored in, whatever parseTime and loc the DSN
+// sets.
+func utcDSN(dsn string) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
 
-	var logLevel zerolog.Level
+// newSealer returns the sealer of the base64 encoded key, nil when no key is configured.
+func newSealer(key string) (*secrets.Sealer, error) {
+	if key == "" {
+		return nil, nil
+	}
 
-	if cfg.Log.Level == "" {
-		logger.Info().Msg("Log level is not set, defaulting to info")
-		logLevel = zerolog.InfoLevel
-	} else {
-		var err error
-		logLevel, err = zerolog.ParseLevel(cfg.Log.Level)
-		if err != nil {
-			logger.Warn().Msgf("Invalid log level %q, defaulting to info", cfg.Log.Level)
-			logLevel = zerolog.InfoLevel
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.NewSealer(decoded)
+}
+
+// waitForDB pings the database until it answers, doubling the backoff after
+// every failure. A negative number of attempts retries forever.
+func waitForDB(db *sql.DB, cfg config.Config, attempts int, log zerolog.Logger) error {
+	if attempts == 0 {
+		attempts = defaultConnectAttempts
+	}
+
+	backoff := cfg.Database.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 1; attempts < 0 || attempt <= attempts; attempt++ {
+		if err = db.Ping(); err == nil {
+			if attempt > 1 {
+				log.Info().Msgf("Connected to the database after %d attempts", attempt)
+			}
+			return nil
+		}
+
+		if attempts > 0 && attempt == attempts {
+			break
+		}
+
+		if attempts > 0 {
+			log.Warn().Err(err).Msgf("Database is not reachable (attempt %d/%d), retrying in %s", attempt, attempts, backoff)
+		} else {
+			log.Warn().Err(err).Msgf("Database is not reachable (attempt %d), retrying in %s", attempt, backoff)
 		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, maxConnectBackoff)
 	}
-	logger = logger.Level(logLevel)
+
+	return err
+}
+
+func configureLogger(cfg config.Config) zerolog.Logger {
+	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
 
 	if cfg.Log.HumanReadable {
 		output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
 		logger = logger.Output(output)
 	}
 
+	setLogLevel(cfg, logger)
 	return logger
 }
 
-func (app *application) cleanup(db *sql.DB, server *http.Server) {
+// setLogLevel sets the level globally rather than on the logger, so that a
+// reload applies it to the copies of the logger held everywhere.
+func setLogLevel(cfg config.Config, logger zerolog.Logger) {
+	logLevel := zerolog.InfoLevel
+	if cfg.Log.Level == "" {
+		logger.Info().Msg("Log level is not set, defaulting to info")
+	} else if level, err := zerolog.ParseLevel(cfg.Log.Level); err != nil {
+		logger.Warn().Msgf("Invalid log level %q, defaulting to info", cfg.Log.Level)
+	} else {
+		logLevel = level
+	}
+	zerolog.SetGlobalLevel(logLevel)
+}
+
+// reloadOnHangup reloads the config every time the process receives SIGHUP.
+// The settings that can change while running apply at once, the running
+// workers keep going, and the other settings are only reported as needing a
+// restart.
+func (app *application) reloadOnHangup(limiter *entity.RateLimiter) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	for range hangup {
+		changes, err := app.config.Reload()
+		if err != nil {
+			app.log.Error().Err(err).Msg("Error reloading the config, the current one is kept")
+			continue
+		}
+		if len(changes) == 0 {
+			app.log.Info().Msg("Config reloaded, nothing changed")
+			continue
+		}
+
+		cfg := app.config.Get()
+		setLogLevel(cfg, app.log)
+		limiter.SetRate(cfg.GlobalMaxRPS)
+
+		for _, change := range changes {
+			if change.Restart {
+				app.log.Warn().Msgf("Config reloaded, %s but a restart is required for it to apply", change)
+				continue
+			}
+			app.log.Info().Msgf("Config reloaded, %s", change)
+		}
+	}
+}
+
+func (app *application) cleanup(db *sql.DB, server *http.Server, stats *entity.StatsD) {
 	interruptChan := make(chan os.Signal, 1)
 	signal.Notify(interruptChan, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	sig := <-interruptChan
 
 	app.log.Info().Msgf("Received shutdown signal %s, cleaning up...", sig)
+	app.workerService.BeginShutdown()
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
@@ -149,5 +327,9 @@ func (app *application) cleanup(db *sql.DB, server *http.Server) {
 		app.log.Error().Msgf("Error closing the database: %s", err)
 	}
 
+	if err := stats.Close(); err != nil {
+		app.log.Error().Err(err).Msg("Error closing the StatsD export")
+	}
+
 	os.Exit(0)
 }
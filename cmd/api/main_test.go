@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bufio"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/internal/service"
+	"github.com/vladComan0/performance-analyzer/internal/testutil"
+	"github.com/vladComan0/performance-analyzer/pkg/helpers"
+)
+
+// slowWorkerService answers GetWorker after delay, and exports pages
+// workers one at a time, delay apart.
+type slowWorkerService struct {
+	service.WorkerService
+	delay time.Duration
+	pages int
+}
+
+func (s *slowWorkerService) GetWorker(id int) (*entity.Worker, error) {
+	time.Sleep(s.delay)
+	return s.WorkerService.GetWorker(id)
+}
+
+func (s *slowWorkerService) ExportWorkers(fn func(workers []*entity.Worker) error) error {
+	for i := 1; i <= s.pages; i++ {
+		time.Sleep(s.delay)
+		worker := entity.NewWorker(1, 1, 1, http.MethodGet, nil, nil, zerolog.Nop())
+		worker.ID = i
+		if err := fn([]*entity.Worker{worker}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWriteTimeouts(t *testing.T) {
+	defer func(write, stream time.Duration) {
+		writeTimeout, streamWriteTimeout = write, stream
+	}(writeTimeout, streamWriteTimeout)
+	writeTimeout, streamWriteTimeout = 100*time.Millisecond, 100*time.Millisecond
+
+	stack := testutil.NewStack(newHandler, testutil.Config())
+	workers := &slowWorkerService{WorkerService: stack.WorkerService, delay: 60 * time.Millisecond, pages: 5}
+	app := newApplication(stack.EnvironmentService, workers, stack.Settings, helpers.NewHelper(zerolog.Nop(), false, false), zerolog.Nop())
+
+	server := httptest.NewUnstartedServer(nil)
+	server.Config = newServer(testutil.Config(), app)
+	server.Start()
+	defer server.Close()
+
+	t.Run("streaming outlasts the write timeout", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/v1/workers/export")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		// 5 pages 60ms apart take three times the write timeout.
+		lines := 0
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines++
+		}
+		if err := scanner.Err(); err != nil {
+			t.Fatalf("export cut after %d workers: %s", lines, err)
+		}
+		if lines != workers.pages {
+			t.Errorf("exported %d workers, want %d", lines, workers.pages)
+		}
+	})
+
+	t.Run("regular endpoints keep the write timeout", func(t *testing.T) {
+		workers.delay = 3 * writeTimeout
+		resp, err := http.Get(server.URL + "/v1/workers/1")
+		if err == nil {
+			resp.Body.Close()
+			t.Fatalf("got %s, want the response cut by the write timeout", resp.Status)
+		}
+	})
+}
+
+func TestUTCDSN(t *testing.T) {
+	tests := []struct {
+		name string
+		dsn  string
+	}{
+		{name: "no time settings", dsn: "web:pass@tcp(localhost:3306)/analyzer"},
+		{name: "local times", dsn: "web:pass@tcp(localhost:3306)/analyzer?parseTime=false&loc=Local"},
+		{name: "another zone", dsn: "web:pass@tcp(localhost:3306)/analyzer?parseTime=true&loc=Europe%2FBucharest"},
+		{name: "other settings", dsn: "web:pass@tcp(db:3306)/analyzer?timeout=5s&charset=utf8mb4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dsn, err := utcDSN(test.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := mysql.ParseDSN(dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cfg.ParseTime || cfg.Loc != time.UTC {
+				t.Errorf("utcDSN(%q) parses times %t in %s, want true in UTC", test.dsn, cfg.ParseTime, cfg.Loc)
+			}
+
+			// The rest of the DSN is left as it is.
+			original, err := mysql.ParseDSN(test.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.User != original.User || cfg.Addr != original.Addr || cfg.DBName != original.DBName || cfg.Timeout != original.Timeout || !maps.Equal(cfg.Params, original.Params) {
+				t.Errorf("utcDSN(%q) = %q, want only the time settings changed", test.dsn, dsn)
+			}
+		})
+	}
+}
+
+func TestUTCDSNInvalid(t *testing.T) {
+	if _, err := utcDSN("web:pass@tcp(localhost:3306"); err == nil {
+		t.Error("utcDSN of a malformed DSN succeeded, want an error")
+	}
+}
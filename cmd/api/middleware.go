@@ -1,15 +1,19 @@
 package main
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
 
 	"github.com/rs/cors"
 )
 
 func (app *application) logRequests(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		app.log.Info().Msgf("%s - %s %s %s", r.RemoteAddr, r.Proto, r.Method, r.URL.RequestURI())
+		app.log.Info().Msgf("%s - %s %s %s", app.clientIP(r), r.Proto, r.Method, r.URL.RequestURI())
 		next.ServeHTTP(w, r)
 	})
 }
@@ -30,9 +34,72 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 	})
 }
 
+// requireDB rejects the request while the database is unreachable instead of letting it fail in the repository.
+func (app *application) requireDB(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.degraded.Load() {
+			w.Header().Set("Retry-After", "5")
+			app.helper.ClientError(w, http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAdmin only lets through the requests bearing the admin token. The
+// admin endpoints are disabled while no token is configured.
+func (app *application) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		adminToken := app.config.Get().AdminToken
+		if adminToken == "" {
+			app.helper.ClientError(w, http.StatusForbidden)
+			return
+		}
+
+		token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			app.helper.ClientError(w, http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireDemoData hides the demo data endpoints unless the config enables them.
+func (app *application) requireDemoData(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.config.Get().DemoData {
+			app.helper.ClientError(w, http.StatusNotFound)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// enableCORS applies the allowed origins of the current config, the CORS
+// handler being rebuilt when a reload changes them.
 func (app *application) enableCORS(next http.Handler) http.Handler {
+	type corsState struct {
+		origins []string
+		handler http.Handler
+	}
+	var current atomic.Pointer[corsState]
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origins := app.config.Get().AllowedOrigins
+		state := current.Load()
+		if state == nil || !slices.Equal(state.origins, origins) {
+			state = &corsState{origins: origins, handler: newCORSHandler(origins, next)}
+			current.Store(state)
+		}
+		state.handler.ServeHTTP(w, r)
+	})
+}
+
+func newCORSHandler(origins []string, next http.Handler) http.Handler {
 	corsHandler := cors.New(cors.Options{
-		AllowedOrigins:   app.config.AllowedOrigins,
+		AllowedOrigins:   origins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization"},
 		AllowCredentials: true,
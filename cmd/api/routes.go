@@ -10,20 +10,61 @@ func (app *application) routes() http.Handler {
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /ping", app.ping)
+	mux.HandleFunc("GET /v1/health", app.health)
+	mux.HandleFunc("GET /v1/capabilities", app.getCapabilities)
+
+	dbChain := alice.New(app.requireDB)
+	adminChain := alice.New(app.requireAdmin)
 
 	// Environments CRUD
-	mux.HandleFunc("POST /v1/environments", app.createEnvironment)
-	mux.HandleFunc("GET /v1/environments/{id}", app.getEnvironment)
-	mux.HandleFunc("GET /v1/environments", app.getAllEnvironments)
-	mux.HandleFunc("PUT /v1/environments/{id}", app.updateEnvironment)
-	mux.HandleFunc("DELETE /v1/environments/{id}", app.deleteEnvironment)
+	mux.Handle("POST /v1/environments", dbChain.ThenFunc(app.createEnvironment))
+	mux.Handle("POST /v1/environments/sweep", dbChain.ThenFunc(app.sweepEnvironments))
+	mux.Handle("GET /v1/environments/diff", dbChain.ThenFunc(app.diffEnvironments))
+	mux.Handle("GET /v1/environments/{id}", dbChain.ThenFunc(app.getEnvironment))
+	mux.Handle("GET /v1/environments", dbChain.ThenFunc(app.getAllEnvironments))
+	mux.Handle("PUT /v1/environments/{id}", dbChain.ThenFunc(app.updateEnvironment))
+	mux.Handle("DELETE /v1/environments/{id}", dbChain.ThenFunc(app.deleteEnvironment))
+	mux.Handle("POST /v1/environments/{id}/baseline", dbChain.ThenFunc(app.setEnvironmentBaseline))
+	mux.Handle("PUT /v1/environments/{id}/body-schema", dbChain.ThenFunc(app.setEnvironmentBodySchema))
+	mux.Handle("GET /v1/environments/{id}/stats", dbChain.ThenFunc(app.getEnvironmentStats))
+	mux.Handle("POST /v1/environments/{id}/verify-auth", dbChain.ThenFunc(app.verifyEnvironmentAuth))
 
 	// Workers CR
-	mux.HandleFunc("POST /v1/workers", app.createWorker)
-	mux.HandleFunc("GET /v1/workers/{id}", app.getWorker)
-	mux.HandleFunc("GET /v1/workers", app.getAllWorkers)
+	mux.Handle("POST /v1/workers", dbChain.ThenFunc(app.createWorker))
+	mux.Handle("POST /v1/workers/validate", dbChain.ThenFunc(app.validateWorker))
+	mux.Handle("POST /v1/workers/estimate", dbChain.ThenFunc(app.estimateWorker))
+	mux.Handle("GET /v1/workers/{id}", dbChain.ThenFunc(app.getWorker))
+	mux.Handle("GET /v1/workers/{id}/summary", dbChain.ThenFunc(app.getWorkerSummary))
+	mux.Handle("GET /v1/workers/{id}/breakdown", dbChain.ThenFunc(app.getWorkerBreakdown))
+	mux.Handle("GET /v1/workers/{id}/latencies", dbChain.ThenFunc(app.getWorkerLatencies))
+	mux.Handle("GET /v1/workers/{id}/cdf", dbChain.ThenFunc(app.getWorkerCDF))
+	mux.Handle("GET /v1/workers/{id}/report", dbChain.ThenFunc(app.getWorkerReport))
+	mux.Handle("GET /v1/workers/{id}/records", dbChain.ThenFunc(app.getWorkerRecords))
+	mux.Handle("POST /v1/workers/{id}/start", dbChain.ThenFunc(app.startWorker))
+	mux.Handle("GET /v1/workers", dbChain.ThenFunc(app.getAllWorkers))
+	mux.Handle("GET /v1/workers/export", dbChain.ThenFunc(app.exportWorkers))
+	mux.Handle("POST /v1/workers/stop-all", adminChain.ThenFunc(app.stopAllWorkers))
+
+	// Admin
+	mux.Handle("GET /v1/admin/registry", adminChain.ThenFunc(app.getRegistry))
+	mux.Handle("POST /v1/admin/registry/reconcile", adminChain.Append(app.requireDB).ThenFunc(app.reconcileRegistry))
+	mux.Handle("GET /v1/admin/storage", adminChain.Append(app.requireDB).ThenFunc(app.getStorageStats))
+	mux.Handle("POST /v1/admin/demo-data", adminChain.Append(app.requireDemoData, app.requireDB).ThenFunc(app.seedDemoData))
+	mux.Handle("DELETE /v1/admin/demo-data", adminChain.Append(app.requireDemoData, app.requireDB).ThenFunc(app.pruneDemoData))
+
+	// Reports
+	mux.Handle("GET /v1/reports/trend", dbChain.ThenFunc(app.getTrend))
+
+	// Scenarios
+	mux.Handle("POST /v1/scenarios/har", dbChain.ThenFunc(app.importHAR))
+	mux.Handle("GET /v1/scenarios/{id}", dbChain.ThenFunc(app.getScenario))
+
+	// Campaigns
+	mux.Handle("POST /v1/campaigns", dbChain.ThenFunc(app.createCampaign))
+	mux.Handle("GET /v1/campaigns/{id}", dbChain.ThenFunc(app.getCampaign))
+	mux.Handle("POST /v1/campaigns/{id}/cancel", dbChain.ThenFunc(app.cancelCampaign))
 
-	standardChain := alice.New(app.recoverPanic, app.logRequests, app.enableCORS)
+	standardChain := alice.New(app.recoverPanic, app.withClientIP, app.logRequests, app.enableCORS)
 
 	return standardChain.Then(mux)
 }
@@ -3,8 +3,10 @@ module github.com/vladComan0/performance-analyzer
 go 1.22.2
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/go-sql-driver/mysql v1.8.1
 	github.com/justinas/alice v1.2.0
+	github.com/klauspost/compress v1.17.11
 	github.com/montanaflynn/stats v0.7.1
 	github.com/rs/cors v1.11.0
 	github.com/rs/zerolog v1.33.0
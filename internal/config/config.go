@@ -1,36 +1,94 @@
 package config
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Addr           string    `mapstructure:"addr"`
-	Environment    string    `mapstructure:"environment"`
-	DSN            string    `mapstructure:"dsn"`
-	DebugEnabled   bool      `mapstructure:"debug_enabled"`
-	AllowedOrigins []string  `mapstructure:"allowed_origins"`
-	Log            logConfig `mapstructure:"log"`
+	Addr                  string          `mapstructure:"addr"`
+	Environment           string          `mapstructure:"environment"`
+	DSN                   string          `mapstructure:"dsn"`
+	DebugEnabled          bool            `mapstructure:"debug_enabled"`
+	AllowedOrigins        []string        `mapstructure:"allowed_origins"`
+	TrustForwardedHeaders bool            `mapstructure:"trust_forwarded_headers"` // honor X-Forwarded-Proto/Host, only from the trusted proxies
+	TrustedProxies        []string        `mapstructure:"trusted_proxies"`         // CIDR ranges whose X-Forwarded-* and X-Real-IP headers are honored
+	AdminToken            string          `mapstructure:"admin_token"`             // bearer token of the admin endpoints, disabled when empty
+	StrictValidation      bool            `mapstructure:"strict_validation"`       // reject the workers whose plan has warnings instead of only returning them
+	SecretsKey            string          `mapstructure:"secrets_key"`             // base64 AES key sealing the stored secrets, the SQL data sources are refused when empty
+	RateWindow            time.Duration   `mapstructure:"rate_window"`             // span of the live request rate of the running workers, 5s when 0
+	GlobalMaxRPS          float64         `mapstructure:"global_max_rps"`          // outbound requests per second of all the workers together, unlimited when 0
+	DefaultRequestTimeout time.Duration   `mapstructure:"default_request_timeout"` // total timeout of the requests of the workers whose input and environment set none, none when 0
+	DemoData              bool            `mapstructure:"demo_data"`               // serve the admin endpoints generating and pruning demo data
+	MaxConcurrency        int             `mapstructure:"max_concurrency"`         // goroutines of a worker, unlimited when 0
+	MaxRequests           int             `mapstructure:"max_requests"`            // requests of a fixed run, unlimited when 0
+	DefaultSeed           int64           `mapstructure:"default_seed"`            // master seed of the random choices of the workers that set none, a random one per worker when 0
+	Log                   logConfig       `mapstructure:"log"`
+	Database              dbConfig        `mapstructure:"database"`
+	Records               recordsConfig   `mapstructure:"records"`
+	BodyFiles             bodyFilesConfig `mapstructure:"body_files"`
+	JSON                  jsonConfig      `mapstructure:"json"`
+	StatsD                statsDConfig    `mapstructure:"statsd"`
 }
 
 type logConfig struct {
-	Level         string `mapstructure:"level"`
-	HumanReadable bool   `mapstructure:"human_readable"`
+	Level             string `mapstructure:"level"`
+	HumanReadable     bool   `mapstructure:"human_readable"`
+	RequestSampleRate int    `mapstructure:"request_sample_rate"` // 1 in N per request debug events is logged for the workers that don't set theirs, all of them when 0 or 1
+}
+
+type dbConfig struct {
+	ConnectAttempts int           `mapstructure:"connect_attempts"` // 0 falls back to the default
+	ConnectBackoff  time.Duration `mapstructure:"connect_backoff"`  // doubled after every failed attempt
+	DegradedMode    bool          `mapstructure:"degraded_mode"`    // start without the database and keep retrying
+	Compression     string        `mapstructure:"compression"`      // of the report, result and captured response columns written, gzip when empty, none, gzip or zstd otherwise
+}
+
+type recordsConfig struct {
+	Dir string `mapstructure:"dir"` // where the request record files are written, "records" when empty
+}
+
+type jsonConfig struct {
+	MaxDepth    int `mapstructure:"max_depth"`    // nested objects and arrays of a request body, 64 when 0
+	MaxElements int `mapstructure:"max_elements"` // elements of a single array of a request body, 10000 when 0
+}
+
+type statsDConfig struct {
+	Addr      string   `mapstructure:"addr"`      // host:port of the StatsD server the metrics of the requests are sent to, none when empty
+	Prefix    string   `mapstructure:"prefix"`    // of the name of every metric
+	DogStatsD bool     `mapstructure:"dogstatsd"` // tag the metrics with the worker and its environment, which plain StatsD doesn't understand
+	Tags      []string `mapstructure:"tags"`      // key:value tags added to every metric, DogStatsD only
+	Buffer    int      `mapstructure:"buffer"`    // metrics queued before they are dropped, 10000 when 0
+}
+
+type bodyFilesConfig struct {
+	Dir string `mapstructure:"dir"` // where the files streamed as request bodies are read from, file streams are refused when empty
 }
 
 func GetConfig() Config {
+	cfg, err := load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Error reading config file")
+	}
+	return cfg
+}
+
+// load reads the config file anew.
+func load() (Config, error) {
 	var cfg Config
 	viper.SetConfigName("config")
 	viper.AddConfigPath(".")
 	viper.SetConfigType("yaml")
 	if err := viper.ReadInConfig(); err != nil {
-		log.Fatal().Err(err).Msg("Error reading config file")
+		return Config{}, err
 	}
 
 	if err := viper.Unmarshal(&cfg); err != nil {
-		log.Fatal().Err(err).Msg("Unable to decode into struct")
+		return Config{}, fmt.Errorf("decoding the config: %w", err)
 	}
 
-	return cfg
+	return cfg, nil
 }
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Holder holds the current config. A reload replaces it as a whole, so a
+// reader never sees the settings of two versions of the file mixed.
+type Holder struct {
+	mu  sync.RWMutex
+	cfg Config
+}
+
+func NewHolder(cfg Config) *Holder {
+	return &Holder{cfg: cfg}
+}
+
+// Get returns the current config, which must not be modified.
+func (h *Holder) Get() Config {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.cfg
+}
+
+// Change is a setting whose value differs in the config file.
+type Change struct {
+	Setting string
+	Old     string // empty for a secret
+	New     string // empty for a secret
+	Restart bool   // the setting isn't reloaded, it only applies once the server is restarted
+}
+
+func (c Change) String() string {
+	if c.Old == "" && c.New == "" {
+		return c.Setting + " changed"
+	}
+	return fmt.Sprintf("%s changed from %q to %q", c.Setting, c.Old, c.New)
+}
+
+// Reload reads the config file anew and applies the settings that can be
+// changed while running. The other ones keep their value and are reported
+// as needing a restart. The current config is left as is when the file
+// can't be read.
+func (h *Holder) Reload() ([]Change, error) {
+	loaded, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	next := h.cfg
+	var changes []Change
+	for _, s := range settings {
+		if s.equal(h.cfg, loaded) {
+			continue
+		}
+
+		change := Change{Setting: s.name, Restart: s.apply == nil}
+		if !s.secret {
+			change.Old, change.New = s.value(h.cfg), s.value(loaded)
+		}
+		changes = append(changes, change)
+		if s.apply != nil {
+			s.apply(&next, loaded)
+		}
+	}
+	h.cfg = next
+	return changes, nil
+}
+
+// setting is a key of the config file, reloadable when it has apply.
+type setting struct {
+	name   string
+	secret bool // its value is never logged
+	value  func(Config) string
+	apply  func(dst *Config, src Config)
+}
+
+func (s setting) equal(a, b Config) bool {
+	return s.value(a) == s.value(b)
+}
+
+var settings = []setting{
+	{name: "addr", value: func(c Config) string { return c.Addr }},
+	{name: "environment", value: func(c Config) string { return c.Environment }},
+	{name: "dsn", secret: true, value: func(c Config) string { return c.DSN }},
+	{name: "debug_enabled", value: func(c Config) string { return fmt.Sprint(c.DebugEnabled) }},
+	{
+		name:  "allowed_origins",
+		value: func(c Config) string { return fmt.Sprint(c.AllowedOrigins) },
+		apply: func(dst *Config, src Config) { dst.AllowedOrigins = slices.Clone(src.AllowedOrigins) },
+	},
+	{name: "trust_forwarded_headers", value: func(c Config) string { return fmt.Sprint(c.TrustForwardedHeaders) }},
+	{name: "trusted_proxies", value: func(c Config) string { return fmt.Sprint(c.TrustedProxies) }},
+	{
+		name:   "admin_token",
+		secret: true,
+		value:  func(c Config) string { return c.AdminToken },
+		apply:  func(dst *Config, src Config) { dst.AdminToken = src.AdminToken },
+	},
+	{
+		name:  "strict_validation",
+		value: func(c Config) string { return fmt.Sprint(c.StrictValidation) },
+		apply: func(dst *Config, src Config) { dst.StrictValidation = src.StrictValidation },
+	},
+	{name: "secrets_key", secret: true, value: func(c Config) string { return c.SecretsKey }},
+	{
+		name:  "rate_window",
+		value: func(c Config) string { return c.RateWindow.String() },
+		apply: func(dst *Config, src Config) { dst.RateWindow = src.RateWindow },
+	},
+	{
+		name:  "global_max_rps",
+		value: func(c Config) string { return fmt.Sprint(c.GlobalMaxRPS) },
+		apply: func(dst *Config, src Config) { dst.GlobalMaxRPS = src.GlobalMaxRPS },
+	},
+	{
+		name:  "default_request_timeout",
+		value: func(c Config) string { return c.DefaultRequestTimeout.String() },
+		apply: func(dst *Config, src Config) { dst.DefaultRequestTimeout = src.DefaultRequestTimeout },
+	},
+	{
+		name:  "demo_data",
+		value: func(c Config) string { return fmt.Sprint(c.DemoData) },
+		apply: func(dst *Config, src Config) { dst.DemoData = src.DemoData },
+	},
+	{
+		name:  "max_concurrency",
+		value: func(c Config) string { return fmt.Sprint(c.MaxConcurrency) },
+		apply: func(dst *Config, src Config) { dst.MaxConcurrency = src.MaxConcurrency },
+	},
+	{
+		name:  "max_requests",
+		value: func(c Config) string { return fmt.Sprint(c.MaxRequests) },
+		apply: func(dst *Config, src Config) { dst.MaxRequests = src.MaxRequests },
+	},
+	{
+		name:  "default_seed",
+		value: func(c Config) string { return fmt.Sprint(c.DefaultSeed) },
+		apply: func(dst *Config, src Config) { dst.DefaultSeed = src.DefaultSeed },
+	},
+	{
+		name:  "log.level",
+		value: func(c Config) string { return c.Log.Level },
+		apply: func(dst *Config, src Config) { dst.Log.Level = src.Log.Level },
+	},
+	{name: "log.human_readable", value: func(c Config) string { return fmt.Sprint(c.Log.HumanReadable) }},
+	{
+		name:  "log.request_sample_rate",
+		value: func(c Config) string { return fmt.Sprint(c.Log.RequestSampleRate) },
+		apply: func(dst *Config, src Config) { dst.Log.RequestSampleRate = src.Log.RequestSampleRate },
+	},
+	{name: "database.connect_attempts", value: func(c Config) string { return fmt.Sprint(c.Database.ConnectAttempts) }},
+	{name: "database.connect_backoff", value: func(c Config) string { return c.Database.ConnectBackoff.String() }},
+	{name: "database.degraded_mode", value: func(c Config) string { return fmt.Sprint(c.Database.DegradedMode) }},
+	{name: "database.compression", value: func(c Config) string { return c.Database.Compression }},
+	{name: "records.dir", value: func(c Config) string { return c.Records.Dir }},
+	{name: "body_files.dir", value: func(c Config) string { return c.BodyFiles.Dir }},
+	{name: "json.max_depth", value: func(c Config) string { return fmt.Sprint(c.JSON.MaxDepth) }},
+	{name: "json.max_elements", value: func(c Config) string { return fmt.Sprint(c.JSON.MaxElements) }},
+	{name: "statsd.addr", value: func(c Config) string { return c.StatsD.Addr }},
+	{name: "statsd.prefix", value: func(c Config) string { return c.StatsD.Prefix }},
+	{name: "statsd.dogstatsd", value: func(c Config) string { return fmt.Sprint(c.StatsD.DogStatsD) }},
+	{name: "statsd.tags", value: func(c Config) string { return fmt.Sprint(c.StatsD.Tags) }},
+	{name: "statsd.buffer", value: func(c Config) string { return fmt.Sprint(c.StatsD.Buffer) }},
+}
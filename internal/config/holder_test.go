@@ -0,0 +1,207 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const baseConfig = `
+addr: ":4001"
+dsn: "user:first@tcp(localhost:3306)/db"
+admin_token: "first-token"
+max_requests: 100
+default_seed: 1
+log:
+  level: "info"
+database:
+  compression: "gzip"
+statsd:
+  addr: ""
+  prefix: "performance_analyzer"
+`
+
+const changedConfig = `
+addr: ":4002"
+dsn: "user:second@tcp(localhost:3306)/db"
+admin_token: "second-token"
+max_requests: 200
+default_seed: 42
+log:
+  level: "debug"
+database:
+  compression: "zstd"
+statsd:
+  addr: "localhost:8125"
+  prefix: "analyzer"
+`
+
+// inConfigDir runs the test from a directory of its own, the config file
+// being read from the current directory.
+func inConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	previous, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(previous) })
+	return dir
+}
+
+func writeConfig(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := inConfigDir(t)
+	writeConfig(t, dir, baseConfig)
+	holder := NewHolder(GetConfig())
+
+	writeConfig(t, dir, changedConfig)
+	changes, err := holder.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]Change, len(changes))
+	for _, change := range changes {
+		byName[change.Setting] = change
+	}
+	want := map[string]Change{
+		"addr":                 {Setting: "addr", Old: ":4001", New: ":4002", Restart: true},
+		"dsn":                  {Setting: "dsn", Restart: true},
+		"admin_token":          {Setting: "admin_token"},
+		"max_requests":         {Setting: "max_requests", Old: "100", New: "200"},
+		"default_seed":         {Setting: "default_seed", Old: "1", New: "42"},
+		"log.level":            {Setting: "log.level", Old: "info", New: "debug"},
+		"database.compression": {Setting: "database.compression", Old: "gzip", New: "zstd", Restart: true},
+		"statsd.addr":          {Setting: "statsd.addr", Old: "", New: "localhost:8125", Restart: true},
+		"statsd.prefix":        {Setting: "statsd.prefix", Old: "performance_analyzer", New: "analyzer", Restart: true},
+	}
+	if !reflect.DeepEqual(byName, want) {
+		t.Errorf("changes = %+v, want %+v", byName, want)
+	}
+
+	cfg := holder.Get()
+	// The reloadable settings are applied.
+	if cfg.MaxRequests != 200 || cfg.DefaultSeed != 42 || cfg.Log.Level != "debug" || cfg.AdminToken != "second-token" {
+		t.Errorf("reloadable settings = %d, %d, %q, %q, want the new ones", cfg.MaxRequests, cfg.DefaultSeed, cfg.Log.Level, cfg.AdminToken)
+	}
+	// The others keep their value until the restart.
+	if cfg.Addr != ":4001" || cfg.DSN != "user:first@tcp(localhost:3306)/db" || cfg.Database.Compression != "gzip" || cfg.StatsD.Addr != "" || cfg.StatsD.Prefix != "performance_analyzer" {
+		t.Errorf("restart-only settings = %q, %q, %q, %q, %q, want the old ones", cfg.Addr, cfg.DSN, cfg.Database.Compression, cfg.StatsD.Addr, cfg.StatsD.Prefix)
+	}
+
+	// Reloading the same file again only reports the restart-only settings again.
+	changes, err = holder.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if !change.Restart {
+			t.Errorf("%s changed again on the second reload", change.Setting)
+		}
+	}
+}
+
+func TestReloadUnreadable(t *testing.T) {
+	dir := inConfigDir(t)
+	writeConfig(t, dir, baseConfig)
+	holder := NewHolder(GetConfig())
+
+	writeConfig(t, dir, "max_requests: [")
+	if _, err := holder.Reload(); err == nil {
+		t.Fatal("a broken config file was reloaded, want an error")
+	}
+	if got := holder.Get().MaxRequests; got != 100 {
+		t.Errorf("max_requests = %d after a failed reload, want 100", got)
+	}
+}
+
+func TestReloadSecrets(t *testing.T) {
+	dir := inConfigDir(t)
+	writeConfig(t, dir, baseConfig+`secrets_key: "first-key"`)
+	holder := NewHolder(GetConfig())
+
+	// Every secret changes, along with other settings.
+	writeConfig(t, dir, changedConfig+`secrets_key: "second-key"`)
+	changes, err := holder.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets := make(map[string]bool)
+	for _, s := range settings {
+		if s.secret {
+			secrets[s.name] = true
+		}
+	}
+	var reported int
+	for _, change := range changes {
+		if !secrets[change.Setting] {
+			continue
+		}
+		reported++
+		if change.Old != "" || change.New != "" {
+			t.Errorf("change of %s = %+v, want its values left out", change.Setting, change)
+		}
+		if strings.Contains(change.String(), "first") || strings.Contains(change.String(), "second") {
+			t.Errorf("%s is logged as %q", change.Setting, change)
+		}
+	}
+	if reported != len(secrets) {
+		t.Errorf("%d secrets reported as changed, want %d", reported, len(secrets))
+	}
+}
+
+// TestSettingsCoverConfig fails on a key of the config file that is neither
+// reloaded nor reported as needing a restart.
+func TestSettingsCoverConfig(t *testing.T) {
+	registered := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		if registered[s.name] {
+			t.Errorf("%s is registered twice", s.name)
+		}
+		registered[s.name] = true
+	}
+
+	keys := configKeys(reflect.TypeOf(Config{}), "")
+	for _, key := range keys {
+		if !registered[key] {
+			t.Errorf("%s is missing from the settings", key)
+		}
+		delete(registered, key)
+	}
+	for name := range registered {
+		t.Errorf("%s is registered but isn't a key of the config", name)
+	}
+}
+
+// configKeys returns the keys of the config file decoded into typ, the keys
+// of the nested sections being prefixed with the name of their section.
+func configKeys(typ reflect.Type, prefix string) []string {
+	var keys []string
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name, ok := field.Tag.Lookup("mapstructure")
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Time{}) {
+			keys = append(keys, configKeys(field.Type, prefix+name+".")...)
+			continue
+		}
+		keys = append(keys, prefix+name)
+	}
+	return keys
+}
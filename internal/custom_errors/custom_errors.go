@@ -5,3 +5,14 @@ import "errors"
 var ErrNoRecord = errors.New("model: no matching record found")
 var ErrInvalidInput = errors.New("model: invalid input")
 var ErrEnvironmentDisabled = errors.New("model: environment is disabled")
+var ErrResolverUnreachable = errors.New("model: resolver is unreachable")
+var ErrInvalidBaseline = errors.New("model: baseline must be a finished worker of the same environment")
+var ErrTooLargeForSync = errors.New("model: run is too large to wait for, poll it instead")
+var ErrMaintenanceWindow = errors.New("model: environment is in a maintenance window")
+var ErrSchemaViolation = errors.New("model: body does not match the schema of the environment")
+var ErrNotStartable = errors.New("model: worker was already started")
+var ErrQuotaExceeded = errors.New("model: daily request quota of the environment is exceeded")
+var ErrDoubtfulPlan = errors.New("model: configuration of the run is doubtful")
+var ErrExclusiveRun = errors.New("model: run conflicts with an exclusive run of the environment")
+var ErrDuplicateName = errors.New("model: name is already taken")
+var ErrShuttingDown = errors.New("model: server is shutting down")
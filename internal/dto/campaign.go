@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+type CampaignInput struct {
+	Template       *entity.Worker `json:"template"`        // create worker payload, without environment_id
+	EnvironmentIDs []int          `json:"environment_ids"` // one worker is created per environment
+}
+
+// CampaignResponse is the representation of a campaign in the API. The
+// comparison is only set once the run of every worker is over.
+type CampaignResponse struct {
+	ID         int                      `json:"id"`
+	Status     entity.Status            `json:"status"`
+	CreatedAt  time.Time                `json:"created_at"`
+	Progress   *entity.CampaignProgress `json:"progress"`
+	Workers    []*CampaignWorker        `json:"workers"`
+	Comparison []*entity.CampaignEntry  `json:"comparison,omitempty"`
+}
+
+// CampaignWorker is the summary of a worker of a campaign, the worker
+// itself being available at /v1/workers/{id}.
+type CampaignWorker struct {
+	ID            int              `json:"id"`
+	EnvironmentID int              `json:"environment_id"`
+	Status        entity.Status    `json:"status"`
+	Progress      *entity.Progress `json:"progress,omitempty"`
+}
+
+// NewCampaignResponse maps a campaign to its API representation, nil for a nil campaign.
+func NewCampaignResponse(campaign *entity.Campaign) *CampaignResponse {
+	if campaign == nil {
+		return nil
+	}
+
+	workers := make([]*CampaignWorker, 0, len(campaign.Workers))
+	for _, worker := range campaign.Workers {
+		workers = append(workers, &CampaignWorker{
+			ID:            worker.ID,
+			EnvironmentID: worker.EnvironmentID,
+			Status:        worker.Status,
+			Progress:      worker.Progress,
+		})
+	}
+
+	return &CampaignResponse{
+		ID:         campaign.ID,
+		Status:     campaign.Status(),
+		CreatedAt:  campaign.CreatedAt,
+		Progress:   campaign.Progress(),
+		Workers:    workers,
+		Comparison: campaign.Comparison(),
+	}
+}
@@ -1,19 +1,125 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
 type CreateEnvironmentInput struct {
-	Name          string  `json:"name"`
-	Endpoint      string  `json:"endpoint"`
-	TokenEndpoint *string `json:"token_endpoint"`
-	Username      *string `json:"username"`
-	Password      *string `json:"password"`
-	Disabled      *bool   `json:"disabled"`
+	Name               string                     `json:"name"`
+	Endpoint           string                     `json:"endpoint"`
+	TokenEndpoint      *string                    `json:"token_endpoint"`
+	Username           *string                    `json:"username"`
+	Password           *string                    `json:"password"`
+	Disabled           *bool                      `json:"disabled"`
+	MaintenanceWindows []entity.MaintenanceWindow `json:"maintenance_windows"`
+	MaintenancePolicy  entity.MaintenancePolicy   `json:"maintenance_policy"`
+	DailyRequestQuota  int                        `json:"daily_request_quota"` // unlimited when 0
+	WorkerDefaults     entity.WorkerDefaults      `json:"default_worker_settings"`
+	DefaultTimeout     entity.Duration            `json:"default_timeout"` // of the requests of the workers setting none, the global one when 0
+	AutoDisable        *entity.AutoDisablePolicy  `json:"auto_disable"`    // disabled after repeated failed runs, never when null
 }
 
 type UpdateEnvironmentInput struct {
-	Name          *string `json:"name"`
-	Endpoint      *string `json:"endpoint"`
-	TokenEndpoint *string `json:"token"`
-	Username      *string `json:"username"`
-	Password      *string `json:"password"`
-	Disabled      *bool   `json:"disabled"`
+	Name               *string                     `json:"name"`
+	Endpoint           *string                     `json:"endpoint"`
+	TokenEndpoint      *string                     `json:"token"`
+	Username           *string                     `json:"username"`
+	Password           *string                     `json:"password"`
+	Disabled           *bool                       `json:"disabled"`
+	MaintenanceWindows *[]entity.MaintenanceWindow `json:"maintenance_windows"` // an empty list removes every window
+	MaintenancePolicy  *entity.MaintenancePolicy   `json:"maintenance_policy"`
+	DailyRequestQuota  *int                        `json:"daily_request_quota"`     // 0 removes the quota
+	WorkerDefaults     *entity.WorkerDefaults      `json:"default_worker_settings"` // an empty object removes every default
+	DefaultTimeout     *entity.Duration            `json:"default_timeout"`         // "0s" falls back to the global one
+	AutoDisable        *entity.AutoDisablePolicy   `json:"auto_disable"`            // an after_runs of 0 removes the policy
+}
+
+type SetBaselineInput struct {
+	WorkerID int `json:"worker_id"`
+}
+
+type SweepInput struct {
+	Method  string           `json:"method"`  // HEAD when empty
+	Timeout *entity.Duration `json:"timeout"` // of every request
+	Budget  *int             `json:"budget"`  // most requests sent by the sweep, unlimited when null
+}
+
+type SetBodySchemaInput struct {
+	Schema *entity.BodySchema `json:"schema"` // null removes the schema
+}
+
+// DemoDataInput sizes the demo data, the fields left out getting the defaults.
+type DemoDataInput struct {
+	Environments          int    `json:"environments"`
+	WorkersPerEnvironment int    `json:"workers_per_environment"`
+	Seed                  *int64 `json:"seed"` // the same seed generates the same data
+}
+
+// EnvironmentResponse is the representation of an environment in the API.
+// The credentials are never sent back, only whether they are set.
+type EnvironmentResponse struct {
+	ID                 int                        `json:"id"`
+	Name               string                     `json:"name"`
+	Endpoint           string                     `json:"endpoint"`
+	TokenEndpoint      string                     `json:"token_endpoint,omitempty"`
+	Username           string                     `json:"username,omitempty"`
+	HasPassword        bool                       `json:"has_password"`
+	Disabled           bool                       `json:"disabled"`
+	DisabledReason     string                     `json:"disabled_reason,omitempty"`
+	BaselineWorkerID   *int                       `json:"baseline_worker_id,omitempty"`
+	BodySchema         *entity.BodySchema         `json:"body_schema,omitempty"`
+	MaintenanceWindows []entity.MaintenanceWindow `json:"maintenance_windows,omitempty"`
+	MaintenancePolicy  entity.MaintenancePolicy   `json:"maintenance_policy"`
+	DailyRequestQuota  int                        `json:"daily_request_quota,omitempty"`
+	WorkerDefaults     entity.WorkerDefaults      `json:"default_worker_settings,omitempty"`
+	DefaultTimeout     entity.Duration            `json:"default_timeout,omitempty"`
+	AutoDisable        *entity.AutoDisablePolicy  `json:"auto_disable,omitempty"`
+	Demo               bool                       `json:"demo,omitempty"`
+	LastAuthVerifiedAt *time.Time                 `json:"last_auth_verified_at,omitempty"`
+	CreatedAt          time.Time                  `json:"created_at"`
+}
+
+// NewEnvironmentResponse maps an environment to its API representation, nil for a nil environment.
+func NewEnvironmentResponse(environment *entity.Environment) *EnvironmentResponse {
+	if environment == nil {
+		return nil
+	}
+
+	policy := environment.MaintenancePolicy
+	if policy == "" {
+		policy = entity.MaintenanceReject
+	}
+
+	return &EnvironmentResponse{
+		ID:                 environment.ID,
+		Name:               environment.Name,
+		Endpoint:           environment.Endpoint,
+		TokenEndpoint:      environment.TokenEndpoint,
+		Username:           environment.Username,
+		HasPassword:        environment.Password != "" || environment.BasicAuthToken != "",
+		Disabled:           environment.Disabled,
+		DisabledReason:     environment.DisabledReason,
+		BaselineWorkerID:   environment.BaselineWorkerID,
+		BodySchema:         environment.BodySchema,
+		MaintenanceWindows: environment.MaintenanceWindows,
+		MaintenancePolicy:  policy,
+		DailyRequestQuota:  environment.DailyRequestQuota,
+		WorkerDefaults:     environment.WorkerDefaults,
+		DefaultTimeout:     environment.DefaultTimeout,
+		AutoDisable:        environment.AutoDisable,
+		Demo:               environment.Demo,
+		LastAuthVerifiedAt: environment.LastAuthVerifiedAt,
+		CreatedAt:          environment.CreatedAt,
+	}
+}
+
+// NewEnvironmentResponses maps a list of environments, an empty list for none.
+func NewEnvironmentResponses(environments []*entity.Environment) []*EnvironmentResponse {
+	responses := make([]*EnvironmentResponse, 0, len(environments))
+	for _, environment := range environments {
+		responses = append(responses, NewEnvironmentResponse(environment))
+	}
+	return responses
 }
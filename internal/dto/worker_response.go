@@ -0,0 +1,377 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// WorkerResponse is the representation of a worker in the API, decoupled
+// from entity.Worker so the storage can change without breaking clients.
+// Configured durations are Go duration strings, as in the create payload,
+// measured times are in seconds and rates in requests per second.
+type WorkerResponse struct {
+	ID                      int                          `json:"id"`
+	EnvironmentID           int                          `json:"environment_id"`
+	CampaignID              *int                         `json:"campaign_id,omitempty"`
+	Status                  entity.Status                `json:"status"`
+	CreatedAt               time.Time                    `json:"created_at"`
+	UpdatedAt               time.Time                    `json:"updated_at"`
+	FinishedAt              *time.Time                   `json:"finished_at,omitempty"`
+	Mode                    entity.Mode                  `json:"mode"`
+	Concurrency             int                          `json:"concurrency"`
+	RequestsPerTask         int                          `json:"requests_per_task"`
+	Report                  string                       `json:"report"`
+	HTTPMethod              string                       `json:"http_method"`
+	Body                    *json.RawMessage             `json:"body,omitempty"`
+	BodyContentType         string                       `json:"body_content_type,omitempty"`
+	BodyVariants            []entity.BodyVariant         `json:"body_variants,omitempty"`
+	VariantSelection        entity.VariantSelection      `json:"variant_selection,omitempty"`
+	TargetRPS               float64                      `json:"target_rps,omitempty"`
+	MinThroughputRatio      float64                      `json:"min_throughput_ratio,omitempty"`
+	RampConfig              *entity.RampConfig           `json:"ramp_config,omitempty"`
+	RampResult              *entity.RampResult           `json:"ramp_result,omitempty"`
+	SoakConfig              *entity.SoakConfig           `json:"soak_config,omitempty"`
+	SoakResult              *entity.SoakResult           `json:"soak_result,omitempty"`
+	SpikeConfig             *entity.SpikeConfig          `json:"spike_config,omitempty"`
+	SpikeResult             *entity.SpikeResult          `json:"spike_result,omitempty"`
+	AutoTuneConfig          *entity.AutoTuneConfig       `json:"auto_tune_config,omitempty"`
+	AutoTuneResult          *entity.AutoTuneResult       `json:"auto_tune_result,omitempty"`
+	RampUp                  entity.Duration              `json:"ramp_up,omitempty"`
+	RampUpJitter            float64                      `json:"ramp_up_jitter,omitempty"`
+	RampDown                entity.Duration              `json:"ramp_down,omitempty"`
+	ThinkTime               *entity.Duration             `json:"think_time,omitempty"`
+	ThinkTimeJitter         float64                      `json:"think_time_jitter,omitempty"`
+	OnResourceExhaustion    entity.ExhaustionPolicy      `json:"on_resource_exhaustion,omitempty"`
+	CircuitBreaker          *entity.CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+	Retry                   *entity.RetryConfig          `json:"retry,omitempty"`
+	Resolver                string                       `json:"resolver,omitempty"`
+	CaptureQuotas           entity.CaptureQuotas         `json:"capture_quotas,omitempty"`
+	CapturedResponses       []*entity.CapturedResponse   `json:"captured_responses,omitempty"`
+	PersistSamples          bool                         `json:"persist_samples,omitempty"`
+	SampleCap               int                          `json:"sample_cap,omitempty"`
+	RecordRequests          bool                         `json:"record_requests,omitempty"`
+	RecordFile              string                       `json:"record_file,omitempty"`
+	KeepAlive               *entity.KeepAliveConfig      `json:"keep_alive,omitempty"`
+	Identities              *entity.IdentityConfig       `json:"identities,omitempty"`
+	DataSource              *DataSourceResponse          `json:"data_source,omitempty"`
+	ScenarioID              *int                         `json:"scenario_id,omitempty"`
+	BodyStream              *entity.BodyStream           `json:"body_stream,omitempty"`
+	Provenance              entity.Provenance            `json:"provenance,omitempty"` // where every setting came from, when the environment has defaults
+	CorrelationHeader       string                       `json:"correlation_header,omitempty"`
+	LogSampleRate           int                          `json:"log_sample_rate,omitempty"`
+	MeasureColdRequests     bool                         `json:"measure_cold_requests,omitempty"`
+	LatencyFailureThreshold entity.Duration              `json:"latency_failure_threshold,omitempty"`
+	SlowRequestPolicy       entity.SlowRequestPolicy     `json:"slow_request_policy,omitempty"`
+	FollowRedirects         *bool                        `json:"follow_redirects,omitempty"`
+	Status3xx               entity.RedirectPolicy        `json:"status_3xx,omitempty"`
+	Timeouts                *entity.RequestTimeouts      `json:"timeouts,omitempty"`
+	Exclusive               bool                         `json:"exclusive,omitempty"`
+	SuccessHeader           *entity.HeaderRule           `json:"success_header,omitempty"`
+	BodyAssertion           *entity.BodyAssertion        `json:"body_assertion,omitempty"`
+	ServedByHeaders         []string                     `json:"served_by_headers,omitempty"`
+	Tags                    []string                     `json:"tags,omitempty"`
+	Seed                    int64                        `json:"seed,omitempty"`
+	ResolvedURL             string                       `json:"resolved_url,omitempty"` // where the first request is sent, in a dry run
+	ConfigEcho              *ConfigEcho                  `json:"config_echo,omitempty"`
+	ExpectedRequests        *int                         `json:"expected_requests"`
+	Progress                *entity.Progress             `json:"progress,omitempty"`
+	Comparison              *entity.Comparison           `json:"comparison,omitempty"`
+	Warnings                []string                     `json:"warnings,omitempty"`
+	Metrics                 *MetricsResponse             `json:"metrics"`
+}
+
+// ConfigEcho is what the configuration of a worker rendered to once it ran.
+type ConfigEcho struct {
+	FirstRequest *entity.RenderedRequest `json:"first_request,omitempty"` // its secrets redacted
+}
+
+// newConfigEcho returns the echo of a worker, nil until it sent a request.
+func newConfigEcho(worker *entity.Worker) *ConfigEcho {
+	if worker.FirstRequest == nil {
+		return nil
+	}
+	return &ConfigEcho{FirstRequest: worker.FirstRequest}
+}
+
+// WorkerSummaryResponse is the lean representation of a run, its numbers
+// without its configuration. Latencies are in seconds.
+type WorkerSummaryResponse struct {
+	ID                int                               `json:"id"`
+	Status            entity.Status                     `json:"status"`
+	Final             bool                              `json:"final"` // the run is over, its numbers won't change
+	FinishedAt        *time.Time                        `json:"finished_at,omitempty"`
+	Progress          *entity.Progress                  `json:"progress,omitempty"`
+	TotalRequests     int                               `json:"total_requests"`
+	FailedRequests    int                               `json:"failed_requests"`
+	CancelledRequests int                               `json:"cancelled_requests"`
+	ErrorRate         float64                           `json:"error_rate"`
+	Throughput        float64                           `json:"throughput"`
+	MaxLatency        float64                           `json:"max_latency"`
+	Percentiles       map[entity.PercentileRank]float64 `json:"percentiles"`
+}
+
+// NewWorkerSummaryResponse maps a worker to its summary, nil for a nil worker.
+// The metrics of a run still in progress are the ones persisted so far.
+func NewWorkerSummaryResponse(worker *entity.Worker) *WorkerSummaryResponse {
+	if worker == nil {
+		return nil
+	}
+
+	summary := &WorkerSummaryResponse{
+		ID:          worker.ID,
+		Status:      worker.Status,
+		Final:       worker.Status.Over(),
+		FinishedAt:  worker.FinishedAt,
+		Progress:    worker.Progress,
+		Percentiles: map[entity.PercentileRank]float64{},
+	}
+	if metrics := NewMetricsResponse(worker.Metrics); metrics != nil {
+		summary.TotalRequests = metrics.TotalRequests
+		summary.FailedRequests = metrics.FailedRequests
+		summary.CancelledRequests = metrics.CancelledRequests
+		summary.ErrorRate = metrics.ErrorRate
+		summary.Throughput = metrics.Throughput
+		summary.MaxLatency = metrics.MaxLatency
+		summary.Percentiles = metrics.Percentiles
+	}
+	return summary
+}
+
+// MetricsResponse is the representation of the metrics of a run in the API.
+// Latencies are in seconds.
+type MetricsResponse struct {
+	TotalRequests        int                                         `json:"total_requests"`
+	FailedRequests       int                                         `json:"failed_requests"`
+	CancelledRequests    int                                         `json:"cancelled_requests"`
+	SlowRequests         int                                         `json:"slow_requests,omitempty"`
+	NeutralRequests      int                                         `json:"neutral_requests,omitempty"`
+	ErrorRate            float64                                     `json:"error_rate"`
+	Throughput           float64                                     `json:"throughput"`
+	EffectiveConcurrency float64                                     `json:"effective_concurrency"`
+	MaxLatency           float64                                     `json:"max_latency"`
+	Percentiles          map[entity.PercentileRank]float64           `json:"percentiles"`
+	ErrorClasses         map[entity.ErrorClass]int                   `json:"error_classes,omitempty"`
+	StatusClasses        map[entity.ResponseClass]int                `json:"status_classes,omitempty"`
+	Diagnostics          []string                                    `json:"diagnostics,omitempty"`
+	Phases               map[entity.Phase]*PhaseMetricsResponse      `json:"phases,omitempty"`
+	CircuitOpenTime      float64                                     `json:"circuit_open_time,omitempty"`
+	CircuitOpenings      int                                         `json:"circuit_openings,omitempty"`
+	GlobalThrottleTime   float64                                     `json:"global_throttle_time,omitempty"`
+	DNSLookups           int                                         `json:"dns_lookups,omitempty"`
+	AvgDNSLatency        float64                                     `json:"avg_dns_latency,omitempty"`
+	MaxDNSLatency        float64                                     `json:"max_dns_latency,omitempty"`
+	ResolvedAddresses    map[string]int                              `json:"resolved_addresses,omitempty"`
+	ProtocolVersions     map[string]int                              `json:"protocol_versions,omitempty"`
+	ServedBy             map[string]map[string]int                   `json:"served_by,omitempty"`
+	Breakdown            []entity.StageTiming                        `json:"breakdown,omitempty"`
+	SamplesSeen          int                                         `json:"samples_seen,omitempty"`
+	SamplesStored        int                                         `json:"samples_stored,omitempty"`
+	KeepAlivePings       int                                         `json:"keep_alive_pings,omitempty"`
+	ReconnectsAvoided    int                                         `json:"reconnects_avoided,omitempty"`
+	SlowestRequests      []entity.SlowRequest                        `json:"slowest_requests,omitempty"`
+	Variants             map[string]*MetricsResponse                 `json:"variants,omitempty"`
+	Connections          map[entity.Connection]*PhaseMetricsResponse `json:"connections,omitempty"`
+}
+
+// PhaseMetricsResponse is the representation of the metrics of one phase of a run in the API.
+type PhaseMetricsResponse struct {
+	TotalRequests     int                               `json:"total_requests"`
+	FailedRequests    int                               `json:"failed_requests"`
+	CancelledRequests int                               `json:"cancelled_requests"`
+	SlowRequests      int                               `json:"slow_requests,omitempty"`
+	NeutralRequests   int                               `json:"neutral_requests,omitempty"`
+	ErrorRate         float64                           `json:"error_rate"`
+	MaxLatency        float64                           `json:"max_latency"`
+	Percentiles       map[entity.PercentileRank]float64 `json:"percentiles"`
+}
+
+// NewWorkerResponse maps a worker to its API representation, nil for a nil worker.
+func NewWorkerResponse(worker *entity.Worker) *WorkerResponse {
+	if worker == nil {
+		return nil
+	}
+
+	mode := worker.Mode
+	if mode == "" {
+		mode = entity.ModeFixed
+	}
+
+	return &WorkerResponse{
+		ID:                      worker.ID,
+		EnvironmentID:           worker.EnvironmentID,
+		CampaignID:              worker.CampaignID,
+		Status:                  worker.Status,
+		CreatedAt:               worker.CreatedAt,
+		UpdatedAt:               worker.UpdatedAt,
+		FinishedAt:              worker.FinishedAt,
+		Mode:                    mode,
+		Concurrency:             worker.Concurrency,
+		RequestsPerTask:         worker.RequestsPerTask,
+		Report:                  worker.Report,
+		HTTPMethod:              worker.HTTPMethod,
+		Body:                    worker.Body,
+		BodyContentType:         worker.BodyContentType,
+		BodyVariants:            worker.BodyVariants,
+		VariantSelection:        worker.VariantSelection,
+		TargetRPS:               worker.TargetRPS,
+		MinThroughputRatio:      worker.MinThroughputRatio,
+		RampConfig:              worker.RampConfig,
+		RampResult:              worker.RampResult,
+		SoakConfig:              worker.SoakConfig,
+		SoakResult:              worker.SoakResult,
+		SpikeConfig:             worker.SpikeConfig,
+		SpikeResult:             worker.SpikeResult,
+		AutoTuneConfig:          worker.AutoTuneConfig,
+		AutoTuneResult:          worker.AutoTuneResult,
+		RampUp:                  worker.RampUp,
+		RampUpJitter:            worker.RampUpJitter,
+		RampDown:                worker.RampDown,
+		ThinkTime:               worker.ThinkTime,
+		ThinkTimeJitter:         worker.ThinkTimeJitter,
+		OnResourceExhaustion:    worker.OnResourceExhaustion,
+		CircuitBreaker:          worker.CircuitBreaker,
+		Retry:                   worker.Retry,
+		Resolver:                worker.Resolver,
+		CaptureQuotas:           worker.CaptureQuotas,
+		CapturedResponses:       worker.CapturedResponses,
+		PersistSamples:          worker.PersistSamples,
+		SampleCap:               worker.SampleCap,
+		RecordRequests:          worker.RecordRequests,
+		RecordFile:              worker.RecordFile,
+		KeepAlive:               worker.KeepAlive,
+		Identities:              worker.Identities,
+		DataSource:              newDataSourceResponse(worker.DataSource),
+		ScenarioID:              worker.ScenarioID,
+		BodyStream:              worker.BodyStream,
+		Provenance:              worker.Provenance,
+		CorrelationHeader:       worker.CorrelationHeader,
+		LogSampleRate:           worker.LogSampleRate,
+		MeasureColdRequests:     worker.MeasureColdRequests,
+		LatencyFailureThreshold: worker.LatencyFailureThreshold,
+		SlowRequestPolicy:       worker.SlowRequestPolicy,
+		FollowRedirects:         worker.FollowRedirects,
+		Status3xx:               worker.Status3xx,
+		Timeouts:                worker.Timeouts,
+		Exclusive:               worker.Exclusive,
+		SuccessHeader:           worker.SuccessHeader,
+		BodyAssertion:           worker.BodyAssertion,
+		ServedByHeaders:         worker.ServedByHeaders,
+		Tags:                    worker.Tags,
+		Seed:                    worker.Seed,
+		ResolvedURL:             worker.ResolvedURL,
+		ConfigEcho:              newConfigEcho(worker),
+		ExpectedRequests:        worker.ExpectedRequests,
+		Progress:                worker.Progress,
+		Comparison:              worker.Comparison,
+		Warnings:                worker.Warnings,
+		Metrics:                 NewMetricsResponse(worker.Metrics),
+	}
+}
+
+// NewWorkerResponses maps a list of workers, an empty list for none.
+func NewWorkerResponses(workers []*entity.Worker) []*WorkerResponse {
+	responses := make([]*WorkerResponse, 0, len(workers))
+	for _, worker := range workers {
+		responses = append(responses, NewWorkerResponse(worker))
+	}
+	return responses
+}
+
+// NewMetricsResponse maps metrics to their API representation, nil for nil metrics.
+func NewMetricsResponse(metrics *entity.Metrics) *MetricsResponse {
+	if metrics == nil {
+		return nil
+	}
+
+	response := &MetricsResponse{
+		TotalRequests:        metrics.TotalRequests,
+		FailedRequests:       metrics.FailedRequests,
+		CancelledRequests:    metrics.CancelledRequests,
+		SlowRequests:         metrics.SlowRequests,
+		NeutralRequests:      metrics.NeutralRequests,
+		ErrorRate:            metrics.ErrorRate,
+		Throughput:           metrics.Throughput,
+		EffectiveConcurrency: metrics.EffectiveConcurrency,
+		MaxLatency:           metrics.MaxLatency,
+		Percentiles:          metrics.Percentiles,
+		ErrorClasses:         metrics.ErrorClasses,
+		StatusClasses:        metrics.StatusClasses,
+		Diagnostics:          metrics.Diagnostics,
+		CircuitOpenTime:      metrics.CircuitOpenTime,
+		CircuitOpenings:      metrics.CircuitOpenings,
+		GlobalThrottleTime:   metrics.GlobalThrottleTime,
+		DNSLookups:           metrics.DNSLookups,
+		AvgDNSLatency:        metrics.AvgDNSLatency,
+		MaxDNSLatency:        metrics.MaxDNSLatency,
+		ResolvedAddresses:    metrics.ResolvedAddresses,
+		ProtocolVersions:     metrics.ProtocolVersions,
+		ServedBy:             metrics.ServedBy,
+		Breakdown:            metrics.Breakdown,
+		SamplesSeen:          metrics.SamplesSeen,
+		SamplesStored:        metrics.SamplesStored,
+		KeepAlivePings:       metrics.KeepAlivePings,
+		ReconnectsAvoided:    metrics.ReconnectsAvoided,
+		SlowestRequests:      metrics.SlowestRequests,
+	}
+	if response.Percentiles == nil {
+		response.Percentiles = map[entity.PercentileRank]float64{}
+	}
+
+	if len(metrics.Phases) > 0 {
+		response.Phases = make(map[entity.Phase]*PhaseMetricsResponse, len(metrics.Phases))
+		for phase, phaseMetrics := range metrics.Phases {
+			response.Phases[phase] = newPhaseMetricsResponse(phaseMetrics)
+		}
+	}
+
+	if len(metrics.Connections) > 0 {
+		response.Connections = make(map[entity.Connection]*PhaseMetricsResponse, len(metrics.Connections))
+		for connection, connectionMetrics := range metrics.Connections {
+			response.Connections[connection] = newPhaseMetricsResponse(connectionMetrics)
+		}
+	}
+
+	if len(metrics.Variants) > 0 {
+		response.Variants = make(map[string]*MetricsResponse, len(metrics.Variants))
+		for name, variantMetrics := range metrics.Variants {
+			response.Variants[name] = NewMetricsResponse(variantMetrics)
+		}
+	}
+
+	return response
+}
+
+// DataSourceResponse echoes the data source of a worker without its
+// secrets nor its CSV, Rows being the number of rows loaded by the run.
+type DataSourceResponse struct {
+	Type  entity.DataSourceType `json:"type"`
+	Query string                `json:"query,omitempty"`
+	Rows  int                   `json:"rows"`
+}
+
+func newDataSourceResponse(source *entity.DataSource) *DataSourceResponse {
+	if source == nil {
+		return nil
+	}
+
+	return &DataSourceResponse{
+		Type:  source.Type,
+		Query: source.Query,
+		Rows:  source.Rows,
+	}
+}
+
+func newPhaseMetricsResponse(metrics *entity.PhaseMetrics) *PhaseMetricsResponse {
+	return &PhaseMetricsResponse{
+		TotalRequests:     metrics.TotalRequests,
+		FailedRequests:    metrics.FailedRequests,
+		CancelledRequests: metrics.CancelledRequests,
+		SlowRequests:      metrics.SlowRequests,
+		NeutralRequests:   metrics.NeutralRequests,
+		ErrorRate:         metrics.ErrorRate,
+		MaxLatency:        metrics.MaxLatency,
+		Percentiles:       metrics.Percentiles,
+	}
+}
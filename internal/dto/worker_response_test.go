@@ -0,0 +1,171 @@
+package dto
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// toJSONObject returns the JSON object v is encoded to.
+func toJSONObject(t *testing.T, v any) map[string]any {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]any
+	if err := json.Unmarshal(data, &object); err != nil {
+		t.Fatal(err)
+	}
+	return object
+}
+
+func sortedKeys(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// The keys of a worker with none of its optional settings are the contract
+// of the API, a change of the entity must not add nor remove any of them.
+func TestWorkerResponseShape(t *testing.T) {
+	environment := entity.NewEnvironment("staging", "http://staging.invalid")
+	environment.Password = "secret"
+	worker := entity.NewWorker(1, 2, 3, http.MethodGet, nil, environment, zerolog.Nop())
+	worker.ID = 7
+	worker.Status = entity.StatusFinished
+	worker.Metrics = entity.NewMetrics()
+	worker.Metrics.Percentiles = nil
+
+	object := toJSONObject(t, NewWorkerResponse(worker))
+
+	want := []string{
+		"concurrency", "created_at", "environment_id", "expected_requests", "http_method", "id",
+		"metrics", "mode", "report", "requests_per_task", "status", "updated_at",
+		// Set by NewWorker to their defaults.
+		"on_resource_exhaustion", "seed", "status_3xx",
+	}
+	slices.Sort(want)
+	if got := sortedKeys(object); !slices.Equal(got, want) {
+		t.Errorf("worker keys = %v, want %v", got, want)
+	}
+	if object["mode"] != string(entity.ModeFixed) {
+		t.Errorf("mode = %v, want the default %s", object["mode"], entity.ModeFixed)
+	}
+
+	metrics, _ := object["metrics"].(map[string]any)
+	wantMetrics := []string{
+		"cancelled_requests", "effective_concurrency", "error_rate", "failed_requests",
+		"max_latency", "percentiles", "throughput", "total_requests",
+	}
+	if got := sortedKeys(metrics); !slices.Equal(got, wantMetrics) {
+		t.Errorf("metrics keys = %v, want %v", got, wantMetrics)
+	}
+	if percentiles, ok := metrics["percentiles"].(map[string]any); !ok || len(percentiles) != 0 {
+		t.Errorf("percentiles = %v, want an empty object rather than null", metrics["percentiles"])
+	}
+}
+
+func TestWorkerResponseUnits(t *testing.T) {
+	thinkTime := entity.Duration(1500 * time.Millisecond)
+	worker := entity.NewWorker(1, 1, 1, http.MethodGet, nil, nil, zerolog.Nop())
+	worker.ThinkTime = &thinkTime
+	worker.RampUp = entity.Duration(time.Minute)
+	worker.Metrics.MaxLatency = 0.25
+	worker.Metrics.Percentiles[entity.P95] = 0.2
+	worker.Metrics.Phases = map[entity.Phase]*entity.PhaseMetrics{
+		entity.PhaseMain: {TotalRequests: 4, FailedRequests: 1, ErrorRate: 0.25},
+	}
+
+	object := toJSONObject(t, NewWorkerResponse(worker))
+
+	// The configured durations are duration strings, the measured ones seconds.
+	if object["think_time"] != "1.5s" || object["ramp_up"] != "1m0s" {
+		t.Errorf("think_time = %v and ramp_up = %v, want duration strings", object["think_time"], object["ramp_up"])
+	}
+	metrics := object["metrics"].(map[string]any)
+	if metrics["max_latency"] != 0.25 {
+		t.Errorf("max_latency = %v, want 0.25 seconds", metrics["max_latency"])
+	}
+	if p95 := metrics["percentiles"].(map[string]any)["95"]; p95 != 0.2 {
+		t.Errorf("p95 = %v, want 0.2 seconds", p95)
+	}
+
+	main, _ := metrics["phases"].(map[string]any)[string(entity.PhaseMain)].(map[string]any)
+	wantPhase := []string{"cancelled_requests", "error_rate", "failed_requests", "max_latency", "percentiles", "total_requests"}
+	if got := sortedKeys(main); !slices.Equal(got, wantPhase) {
+		t.Errorf("phase keys = %v, want %v", got, wantPhase)
+	}
+}
+
+func TestNilResponses(t *testing.T) {
+	if NewWorkerResponse(nil) != nil || NewMetricsResponse(nil) != nil || NewEnvironmentResponse(nil) != nil {
+		t.Error("nil entities mapped to a response, want nil")
+	}
+
+	// A worker loaded before it ran has no metrics, they are null rather than zeros.
+	worker := entity.NewWorker(1, 1, 1, http.MethodGet, nil, nil, zerolog.Nop())
+	worker.Metrics = nil
+	if object := toJSONObject(t, NewWorkerResponse(worker)); object["metrics"] != nil {
+		t.Errorf("metrics = %v, want null", object["metrics"])
+	}
+
+	if got := NewWorkerResponses(nil); got == nil || len(got) != 0 {
+		t.Errorf("NewWorkerResponses(nil) = %v, want an empty list", got)
+	}
+}
+
+func TestEnvironmentResponseHidesCredentials(t *testing.T) {
+	environment := entity.NewEnvironment("staging", "http://staging.invalid")
+	environment.ID = 3
+	environment.Username = "loadtest"
+	environment.Password = "secret"
+	environment.BasicAuthToken = "Basic bG9hZHRlc3Q6c2VjcmV0"
+
+	object := toJSONObject(t, NewEnvironmentResponse(environment))
+
+	if object["has_password"] != true {
+		t.Errorf("has_password = %v, want true", object["has_password"])
+	}
+	data, _ := json.Marshal(object)
+	for _, secret := range []string{environment.Password, environment.BasicAuthToken} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("response %s exposes the credentials", data)
+		}
+	}
+	if object["maintenance_policy"] != string(entity.MaintenanceReject) {
+		t.Errorf("maintenance_policy = %v, want the default %s", object["maintenance_policy"], entity.MaintenanceReject)
+	}
+}
+
+func TestWorkerResponseTimestamps(t *testing.T) {
+	worker := entity.NewWorker(1, 2, 3, http.MethodGet, nil, nil, zerolog.Nop())
+	worker.CreatedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	worker.UpdatedAt = worker.CreatedAt.Add(90 * time.Second)
+
+	// finished_at is left out while the run isn't over.
+	object := toJSONObject(t, NewWorkerResponse(worker))
+	if object["created_at"] != "2024-05-01T10:00:00Z" || object["updated_at"] != "2024-05-01T10:01:30Z" {
+		t.Errorf("created_at = %v and updated_at = %v, want RFC 3339 in UTC", object["created_at"], object["updated_at"])
+	}
+	if _, ok := object["finished_at"]; ok {
+		t.Errorf("finished_at = %v, want it left out", object["finished_at"])
+	}
+
+	finishedAt := worker.UpdatedAt
+	worker.FinishedAt = &finishedAt
+	object = toJSONObject(t, NewWorkerResponse(worker))
+	if object["finished_at"] != "2024-05-01T10:01:30Z" {
+		t.Errorf("finished_at = %v, want RFC 3339 in UTC", object["finished_at"])
+	}
+}
@@ -0,0 +1,38 @@
+package entity
+
+// StorageStats tells how much the compression of the blob columns saves.
+type StorageStats struct {
+	Compression string       `json:"compression"` // the algorithm the columns are written with
+	Columns     []*BlobStats `json:"columns"`
+	StoredBytes int          `json:"stored_bytes"`
+	RawBytes    int          `json:"raw_bytes"`
+}
+
+// BlobStats are the sizes of the non-null values of a blob column.
+type BlobStats struct {
+	Column         string `json:"column"`
+	Rows           int    `json:"rows"`
+	CompressedRows int    `json:"compressed_rows"` // the others were written uncompressed
+	StoredBytes    int    `json:"stored_bytes"`
+	RawBytes       int    `json:"raw_bytes"` // of the JSON they hold
+}
+
+// Add counts a value of the column, stored in stored bytes for raw bytes of JSON.
+func (s *BlobStats) Add(stored, raw int, compressed bool) {
+	s.Rows++
+	s.StoredBytes += stored
+	s.RawBytes += raw
+	if compressed {
+		s.CompressedRows++
+	}
+}
+
+// NewStorageStats totals the stats of the columns written with compression.
+func NewStorageStats(compression string, columns []*BlobStats) *StorageStats {
+	stats := &StorageStats{Compression: compression, Columns: columns}
+	for _, column := range columns {
+		stats.StoredBytes += column.StoredBytes
+		stats.RawBytes += column.RawBytes
+	}
+	return stats
+}
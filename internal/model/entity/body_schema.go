@@ -0,0 +1,214 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DefaultBodyContentType is sent when a worker doesn't set its own.
+const DefaultBodyContentType = "application/json"
+
+// Types of a BodySchema.
+const (
+	SchemaObject  = "object"
+	SchemaArray   = "array"
+	SchemaString  = "string"
+	SchemaNumber  = "number"
+	SchemaInteger = "integer"
+	SchemaBoolean = "boolean"
+	SchemaNull    = "null"
+)
+
+// BodySchema is the subset of JSON Schema the request bodies of an
+// environment are checked against: type, properties, required,
+// additionalProperties, items, enum and the length and range bounds.
+// Other keywords are ignored.
+type BodySchema struct {
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*BodySchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *BodySchema            `json:"items,omitempty"`
+	Enum                 []any                  `json:"enum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+}
+
+// SchemaError is a body not matching a BodySchema, Path locating the
+// offending value, e.g. $.items[2].id.
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// Valid reports whether every type of the schema is known.
+func (s *BodySchema) Valid() bool {
+	switch s.Type {
+	case "", SchemaObject, SchemaArray, SchemaString, SchemaNumber, SchemaInteger, SchemaBoolean, SchemaNull:
+	default:
+		return false
+	}
+	for _, property := range s.Properties {
+		if property == nil || !property.Valid() {
+			return false
+		}
+	}
+	return s.Items == nil || s.Items.Valid()
+}
+
+// Validate checks body against the schema, the error being a *SchemaError
+// when the body is valid JSON that doesn't match.
+func (s *BodySchema) Validate(body []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return s.validate("$", value)
+}
+
+func (s *BodySchema) validate(path string, value any) error {
+	if s.Type != "" && !hasSchemaType(value, s.Type) {
+		return &SchemaError{Path: path, Message: fmt.Sprintf("expected %s, got %s", s.Type, schemaType(value))}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(value, s.Enum) {
+		return &SchemaError{Path: path, Message: "value is not one of the allowed values"}
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		return s.validateObject(path, v)
+	case []any:
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if s.MinLength != nil && length < *s.MinLength {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("shorter than %d characters", *s.MinLength)}
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("longer than %d characters", *s.MaxLength)}
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("less than %v", *s.Minimum)}
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("greater than %v", *s.Maximum)}
+		}
+	}
+	return nil
+}
+
+func (s *BodySchema) validateObject(path string, object map[string]any) error {
+	for _, name := range s.Required {
+		if _, ok := object[name]; !ok {
+			return &SchemaError{Path: path, Message: fmt.Sprintf("missing required property %q", name)}
+		}
+	}
+
+	// Sorted so the same body always reports the same violation.
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		property, ok := s.Properties[name]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				return &SchemaError{Path: path, Message: fmt.Sprintf("unexpected property %q", name)}
+			}
+			continue
+		}
+		if err := property.validate(path+"."+name, object[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasSchemaType(value any, schemaType string) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		return schemaType == SchemaObject
+	case []any:
+		return schemaType == SchemaArray
+	case string:
+		return schemaType == SchemaString
+	case bool:
+		return schemaType == SchemaBoolean
+	case nil:
+		return schemaType == SchemaNull
+	case json.Number:
+		if schemaType == SchemaNumber {
+			return true
+		}
+		n, err := v.Float64()
+		return schemaType == SchemaInteger && err == nil && n == math.Trunc(n)
+	}
+	return false
+}
+
+func schemaType(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return SchemaObject
+	case []any:
+		return SchemaArray
+	case string:
+		return SchemaString
+	case bool:
+		return SchemaBoolean
+	case json.Number:
+		return SchemaNumber
+	default:
+		return SchemaNull
+	}
+}
+
+func inEnum(value any, enum []any) bool {
+	// The enum is decoded without UseNumber, numbers are compared as float64.
+	if n, ok := value.(json.Number); ok {
+		f, _ := n.Float64()
+		value = f
+	}
+	for _, allowed := range enum {
+		if reflect.DeepEqual(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsJSONContentType reports whether contentType is application/json or a
+// +json suffixed type such as application/problem+json.
+func IsJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,115 @@
+package entity
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// MaxGeneratedBodySize bounds the size of a generated body, in bytes.
+const MaxGeneratedBodySize = 64 << 30
+
+// BodyStreamType is where a streamed body is read from.
+type BodyStreamType string
+
+const (
+	BodyStreamFile      BodyStreamType = "file"      // a file of the body files directory of the server
+	BodyStreamGenerated BodyStreamType = "generated" // Size bytes of filler
+)
+
+// BodyStream is a body too large to be held in memory. Every request reads
+// it anew from its source, so the memory used grows neither with its size
+// nor with the concurrency.
+type BodyStream struct {
+	Type    BodyStreamType `json:"type"`
+	File    string         `json:"file,omitempty"`    // relative to the body files directory
+	Size    int64          `json:"size,omitempty"`    // of a generated body, in bytes
+	Chunked bool           `json:"chunked,omitempty"` // sent in chunks, without Content-Length
+}
+
+// bodyProvider yields a fresh reader of a body for every request, along
+// with its length.
+type bodyProvider interface {
+	open() (io.ReadCloser, int64, error)
+}
+
+func newBodyProvider(stream *BodyStream, dir string) bodyProvider {
+	if stream.Type == BodyStreamFile {
+		return fileBody(filepath.Join(dir, stream.File))
+	}
+	return generatedBody(stream.Size)
+}
+
+// fileBody is read from the file at its path, which may change between requests.
+type fileBody string
+
+func (f fileBody) open() (io.ReadCloser, int64, error) {
+	file, err := os.Open(string(f))
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// generatedBody is that many bytes of filler.
+type generatedBody int64
+
+func (g generatedBody) open() (io.ReadCloser, int64, error) {
+	return io.NopCloser(&fillerReader{remaining: int64(g)}), int64(g), nil
+}
+
+var filler = []byte("performance-analyzer generated body ")
+
+// fillerReader repeats filler until remaining bytes were read.
+type fillerReader struct {
+	remaining int64
+	offset    int
+}
+
+func (f *fillerReader) Read(p []byte) (int, error) {
+	if f.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > f.remaining {
+		p = p[:f.remaining]
+	}
+
+	n := 0
+	for n < len(p) {
+		copied := copy(p[n:], filler[f.offset:])
+		f.offset = (f.offset + copied) % len(filler)
+		n += copied
+	}
+	f.remaining -= int64(n)
+	return n, nil
+}
+
+// withBodyStream gives req a fresh reader of the streamed body, sent in
+// chunks when the stream asks for it or its length is unknown.
+func (w *Worker) withBodyStream(req *http.Request) (*http.Request, error) {
+	body, size, err := w.stream.open()
+	if err != nil {
+		return nil, err
+	}
+	if w.BodyStream.Chunked {
+		size = -1
+	}
+
+	req.Body, req.ContentLength = body, size
+	if size == 0 {
+		body.Close()
+		req.Body = http.NoBody
+	}
+	// Redirects and retries of the transport read the body again from its source.
+	req.GetBody = func() (io.ReadCloser, error) {
+		body, _, err := w.stream.open()
+		return body, err
+	}
+	return req, nil
+}
@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// bodyCounter is a stub counting the bytes of the bodies it receives.
+type bodyCounter struct {
+	mu             sync.Mutex
+	sizes          []int64
+	contentLengths []int64
+	chunked        int
+}
+
+func (c *bodyCounter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n, err := io.Copy(io.Discard, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sizes = append(c.sizes, n)
+	c.contentLengths = append(c.contentLengths, r.ContentLength)
+	if len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked" {
+		c.chunked++
+	}
+}
+
+func TestGeneratedBodyStream(t *testing.T) {
+	const size = 32 << 20
+	counter := &bodyCounter{}
+	stub := httptest.NewServer(counter)
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 4, 2, WithWorkerBodyStream(&BodyStream{Type: BodyStreamGenerated, Size: size}, ""))
+	worker.HTTPMethod = http.MethodPost
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	runWorker(context.Background(), worker)
+	runtime.ReadMemStats(&after)
+
+	if worker.Metrics.FailedRequests != 0 || len(counter.sizes) != 8 {
+		t.Fatalf("%d requests received, %d failed, want 8 received", len(counter.sizes), worker.Metrics.FailedRequests)
+	}
+	for i, n := range counter.sizes {
+		if n != size || counter.contentLengths[i] != size {
+			t.Errorf("request %d: received %d bytes with Content-Length %d, want %d", i, n, counter.contentLengths[i], size)
+		}
+	}
+
+	// 256MiB went through, a body held in memory would take 32MiB at least.
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/2 {
+		t.Errorf("allocated %d bytes during the run, want the memory to stay flat", allocated)
+	}
+}
+
+func TestFileBodyStream(t *testing.T) {
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	if err := os.WriteFile(filepath.Join(dir, "upload.bin"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name              string
+		chunked           bool
+		wantContentLength int64
+		wantChunked       int
+	}{
+		{name: "with Content-Length", wantContentLength: int64(len(content))},
+		{name: "chunked", chunked: true, wantContentLength: -1, wantChunked: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			counter := &bodyCounter{}
+			stub := httptest.NewServer(counter)
+			defer stub.Close()
+
+			stream := &BodyStream{Type: BodyStreamFile, File: "upload.bin", Chunked: test.chunked}
+			worker := newTestWorker(stub.URL, 1, 3, WithWorkerBodyStream(stream, dir))
+			worker.HTTPMethod = http.MethodPut
+			runWorker(context.Background(), worker)
+
+			if len(counter.sizes) != 3 {
+				t.Fatalf("%d requests received, want 3", len(counter.sizes))
+			}
+			// Every request reads the file from its start.
+			for i, n := range counter.sizes {
+				if n != int64(len(content)) || counter.contentLengths[i] != test.wantContentLength {
+					t.Errorf("request %d: received %d bytes with Content-Length %d, want %d with %d",
+						i, n, counter.contentLengths[i], len(content), test.wantContentLength)
+				}
+			}
+			if counter.chunked != test.wantChunked {
+				t.Errorf("%d chunked requests, want %d", counter.chunked, test.wantChunked)
+			}
+		})
+	}
+}
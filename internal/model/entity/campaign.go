@@ -0,0 +1,99 @@
+package entity
+
+import (
+	"time"
+)
+
+// Campaign groups the workers created from a single template, one per
+// environment, so they are followed and cancelled together.
+type Campaign struct {
+	ID        int
+	Workers   []*Worker // ordered by id, each with its progress set
+	CreatedAt time.Time
+}
+
+// CampaignProgress tells how far the workers of a campaign are, Ratio being
+// the mean of their ratios.
+type CampaignProgress struct {
+	Workers int     `json:"workers"`
+	Over    int     `json:"over"`
+	Ratio   float64 `json:"ratio"` // between 0 and 1
+}
+
+// CampaignEntry is the row of a worker in the comparison of a campaign.
+type CampaignEntry struct {
+	EnvironmentID int                        `json:"environment_id"`
+	WorkerID      int                        `json:"worker_id"`
+	Status        Status                     `json:"status"`
+	TotalRequests int                        `json:"total_requests"`
+	ErrorRate     float64                    `json:"error_rate"`
+	Throughput    float64                    `json:"throughput"`  // in requests per second
+	Percentiles   map[PercentileRank]float64 `json:"percentiles"` // in seconds
+}
+
+// Status is running while the run of any worker isn't over, cancelled if
+// any was cancelled and finished otherwise, the workers that failed being
+// told apart in the comparison.
+func (c *Campaign) Status() Status {
+	cancelled := false
+	for _, worker := range c.Workers {
+		if !worker.Status.Over() {
+			return StatusRunning
+		}
+		if worker.Status == StatusCancelled {
+			cancelled = true
+		}
+	}
+
+	if cancelled {
+		return StatusCancelled
+	}
+	return StatusFinished
+}
+
+// Progress sums up the progress of the workers, which must be set.
+func (c *Campaign) Progress() *CampaignProgress {
+	progress := &CampaignProgress{Workers: len(c.Workers)}
+	if len(c.Workers) == 0 {
+		return progress
+	}
+
+	for _, worker := range c.Workers {
+		if worker.Status.Over() {
+			progress.Over++
+		}
+		if worker.Progress != nil {
+			progress.Ratio += worker.Progress.Ratio
+		}
+	}
+	progress.Ratio /= float64(len(c.Workers))
+	return progress
+}
+
+// Comparison puts the results of the workers side by side, one row per
+// environment. It is nil until the run of every worker is over.
+func (c *Campaign) Comparison() []*CampaignEntry {
+	if c.Status() == StatusRunning {
+		return nil
+	}
+
+	entries := make([]*CampaignEntry, 0, len(c.Workers))
+	for _, worker := range c.Workers {
+		entry := &CampaignEntry{
+			EnvironmentID: worker.EnvironmentID,
+			WorkerID:      worker.ID,
+			Status:        worker.Status,
+			Percentiles:   make(map[PercentileRank]float64),
+		}
+		if worker.Metrics != nil {
+			entry.TotalRequests = worker.Metrics.TotalRequests
+			entry.ErrorRate = worker.Metrics.ErrorRate
+			entry.Throughput = worker.Metrics.Throughput
+			for rank, value := range worker.Metrics.Percentiles {
+				entry.Percentiles[rank] = value
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,60 @@
+package entity
+
+import "net/http"
+
+// SupportedHTTPMethods are the methods a worker sends its requests with,
+// the steps of a scenario bringing their own.
+var SupportedHTTPMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// SupportedModes are the modes of a worker.
+var SupportedModes = []Mode{ModeFixed, ModeRampToFailure, ModeSoak, ModeSpike, ModeAutoTune}
+
+// Authentication modes of an environment.
+const (
+	AuthNone          = "none"
+	AuthTokenEndpoint = "token_endpoint" // a bearer token fetched from the token endpoint of the environment with its credentials
+)
+
+// Capabilities describes what the instance supports and the limits its
+// input is validated against, for the clients to know beforehand what would
+// be rejected.
+type Capabilities struct {
+	HTTPMethods []string           `json:"http_methods"`
+	Modes       []Mode             `json:"modes"`
+	AuthModes   []string           `json:"auth_modes"`
+	DataSources []DataSourceType   `json:"data_sources"`
+	Features    CapabilityFeatures `json:"features"`
+	Limits      CapabilityLimits   `json:"limits"`
+}
+
+// CapabilityFeatures tells which optional features are enabled.
+type CapabilityFeatures struct {
+	Scheduling       bool `json:"scheduling"`        // runs started at a given time, only deferred past a maintenance window so far
+	BodyFiles        bool `json:"body_files"`        // a body files directory is configured
+	SQLDataSources   bool `json:"sql_data_sources"`  // a secrets key is configured
+	StrictValidation bool `json:"strict_validation"` // the workers whose plan has warnings are rejected
+	Admin            bool `json:"admin"`             // an admin token is configured
+	DemoData         bool `json:"demo_data"`
+}
+
+// CapabilityLimits are the bounds of the input, 0 meaning unlimited for the configured ones.
+type CapabilityLimits struct {
+	MaxConcurrency          int      `json:"max_concurrency"`
+	MaxRequests             int      `json:"max_requests"` // of a fixed run
+	MaxSyncRequests         int      `json:"max_sync_requests"`
+	MaxSyncDuration         Duration `json:"max_sync_duration"`
+	GlobalMaxRPS            float64  `json:"global_max_rps"`
+	DefaultRequestTimeout   Duration `json:"default_request_timeout"`
+	MaxSampleCap            int      `json:"max_sample_cap"`
+	MaxLogSampleRate        int      `json:"max_log_sample_rate"`
+	MaxBodyVariants         int      `json:"max_body_variants"`
+	MaxCaptureQuota         int      `json:"max_capture_quota"`
+	MaxIdentities           int      `json:"max_identities"`
+	MaxDataSourceRows       int      `json:"max_data_source_rows"`
+	MaxDataSourceCSV        int      `json:"max_data_source_csv"` // in bytes
+	MaxScenarioSteps        int      `json:"max_scenario_steps"`
+	MaxRetries              int      `json:"max_retries"` // of a single request
+	MaxServedByHeaders      int      `json:"max_served_by_headers"`
+	MaxCampaignEnvironments int      `json:"max_campaign_environments"`
+	MaxSweepTimeout         Duration `json:"max_sweep_timeout"`
+}
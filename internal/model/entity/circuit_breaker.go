@@ -0,0 +1,159 @@
+package entity
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"      // every goroutine is paused
+	BreakerHalfOpen BreakerState = "half_open" // a single probe at a time is let through
+)
+
+// halfOpenPollInterval is how often goroutines waiting behind a probe check whether the breaker closed.
+const halfOpenPollInterval = 10 * time.Millisecond
+
+// CircuitBreakerConfig opens the circuit once more than FailureRate of the last
+// Window requests failed, pauses the worker for Cooldown, then lets probes
+// through one at a time until HalfOpenProbes of them succeeded in a row.
+type CircuitBreakerConfig struct {
+	Window         int      `json:"window"`
+	FailureRate    float64  `json:"failure_rate"`
+	Cooldown       Duration `json:"cooldown"`
+	HalfOpenProbes int      `json:"half_open_probes"`
+}
+
+type circuitBreaker struct {
+	config         *CircuitBreakerConfig
+	log            zerolog.Logger
+	mu             sync.Mutex
+	state          BreakerState
+	outcomes       []bool // sliding window of the latest outcomes, true for a failure
+	next           int
+	filled         int
+	failures       int
+	openedAt       time.Time
+	openFor        time.Duration
+	openings       int
+	probeInFlight  bool
+	probeSuccesses int
+}
+
+func newCircuitBreaker(config *CircuitBreakerConfig, log zerolog.Logger) *circuitBreaker {
+	return &circuitBreaker{
+		config:   config,
+		log:      log,
+		state:    BreakerClosed,
+		outcomes: make([]bool, config.Window),
+	}
+}
+
+// acquire blocks until a request may be sent and reports whether that request
+// is a half-open probe. ok is false when ctx was cancelled while waiting.
+func (b *circuitBreaker) acquire(ctx context.Context) (probe, ok bool) {
+	for {
+		b.mu.Lock()
+		var wait time.Duration
+
+		switch b.state {
+		case BreakerClosed:
+			b.mu.Unlock()
+			return false, true
+		case BreakerOpen:
+			wait = time.Duration(b.config.Cooldown) - time.Since(b.openedAt)
+			if wait <= 0 {
+				b.openFor += time.Since(b.openedAt)
+				b.state = BreakerHalfOpen
+				b.probeSuccesses = 0
+				b.log.Info().Msg("Circuit breaker half-open, probing the target")
+				b.mu.Unlock()
+				continue
+			}
+		case BreakerHalfOpen:
+			if !b.probeInFlight {
+				b.probeInFlight = true
+				b.mu.Unlock()
+				return true, true
+			}
+			wait = halfOpenPollInterval
+		}
+		b.mu.Unlock()
+
+		if !sleep(ctx, wait) {
+			return false, false
+		}
+	}
+}
+
+// record feeds the outcome of a request acquired through acquire.
+func (b *circuitBreaker) record(failed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probeInFlight = false
+		if failed {
+			b.open()
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.config.HalfOpenProbes {
+			b.close()
+		}
+		return
+	}
+
+	if b.state != BreakerClosed {
+		// Late outcome of a request sent before the circuit opened.
+		return
+	}
+
+	if b.outcomes[b.next] {
+		b.failures--
+	}
+	b.outcomes[b.next] = failed
+	if failed {
+		b.failures++
+	}
+	b.next = (b.next + 1) % len(b.outcomes)
+	b.filled = min(b.filled+1, len(b.outcomes))
+
+	if b.filled == len(b.outcomes) && float64(b.failures)/float64(len(b.outcomes)) > b.config.FailureRate {
+		b.open()
+	}
+}
+
+// open must be called with b.mu held.
+func (b *circuitBreaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = time.Now()
+	b.openings++
+	b.log.Warn().Msgf("Circuit breaker open, pausing the worker for %s", time.Duration(b.config.Cooldown))
+}
+
+// close must be called with b.mu held.
+func (b *circuitBreaker) close() {
+	b.state = BreakerClosed
+	clear(b.outcomes)
+	b.next, b.filled, b.failures = 0, 0, 0
+	b.log.Info().Msg("Circuit breaker closed, resuming the worker")
+}
+
+// stats returns how long the circuit stayed open, including a still ongoing
+// open period, and how many times it opened.
+func (b *circuitBreaker) stats() (time.Duration, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	openFor := b.openFor
+	if b.state == BreakerOpen {
+		openFor += time.Since(b.openedAt)
+	}
+	return openFor, b.openings
+}
@@ -0,0 +1,140 @@
+package entity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestBreaker() *circuitBreaker {
+	return newCircuitBreaker(&CircuitBreakerConfig{
+		Window:         4,
+		FailureRate:    0.5,
+		Cooldown:       Duration(20 * time.Millisecond),
+		HalfOpenProbes: 2,
+	}, zerolog.Nop())
+}
+
+func (b *circuitBreaker) currentState() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// mustAcquire acquires the breaker and fails the test if it didn't let a
+// request through, or let it through as a probe when probe is false.
+func mustAcquire(t *testing.T, b *circuitBreaker, wantProbe bool) {
+	t.Helper()
+	probe, ok := b.acquire(context.Background())
+	if !ok {
+		t.Fatal("acquire() failed")
+	}
+	if probe != wantProbe {
+		t.Fatalf("acquire() probe = %t, want %t", probe, wantProbe)
+	}
+}
+
+func TestCircuitBreakerOpens(t *testing.T) {
+	b := newTestBreaker()
+
+	// Until the window is full the breaker stays closed, whatever the failures.
+	for i := 0; i < 3; i++ {
+		mustAcquire(t, b, false)
+		b.record(true, false)
+	}
+	if state := b.currentState(); state != BreakerClosed {
+		t.Fatalf("state with a partial window = %s, want %s", state, BreakerClosed)
+	}
+
+	mustAcquire(t, b, false)
+	b.record(true, false)
+	if state := b.currentState(); state != BreakerOpen {
+		t.Fatalf("state after 4 failures out of 4 = %s, want %s", state, BreakerOpen)
+	}
+}
+
+func TestCircuitBreakerStaysClosedAtThreshold(t *testing.T) {
+	b := newTestBreaker()
+
+	// A failure rate of exactly 0.5 doesn't cross the threshold.
+	for _, failed := range []bool{true, false, true, false, true, false} {
+		mustAcquire(t, b, false)
+		b.record(failed, false)
+	}
+	if state := b.currentState(); state != BreakerClosed {
+		t.Fatalf("state = %s, want %s", state, BreakerClosed)
+	}
+}
+
+func TestCircuitBreakerHalfOpenAndClose(t *testing.T) {
+	b := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(true, false)
+	}
+
+	// The first request after the cooldown is a probe, a failed one opens the circuit again.
+	start := time.Now()
+	mustAcquire(t, b, true)
+	if waited := time.Since(start); waited < 15*time.Millisecond {
+		t.Errorf("acquired after %s, want the cooldown to be waited", waited)
+	}
+	if state := b.currentState(); state != BreakerHalfOpen {
+		t.Fatalf("state after the cooldown = %s, want %s", state, BreakerHalfOpen)
+	}
+	b.record(true, true)
+	if state := b.currentState(); state != BreakerOpen {
+		t.Fatalf("state after a failed probe = %s, want %s", state, BreakerOpen)
+	}
+
+	// Two probes succeeding in a row close it.
+	mustAcquire(t, b, true)
+	b.record(false, true)
+	if state := b.currentState(); state != BreakerHalfOpen {
+		t.Fatalf("state after a successful probe = %s, want %s", state, BreakerHalfOpen)
+	}
+	mustAcquire(t, b, true)
+	b.record(false, true)
+	if state := b.currentState(); state != BreakerClosed {
+		t.Fatalf("state after two successful probes = %s, want %s", state, BreakerClosed)
+	}
+	mustAcquire(t, b, false)
+
+	openFor, openings := b.stats()
+	if openings != 2 {
+		t.Errorf("openings = %d, want 2", openings)
+	}
+	if openFor < 30*time.Millisecond {
+		t.Errorf("open for %s, want at least both cooldowns", openFor)
+	}
+}
+
+func TestCircuitBreakerSingleProbe(t *testing.T) {
+	b := newTestBreaker()
+	for i := 0; i < 4; i++ {
+		b.record(true, false)
+	}
+	mustAcquire(t, b, true)
+
+	// While the probe is in flight, the other goroutines wait.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := b.acquire(ctx); ok {
+		t.Fatal("a second request was let through alongside the probe")
+	}
+}
+
+func TestCircuitBreakerCancelledWhileOpen(t *testing.T) {
+	b := newTestBreaker()
+	b.config.Cooldown = Duration(time.Minute)
+	for i := 0; i < 4; i++ {
+		b.record(true, false)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := b.acquire(ctx); ok {
+		t.Fatal("acquire() succeeded on a cancelled context while open")
+	}
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cursor is where a page of rows ends, the rows being ordered by their
+// creation time and then by their id. Unlike an offset, it stays put when
+// rows are created while a client pages through them.
+type Cursor struct {
+	CreatedAt time.Time `json:"created_at"`
+	ID        int       `json:"id"`
+}
+
+// NewWorkerCursor returns the cursor of a page ending with worker.
+func NewWorkerCursor(worker *Worker) *Cursor {
+	return &Cursor{CreatedAt: worker.CreatedAt, ID: worker.ID}
+}
+
+// String returns the cursor as handed out to the clients, which are to pass
+// it back as is.
+func (c *Cursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// ParseCursor reads a cursor handed out by String.
+func ParseCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	if cursor.ID < 1 {
+		return nil, errors.New("cursor without an id")
+	}
+	return &cursor, nil
+}
+
+// After reports whether a row created at createdAt with id comes after the
+// cursor in the order of the pages.
+func (c *Cursor) After(createdAt time.Time, id int) bool {
+	if !createdAt.Equal(c.CreatedAt) {
+		return createdAt.After(c.CreatedAt)
+	}
+	return id > c.ID
+}
@@ -0,0 +1,89 @@
+package entity
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// DemoData is what a generation of demo data inserted.
+type DemoData struct {
+	Seed           int64 `json:"seed"`
+	EnvironmentIDs []int `json:"environment_ids"`
+	Workers        int   `json:"workers"`
+}
+
+// DemoProfile is how a synthetic target behaves, its latencies following a
+// log-normal distribution around Median.
+type DemoProfile struct {
+	Median    time.Duration
+	Spread    float64 // standard deviation of the logarithm of the latencies
+	ErrorRate float64
+	Drift     float64 // relative change of the median from the first run to the last one
+}
+
+// NewDemoProfile draws a plausible target from rng, from a fast and
+// reliable service to a slow and flaky one.
+func NewDemoProfile(rng *rand.Rand) DemoProfile {
+	return DemoProfile{
+		Median:    time.Duration(20+rng.Intn(280)) * time.Millisecond,
+		Spread:    0.2 + rng.Float64()*0.5,
+		ErrorRate: rng.Float64() * 0.03,
+		Drift:     rng.Float64()*0.5 - 0.2,
+	}
+}
+
+// demoConcurrencies and demoRequestsPerTask are the sizes demo runs are drawn from.
+var (
+	demoConcurrencies   = []int{1, 2, 5, 10, 20}
+	demoRequestsPerTask = []int{20, 50, 100}
+)
+
+// DemoRunSize draws the concurrency and the requests per task of a demo run from rng.
+func DemoRunSize(rng *rand.Rand) (int, int) {
+	return demoConcurrencies[rng.Intn(len(demoConcurrencies))], demoRequestsPerTask[rng.Intn(len(demoRequestsPerTask))]
+}
+
+// DemoMetrics returns the metrics of a finished run of requests against
+// profile, drawn from rng. Progress, between 0 and 1, places the run in the
+// history of the target, its median moving by the drift of the profile.
+// One run in twenty is an incident, failing ten times as often with a
+// heavier tail.
+func DemoMetrics(rng *rand.Rand, profile DemoProfile, concurrency, requests int, progress float64) *Metrics {
+	median := float64(profile.Median) * (1 + profile.Drift*progress)
+	spread, errorRate := profile.Spread, profile.ErrorRate
+	if rng.Float64() < 0.05 {
+		spread, errorRate = spread*1.5, min(errorRate*10, 0.5)
+	}
+
+	metrics := NewMetrics()
+	var busy time.Duration
+	for i := 0; i < requests; i++ {
+		latency := time.Duration(median * math.Exp(spread*rng.NormFloat64()))
+		busy += latency
+		metrics.IncrementTotalRequests()
+
+		switch {
+		case rng.Float64() >= errorRate:
+			metrics.AddLatency(latency)
+			metrics.IncrementStatusClass(ResponseClass2xx)
+		case rng.Float64() < 0.8:
+			metrics.IncrementFailedRequests()
+			metrics.IncrementStatusClass(ResponseClass5xx)
+		default:
+			metrics.IncrementFailedRequests()
+			metrics.IncrementErrorClass(ErrorClassTimeout)
+		}
+	}
+
+	// The goroutines share the requests evenly and spend a little time between them.
+	elapsed := time.Duration(float64(busy) / float64(concurrency) * 1.05)
+	if err := metrics.CalculatePercentiles(P50, P95, P99, P999); err != nil {
+		// Every request failed, there is no latency to rank.
+		metrics.Percentiles = make(map[PercentileRank]float64)
+	}
+	metrics.CalculateMaxLatency()
+	metrics.CalculateErrorRate()
+	metrics.CalculateThroughput(elapsed)
+	return metrics
+}
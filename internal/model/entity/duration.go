@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Duration is a time.Duration that is encoded in JSON as a Go duration
+// string (e.g. "30s", "1m30s").
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.New("duration must be a string such as \"30s\"")
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+	return nil
+}
+
+// latencyUnits are the units FormatSeconds picks from, smallest first.
+var latencyUnits = []struct {
+	suffix string
+	scale  float64 // in seconds
+}{
+	{"µs", 1e-6},
+	{"ms", 1e-3},
+	{"s", 1},
+}
+
+// FormatSeconds renders a latency in seconds for a human, in the largest
+// unit keeping it under 1000: 0.003421 is 3.4ms, 1.2 is 1.2s. Values
+// under 100 of their unit keep one decimal, a minute or more is rendered as
+// a Go duration rounded to the second. Logs keep the raw value in their
+// fields, this is only for their messages.
+func FormatSeconds(seconds float64) string {
+	if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return fmt.Sprint(seconds)
+	}
+	if seconds < 0 {
+		return "-" + FormatSeconds(-seconds)
+	}
+	if seconds == 0 {
+		return "0s"
+	}
+	if seconds >= 59.95 {
+		return time.Duration(math.Round(seconds) * float64(time.Second)).String()
+	}
+
+	for i, unit := range latencyUnits {
+		value := seconds / unit.scale
+		// 999.7ms would be printed as 1000ms, which is better told as 1.0s.
+		if value >= 999.5 && i < len(latencyUnits)-1 {
+			continue
+		}
+		if value < 99.95 {
+			return fmt.Sprintf("%.1f%s", value, unit.suffix)
+		}
+		return fmt.Sprintf("%.0f%s", value, unit.suffix)
+	}
+	return ""
+}
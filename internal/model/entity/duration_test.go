@@ -0,0 +1,42 @@
+package entity
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatSeconds(t *testing.T) {
+	tests := []struct {
+		seconds float64
+		want    string
+	}{
+		{seconds: 0, want: "0s"},
+		{seconds: 0.0000001, want: "0.1µs"},
+		{seconds: 0.000001, want: "1.0µs"},
+		{seconds: 0.0000999, want: "99.9µs"},
+		// Past 99.95 of a unit the decimal is dropped.
+		{seconds: 0.00009996, want: "100µs"},
+		{seconds: 0.0009994, want: "999µs"},
+		// 999.6µs would round to 1000µs, the next unit tells it better.
+		{seconds: 0.0009996, want: "1.0ms"},
+		{seconds: 0.003421, want: "3.4ms"},
+		{seconds: 0.09996, want: "100ms"},
+		{seconds: 0.9994, want: "999ms"},
+		{seconds: 0.9996, want: "1.0s"},
+		{seconds: 1.2, want: "1.2s"},
+		{seconds: 59.94, want: "59.9s"},
+		// A minute or more is a duration rounded to the second.
+		{seconds: 59.95, want: "1m0s"},
+		{seconds: 90.4, want: "1m30s"},
+		{seconds: 3600, want: "1h0m0s"},
+		{seconds: -0.0025, want: "-2.5ms"},
+		{seconds: math.NaN(), want: "NaN"},
+		{seconds: math.Inf(1), want: "+Inf"},
+	}
+
+	for _, test := range tests {
+		if got := FormatSeconds(test.seconds); got != test.want {
+			t.Errorf("FormatSeconds(%g) = %q, want %q", test.seconds, got, test.want)
+		}
+	}
+}
@@ -5,15 +5,26 @@ import (
 )
 
 type Environment struct {
-	ID             int       `json:"id"`
-	Name           string    `json:"name"`
-	Endpoint       string    `json:"endpoint"`
-	TokenEndpoint  string    `json:"token_endpoint,omitempty"`
-	Username       string    `json:"username,omitempty"`
-	Password       string    `json:"password,omitempty"`
-	BasicAuthToken string    `json:"basic_auth_token,omitempty"`
-	Disabled       bool      `json:"disabled,omitempty"`
-	CreatedAt      time.Time `json:"-"`
+	ID                 int                 `json:"id"`
+	Name               string              `json:"name"`
+	Endpoint           string              `json:"endpoint"`
+	TokenEndpoint      string              `json:"token_endpoint,omitempty"`
+	Username           string              `json:"username,omitempty"`
+	Password           string              `json:"password,omitempty"`
+	BasicAuthToken     string              `json:"basic_auth_token,omitempty"`
+	Disabled           bool                `json:"disabled,omitempty"`
+	DisabledReason     string              `json:"disabled_reason,omitempty"`    // set when the environment was disabled by its AutoDisable policy
+	BaselineWorkerID   *int                `json:"baseline_worker_id,omitempty"` // the agreed-good run every other run is compared to
+	BodySchema         *BodySchema         `json:"body_schema,omitempty"`        // checked against the JSON bodies of the workers
+	MaintenanceWindows []MaintenanceWindow `json:"maintenance_windows,omitempty"`
+	MaintenancePolicy  MaintenancePolicy   `json:"maintenance_policy,omitempty"`  // MaintenanceReject when empty
+	DailyRequestQuota  int                 `json:"daily_request_quota,omitempty"` // requests a UTC day, unlimited when 0
+	WorkerDefaults     WorkerDefaults      `json:"default_worker_settings,omitempty"`
+	DefaultTimeout     Duration            `json:"default_timeout,omitempty"` // total timeout of the requests of the workers setting none, the global one when 0
+	Demo               bool                `json:"demo,omitempty"`            // generated along with its workers as demo data, pruned with it
+	AutoDisable        *AutoDisablePolicy  `json:"auto_disable,omitempty"`
+	LastAuthVerifiedAt *time.Time          `json:"last_auth_verified_at,omitempty"` // when its credentials last got a token, nil since they were edited
+	CreatedAt          time.Time           `json:"-"`
 }
 
 // NewEnvironment creates a new Environment with the given options.
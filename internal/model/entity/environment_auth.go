@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/pkg/tokens"
+)
+
+// AuthFailure tells why the credentials of an environment got no token.
+type AuthFailure string
+
+const (
+	// AuthBadCredentials means the auth server rejected the credentials.
+	AuthBadCredentials AuthFailure = "bad_credentials"
+	// AuthUnreachable means the auth server couldn't be reached in time.
+	AuthUnreachable AuthFailure = "unreachable"
+	// AuthServerError means the auth server answered, but with an error or
+	// with something that isn't a token.
+	AuthServerError AuthFailure = "server_error"
+)
+
+// AuthCheck is the outcome of fetching a token with the credentials of an
+// environment, without sending anything to its endpoint. The token itself
+// is never part of it.
+type AuthCheck struct {
+	EnvironmentID int         `json:"environment_id"`
+	Verified      bool        `json:"verified"`
+	TTL           Duration    `json:"ttl,omitempty"`         // of the token fetched
+	Scopes        []string    `json:"scopes,omitempty"`      // granted to the token, when the auth server tells
+	StatusCode    int         `json:"status_code,omitempty"` // answered by the auth server, 0 when unreachable
+	Failure       AuthFailure `json:"failure,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	VerifiedAt    *time.Time  `json:"verified_at,omitempty"`
+}
+
+// NewAuthCheck returns the check of an environment given the token fetched
+// or the error it failed with.
+func NewAuthCheck(environmentID int, token tokens.Token, err error) *AuthCheck {
+	check := &AuthCheck{EnvironmentID: environmentID}
+	if err == nil {
+		check.Verified = true
+		check.TTL = Duration(token.ExpiresIn)
+		check.Scopes = token.Scopes
+		check.StatusCode = http.StatusOK
+		return check
+	}
+
+	check.Error = err.Error()
+	var statusErr *tokens.StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		check.StatusCode = statusErr.StatusCode
+		check.Failure = AuthServerError
+		// invalid_grant and invalid_client are answered 400 and 401 by OAuth servers.
+		switch statusErr.StatusCode {
+		case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+			check.Failure = AuthBadCredentials
+		}
+	case errors.Is(err, tokens.ErrInvalidTokenResponse):
+		check.StatusCode = http.StatusOK
+		check.Failure = AuthServerError
+	default:
+		check.Failure = AuthUnreachable
+	}
+	return check
+}
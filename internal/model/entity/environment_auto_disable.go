@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxAutoDisableRuns bounds the runs an AutoDisablePolicy looks back on.
+const MaxAutoDisableRuns = 100
+
+// AutoDisablePolicy disables an environment once its last AfterRuns runs
+// all failed, so that no run is started against a target that is broken
+// for good until someone looks into it. A run fails when it ends Failed,
+// or with an error rate of at least ErrorRate when it is set. The cancelled
+// runs say nothing about the target and are skipped.
+type AutoDisablePolicy struct {
+	AfterRuns int       `json:"after_runs"`
+	ErrorRate float64   `json:"error_rate,omitempty"` // between 0 and 1, 0 only counting the Failed runs
+	Since     time.Time `json:"since"`                // set by the server, only the runs finished since count
+}
+
+// failed reports whether a run counts towards disabling the environment.
+func (p *AutoDisablePolicy) failed(worker *Worker) bool {
+	if worker.Status == StatusFailed {
+		return true
+	}
+	return p.ErrorRate > 0 && worker.Metrics != nil && worker.Metrics.TotalRequests > 0 && worker.Metrics.ErrorRate >= p.ErrorRate
+}
+
+// Trips reports whether the latest runs of the environment, the most recent
+// first, disable it, with the reason why.
+func (p *AutoDisablePolicy) Trips(latest []*Worker) (string, bool) {
+	if p.AfterRuns < 1 || len(latest) < p.AfterRuns {
+		return "", false
+	}
+
+	ids := make([]int, 0, p.AfterRuns)
+	for _, worker := range latest[:p.AfterRuns] {
+		if !p.failed(worker) {
+			return "", false
+		}
+		ids = append(ids, worker.ID)
+	}
+
+	criterion := "failed"
+	if p.ErrorRate > 0 {
+		criterion = fmt.Sprintf("failed or had an error rate of at least %g", p.ErrorRate)
+	}
+	return fmt.Sprintf("the last %d runs %s (workers %v)", p.AfterRuns, criterion, ids), true
+}
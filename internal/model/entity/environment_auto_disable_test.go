@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAutoDisableTrips(t *testing.T) {
+	run := func(id int, status Status, errorRate float64) *Worker {
+		metrics := NewMetrics()
+		metrics.TotalRequests, metrics.ErrorRate = 100, errorRate
+		return &Worker{ID: id, Status: status, Metrics: metrics}
+	}
+	failed := func(id int) *Worker { return run(id, StatusFailed, 0) }
+	erroring := func(id int) *Worker { return run(id, StatusFinished, 0.6) }
+	healthy := func(id int) *Worker { return run(id, StatusFinished, 0.01) }
+
+	tests := []struct {
+		name      string
+		policy    AutoDisablePolicy
+		latest    []*Worker // the most recent first
+		wantTrips bool
+		wantIDs   string
+	}{
+		{name: "consecutive failures", policy: AutoDisablePolicy{AfterRuns: 3}, latest: []*Worker{failed(9), failed(8), failed(7), healthy(6)}, wantTrips: true, wantIDs: "[9 8 7]"},
+		{name: "short history", policy: AutoDisablePolicy{AfterRuns: 3}, latest: []*Worker{failed(9), failed(8)}},
+		{name: "failures interrupted", policy: AutoDisablePolicy{AfterRuns: 3}, latest: []*Worker{failed(9), healthy(8), failed(7)}},
+		{name: "error rate not counted", policy: AutoDisablePolicy{AfterRuns: 2}, latest: []*Worker{erroring(9), failed(8)}},
+		{name: "error rate over the threshold", policy: AutoDisablePolicy{AfterRuns: 2, ErrorRate: 0.5}, latest: []*Worker{erroring(9), failed(8)}, wantTrips: true, wantIDs: "[9 8]"},
+		{name: "error rate under the threshold", policy: AutoDisablePolicy{AfterRuns: 2, ErrorRate: 0.7}, latest: []*Worker{erroring(9), failed(8)}},
+		{name: "run without requests", policy: AutoDisablePolicy{AfterRuns: 1, ErrorRate: 0.5}, latest: []*Worker{{ID: 9, Status: StatusFinished, Metrics: NewMetrics()}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, trips := test.policy.Trips(test.latest)
+			if trips != test.wantTrips {
+				t.Fatalf("trips = %t, want %t", trips, test.wantTrips)
+			}
+			if trips && !strings.Contains(reason, "workers "+test.wantIDs) {
+				t.Errorf("reason %q doesn't list the workers %s", reason, test.wantIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+)
+
+// Secret states of an environment in a diff.
+const (
+	SecretSet   = "set"
+	SecretUnset = "unset"
+)
+
+// FieldDifference is a setting whose value differs between two environments.
+type FieldDifference struct {
+	Field string          `json:"field"` // keyed like the environment input
+	A     json.RawMessage `json:"a"`
+	B     json.RawMessage `json:"b"`
+}
+
+// SecretDifference compares a secret of two environments without revealing it.
+type SecretDifference struct {
+	Field  string `json:"field"`
+	A      string `json:"a"` // SecretSet or SecretUnset
+	B      string `json:"b"`
+	Differ bool   `json:"differ"`
+}
+
+// EnvironmentDiff is the field by field comparison of two environments.
+type EnvironmentDiff struct {
+	A       int                `json:"a"`
+	B       int                `json:"b"`
+	Fields  []FieldDifference  `json:"fields"`  // the settings that differ, secrets excluded
+	Secrets []SecretDifference `json:"secrets"` // every secret, whether it differs or not
+}
+
+// DiffEnvironments compares the settings of two environments, their ids
+// aside. The maintenance policy is compared once its default is applied.
+func DiffEnvironments(a, b *Environment) (*EnvironmentDiff, error) {
+	secrets := []struct {
+		key  string
+		a, b string
+	}{
+		{"password", a.Password, b.Password},
+		{"basic_auth_token", a.BasicAuthToken, b.BasicAuthToken},
+	}
+
+	effectiveA, effectiveB := *a, *b
+	for _, environment := range []*Environment{&effectiveA, &effectiveB} {
+		if environment.MaintenancePolicy == "" {
+			environment.MaintenancePolicy = MaintenanceReject
+		}
+	}
+
+	skipped := map[string]bool{"id": true}
+	for _, secret := range secrets {
+		skipped[secret.key] = true
+	}
+	fields, err := diffFields(&effectiveA, &effectiveB, skipped)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &EnvironmentDiff{A: a.ID, B: b.ID, Fields: fields, Secrets: []SecretDifference{}}
+	for _, secret := range secrets {
+		diff.Secrets = append(diff.Secrets, SecretDifference{
+			Field:  secret.key,
+			A:      secretState(secret.a),
+			B:      secretState(secret.b),
+			Differ: secret.a != secret.b,
+		})
+	}
+	return diff, nil
+}
+
+func secretState(value string) string {
+	if value == "" {
+		return SecretUnset
+	}
+	return SecretSet
+}
+
+// diffFields compares the fields of two pointers to structs of the same
+// type by their JSON encoding, in the order of the struct. The fields left
+// out of the JSON and the ones keyed in skipped aren't compared.
+func diffFields(a, b any, skipped map[string]bool) ([]FieldDifference, error) {
+	valueA, valueB := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	differences := []FieldDifference{}
+	for i := 0; i < valueA.NumField(); i++ {
+		key := jsonKey(valueA.Type().Field(i))
+		if key == "" || skipped[key] {
+			continue
+		}
+
+		encodedA, err := json.Marshal(valueA.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		encodedB, err := json.Marshal(valueB.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(encodedA, encodedB) {
+			differences = append(differences, FieldDifference{Field: key, A: encodedA, B: encodedB})
+		}
+	}
+	return differences, nil
+}
@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffEnvironments(t *testing.T) {
+	staging := NewEnvironment("staging", "https://api.example.com")
+	staging.ID = 1
+	staging.TokenEndpoint = "https://auth.example.com"
+	staging.Username = "loadtest"
+	staging.Password = "staging-secret"
+	staging.BasicAuthToken = "c2hhcmVk"
+	staging.DailyRequestQuota = 10000
+
+	prod := NewEnvironment("prod", "https://api.example.com")
+	prod.ID = 2
+	prod.TokenEndpoint = "https://auth.staging.example.com"
+	prod.Username = "loadtest"
+	prod.BasicAuthToken = "c2hhcmVk"
+	prod.DailyRequestQuota = 10000
+	// The default policy, spelled out.
+	prod.MaintenancePolicy = MaintenanceReject
+
+	diff, err := DiffEnvironments(staging, prod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FieldDifference{
+		{Field: "name", A: json.RawMessage(`"staging"`), B: json.RawMessage(`"prod"`)},
+		{Field: "token_endpoint", A: json.RawMessage(`"https://auth.example.com"`), B: json.RawMessage(`"https://auth.staging.example.com"`)},
+	}
+	if !reflect.DeepEqual(diff.Fields, want) {
+		t.Errorf("fields = %s, want %s", diff.Fields, want)
+	}
+
+	wantSecrets := []SecretDifference{
+		{Field: "password", A: SecretSet, B: SecretUnset, Differ: true},
+		{Field: "basic_auth_token", A: SecretSet, B: SecretSet},
+	}
+	if !reflect.DeepEqual(diff.Secrets, wantSecrets) {
+		t.Errorf("secrets = %+v, want %+v", diff.Secrets, wantSecrets)
+	}
+	if diff.A != 1 || diff.B != 2 {
+		t.Errorf("diff of %d and %d, want 1 and 2", diff.A, diff.B)
+	}
+
+	// The secrets are compared, never revealed.
+	data, err := json.Marshal(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{staging.Password, staging.BasicAuthToken} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("diff %s reveals %q", data, secret)
+		}
+	}
+}
+
+func TestDiffIdenticalEnvironments(t *testing.T) {
+	a := NewEnvironment("staging", "https://api.example.com")
+	a.ID = 1
+	b := NewEnvironment("staging", "https://api.example.com")
+	b.ID = 2
+
+	diff, err := DiffEnvironments(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Fields) != 0 {
+		t.Errorf("fields = %s, want none", diff.Fields)
+	}
+	for _, secret := range diff.Secrets {
+		if secret.Differ || secret.A != SecretUnset {
+			t.Errorf("secret %+v, want both unset", secret)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 type EnvironmentOption func(*Environment)
 
 func WithEnvironmentTokenEndpoint(tokenEndpoint string) EnvironmentOption {
@@ -20,8 +22,48 @@ func WithEnvironmentPassword(password string) EnvironmentOption {
 	}
 }
 
+func WithEnvironmentMaintenance(windows []MaintenanceWindow, policy MaintenancePolicy) EnvironmentOption {
+	return func(e *Environment) {
+		e.MaintenanceWindows = windows
+		e.MaintenancePolicy = policy
+	}
+}
+
 func WithEnvironmentDisabled(disabled bool) EnvironmentOption {
 	return func(e *Environment) {
 		e.Disabled = disabled
 	}
 }
+
+func WithEnvironmentWorkerDefaults(defaults WorkerDefaults) EnvironmentOption {
+	return func(e *Environment) {
+		e.WorkerDefaults = defaults
+	}
+}
+
+func WithEnvironmentDailyRequestQuota(quota int) EnvironmentOption {
+	return func(e *Environment) {
+		e.DailyRequestQuota = quota
+	}
+}
+
+func WithEnvironmentDefaultTimeout(timeout Duration) EnvironmentOption {
+	return func(e *Environment) {
+		e.DefaultTimeout = timeout
+	}
+}
+
+func WithEnvironmentDemo() EnvironmentOption {
+	return func(e *Environment) {
+		e.Demo = true
+	}
+}
+
+// WithEnvironmentAutoDisable sets the auto disable policy, validated
+// beforehand, counting the runs from now on.
+func WithEnvironmentAutoDisable(policy AutoDisablePolicy) EnvironmentOption {
+	return func(e *Environment) {
+		policy.Since = time.Now().UTC()
+		e.AutoDisable = &policy
+	}
+}
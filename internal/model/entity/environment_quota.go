@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"fmt"
+)
+
+// RequestUsage is the consumption of the daily request quota of an
+// environment. The requests of a run are counted on the UTC day it ends.
+type RequestUsage struct {
+	Day               string `json:"day"` // YYYY-MM-DD, in UTC
+	Requests          int    `json:"requests"`
+	DailyRequestQuota int    `json:"daily_request_quota,omitempty"` // unlimited when 0
+	Remaining         *int   `json:"remaining,omitempty"`           // nil when unlimited
+}
+
+// NewRequestUsage computes what remains of the quota of environment after requests.
+func NewRequestUsage(environment *Environment, day string, requests int) *RequestUsage {
+	usage := &RequestUsage{
+		Day:               day,
+		Requests:          requests,
+		DailyRequestQuota: environment.DailyRequestQuota,
+	}
+
+	if environment.DailyRequestQuota > 0 {
+		remaining := max(environment.DailyRequestQuota-requests, 0)
+		usage.Remaining = &remaining
+	}
+	return usage
+}
+
+// QuotaError rejects a worker whose run would exceed the daily request
+// quota of its environment.
+type QuotaError struct {
+	Quota     int
+	Remaining int
+	Requested *int // planned requests of the worker, nil when they can't be known upfront
+}
+
+func (e *QuotaError) Error() string {
+	if e.Requested == nil {
+		return fmt.Sprintf("the daily request quota of %d of the environment is used up", e.Quota)
+	}
+	return fmt.Sprintf("the run plans %d requests, only %d remain of the daily request quota of %d of the environment", *e.Requested, e.Remaining, e.Quota)
+}
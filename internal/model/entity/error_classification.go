@@ -0,0 +1,47 @@
+package entity
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+type ErrorClass string
+
+const (
+	// ErrorClassFileDescriptors means the process ran out of file descriptors (EMFILE/ENFILE).
+	ErrorClassFileDescriptors ErrorClass = "file_descriptors_exhausted"
+	// ErrorClassDial means a connection to the target could not be established.
+	ErrorClassDial    ErrorClass = "dial"
+	ErrorClassTimeout ErrorClass = "timeout"
+	ErrorClassOther   ErrorClass = "other"
+	// ErrorClassRedirect means a 3xx response was received by a worker counting them as failures.
+	ErrorClassRedirect ErrorClass = "redirect"
+	// ErrorClassTotalTimeout means a request outlasted the total timeout of the worker.
+	ErrorClassTotalTimeout ErrorClass = "total_timeout"
+	// ErrorClassIdleTimeout means a response body stalled for longer than the idle timeout of the worker.
+	ErrorClassIdleTimeout ErrorClass = "idle_timeout"
+	// ErrorClassHeaderMismatch means a 2xx response lacked the success header expected by the worker.
+	ErrorClassHeaderMismatch ErrorClass = "header_mismatch"
+	// ErrorClassAssertionFailed means a 2xx response failed the body assertion of the worker.
+	ErrorClassAssertionFailed ErrorClass = "assertion_failed"
+)
+
+// classifyError maps a transport error returned by the HTTP client to an ErrorClass.
+func classifyError(err error) ErrorClass {
+	if errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE) {
+		return ErrorClassFileDescriptors
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return ErrorClassDial
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	return ErrorClassOther
+}
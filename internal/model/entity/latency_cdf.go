@@ -0,0 +1,46 @@
+package entity
+
+import (
+	"math"
+	"sort"
+)
+
+// Bounds of the number of points of a latency CDF.
+const (
+	DefaultCDFPoints = 100
+	MaxCDFPoints     = 1000
+)
+
+// CDFPoint is the latency under which a percentile of the requests completed.
+type CDFPoint struct {
+	Percentile float64 `json:"percentile"`
+	Latency    float64 `json:"latency"` // in seconds
+}
+
+// NewCDF returns the latency at points evenly spaced percentiles, from
+// 100/points to 100, ordered by percentile. Each latency is the nearest-rank
+// sample, so the curve never decreases. Past one point per sample the
+// points would only repeat the samples, so there are at most len(samples).
+func NewCDF(samples []LatencySample, points int) []CDFPoint {
+	if len(samples) == 0 || points <= 0 {
+		return []CDFPoint{}
+	}
+	points = min(points, len(samples))
+
+	latencies := make([]float64, len(samples))
+	for i, sample := range samples {
+		latencies[i] = sample.Latency
+	}
+	sort.Float64s(latencies)
+
+	cdf := make([]CDFPoint, 0, points)
+	for k := 1; k <= points; k++ {
+		percentile := 100 * float64(k) / float64(points)
+		rank := int(math.Ceil(percentile / 100 * float64(len(latencies))))
+		cdf = append(cdf, CDFPoint{
+			Percentile: percentile,
+			Latency:    latencies[max(rank, 1)-1],
+		})
+	}
+	return cdf
+}
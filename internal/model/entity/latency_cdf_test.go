@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestCDF(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	samples := make([]LatencySample, 500)
+	for i := range samples {
+		// A long tail, like the latencies of a real run.
+		samples[i] = LatencySample{Latency: 0.01 + rng.ExpFloat64()*0.05}
+	}
+	slowest := 0.0
+	for _, sample := range samples {
+		slowest = max(slowest, sample.Latency)
+	}
+
+	tests := []struct {
+		name   string
+		points int
+		want   int
+	}{
+		{name: "default points", points: DefaultCDFPoints, want: DefaultCDFPoints},
+		{name: "a single point", points: 1, want: 1},
+		{name: "uneven points", points: 37, want: 37},
+		{name: "more points than samples", points: MaxCDFPoints, want: len(samples)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cdf := NewCDF(samples, test.points)
+			if len(cdf) != test.want {
+				t.Fatalf("%d points, want %d", len(cdf), test.want)
+			}
+
+			for i := 1; i < len(cdf); i++ {
+				if cdf[i].Percentile <= cdf[i-1].Percentile {
+					t.Errorf("percentile %g after %g, want increasing", cdf[i].Percentile, cdf[i-1].Percentile)
+				}
+				if cdf[i].Latency < cdf[i-1].Latency {
+					t.Errorf("latency %g at p%g after %g at p%g, want the curve never decreasing",
+						cdf[i].Latency, cdf[i].Percentile, cdf[i-1].Latency, cdf[i-1].Percentile)
+				}
+			}
+
+			last := cdf[len(cdf)-1]
+			if last.Percentile != 100 || last.Latency != slowest {
+				t.Errorf("last point = %+v, want p100 at the slowest latency %g", last, slowest)
+			}
+		})
+	}
+}
+
+func TestCDFWithoutSamples(t *testing.T) {
+	if cdf := NewCDF(nil, DefaultCDFPoints); cdf == nil || len(cdf) != 0 {
+		t.Errorf("NewCDF(nil) = %v, want an empty curve", cdf)
+	}
+}
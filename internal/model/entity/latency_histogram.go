@@ -0,0 +1,90 @@
+package entity
+
+import "sort"
+
+// LatencyHistogram is the latency distribution of one or more runs, every
+// latency weighing the requests it stands for. A run keeping a subset of
+// its samples has each of them stand for several requests, so merging runs
+// of different sizes weighs each of them by its requests, rather than by
+// its samples or by averaging its summary percentiles.
+type LatencyHistogram struct {
+	bins  []latencyBin // ordered by latency
+	total float64      // requests the histogram stands for
+}
+
+type latencyBin struct {
+	latency float64 // in seconds
+	weight  float64 // requests
+}
+
+// NewLatencyHistogram returns the histogram of a run from its persisted
+// samples and the number of samples it saw, its successful requests. Seen
+// is taken as the number of samples when lower.
+func NewLatencyHistogram(samples []LatencySample, seen int) *LatencyHistogram {
+	histogram := &LatencyHistogram{bins: make([]latencyBin, 0, len(samples))}
+	if len(samples) == 0 {
+		return histogram
+	}
+
+	weight := float64(max(seen, len(samples))) / float64(len(samples))
+	for _, sample := range samples {
+		histogram.bins = append(histogram.bins, latencyBin{latency: sample.Latency, weight: weight})
+	}
+	sort.Slice(histogram.bins, func(i, j int) bool {
+		return histogram.bins[i].latency < histogram.bins[j].latency
+	})
+	histogram.total = weight * float64(len(samples))
+	return histogram
+}
+
+// MergeWeighted returns the histogram of the union of the runs of
+// histograms, each of them weighing its requests. The histograms are left
+// unchanged.
+func MergeWeighted(histograms ...*LatencyHistogram) *LatencyHistogram {
+	merged := &LatencyHistogram{}
+	for _, histogram := range histograms {
+		merged.bins = append(merged.bins, histogram.bins...)
+		merged.total += histogram.total
+	}
+	sort.SliceStable(merged.bins, func(i, j int) bool {
+		return merged.bins[i].latency < merged.bins[j].latency
+	})
+	return merged
+}
+
+// Requests returns the number of requests the histogram stands for.
+func (h *LatencyHistogram) Requests() float64 {
+	return h.total
+}
+
+// Percentile returns the weighted nearest-rank latency of rank, between 0
+// and 100, in seconds. It reports false for an empty histogram.
+func (h *LatencyHistogram) Percentile(rank float64) (float64, bool) {
+	if len(h.bins) == 0 {
+		return 0, false
+	}
+
+	// The tolerance keeps the sums of fractional weights from missing a rank they reach.
+	target := rank / 100 * h.total
+	tolerance := h.total * 1e-9
+	var cumulative float64
+	for _, bin := range h.bins {
+		cumulative += bin.weight
+		if cumulative+tolerance >= target {
+			return bin.latency, true
+		}
+	}
+	return h.bins[len(h.bins)-1].latency, true
+}
+
+// Percentiles returns the latency of every rank of PercentileRank, in
+// seconds, none for an empty histogram.
+func (h *LatencyHistogram) Percentiles() map[PercentileRank]float64 {
+	percentiles := make(map[PercentileRank]float64)
+	for rank, value := range map[PercentileRank]float64{P50: 50, P95: 95, P99: 99, P999: 99.9} {
+		if latency, ok := h.Percentile(value); ok {
+			percentiles[rank] = latency
+		}
+	}
+	return percentiles
+}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+// constantHistogram returns the histogram of a run of requests that kept
+// samples of them, every one of latency seconds.
+func constantHistogram(latency float64, samples, requests int) *LatencyHistogram {
+	kept := make([]LatencySample, samples)
+	for i := range kept {
+		kept[i] = LatencySample{SentAt: time.Unix(int64(i), 0), Latency: latency}
+	}
+	return NewLatencyHistogram(kept, requests)
+}
+
+func TestMergeWeighted(t *testing.T) {
+	// Both runs kept 10 samples, but the fast one stands for 100 requests
+	// and the slow one for 10. Weighing by samples would put p75 at the
+	// slow latency, and averaging the percentiles would put p50 at 0.55.
+	fast := constantHistogram(0.1, 10, 100)
+	slow := constantHistogram(1.0, 10, 10)
+
+	merged := MergeWeighted(fast, slow)
+	if merged.Requests() != 110 {
+		t.Errorf("merged histogram stands for %v requests, want 110", merged.Requests())
+	}
+
+	tests := []struct {
+		rank float64
+		want float64
+	}{
+		{rank: 0, want: 0.1},
+		{rank: 50, want: 0.1},
+		{rank: 75, want: 0.1},
+		{rank: 100.0 * 100 / 110, want: 0.1}, // the last fast request
+		{rank: 95, want: 1.0},
+		{rank: 100, want: 1.0},
+	}
+	for _, test := range tests {
+		if got, ok := merged.Percentile(test.rank); !ok || got != test.want {
+			t.Errorf("p%v = %v, %v, want %v", test.rank, got, ok, test.want)
+		}
+	}
+
+	if fast.Requests() != 100 || slow.Requests() != 10 || len(fast.bins) != 10 || len(slow.bins) != 10 {
+		t.Error("merging changed the histograms merged")
+	}
+}
+
+func TestMergeWeightedFractional(t *testing.T) {
+	// Three samples of 10 requests weigh 10/3 each, and the sums of those
+	// must still reach the ranks they stand for.
+	merged := MergeWeighted(constantHistogram(0.2, 3, 10), constantHistogram(0.4, 3, 10))
+	if got, _ := merged.Percentile(50); got != 0.2 {
+		t.Errorf("p50 = %v, want 0.2", got)
+	}
+	if got, _ := merged.Percentile(50.1); got != 0.4 {
+		t.Errorf("p50.1 = %v, want 0.4", got)
+	}
+}
+
+func TestMergeWeightedEmpty(t *testing.T) {
+	if _, ok := MergeWeighted().Percentile(50); ok {
+		t.Error("merging no histograms reported a percentile")
+	}
+
+	empty := NewLatencyHistogram(nil, 0)
+	merged := MergeWeighted(empty, constantHistogram(0.3, 2, 2))
+	if got, ok := merged.Percentile(99); !ok || got != 0.3 {
+		t.Errorf("p99 = %v, %v, want 0.3 from the only run with samples", got, ok)
+	}
+	if len(merged.Percentiles()) != 4 {
+		t.Errorf("percentiles = %v, want every rank", merged.Percentiles())
+	}
+}
@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bounds of the raw latency samples persisted per worker.
+const (
+	DefaultSampleCap = 100_000
+	MaxSampleCap     = 1_000_000
+)
+
+// LatencySample is the raw latency of a single successful request.
+type LatencySample struct {
+	SentAt  time.Time `json:"sent_at"`
+	Latency float64   `json:"latency"` // in seconds
+	Phase   Phase     `json:"phase"`
+}
+
+// sampleReservoir keeps a uniform random subset of at most capacity samples
+// (reservoir sampling), so a run larger than the cap is thinned evenly
+// instead of losing its tail.
+type sampleReservoir struct {
+	mu       sync.Mutex
+	capacity int
+	samples  []LatencySample
+	seen     int
+	rng      *rand.Rand
+}
+
+func newSampleReservoir(capacity int, rng *rand.Rand) *sampleReservoir {
+	return &sampleReservoir{
+		capacity: capacity,
+		rng:      rng,
+	}
+}
+
+// add reports true for the sample that first overflows the capacity.
+func (r *sampleReservoir) add(sample LatencySample) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen++
+	if len(r.samples) < r.capacity {
+		r.samples = append(r.samples, sample)
+		return false
+	}
+
+	// Every one of the seen samples ends up kept with the same probability capacity/seen.
+	if j := r.rng.Intn(r.seen); j < r.capacity {
+		r.samples[j] = sample
+	}
+	return r.seen == r.capacity+1
+}
+
+// result returns the kept samples in the order they were sent and the number of samples seen.
+func (r *sampleReservoir) result() ([]LatencySample, int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	samples := make([]LatencySample, len(r.samples))
+	copy(samples, r.samples)
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].SentAt.Before(samples[j].SentAt)
+	})
+	return samples, r.seen
+}
+
+// recordSample keeps the latency of a request if the worker persists its samples.
+func (w *Worker) recordSample(sentAt time.Time, latency time.Duration, phase Phase) {
+	if w.samples == nil {
+		return
+	}
+
+	if w.samples.add(LatencySample{SentAt: sentAt.UTC(), Latency: latency.Seconds(), Phase: phase}) {
+		w.log.Warn().Msgf("Worker %d exceeded its cap of %d latency samples, the persisted samples are now a uniform random subset", w.ID, w.samples.capacity)
+	}
+}
+
+// persistSamples stores the kept samples and records how many were seen.
+func (w *Worker) persistSamples(store WorkerStore) {
+	if w.samples == nil {
+		return
+	}
+
+	samples, seen := w.samples.result()
+	w.Metrics.SetSampleCounts(seen, len(samples))
+	if err := store.InsertSamples(w.ID, samples); err != nil {
+		w.log.Error().Err(err).Msg("Error inserting latency samples")
+	}
+}
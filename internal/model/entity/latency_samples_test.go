@@ -0,0 +1,112 @@
+package entity
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// sampleAt returns the sample of the request number i of a run started at start.
+func sampleAt(start time.Time, i int) LatencySample {
+	return LatencySample{SentAt: start.Add(time.Duration(i) * time.Millisecond), Latency: float64(i)}
+}
+
+func TestReservoirUnderCapacity(t *testing.T) {
+	reservoir := newSampleReservoir(10, rand.New(rand.NewSource(1)))
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		if reservoir.add(sampleAt(start, i)) {
+			t.Fatalf("sample %d reported as overflowing a reservoir of 10", i)
+		}
+	}
+
+	samples, seen := reservoir.result()
+	if seen != 10 || len(samples) != 10 {
+		t.Fatalf("seen %d and kept %d samples, want 10 of both", seen, len(samples))
+	}
+	for i, sample := range samples {
+		if sample.Latency != float64(i) {
+			t.Errorf("sample %d = %v, want every sample kept in order", i, sample.Latency)
+		}
+	}
+}
+
+func TestReservoirOverflow(t *testing.T) {
+	reservoir := newSampleReservoir(10, rand.New(rand.NewSource(1)))
+	start := time.Now()
+	overflows := 0
+	for i := 0; i < 1000; i++ {
+		if reservoir.add(sampleAt(start, i)) {
+			overflows++
+			if i != 10 {
+				t.Errorf("overflow reported at sample %d, want 10", i)
+			}
+		}
+	}
+	if overflows != 1 {
+		t.Errorf("overflow reported %d times, want once", overflows)
+	}
+
+	samples, seen := reservoir.result()
+	if seen != 1000 || len(samples) != 10 {
+		t.Fatalf("seen %d and kept %d samples, want 1000 and 10", seen, len(samples))
+	}
+	for i := 1; i < len(samples); i++ {
+		if !samples[i].SentAt.After(samples[i-1].SentAt) {
+			t.Fatalf("samples not in the order they were sent, or kept twice: %v", samples)
+		}
+	}
+}
+
+// TestReservoirUniform checks that every sample of the stream is kept with
+// the same probability capacity/stream, wherever it is in the stream.
+func TestReservoirUniform(t *testing.T) {
+	const (
+		capacity = 10
+		stream   = 100
+		trials   = 10_000
+	)
+	rng := rand.New(rand.NewSource(42))
+	start := time.Now()
+
+	kept := make([]int, stream)
+	for trial := 0; trial < trials; trial++ {
+		reservoir := newSampleReservoir(capacity, rng)
+		for i := 0; i < stream; i++ {
+			reservoir.add(sampleAt(start, i))
+		}
+		samples, _ := reservoir.result()
+		for _, sample := range samples {
+			kept[int(sample.Latency)]++
+		}
+	}
+
+	// Every sample is kept a binomial number of times, 5 standard deviations
+	// leaves no room for flakiness while still catching a biased reservoir.
+	p := float64(capacity) / stream
+	expected := trials * p
+	tolerance := 5 * math.Sqrt(trials*p*(1-p))
+	var chiSquare float64
+	for i, count := range kept {
+		if math.Abs(float64(count)-expected) > tolerance {
+			t.Errorf("sample %d kept %d times, want %.0f±%.0f", i, count, expected, tolerance)
+		}
+		chiSquare += math.Pow(float64(count)-expected, 2) / expected
+	}
+
+	// The critical value of the chi-square with 99 degrees of freedom at p=0.001.
+	if chiSquare > 148.2 {
+		t.Errorf("chi-square = %.1f, the samples aren't kept uniformly", chiSquare)
+	}
+
+	// The tail of the stream, dropped by a truncating cap, is kept as often as its head.
+	var head, tail int
+	for i := 0; i < capacity; i++ {
+		head += kept[i]
+		tail += kept[stream-capacity+i]
+	}
+	if ratio := float64(tail) / float64(head); ratio < 0.9 || ratio > 1.1 {
+		t.Errorf("tail kept %d times against %d for the head", tail, head)
+	}
+}
@@ -0,0 +1,171 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maintenanceCheckInterval is how often a running worker checks whether its environment entered a maintenance window.
+const maintenanceCheckInterval = 30 * time.Second
+
+// MaintenancePolicy is what happens to a worker created during a maintenance window.
+type MaintenancePolicy string
+
+const (
+	MaintenanceReject MaintenancePolicy = "reject"
+	MaintenanceDefer  MaintenancePolicy = "defer" // the run starts once the window is over
+)
+
+// MaintenanceWindow is a time of day range during which an environment
+// can't be trusted, e.g. while it is rebuilt. A window whose end is before
+// its start spans midnight.
+type MaintenanceWindow struct {
+	Name     string         `json:"name"`
+	Start    string         `json:"start"`              // HH:MM
+	End      string         `json:"end"`                // HH:MM
+	Timezone string         `json:"timezone,omitempty"` // IANA name, UTC when empty
+	Weekdays []time.Weekday `json:"weekdays,omitempty"` // on which the window starts, 0 being Sunday, every day when empty
+}
+
+// MaintenanceError rejects a worker created during a maintenance window.
+type MaintenanceError struct {
+	Window MaintenanceWindow
+	Until  time.Time
+}
+
+func (e *MaintenanceError) Error() string {
+	return fmt.Sprintf("the environment is in its maintenance window %s until %s", e.Window, e.Until.UTC().Format(time.RFC3339))
+}
+
+func (mw MaintenanceWindow) String() string {
+	timezone := mw.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	return fmt.Sprintf("%q (%s-%s %s)", mw.Name, mw.Start, mw.End, timezone)
+}
+
+// Valid reports whether the times, the timezone and the weekdays can be parsed.
+func (mw MaintenanceWindow) Valid() bool {
+	_, _, _, err := mw.parse()
+	if err != nil || mw.Start == mw.End {
+		return false
+	}
+	for _, day := range mw.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return false
+		}
+	}
+	return true
+}
+
+func (mw MaintenanceWindow) parse() (start, end time.Duration, location *time.Location, err error) {
+	location = time.UTC
+	if mw.Timezone != "" {
+		if location, err = time.LoadLocation(mw.Timezone); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+	if start, err = timeOfDay(mw.Start); err != nil {
+		return 0, 0, nil, err
+	}
+	if end, err = timeOfDay(mw.End); err != nil {
+		return 0, 0, nil, err
+	}
+	return start, end, location, nil
+}
+
+func timeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active reports whether t falls in the window and, if so, when the window ends.
+func (mw MaintenanceWindow) Active(t time.Time) (bool, time.Time) {
+	start, end, location, err := mw.parse()
+	if err != nil {
+		return false, time.Time{}
+	}
+
+	local := t.In(location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+
+	// A window spanning midnight may have started the day before.
+	for _, day := range []time.Time{midnight, midnight.AddDate(0, 0, -1)} {
+		if !mw.startsOn(day.Weekday()) {
+			continue
+		}
+		from := day.Add(start)
+		to := day.Add(end)
+		if end < start {
+			to = day.AddDate(0, 0, 1).Add(end)
+		}
+		if !local.Before(from) && local.Before(to) {
+			return true, to
+		}
+	}
+	return false, time.Time{}
+}
+
+func (mw MaintenanceWindow) startsOn(day time.Weekday) bool {
+	if len(mw.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range mw.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// ActiveMaintenanceWindow returns the window of the environment t falls in and when it ends.
+func (e *Environment) ActiveMaintenanceWindow(t time.Time) (*MaintenanceWindow, time.Time, bool) {
+	for i := range e.MaintenanceWindows {
+		if active, until := e.MaintenanceWindows[i].Active(t); active {
+			return &e.MaintenanceWindows[i], until, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// watchMaintenance annotates the worker with a warning every time its
+// environment enters a maintenance window while it runs. The run carries on.
+func (w *Worker) watchMaintenance(ctx context.Context, store WorkerStore) (stop func()) {
+	if w.Environment == nil || len(w.Environment.MaintenanceWindows) == 0 {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(maintenanceCheckInterval)
+		defer ticker.Stop()
+
+		_, _, inWindow := w.Environment.ActiveMaintenanceWindow(time.Now())
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				window, _, active := w.Environment.ActiveMaintenanceWindow(now)
+				if active && !inWindow {
+					w.addWarning(store, fmt.Sprintf("entered the maintenance window %s at %s, the results may be unreliable", window, now.UTC().Format(time.RFC3339)))
+				}
+				inWindow = active
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -2,19 +2,55 @@ package entity
 
 import (
 	"github.com/montanaflynn/stats"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 )
 
 type Metrics struct {
-	MaxLatency     float64                    `json:"max_latency"` // in seconds
-	Percentiles    map[PercentileRank]float64 `json:"percentiles"` // in seconds
-	TotalRequests  int                        `json:"total_requests"`
-	FailedRequests int                        `json:"failed_requests"`
-	ErrorRate      float64                    `json:"error_rate"`
-	latencies      []time.Duration
-	mu             sync.Mutex
+	MaxLatency           float64                      `json:"max_latency"` // in seconds
+	Percentiles          map[PercentileRank]float64   `json:"percentiles"` // in seconds
+	TotalRequests        int                          `json:"total_requests"`
+	FailedRequests       int                          `json:"failed_requests"`
+	CancelledRequests    int                          `json:"cancelled_requests"`         // aborted when the run ended, neither succeeded nor failed
+	SlowRequests         int                          `json:"slow_requests,omitempty"`    // over the latency failure threshold, also failed unless only counted
+	NeutralRequests      int                          `json:"neutral_requests,omitempty"` // 3xx responses under RedirectNeutral, left out of the error rate
+	ErrorRate            float64                      `json:"error_rate"`
+	Throughput           float64                      `json:"throughput"`            // in requests per second
+	EffectiveConcurrency float64                      `json:"effective_concurrency"` // average number of requests in flight
+	ErrorClasses         map[ErrorClass]int           `json:"error_classes,omitempty"`
+	StatusClasses        map[ResponseClass]int        `json:"status_classes,omitempty"` // responses received per status class
+	Diagnostics          []string                     `json:"diagnostics,omitempty"`
+	Phases               map[Phase]*PhaseMetrics      `json:"phases,omitempty"`            // the global numbers are the union of all the phases
+	CircuitOpenTime      float64                      `json:"circuit_open_time,omitempty"` // in seconds
+	CircuitOpenings      int                          `json:"circuit_openings,omitempty"`
+	GlobalThrottleTime   float64                      `json:"global_throttle_time,omitempty"` // in seconds, waited on the process-wide request rate
+	DNSLookups           int                          `json:"dns_lookups,omitempty"`
+	AvgDNSLatency        float64                      `json:"avg_dns_latency,omitempty"`    // in seconds
+	MaxDNSLatency        float64                      `json:"max_dns_latency,omitempty"`    // in seconds
+	ResolvedAddresses    map[string]int               `json:"resolved_addresses,omitempty"` // requests per backend ip:port
+	ProtocolVersions     map[string]int               `json:"protocol_versions,omitempty"`  // responses per HTTP version, e.g. HTTP/2.0
+	ServedBy             map[string]map[string]int    `json:"served_by,omitempty"`          // responses per value of every tracked header
+	Breakdown            []StageTiming                `json:"breakdown,omitempty"`          // in the order the stages happen
+	SamplesSeen          int                          `json:"samples_seen,omitempty"`
+	SamplesStored        int                          `json:"samples_stored,omitempty"` // lower than SamplesSeen once the sample cap was hit
+	KeepAlivePings       int                          `json:"keep_alive_pings,omitempty"`
+	ReconnectsAvoided    int                          `json:"reconnects_avoided,omitempty"` // pings that found their connection still open
+	SlowestRequests      []SlowRequest                `json:"slowest_requests,omitempty"`   // slowest first, only with a correlation header
+	Variants             map[string]*Metrics          `json:"variants,omitempty"`           // per body variant name
+	Connections          map[Connection]*PhaseMetrics `json:"connections,omitempty"`        // cold and warm requests, only when measured
+	latencies            []time.Duration
+	stageDurations       map[Stage][]time.Duration
+	window               *rateWindow // of the live request rate, nil when untracked
+	mu                   sync.Mutex
+}
+
+// StageTiming summarises a stage over every successful request of the run.
+type StageTiming struct {
+	Stage Stage   `json:"stage"`
+	Mean  float64 `json:"mean"` // in seconds
+	P95   float64 `json:"p95"`  // in seconds
 }
 
 func NewMetrics() *Metrics {
@@ -23,6 +59,12 @@ func NewMetrics() *Metrics {
 	}
 }
 
+// maxResolvedAddresses caps the addresses tracked for targets behind large pools.
+const (
+	maxResolvedAddresses = 32
+	otherAddresses       = "other"
+)
+
 type PercentileRank string
 
 const (
@@ -32,30 +74,160 @@ const (
 	P999 PercentileRank = "99.9"
 )
 
-func (m *Metrics) AddLatency(latency time.Duration) {
+// Phase labels the part of a run a request belongs to.
+type Phase string
+
+const (
+	PhaseMain     Phase = "main"
+	PhaseRampUp   Phase = "ramp_up"
+	PhaseRampDown Phase = "ramp_down"
+	PhaseBaseline Phase = "baseline"
+	PhaseSpike    Phase = "spike"
+)
+
+// PhaseMetrics aggregates the requests of a single phase of a run.
+type PhaseMetrics struct {
+	MaxLatency        float64                    `json:"max_latency"` // in seconds
+	Percentiles       map[PercentileRank]float64 `json:"percentiles"` // in seconds
+	TotalRequests     int                        `json:"total_requests"`
+	FailedRequests    int                        `json:"failed_requests"`
+	CancelledRequests int                        `json:"cancelled_requests"`
+	SlowRequests      int                        `json:"slow_requests,omitempty"`
+	NeutralRequests   int                        `json:"neutral_requests,omitempty"`
+	ErrorRate         float64                    `json:"error_rate"`
+	latencies         []time.Duration
+}
+
+// phase returns the aggregate of the given phase, PhaseMain when none is
+// given, creating it on first use. The caller must hold m.mu.
+func (m *Metrics) phase(phase []Phase) *PhaseMetrics {
+	label := PhaseMain
+	if len(phase) > 0 && phase[0] != "" {
+		label = phase[0]
+	}
+
+	if m.Phases == nil {
+		m.Phases = make(map[Phase]*PhaseMetrics)
+	}
+
+	aggregate, exists := m.Phases[label]
+	if !exists {
+		aggregate = &PhaseMetrics{Percentiles: make(map[PercentileRank]float64)}
+		m.Phases[label] = aggregate
+	}
+
+	return aggregate
+}
+
+func (m *Metrics) AddLatency(latency time.Duration, phase ...Phase) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.latencies = append(m.latencies, latency)
+
+	aggregate := m.phase(phase)
+	aggregate.latencies = append(aggregate.latencies, latency)
+}
+
+// AddConnectionRequest counts a request under connection, with its latency
+// if it received a response. Cancelled requests aren't counted.
+func (m *Metrics) AddConnectionRequest(connection Connection, latency time.Duration, succeeded bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Connections == nil {
+		m.Connections = make(map[Connection]*PhaseMetrics)
+	}
+
+	aggregate, exists := m.Connections[connection]
+	if !exists {
+		aggregate = &PhaseMetrics{Percentiles: make(map[PercentileRank]float64)}
+		m.Connections[connection] = aggregate
+	}
+
+	aggregate.TotalRequests++
+	if !succeeded {
+		aggregate.FailedRequests++
+		return
+	}
+	aggregate.latencies = append(aggregate.latencies, latency)
+}
+
+func (m *Metrics) SetVariants(variants map[string]*Metrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Variants = variants
+}
+
+// AddSlowRequest keeps the request if it is among the slowestRequestsKept slowest so far.
+func (m *Metrics) AddSlowRequest(requestID string, latency time.Duration, phase Phase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seconds := latency.Seconds()
+	n := len(m.SlowestRequests)
+	if n == slowestRequestsKept && seconds <= m.SlowestRequests[n-1].Latency {
+		return
+	}
+
+	i := sort.Search(n, func(i int) bool { return m.SlowestRequests[i].Latency < seconds })
+	if n < slowestRequestsKept {
+		m.SlowestRequests = append(m.SlowestRequests, SlowRequest{})
+	}
+	copy(m.SlowestRequests[i+1:], m.SlowestRequests[i:])
+	m.SlowestRequests[i] = SlowRequest{RequestID: requestID, Phase: phase, Latency: seconds}
 }
 
 func (m *Metrics) CalculateMaxLatency() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	m.MaxLatency = maxLatency(m.latencies)
+	for _, aggregate := range m.Phases {
+		aggregate.MaxLatency = maxLatency(aggregate.latencies)
+	}
+	for _, aggregate := range m.Connections {
+		aggregate.MaxLatency = maxLatency(aggregate.latencies)
+	}
+}
+
+func maxLatency(latencies []time.Duration) float64 {
 	var maximum time.Duration
-	for _, latency := range m.latencies {
+	for _, latency := range latencies {
 		maximum = max(maximum, latency)
 	}
-	m.MaxLatency = float64(maximum) / float64(time.Second)
+	return float64(maximum) / float64(time.Second)
 }
 
 func (m *Metrics) CalculatePercentiles(percentileRanks ...PercentileRank) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	latencies := make([]float64, len(m.latencies))
-	for i, latency := range m.latencies {
+	for _, aggregate := range m.Phases {
+		if len(aggregate.latencies) == 0 {
+			// A phase in which every request failed has no latency to rank.
+			continue
+		}
+		if err := calculatePercentiles(aggregate.latencies, aggregate.Percentiles, percentileRanks); err != nil {
+			return err
+		}
+	}
+
+	for _, aggregate := range m.Connections {
+		if len(aggregate.latencies) == 0 {
+			continue
+		}
+		if err := calculatePercentiles(aggregate.latencies, aggregate.Percentiles, percentileRanks); err != nil {
+			return err
+		}
+	}
+
+	return calculatePercentiles(m.latencies, m.Percentiles, percentileRanks)
+}
+
+func calculatePercentiles(durations []time.Duration, percentiles map[PercentileRank]float64, percentileRanks []PercentileRank) error {
+	latencies := make([]float64, len(durations))
+	for i, latency := range durations {
 		latencies[i] = float64(latency) / float64(time.Second)
 	}
 
@@ -68,7 +240,7 @@ func (m *Metrics) CalculatePercentiles(percentileRanks ...PercentileRank) error
 		if err != nil {
 			return err
 		}
-		m.Percentiles[rank] = result
+		percentiles[rank] = result
 	}
 
 	return nil
@@ -82,26 +254,239 @@ func calculatePercentile(latencies []float64, rank float64) (float64, error) {
 	return result, nil
 }
 
-func (m *Metrics) IncrementTotalRequests() {
+func (m *Metrics) IncrementTotalRequests(phase ...Phase) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.TotalRequests++
+	m.phase(phase).TotalRequests++
+	if m.window != nil {
+		m.window.add(time.Now())
+	}
+}
+
+// TrackRate keeps the request rate over the last span of the run, as
+// reported by CurrentRate.
+func (m *Metrics) TrackRate(span time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.window = newRateWindow(span)
+}
+
+// CurrentRate is the request rate in requests per second over the tracked
+// window. It reports false when the rate isn't tracked or no second of the
+// run is complete yet.
+func (m *Metrics) CurrentRate() (float64, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.window == nil {
+		return 0, false
+	}
+	return m.window.rate(time.Now())
+}
+
+func (m *Metrics) GetTotalRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.TotalRequests
 }
 
-func (m *Metrics) IncrementFailedRequests() {
+// allFailed reports whether requests were recorded and all of them failed.
+// The steps of a run read it while the requests of the step still complete.
+func (m *Metrics) allFailed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.TotalRequests > 0 && m.TotalRequests == m.FailedRequests
+}
+
+func (m *Metrics) IncrementFailedRequests(phase ...Phase) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	m.FailedRequests++
+	m.phase(phase).FailedRequests++
+}
+
+func (m *Metrics) IncrementCancelledRequests(phase ...Phase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.CancelledRequests++
+	m.phase(phase).CancelledRequests++
+}
+
+func (m *Metrics) IncrementSlowRequests(phase ...Phase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SlowRequests++
+	m.phase(phase).SlowRequests++
+}
+
+func (m *Metrics) IncrementNeutralRequests(phase ...Phase) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.NeutralRequests++
+	m.phase(phase).NeutralRequests++
+}
+
+func (m *Metrics) IncrementStatusClass(class ResponseClass) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.StatusClasses == nil {
+		m.StatusClasses = make(map[ResponseClass]int)
+	}
+	m.StatusClasses[class]++
+}
+
+func (m *Metrics) IncrementErrorClass(class ErrorClass) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ErrorClasses == nil {
+		m.ErrorClasses = make(map[ErrorClass]int)
+	}
+	m.ErrorClasses[class]++
+}
+
+func (m *Metrics) AddDiagnostic(diagnostic string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Diagnostics = append(m.Diagnostics, diagnostic)
+}
+
+func (m *Metrics) AddDNSLatency(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	seconds := latency.Seconds()
+	m.DNSLookups++
+	m.AvgDNSLatency += (seconds - m.AvgDNSLatency) / float64(m.DNSLookups)
+	m.MaxDNSLatency = max(m.MaxDNSLatency, seconds)
+}
+
+// AddResolvedAddress counts a request sent to address. Past maxResolvedAddresses
+// distinct addresses the new ones are counted under otherAddresses.
+func (m *Metrics) AddResolvedAddress(address string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ResolvedAddresses == nil {
+		m.ResolvedAddresses = make(map[string]int)
+	}
+
+	if _, exists := m.ResolvedAddresses[address]; !exists && len(m.ResolvedAddresses) >= maxResolvedAddresses {
+		address = otherAddresses
+	}
+	m.ResolvedAddresses[address]++
+}
+
+func (m *Metrics) AddStageDurations(durations map[Stage]time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.stageDurations == nil {
+		m.stageDurations = make(map[Stage][]time.Duration)
+	}
+	for stage, duration := range durations {
+		m.stageDurations[stage] = append(m.stageDurations[stage], duration)
+	}
+}
+
+func (m *Metrics) CalculateBreakdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Breakdown = nil
+	for _, stage := range stages {
+		durations := m.stageDurations[stage]
+		if len(durations) == 0 {
+			continue
+		}
+
+		seconds := make([]float64, len(durations))
+		var total float64
+		for i, duration := range durations {
+			seconds[i] = duration.Seconds()
+			total += seconds[i]
+		}
+
+		p95, err := calculatePercentile(seconds, 95)
+		if err != nil {
+			continue
+		}
+
+		m.Breakdown = append(m.Breakdown, StageTiming{
+			Stage: stage,
+			Mean:  total / float64(len(seconds)),
+			P95:   p95,
+		})
+	}
+}
+
+func (m *Metrics) AddKeepAlivePing(reused bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.KeepAlivePings++
+	if reused {
+		m.ReconnectsAvoided++
+	}
+}
+
+func (m *Metrics) SetSampleCounts(seen, stored int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.SamplesSeen = seen
+	m.SamplesStored = stored
+}
+
+func (m *Metrics) SetCircuitBreakerStats(openFor time.Duration, openings int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.CircuitOpenTime = openFor.Seconds()
+	m.CircuitOpenings = openings
+}
+
+// AddGlobalThrottle adds the time a request waited on the process-wide request rate.
+func (m *Metrics) AddGlobalThrottle(waited time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.GlobalThrottleTime += waited.Seconds()
 }
 
 func (m *Metrics) CalculateErrorRate() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if m.TotalRequests == 0 {
-		m.ErrorRate = 0
+	m.ErrorRate = errorRate(m.TotalRequests-m.NeutralRequests, m.FailedRequests)
+	for _, aggregate := range m.Phases {
+		aggregate.ErrorRate = errorRate(aggregate.TotalRequests-aggregate.NeutralRequests, aggregate.FailedRequests)
+	}
+	for _, aggregate := range m.Connections {
+		aggregate.ErrorRate = errorRate(aggregate.TotalRequests, aggregate.FailedRequests)
+	}
+}
+
+func errorRate(total, failed int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failed) / float64(total)
+}
+
+// CalculateThroughput derives the achieved request rate and the effective
+// concurrency (Little's law: time spent in requests / wall time) over elapsed.
+func (m *Metrics) CalculateThroughput(elapsed time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if elapsed <= 0 {
+		m.Throughput = 0
+		m.EffectiveConcurrency = 0
 		return
 	}
 
-	m.ErrorRate = float64(m.FailedRequests) / float64(m.TotalRequests)
+	var busy time.Duration
+	for _, latency := range m.latencies {
+		busy += latency
+	}
+
+	m.Throughput = float64(m.TotalRequests) / elapsed.Seconds()
+	m.EffectiveConcurrency = float64(busy) / float64(elapsed)
 }
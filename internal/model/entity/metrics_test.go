@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhasesAddUpToGlobal(t *testing.T) {
+	m := NewMetrics()
+
+	requests := []struct {
+		phase   Phase
+		latency time.Duration
+		failed  bool
+	}{
+		{PhaseRampUp, 40 * time.Millisecond, false},
+		{PhaseRampUp, 0, true},
+		{PhaseMain, 10 * time.Millisecond, false},
+		{PhaseMain, 20 * time.Millisecond, false},
+		{PhaseMain, 90 * time.Millisecond, false},
+		{PhaseMain, 0, true},
+		{PhaseRampDown, 30 * time.Millisecond, false},
+		{"", 50 * time.Millisecond, false}, // counted under PhaseMain
+	}
+	for _, r := range requests {
+		m.IncrementTotalRequests(r.phase)
+		if r.failed {
+			m.IncrementFailedRequests(r.phase)
+			continue
+		}
+		m.AddLatency(r.latency, r.phase)
+	}
+	m.IncrementCancelledRequests(PhaseRampDown)
+	m.IncrementTotalRequests(PhaseRampDown)
+
+	if err := m.CalculatePercentiles(P50, P99); err != nil {
+		t.Fatal(err)
+	}
+	m.CalculateMaxLatency()
+	m.CalculateErrorRate()
+
+	if len(m.Phases) != 3 {
+		t.Fatalf("got %d phases, want 3: %v", len(m.Phases), m.Phases)
+	}
+	if got := m.Phases[PhaseMain].TotalRequests; got != 5 {
+		t.Errorf("main total requests = %d, want 5", got)
+	}
+
+	var total, failed, cancelled, latencies int
+	var maxLatency float64
+	for _, aggregate := range m.Phases {
+		total += aggregate.TotalRequests
+		failed += aggregate.FailedRequests
+		cancelled += aggregate.CancelledRequests
+		latencies += len(aggregate.latencies)
+		maxLatency = max(maxLatency, aggregate.MaxLatency)
+	}
+
+	if total != m.TotalRequests {
+		t.Errorf("total requests of the phases = %d, want %d", total, m.TotalRequests)
+	}
+	if failed != m.FailedRequests {
+		t.Errorf("failed requests of the phases = %d, want %d", failed, m.FailedRequests)
+	}
+	if cancelled != m.CancelledRequests {
+		t.Errorf("cancelled requests of the phases = %d, want %d", cancelled, m.CancelledRequests)
+	}
+	if latencies != len(m.latencies) {
+		t.Errorf("latencies of the phases = %d, want %d", latencies, len(m.latencies))
+	}
+	if maxLatency != m.MaxLatency {
+		t.Errorf("max latency of the phases = %v, want %v", maxLatency, m.MaxLatency)
+	}
+	if want := 2.0 / 9; m.ErrorRate != want {
+		t.Errorf("error rate = %v, want %v", m.ErrorRate, want)
+	}
+}
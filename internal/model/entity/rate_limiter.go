@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter spaces out the requests of every worker sharing it, so that
+// together they never send more than its rate. A nil limiter is unlimited.
+type RateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration // 0 while unlimited
+	next     time.Time     // the earliest time the next request may be sent
+}
+
+// NewRateLimiter returns a limiter of rps requests per second, unlimited
+// while rps isn't positive.
+func NewRateLimiter(rps float64) *RateLimiter {
+	l := &RateLimiter{}
+	l.SetRate(rps)
+	return l
+}
+
+// SetRate changes the rate of the limiter, the slots already handed out
+// being kept.
+func (l *RateLimiter) SetRate(rps float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rps <= 0 {
+		l.interval = 0
+		return
+	}
+	l.interval = time.Duration(float64(time.Second) / rps)
+}
+
+// wait reserves the next free slot and blocks until it comes, reporting how
+// long it waited, or false if ctx ended first. The slots are handed out in
+// the order they are asked for, whatever worker asks.
+func (l *RateLimiter) wait(ctx context.Context) (time.Duration, bool) {
+	if l == nil {
+		return 0, true
+	}
+
+	l.mu.Lock()
+	if l.interval == 0 {
+		l.mu.Unlock()
+		return 0, true
+	}
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := slot.Sub(now)
+	if delay <= 0 {
+		return 0, true
+	}
+	return delay, sleep(ctx, delay)
+}
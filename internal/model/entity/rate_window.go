@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"time"
+)
+
+// DefaultRateWindow is the span of the live request rate when none is configured.
+const DefaultRateWindow = 5 * time.Second
+
+// MaxRateWindow bounds the span of the live request rate.
+const MaxRateWindow = 5 * time.Minute
+
+// rateWindow counts the requests of the last seconds of a run, one slot per
+// second indexed by the unix second, so a request costs an increment.
+type rateWindow struct {
+	size    int64   // in seconds
+	counts  []int   // one more slot than size for the second going on
+	seconds []int64 // the second counted by each slot, an older one once the slot is stale
+	first   int64   // the second of the first request, 0 before it
+}
+
+// newRateWindow sizes a window in whole seconds, between 1 and MaxRateWindow.
+func newRateWindow(span time.Duration) *rateWindow {
+	size := int(min(max(span, time.Second), MaxRateWindow) / time.Second)
+	return &rateWindow{
+		size:    int64(size),
+		counts:  make([]int, size+1),
+		seconds: make([]int64, size+1),
+	}
+}
+
+func (r *rateWindow) add(now time.Time) {
+	second := now.Unix()
+	if r.first == 0 {
+		r.first = second
+	}
+
+	slot := int(second % int64(len(r.counts)))
+	if r.seconds[slot] != second {
+		r.seconds[slot] = second
+		r.counts[slot] = 0
+	}
+	r.counts[slot]++
+}
+
+// rate is the mean requests per second over the complete seconds of the
+// window before now, fewer of them early in the run. It reports false
+// until a second of the run is complete.
+func (r *rateWindow) rate(now time.Time) (float64, bool) {
+	current := now.Unix()
+	if r.first == 0 || current <= r.first {
+		return 0, false
+	}
+
+	span := min(r.size, current-r.first)
+	requests := 0
+	for slot, second := range r.seconds {
+		if second >= current-span && second < current {
+			requests += r.counts[slot]
+		}
+	}
+	return float64(requests) / float64(span), true
+}
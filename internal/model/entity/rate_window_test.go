@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateWindow(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	window := newRateWindow(DefaultRateWindow)
+
+	// feed sends rps requests evenly over every second from second from to to, excluded.
+	feed := func(from, to, rps int) {
+		for second := from; second < to; second++ {
+			for i := 0; i < rps; i++ {
+				window.add(start.Add(time.Duration(second)*time.Second + time.Duration(i)*time.Second/time.Duration(rps)))
+			}
+		}
+	}
+	check := func(second int, want float64) {
+		t.Helper()
+		got, ok := window.rate(start.Add(time.Duration(second)*time.Second + 300*time.Millisecond))
+		if !ok || got != want {
+			t.Errorf("rate at second %d = %g (%t), want %g", second, got, ok, want)
+		}
+	}
+
+	if _, ok := window.rate(start); ok {
+		t.Error("rate before any request reported, want none")
+	}
+	feed(0, 1, 20)
+	if _, ok := window.rate(start.Add(500 * time.Millisecond)); ok {
+		t.Error("rate reported during the first second, want none")
+	}
+
+	// 20 req/s, early on over the seconds elapsed only.
+	feed(1, 2, 20)
+	check(2, 20)
+	feed(2, 10, 20)
+	check(10, 20)
+
+	// The rate moves to 50 req/s over the span of the window.
+	feed(10, 11, 50)
+	check(11, 26)
+	feed(11, 13, 50)
+	check(13, 38)
+	feed(13, 15, 50)
+	check(15, 50)
+	feed(15, 16, 50)
+	check(16, 50)
+
+	// Silence empties the window.
+	check(18, 30)
+	check(21, 0)
+	check(40, 0)
+}
+
+func TestRateWindowSize(t *testing.T) {
+	tests := []struct {
+		span time.Duration
+		want int64
+	}{
+		{span: 0, want: 1},
+		{span: 500 * time.Millisecond, want: 1},
+		{span: 10 * time.Second, want: 10},
+		{span: 10*time.Second + 900*time.Millisecond, want: 10},
+		{span: time.Hour, want: int64(MaxRateWindow / time.Second)},
+	}
+
+	for _, test := range tests {
+		if got := newRateWindow(test.span).size; got != test.want {
+			t.Errorf("window of %s sized %ds, want %ds", test.span, got, test.want)
+		}
+	}
+}
+
+func TestCurrentRate(t *testing.T) {
+	metrics := NewMetrics()
+	if _, ok := metrics.CurrentRate(); ok {
+		t.Error("rate reported without tracking it, want none")
+	}
+
+	metrics.TrackRate(time.Second)
+	metrics.IncrementTotalRequests(PhaseMain)
+	if _, ok := metrics.CurrentRate(); ok {
+		t.Error("rate reported during the first second, want none")
+	}
+}
@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// redacted replaces the secrets in the logs and the recorded requests.
+const redacted = "REDACTED"
+
+// sensitiveWords mark the header and query parameter names carrying a
+// secret, such as Authorization, X-Api-Key, Cookie or access_token.
+var sensitiveWords = []string{"auth", "token", "key", "secret", "password", "passwd", "cookie", "session", "signature", "credential"}
+
+func sensitiveName(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range sensitiveWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns a copy of header whose sensitive values are redacted.
+func RedactHeaders(header http.Header) http.Header {
+	copied := header.Clone()
+	for name, values := range copied {
+		if sensitiveName(name) {
+			for i := range values {
+				values[i] = redacted
+			}
+		}
+	}
+	return copied
+}
+
+// RedactURL returns rawURL with the password of its user info and its
+// sensitive query parameters redacted, as is when it doesn't parse.
+func RedactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	if parsed.RawQuery != "" {
+		query := parsed.Query()
+		changed := false
+		for name, values := range query {
+			if sensitiveName(name) {
+				for i := range values {
+					values[i] = redacted
+				}
+				changed = true
+			}
+		}
+		if changed {
+			parsed.RawQuery = query.Encode()
+		}
+	}
+	return parsed.Redacted()
+}
@@ -0,0 +1,44 @@
+package entity
+
+import "sync/atomic"
+
+// RequestBudget caps the requests sent by several workers together. A nil
+// budget is unlimited.
+type RequestBudget struct {
+	limit  int64
+	spent  atomic.Int64
+	denied atomic.Int64
+}
+
+// BudgetUsage records how a request budget was spent.
+type BudgetUsage struct {
+	Limit  int `json:"limit"`
+	Spent  int `json:"spent"`  // requests sent within the budget
+	Denied int `json:"denied"` // requests not sent once it was spent
+}
+
+func NewRequestBudget(limit int) *RequestBudget {
+	return &RequestBudget{limit: int64(limit)}
+}
+
+// take reserves a request, false once the budget is spent. The reservations
+// never exceed the limit, whatever the number of workers sharing it.
+func (b *RequestBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if b.spent.Add(1) > b.limit {
+		b.spent.Add(-1)
+		b.denied.Add(1)
+		return false
+	}
+	return true
+}
+
+func (b *RequestBudget) Usage() *BudgetUsage {
+	return &BudgetUsage{
+		Limit:  int(b.limit),
+		Spent:  int(b.spent.Load()),
+		Denied: int(b.denied.Load()),
+	}
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRequestBudget(t *testing.T) {
+	var received atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	}))
+	defer stub.Close()
+
+	tests := []struct {
+		name    string
+		workers int
+		limit   int
+		want    int
+	}{
+		{name: "budget spent", workers: 50, limit: 7, want: 7},
+		{name: "budget exactly spent", workers: 50, limit: 50, want: 50},
+		{name: "budget left over", workers: 50, limit: 80, want: 50},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received.Store(0)
+			budget := NewRequestBudget(test.limit)
+
+			// Every worker probes at the same time and draws from the one budget.
+			results := make([]*ProbeResult, test.workers)
+			var wg sync.WaitGroup
+			for i := 0; i < test.workers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					worker := newTestWorker(stub.URL, 1, 1, WithWorkerRequestBudget(budget))
+					results[i] = worker.Probe(context.Background())
+				}(i)
+			}
+			wg.Wait()
+
+			if got := int(received.Load()); got != test.want {
+				t.Errorf("requests received = %d, want %d", got, test.want)
+			}
+
+			skipped := 0
+			for _, result := range results {
+				if result.Skipped {
+					skipped++
+				}
+			}
+			want := &BudgetUsage{Limit: test.limit, Spent: test.want, Denied: test.workers - test.want}
+			if usage := budget.Usage(); *usage != *want {
+				t.Errorf("usage = %+v, want %+v", *usage, *want)
+			}
+			if skipped != want.Denied {
+				t.Errorf("skipped probes = %d, want %d", skipped, want.Denied)
+			}
+		})
+	}
+}
+
+func TestRequestBudgetUnlimited(t *testing.T) {
+	var budget *RequestBudget
+	for i := 0; i < 100; i++ {
+		if !budget.take() {
+			t.Fatalf("take() = false after %d requests, want a nil budget unlimited", i)
+		}
+	}
+}
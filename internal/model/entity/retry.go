@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"math/rand"
+	"time"
+)
+
+// MaxRetries bounds the retries of a single request.
+const MaxRetries = 10
+
+// JitterStrategy spreads the retry backoffs of the goroutines of a worker,
+// so that they don't retry in lockstep against a recovering target. The
+// strategies are the ones of the AWS Architecture Blog, "Exponential
+// Backoff And Jitter".
+type JitterStrategy string
+
+const (
+	JitterNone         JitterStrategy = "none"         // min(max, base*2^attempt)
+	JitterFull         JitterStrategy = "full"         // random in [0, min(max, base*2^attempt)]
+	JitterEqual        JitterStrategy = "equal"        // half of min(max, base*2^attempt), plus a random part up to the other half
+	JitterDecorrelated JitterStrategy = "decorrelated" // min(max, random in [base, 3*previous]), the first previous being base
+)
+
+// JitterStrategies are the valid strategies of a RetryConfig.
+var JitterStrategies = []JitterStrategy{JitterNone, JitterFull, JitterEqual, JitterDecorrelated}
+
+// RetryConfig retries a failed request up to MaxRetries times, after a
+// backoff growing from BaseDelay up to MaxDelay. Every attempt is a request
+// of its own, counted in the metrics, waiting for the global request rate
+// and going through the circuit breaker.
+type RetryConfig struct {
+	MaxRetries int            `json:"max_retries"`
+	BaseDelay  Duration       `json:"base_delay"`
+	MaxDelay   Duration       `json:"max_delay"`
+	Jitter     JitterStrategy `json:"jitter,omitempty"` // JitterNone when empty
+}
+
+// backoff returns the delay before the retry following the given attempt,
+// 0 for the first one, previous being the delay before that attempt.
+func (c *RetryConfig) backoff(attempt int, previous time.Duration, rng *rand.Rand) time.Duration {
+	base, ceiling := time.Duration(c.BaseDelay), time.Duration(c.MaxDelay)
+
+	if c.Jitter == JitterDecorrelated {
+		if previous < base {
+			previous = base
+		}
+		upper := min(3*previous, ceiling)
+		if upper <= base {
+			return upper
+		}
+		return base + time.Duration(rng.Int63n(int64(upper-base)+1))
+	}
+
+	exponential := ceiling
+	if attempt < 62 && base <= ceiling>>attempt {
+		exponential = base << attempt
+	}
+
+	switch c.Jitter {
+	case JitterFull:
+		return time.Duration(rng.Int63n(int64(exponential) + 1))
+	case JitterEqual:
+		half := exponential / 2
+		return exponential - half + time.Duration(rng.Int63n(int64(half)+1))
+	default:
+		return exponential
+	}
+}
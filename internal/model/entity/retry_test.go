@@ -0,0 +1,125 @@
+package entity
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBackoffBounds(t *testing.T) {
+	const (
+		base    = 10 * time.Millisecond
+		ceiling = time.Second
+		draws   = 20000
+	)
+	// exponential is min(max, base*2^attempt).
+	exponential := func(attempt int) time.Duration {
+		if attempt >= 7 {
+			return ceiling
+		}
+		return min(ceiling, base<<attempt)
+	}
+
+	tests := []struct {
+		jitter JitterStrategy
+		bounds func(attempt int, previous time.Duration) (lower, upper time.Duration)
+	}{
+		{jitter: JitterNone, bounds: func(attempt int, _ time.Duration) (time.Duration, time.Duration) {
+			return exponential(attempt), exponential(attempt)
+		}},
+		{jitter: JitterFull, bounds: func(attempt int, _ time.Duration) (time.Duration, time.Duration) {
+			return 0, exponential(attempt)
+		}},
+		{jitter: JitterEqual, bounds: func(attempt int, _ time.Duration) (time.Duration, time.Duration) {
+			return exponential(attempt) - exponential(attempt)/2, exponential(attempt)
+		}},
+		{jitter: JitterDecorrelated, bounds: func(_ int, previous time.Duration) (time.Duration, time.Duration) {
+			return base, min(ceiling, 3*max(previous, base))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.jitter), func(t *testing.T) {
+			config := &RetryConfig{MaxRetries: MaxRetries, BaseDelay: Duration(base), MaxDelay: Duration(ceiling), Jitter: test.jitter}
+			rng := rand.New(rand.NewSource(1))
+
+			var sum, lowest, highest time.Duration
+			lowest = ceiling
+			for i := 0; i < draws; i++ {
+				// A whole sequence of retries, the decorrelated delays building on the previous one.
+				var delay time.Duration
+				for attempt := 0; attempt < MaxRetries; attempt++ {
+					previous := delay
+					delay = config.backoff(attempt, previous, rng)
+					lower, upper := test.bounds(attempt, previous)
+					if delay < lower || delay > upper {
+						t.Fatalf("attempt %d after %s waited %s, want within [%s, %s]", attempt, previous, delay, lower, upper)
+					}
+					if attempt == 2 {
+						sum += delay
+						lowest, highest = min(lowest, delay), max(highest, delay)
+					}
+				}
+			}
+
+			// The random strategies spread the delays of the third retry.
+			if test.jitter != JitterNone && highest-lowest < exponential(2)/4 {
+				t.Errorf("third retries waited within [%s, %s], want them spread", lowest, highest)
+			}
+			if mean := sum / draws; test.jitter == JitterFull && (mean < exponential(2)*4/10 || mean > exponential(2)*6/10) {
+				t.Errorf("third retries waited %s on average, want about %s", mean, exponential(2)/2)
+			}
+		})
+	}
+}
+
+func TestBackoffOverflow(t *testing.T) {
+	config := &RetryConfig{BaseDelay: Duration(time.Second), MaxDelay: Duration(time.Minute)}
+	rng := rand.New(rand.NewSource(1))
+	for _, attempt := range []int{30, 62, 63, 1000} {
+		if delay := config.backoff(attempt, 0, rng); delay != time.Minute {
+			t.Errorf("attempt %d waited %s, want the max delay", attempt, delay)
+		}
+	}
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxRetries int
+		failures   int32 // responses failing the success header rule before the first passing it
+		wantSent   int
+		wantFailed int
+	}{
+		{name: "succeeds on a retry", maxRetries: 3, failures: 2, wantSent: 3, wantFailed: 2},
+		{name: "retries exhausted", maxRetries: 1, failures: 5, wantSent: 2, wantFailed: 2},
+		{name: "first attempt succeeds", maxRetries: 3, failures: 0, wantSent: 1, wantFailed: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var received atomic.Int32
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if received.Add(1) > test.failures {
+					w.Header().Set("X-Status", "ok")
+				}
+			}))
+			defer stub.Close()
+
+			retry := &RetryConfig{MaxRetries: test.maxRetries, BaseDelay: Duration(time.Millisecond), MaxDelay: Duration(5 * time.Millisecond), Jitter: JitterFull}
+			worker := newTestWorker(stub.URL, 1, 1, WithWorkerRetry(retry), WithWorkerSuccessHeader(&HeaderRule{Name: "X-Status", Value: "ok"}))
+			runWorker(context.Background(), worker)
+
+			if got := int(received.Load()); got != test.wantSent {
+				t.Errorf("stub received %d requests, want %d", got, test.wantSent)
+			}
+			if worker.Metrics.TotalRequests != test.wantSent || worker.Metrics.FailedRequests != test.wantFailed {
+				t.Errorf("%d requests of which %d failed, want %d and %d", worker.Metrics.TotalRequests, worker.Metrics.FailedRequests, test.wantSent, test.wantFailed)
+			}
+		})
+	}
+}
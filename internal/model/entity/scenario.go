@@ -0,0 +1,156 @@
+package entity
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bounds of a scenario.
+const (
+	MaxScenarioSteps    = 1000
+	MaxScenarioStepBody = 1 << 20 // in bytes
+)
+
+// Scenario is a sequence of requests replayed in order by every goroutine
+// of a worker, each request of the goroutine taking the next step and the
+// sequence starting over once done. The steps carry their own method,
+// headers and body, the ones of the worker only apply to what the steps
+// leave unset.
+type Scenario struct {
+	ID          int             `json:"id"`
+	Name        string          `json:"name"`
+	Rebase      bool            `json:"rebase,omitempty"`  // send the steps to the scheme, host and base path of the environment endpoint
+	Shuffle     bool            `json:"shuffle,omitempty"` // replay the steps in a new order on every pass of every goroutine
+	Steps       []*ScenarioStep `json:"steps"`
+	Unsupported []string        `json:"unsupported,omitempty"` // the entries of the import left out, and why
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// ScenarioStep is a single request of a scenario.
+type ScenarioStep struct {
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// scenarioCursor is where a goroutine is in its pass over the steps.
+type scenarioCursor struct {
+	next  int
+	order []int      // of the steps in the current pass, nil while they are replayed in order
+	rng   *rand.Rand // of the goroutine, nil unless the steps are shuffled
+}
+
+// scenarioCursors holds the cursor of every goroutine of a worker.
+type scenarioCursors struct {
+	mu      sync.Mutex
+	shuffle bool
+	cursors map[int]*scenarioCursor // by goroutine index
+	newRand func(index int) *rand.Rand
+}
+
+func newScenarioCursors(shuffle bool, newRand func(index int) *rand.Rand) *scenarioCursors {
+	return &scenarioCursors{shuffle: shuffle, cursors: make(map[int]*scenarioCursor), newRand: newRand}
+}
+
+// take returns the step the goroutine with the given index sends next, a
+// shuffled scenario being given a new order at the start of every pass.
+func (c *scenarioCursors) take(index, steps int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cursor := c.cursors[index]
+	if cursor == nil {
+		cursor = &scenarioCursor{}
+		if c.shuffle {
+			cursor.rng = c.newRand(index)
+		}
+		c.cursors[index] = cursor
+	}
+
+	if cursor.next == 0 && cursor.rng != nil {
+		cursor.order = cursor.rng.Perm(steps)
+	}
+	step := cursor.next
+	if cursor.order != nil {
+		step = cursor.order[step]
+	}
+	cursor.next = (cursor.next + 1) % steps
+	return step
+}
+
+type scenarioStepKey struct{}
+
+// withScenarioStep binds the next step of the goroutine with the given index
+// to the requests created with the returned context.
+func (w *Worker) withScenarioStep(ctx context.Context, index int) context.Context {
+	if w.Scenario == nil {
+		return ctx
+	}
+	step := w.Scenario.Steps[w.scenario.take(index, len(w.Scenario.Steps))]
+	return context.WithValue(ctx, scenarioStepKey{}, step)
+}
+
+// scenarioStep returns the step bound to ctx, if any.
+func scenarioStep(ctx context.Context) (*ScenarioStep, bool) {
+	step, ok := ctx.Value(scenarioStepKey{}).(*ScenarioStep)
+	return step, ok
+}
+
+// stepURL is where a step is sent, rebased on the endpoint of the
+// environment when the scenario is.
+func (w *Worker) stepURL(step *ScenarioStep) (string, error) {
+	if !w.Scenario.Rebase {
+		return step.URL, nil
+	}
+	return rebaseURL(w.Environment.Endpoint, step.URL)
+}
+
+// rebaseURL returns target sent to the scheme and host of endpoint. The
+// path of target is joined to the base path of endpoint unless it already
+// starts with it, the way a recording against the same deployment would.
+func rebaseURL(endpoint, target string) (string, error) {
+	base, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	rebased, err := url.Parse(target)
+	if err != nil {
+		return "", err
+	}
+
+	if basePath := strings.TrimRight(base.Path, "/"); basePath != "" && !hasPathPrefix(rebased.Path, basePath) {
+		joined := base.JoinPath(rebased.EscapedPath())
+		rebased.Path, rebased.RawPath = joined.Path, joined.RawPath
+	}
+	rebased.Scheme, rebased.Host, rebased.User = base.Scheme, base.Host, base.User
+	return rebased.String(), nil
+}
+
+// hasPathPrefix reports whether the segments of path start with the ones of prefix.
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// withStep gives req the headers and body of step, its method and URL being
+// set when req is created.
+func (s *ScenarioStep) withStep(req *http.Request) {
+	for name, value := range s.Headers {
+		req.Header.Set(name, value)
+	}
+	if s.Body == "" {
+		return
+	}
+
+	req.ContentLength = int64(len(s.Body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(s.Body)), nil
+	}
+	req.Body, _ = req.GetBody()
+}
@@ -0,0 +1,141 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrEmptyScenario rejects an import without a single supported entry.
+var ErrEmptyScenario = errors.New("no supported entry")
+
+// harFile is the part of an HTTP Archive (HAR 1.2) a scenario is built from.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Params   []any  `json:"params"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// harMethods are the methods a step may replay.
+var harMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// harSkippedHeaders are set by the transport of the worker, not replayed.
+var harSkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"keep-alive":        true,
+	"transfer-encoding": true,
+	"accept-encoding":   true,
+	"upgrade":           true,
+	"te":                true,
+}
+
+// ParseHAR builds the steps of a scenario from the entries of an HTTP
+// Archive, in the order they are listed. The entries that can't be
+// replayed, such as WebSocket or data: URLs, unknown methods or multipart
+// uploads without their text, are left out and reported. ErrEmptyScenario
+// if no entry is left.
+func ParseHAR(data []byte) (steps []*ScenarioStep, unsupported []string, err error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, nil, err
+	}
+
+	for i, entry := range har.Log.Entries {
+		request := entry.Request
+		step, reason := harStep(request.Method, request.URL)
+		if step != nil && request.PostData != nil {
+			switch {
+			case request.PostData.Text == "" && len(request.PostData.Params) > 0:
+				step, reason = nil, "form parameters without their text"
+			case len(request.PostData.Text) > MaxScenarioStepBody:
+				step, reason = nil, fmt.Sprintf("body over %d bytes", MaxScenarioStepBody)
+			default:
+				step.Body = request.PostData.Text
+				if request.PostData.MimeType != "" {
+					step.Headers["Content-Type"] = request.PostData.MimeType
+				}
+			}
+		}
+		if step == nil {
+			unsupported = append(unsupported, fmt.Sprintf("entry %d (%s %s): %s", i, request.Method, request.URL, reason))
+			continue
+		}
+
+		for _, header := range request.Headers {
+			name := header.Name
+			// HTTP/2 pseudo headers such as :authority.
+			if strings.HasPrefix(name, ":") || harSkippedHeaders[strings.ToLower(name)] || !ValidHeaderName(name) {
+				continue
+			}
+			if _, set := step.Headers["Content-Type"]; set && strings.EqualFold(name, "Content-Type") {
+				continue
+			}
+			step.Headers[http.CanonicalHeaderKey(name)] = header.Value
+		}
+		if len(step.Headers) == 0 {
+			step.Headers = nil
+		}
+
+		if len(steps) == MaxScenarioSteps {
+			unsupported = append(unsupported, fmt.Sprintf("entries from %d: over %d steps", i, MaxScenarioSteps))
+			break
+		}
+		steps = append(steps, step)
+	}
+
+	if len(steps) == 0 {
+		return nil, unsupported, ErrEmptyScenario
+	}
+	return steps, unsupported, nil
+}
+
+// harStep checks the method and URL of an entry, returning why it can't be
+// replayed when it can't.
+func harStep(method, rawURL string) (*ScenarioStep, string) {
+	method = strings.ToUpper(method)
+	if !harMethods[method] {
+		return nil, "unsupported method"
+	}
+
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "invalid URL"
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Sprintf("unsupported scheme %q", target.Scheme)
+	}
+	if target.Host == "" {
+		return nil, "URL without a host"
+	}
+	// The fragment is never sent.
+	target.Fragment = ""
+
+	return &ScenarioStep{Method: method, URL: target.String(), Headers: make(map[string]string)}, ""
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHAR(t *testing.T) {
+	data, err := os.ReadFile("testdata/sample.har")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, unsupported, err := ParseHAR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*ScenarioStep{
+		{
+			Method:  "GET",
+			URL:     "https://shop.example.com/api/products?page=2",
+			Headers: map[string]string{"Accept": "application/json", "X-Session": "abc123"},
+		},
+		{
+			Method:  "POST",
+			URL:     "https://shop.example.com/api/cart",
+			Headers: map[string]string{"Content-Type": "application/json"},
+			Body:    `{"product_id":42,"quantity":1}`,
+		},
+		{
+			Method:  "DELETE",
+			URL:     "https://shop.example.com/api/cart/42",
+			Headers: map[string]string{"X-Session": "abc123"},
+		},
+	}
+	if !reflect.DeepEqual(steps, want) {
+		for i, step := range steps {
+			t.Logf("step %d: %+v", i, *step)
+		}
+		t.Fatalf("steps differ from the supported entries of the HAR file")
+	}
+
+	// The WebSocket, the multipart upload and the CONNECT are reported with their index.
+	wantUnsupported := []string{"entry 2 ", "entry 3 ", "entry 4 "}
+	if len(unsupported) != len(wantUnsupported) {
+		t.Fatalf("unsupported = %q, want %d entries", unsupported, len(wantUnsupported))
+	}
+	for i, prefix := range wantUnsupported {
+		if !strings.HasPrefix(unsupported[i], prefix) {
+			t.Errorf("unsupported[%d] = %q, want it to start with %q", i, unsupported[i], prefix)
+		}
+	}
+}
+
+func TestParseHARWithoutSupportedEntry(t *testing.T) {
+	data := `{"log": {"entries": [{"request": {"method": "GET", "url": "data:text/plain,hello"}}]}}`
+
+	_, unsupported, err := ParseHAR([]byte(data))
+	if !errors.Is(err, ErrEmptyScenario) {
+		t.Fatalf("ParseHAR() error = %v, want ErrEmptyScenario", err)
+	}
+	if len(unsupported) != 1 {
+		t.Errorf("unsupported = %q, want the data: URL", unsupported)
+	}
+}
@@ -0,0 +1,77 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// replayedPasses replays a scenario of steps steps over passes passes of a
+// single goroutine and returns the steps of every pass, in the order they
+// were received.
+func replayedPasses(t *testing.T, shuffle bool, steps, passes int) [][]int {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []int
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		step, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/step/"))
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, step)
+	}))
+	defer stub.Close()
+
+	scenario := &Scenario{ID: 1, Name: "independent steps", Shuffle: shuffle}
+	for i := 0; i < steps; i++ {
+		scenario.Steps = append(scenario.Steps, &ScenarioStep{Method: http.MethodGet, URL: fmt.Sprintf("%s/step/%d", stub.URL, i)})
+	}
+	worker := newTestWorker(stub.URL, 1, steps*passes, WithWorkerScenario(scenario), WithWorkerSeed(1))
+	runWorker(context.Background(), worker)
+
+	if len(received) != steps*passes {
+		t.Fatalf("received %d requests, want %d", len(received), steps*passes)
+	}
+	var orders [][]int
+	for pass := 0; pass < passes; pass++ {
+		orders = append(orders, received[pass*steps:(pass+1)*steps])
+	}
+	return orders
+}
+
+func TestScenarioShuffle(t *testing.T) {
+	const steps, passes = 5, 8
+	inOrder := []int{0, 1, 2, 3, 4}
+
+	t.Run("disabled", func(t *testing.T) {
+		for pass, order := range replayedPasses(t, false, steps, passes) {
+			if !slices.Equal(order, inOrder) {
+				t.Errorf("pass %d replayed %v, want %v", pass, order, inOrder)
+			}
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		orders := replayedPasses(t, true, steps, passes)
+
+		distinct := make(map[string]bool)
+		for pass, order := range orders {
+			// Every pass still replays every step once.
+			sorted := slices.Clone(order)
+			slices.Sort(sorted)
+			if !slices.Equal(sorted, inOrder) {
+				t.Errorf("pass %d replayed %v, want every step once", pass, order)
+			}
+			distinct[fmt.Sprint(order)] = true
+		}
+		if len(distinct) < 2 {
+			t.Errorf("every pass replayed %v, want the order to vary", orders[0])
+		}
+	})
+}
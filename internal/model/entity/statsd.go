@@ -0,0 +1,161 @@
+package entity
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Defaults of a StatsD exporter.
+const (
+	DefaultStatsDBuffer = 10_000
+	statsDMaxPacket     = 1432 // fits in a single Ethernet frame
+	statsDFlushEvery    = 100 * time.Millisecond
+)
+
+// StatsD exports the metrics of the requests of every worker sharing it to
+// a StatsD or DogStatsD server, over UDP. The metrics are queued and sent
+// by a goroutine of their own, so that a slow or missing server never holds
+// a request back: once the queue is full, the metrics are dropped. A nil
+// exporter exports nothing.
+type StatsD struct {
+	conn      net.Conn
+	prefix    string // of the name of every metric, with its trailing dot
+	dogStatsD bool   // the server understands tags, plain StatsD not
+	tags      []string
+	mu        sync.RWMutex // held for writing by Close only
+	closed    bool         // guarded by mu
+	lines     chan string
+	done      chan struct{}
+	dropped   atomic.Int64
+}
+
+// NewStatsD returns an exporter to the server at addr, host:port. The tags,
+// key:value, are added to the ones of the worker on every metric, only
+// when dogStatsD is set. Buffer bounds the metrics queued, DefaultStatsDBuffer
+// when 0.
+func NewStatsD(addr, prefix string, dogStatsD bool, tags []string, buffer int) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dialing the StatsD server: %w", err)
+	}
+	if buffer <= 0 {
+		buffer = DefaultStatsDBuffer
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	s := &StatsD{
+		conn:      conn,
+		prefix:    prefix,
+		dogStatsD: dogStatsD,
+		tags:      tags,
+		lines:     make(chan string, buffer),
+		done:      make(chan struct{}),
+	}
+	go s.loop()
+	return s, nil
+}
+
+// Close sends the metrics still queued and closes the connection. The
+// metrics of the workers still running are dropped afterwards.
+func (s *StatsD) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	s.closed = true
+	close(s.lines)
+	s.mu.Unlock()
+	<-s.done
+	return s.conn.Close()
+}
+
+// Dropped returns the number of metrics dropped because the queue was full or closed.
+func (s *StatsD) Dropped() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.dropped.Load()
+}
+
+// workerTags returns the suffix of the metrics of a worker, tagging them
+// with the worker and its environment along with the tags of the exporter.
+func (s *StatsD) workerTags(worker *Worker) string {
+	if s == nil || !s.dogStatsD {
+		return ""
+	}
+	tags := append([]string{fmt.Sprintf("worker_id:%d", worker.ID), fmt.Sprintf("environment_id:%d", worker.EnvironmentID)}, s.tags...)
+	return "|#" + strings.Join(tags, ",")
+}
+
+// request exports a request of a worker: a timer of its latency and the
+// counters of the requests and of the failed ones.
+func (s *StatsD) request(suffix string, latency time.Duration, failed bool) {
+	if s == nil {
+		return
+	}
+	s.queue(fmt.Sprintf("%srequests:1|c%s", s.prefix, suffix))
+	s.queue(fmt.Sprintf("%srequest.latency:%g|ms%s", s.prefix, float64(latency.Microseconds())/1000, suffix))
+	if failed {
+		s.queue(fmt.Sprintf("%serrors:1|c%s", s.prefix, suffix))
+	}
+}
+
+// queue adds a metric line to the queue without blocking, dropping it when
+// the queue is full or closed.
+func (s *StatsD) queue(line string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+
+	select {
+	case s.lines <- line:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// loop packs the queued lines into packets, sent once full or every
+// statsDFlushEvery, until the queue is closed.
+func (s *StatsD) loop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(statsDFlushEvery)
+	defer ticker.Stop()
+
+	var packet []byte
+	flush := func() {
+		if len(packet) > 0 {
+			// A lost packet is lost, like any UDP one.
+			_, _ = s.conn.Write(packet)
+			packet = packet[:0]
+		}
+	}
+
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				flush()
+				return
+			}
+			if len(packet) > 0 && len(packet)+1+len(line) > statsDMaxPacket {
+				flush()
+			}
+			if len(packet) > 0 {
+				packet = append(packet, '\n')
+			}
+			packet = append(packet, line...)
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
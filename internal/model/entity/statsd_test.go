@@ -0,0 +1,157 @@
+package entity
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// listenStatsD returns a stub StatsD server and a function reading the
+// metric lines it received until none arrives for 200ms.
+func listenStatsD(t *testing.T) (string, func() []string) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	received := func() []string {
+		var lines []string
+		buffer := make([]byte, 64*1024)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buffer)
+			if err != nil {
+				return lines
+			}
+			if n > statsDMaxPacket {
+				t.Errorf("packet of %d bytes, want at most %d", n, statsDMaxPacket)
+			}
+			lines = append(lines, strings.Split(string(buffer[:n]), "\n")...)
+		}
+	}
+	return conn.LocalAddr().String(), received
+}
+
+func TestStatsD(t *testing.T) {
+	// The first request misses the success header and fails.
+	var served atomic.Int32
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served.Add(1) > 1 {
+			w.Header().Set("X-Status", "ok")
+		}
+	}))
+	defer stub.Close()
+
+	tests := []struct {
+		name      string
+		dogStatsD bool
+		suffix    string
+	}{
+		{name: "DogStatsD", dogStatsD: true, suffix: "|#worker_id:3,environment_id:7,region:eu"},
+		{name: "plain StatsD", dogStatsD: false, suffix: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			served.Store(0)
+			addr, received := listenStatsD(t)
+			stats, err := NewStatsD(addr, "perf", test.dogStatsD, []string{"region:eu"}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			worker := newTestWorker(stub.URL, 1, 3, WithWorkerStatsD(stats), WithWorkerSuccessHeader(&HeaderRule{Name: "X-Status", Value: "ok"}))
+			worker.ID, worker.EnvironmentID = 3, 7
+			runWorker(context.Background(), worker)
+			if err := stats.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			counts := make(map[string]int)
+			latency := regexp.MustCompile(`^perf\.request\.latency:[0-9.e+-]+\|ms` + regexp.QuoteMeta(test.suffix) + `$`)
+			for _, line := range received() {
+				switch {
+				case latency.MatchString(line):
+					counts["latency"]++
+				default:
+					counts[line]++
+				}
+			}
+
+			want := map[string]int{
+				"latency":                         3,
+				"perf.requests:1|c" + test.suffix: 3,
+				"perf.errors:1|c" + test.suffix:   1,
+			}
+			if len(counts) != len(want) {
+				t.Errorf("received %v, want %v", counts, want)
+			}
+			for line, n := range want {
+				if counts[line] != n {
+					t.Errorf("received %q %d times, want %d", line, counts[line], n)
+				}
+			}
+			if stats.Dropped() != 0 {
+				t.Errorf("%d metrics dropped, want none", stats.Dropped())
+			}
+		})
+	}
+}
+
+func TestStatsDPackets(t *testing.T) {
+	addr, received := listenStatsD(t)
+	stats, err := NewStatsD(addr, "perf.", false, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Far more than a packet holds, every line arriving whole.
+	const requests = 200
+	for i := 0; i < requests; i++ {
+		stats.request("", time.Duration(i)*time.Millisecond, false)
+	}
+	if err := stats.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := received()
+	if len(lines) != 2*requests {
+		t.Fatalf("received %d lines, want %d", len(lines), 2*requests)
+	}
+	if !slices.Contains(lines, "perf.request.latency:199|ms") {
+		t.Errorf("the latency of the last request is missing from %v", lines[len(lines)-4:])
+	}
+}
+
+func TestStatsDDropped(t *testing.T) {
+	addr, _ := listenStatsD(t)
+	stats, err := NewStatsD(addr, "perf", false, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once closed, the metrics are dropped rather than blocking or panicking.
+	stats.request("", time.Millisecond, true)
+	if got := stats.Dropped(); got != 3 {
+		t.Errorf("%d metrics dropped, want 3", got)
+	}
+
+	var none *StatsD
+	none.request(none.workerTags(&Worker{}), time.Millisecond, true)
+	if none.Dropped() != 0 || none.Close() != nil {
+		t.Error("a nil exporter did something")
+	}
+}
@@ -0,0 +1,85 @@
+package entity
+
+import "time"
+
+// TrendReport follows the runs of the workers with a tag over a time range,
+// their metrics being aggregated per bucket of the time the runs finished.
+type TrendReport struct {
+	Tag     string         `json:"tag"`
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"` // excluded
+	Bucket  Duration       `json:"bucket"`
+	Buckets []*TrendBucket `json:"buckets"` // every bucket of the range, the empty ones included
+}
+
+// TrendBucket aggregates the runs finished within [Start, Start+Bucket).
+// The rates are nil for an empty bucket, and the latencies without a run
+// that persisted its samples.
+type TrendBucket struct {
+	Start           time.Time `json:"start"`
+	Workers         int       `json:"workers"`
+	Requests        int       `json:"requests"`
+	FailedRequests  int       `json:"failed_requests"`
+	ErrorRate       *float64  `json:"error_rate"`
+	Throughput      *float64  `json:"throughput"`                        // in requests per second, of the runs weighted by their requests
+	P50             *float64  `json:"p50"`                               // in seconds
+	P95             *float64  `json:"p95"`                               // in seconds
+	P99             *float64  `json:"p99"`                               // in seconds
+	WithoutSamples  int       `json:"workers_without_samples,omitempty"` // left out of the latencies
+	neutralRequests int
+	throughputSum   float64 // of the throughput of every run times its requests
+	histograms      []*LatencyHistogram
+}
+
+// NewTrendReport returns a report over [from, to) without any run, its
+// buckets starting at from.
+func NewTrendReport(tag string, from, to time.Time, bucket time.Duration) *TrendReport {
+	report := &TrendReport{Tag: tag, From: from, To: to, Bucket: Duration(bucket), Buckets: []*TrendBucket{}}
+	for start := from; start.Before(to); start = start.Add(bucket) {
+		report.Buckets = append(report.Buckets, &TrendBucket{Start: start})
+	}
+	return report
+}
+
+// Add counts a finished run in its bucket, with the histogram of its
+// persisted samples, nil when it has none. The runs finished out of the
+// range of the report are ignored.
+func (r *TrendReport) Add(worker *Worker, histogram *LatencyHistogram) {
+	if worker.FinishedAt == nil || worker.FinishedAt.Before(r.From) || !worker.FinishedAt.Before(r.To) {
+		return
+	}
+	bucket := r.Buckets[int(worker.FinishedAt.Sub(r.From)/time.Duration(r.Bucket))]
+
+	bucket.Workers++
+	if worker.Metrics != nil {
+		bucket.Requests += worker.Metrics.TotalRequests
+		bucket.FailedRequests += worker.Metrics.FailedRequests
+		bucket.neutralRequests += worker.Metrics.NeutralRequests
+		bucket.throughputSum += worker.Metrics.Throughput * float64(worker.Metrics.TotalRequests)
+	}
+
+	if histogram == nil || histogram.Requests() == 0 {
+		bucket.WithoutSamples++
+		return
+	}
+	bucket.histograms = append(bucket.histograms, histogram)
+}
+
+// Finish computes the rates and the latencies of every bucket once every
+// run is added.
+func (r *TrendReport) Finish() {
+	for _, bucket := range r.Buckets {
+		if bucket.Requests > 0 {
+			errorRate := errorRate(bucket.Requests-bucket.neutralRequests, bucket.FailedRequests)
+			throughput := bucket.throughputSum / float64(bucket.Requests)
+			bucket.ErrorRate, bucket.Throughput = &errorRate, &throughput
+		}
+
+		merged := MergeWeighted(bucket.histograms...)
+		for rank, dst := range map[float64]**float64{50: &bucket.P50, 95: &bucket.P95, 99: &bucket.P99} {
+			if latency, ok := merged.Percentile(rank); ok {
+				*dst = &latency
+			}
+		}
+	}
+}
@@ -1,90 +1,393 @@
 package entity
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/vladComan0/performance-analyzer/pkg/tokens"
-	"math/rand"
+	"io"
 	"net/http"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// MaxLogSampleRate bounds Worker.LogSampleRate.
+const MaxLogSampleRate = 1_000_000
+
 type Worker struct {
-	ID              int                  `json:"id"`
-	EnvironmentID   int                  `json:"environment_id"`
-	Concurrency     int                  `json:"concurrency"`
-	RequestsPerTask int                  `json:"requests_per_task"`
-	Report          string               `json:"report"`
-	HTTPMethod      string               `json:"http_method"`
-	Body            *json.RawMessage     `json:"body"`
-	Status          Status               `json:"status"`
-	CreatedAt       time.Time            `json:"-"`
-	Metrics         *Metrics             `json:"metrics"`
-	Environment     *Environment         `json:"-"`
-	TokenManager    *tokens.TokenManager `json:"-"`
-	log             zerolog.Logger
-	mu              sync.Mutex
+	ID                      int                   `json:"id"`
+	EnvironmentID           int                   `json:"environment_id"`
+	CampaignID              *int                  `json:"campaign_id,omitempty"` // set by the campaign that created the worker
+	Concurrency             int                   `json:"concurrency"`
+	RequestsPerTask         int                   `json:"requests_per_task"`
+	Report                  string                `json:"report"`
+	HTTPMethod              string                `json:"http_method"`
+	Body                    *json.RawMessage      `json:"body,omitempty"`
+	BodyContentType         string                `json:"body_content_type,omitempty"`      // DefaultBodyContentType when empty
+	SkipSchemaValidation    bool                  `json:"skip_schema_validation,omitempty"` // don't check the body against the schema of the environment
+	Autostart               *bool                 `json:"autostart,omitempty"`              // nil starts the worker on creation
+	BodyVariants            []BodyVariant         `json:"body_variants,omitempty"`          // sent instead of Body, one per request
+	VariantSelection        VariantSelection      `json:"variant_selection,omitempty"`      // VariantRoundRobin when empty
+	TargetRPS               float64               `json:"target_rps,omitempty"`
+	MinThroughputRatio      float64               `json:"min_throughput_ratio,omitempty"` // fraction of TargetRPS, e.g. 0.9
+	Mode                    Mode                  `json:"mode,omitempty"`
+	RampConfig              *RampConfig           `json:"ramp_config,omitempty"`
+	RampResult              *RampResult           `json:"ramp_result,omitempty"`
+	SoakConfig              *SoakConfig           `json:"soak_config,omitempty"`
+	SoakResult              *SoakResult           `json:"soak_result,omitempty"`
+	SpikeConfig             *SpikeConfig          `json:"spike_config,omitempty"`
+	SpikeResult             *SpikeResult          `json:"spike_result,omitempty"`
+	AutoTuneConfig          *AutoTuneConfig       `json:"auto_tune_config,omitempty"`
+	AutoTuneResult          *AutoTuneResult       `json:"auto_tune_result,omitempty"`
+	RampUp                  Duration              `json:"ramp_up,omitempty"`
+	RampUpJitter            float64               `json:"ramp_up_jitter,omitempty"` // fraction of the gap between goroutine starts
+	RampDown                Duration              `json:"ramp_down,omitempty"`      // cool down at the end of a fixed, soak or spike run
+	ThinkTime               *Duration             `json:"think_time,omitempty"`     // nil keeps the random pause of up to 1s
+	ThinkTimeJitter         float64               `json:"think_time_jitter,omitempty"`
+	OnResourceExhaustion    ExhaustionPolicy      `json:"on_resource_exhaustion,omitempty"` // fixed mode only
+	CircuitBreaker          *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+	Retry                   *RetryConfig          `json:"retry,omitempty"`
+	Resolver                string                `json:"resolver,omitempty"` // host:port of the DNS server, the system one when empty
+	ExpectedRequests        *int                  `json:"expected_requests"`  // nil for duration based runs
+	Progress                *Progress             `json:"progress,omitempty"`
+	Comparison              *Comparison           `json:"comparison,omitempty"` // against the baseline of the environment
+	CaptureQuotas           CaptureQuotas         `json:"capture_quotas,omitempty"`
+	CapturedResponses       []*CapturedResponse   `json:"captured_responses,omitempty"`
+	FirstRequest            *RenderedRequest      `json:"first_request,omitempty"`
+	PersistSamples          bool                  `json:"persist_samples,omitempty"`
+	SampleCap               int                   `json:"sample_cap,omitempty"`      // DefaultSampleCap when 0
+	RecordRequests          bool                  `json:"record_requests,omitempty"` // write every request to a CSV file
+	RecordFile              string                `json:"record_file,omitempty"`     // name of the file once the run is over
+	KeepAlive               *KeepAliveConfig      `json:"keep_alive,omitempty"`
+	Identities              *IdentityConfig       `json:"identities,omitempty"`                // a distinct identity per goroutine
+	DataSource              *DataSource           `json:"data_source,omitempty"`               // rows filling the placeholders of the requests
+	ScenarioID              *int                  `json:"scenario_id,omitempty"`               // replayed instead of the endpoint of the environment
+	Scenario                *Scenario             `json:"-"`                                   // copied from ScenarioID on creation
+	BodyStream              *BodyStream           `json:"body_stream,omitempty"`               // read anew by every request instead of being held in memory
+	Provenance              Provenance            `json:"-"`                                   // where the settings came from, nil when the environment has no defaults
+	CorrelationHeader       string                `json:"correlation_header,omitempty"`        // carries a unique ID per request, none sent when empty
+	LogSampleRate           int                   `json:"log_sample_rate,omitempty"`           // 1 in LogSampleRate per request debug events is logged, all of them when 0 or 1
+	MeasureColdRequests     bool                  `json:"measure_cold_requests,omitempty"`     // report the first request of every goroutine apart from the others
+	LatencyFailureThreshold Duration              `json:"latency_failure_threshold,omitempty"` // responses slower than it are counted as slow, none when 0
+	SlowRequestPolicy       SlowRequestPolicy     `json:"slow_request_policy,omitempty"`       // SlowRequestFail when empty
+	FollowRedirects         *bool                 `json:"follow_redirects,omitempty"`          // nil follows them
+	Status3xx               RedirectPolicy        `json:"status_3xx,omitempty"`                // RedirectNeutral when empty
+	Timeouts                *RequestTimeouts      `json:"timeouts,omitempty"`                  // none when nil, a request waiting for as long as it takes
+	Exclusive               bool                  `json:"exclusive,omitempty"`                 // no other worker of the environment runs alongside it
+	SuccessHeader           *HeaderRule           `json:"success_header,omitempty"`            // a 2xx response without it counts as a failure
+	BodyAssertion           *BodyAssertion        `json:"body_assertion,omitempty"`            // a 2xx response failing it counts as a failure
+	ServedByHeaders         []string              `json:"served_by_headers,omitempty"`         // DefaultServedByHeaders when empty
+	Tags                    []string              `json:"tags,omitempty"`                      // labels grouping the runs in the trend reports
+	Seed                    int64                 `json:"seed,omitempty"`                      // master seed of every random choice of the run, picked at random when 0
+	ResolvedURL             string                `json:"-"`                                   // where the first request is sent, only set by a dry run
+	Warnings                []string              `json:"warnings,omitempty"`                  // things that happened during the run that make its results doubtful
+	Status                  Status                `json:"status"`
+	CreatedAt               time.Time             `json:"-"`
+	UpdatedAt               time.Time             `json:"-"`
+	FinishedAt              *time.Time            `json:"-"` // nil until the run is over
+	Metrics                 *Metrics              `json:"metrics"`
+	Environment             *Environment          `json:"-"`
+	TokenManager            *tokens.TokenManager  `json:"-"`
+	log                     zerolog.Logger
+	requestLog              zerolog.Logger // log sampled for the per request debug events
+	mu                      sync.Mutex
+	activeLimit             atomic.Int32 // 0 while no throttling happened
+	lastThrottle            atomic.Int64 // in unix nanoseconds
+	exhaustionOnce          sync.Once
+	startedAt               time.Time // guarded by mu
+	client                  *http.Client
+	capture                 *captureBuffer
+	samples                 *sampleReservoir
+	lastRequest             atomic.Int64 // in unix nanoseconds
+	firstSent               atomic.Bool  // the first request was recorded
+	breaker                 *circuitBreaker
+	retryRands              *goroutineRands
+	requestIDs              *requestIDs
+	variants                *bodyVariants
+	data                    *dataRing
+	scenario                *scenarioCursors
+	bodyFilesDir            string
+	stream                  bodyProvider
+	headerPattern           *regexp.Regexp // of SuccessHeader, nil when it expects a value or its pattern doesn't compile
+	assertionPath           []jsonPathStep // of BodyAssertion
+	recordDir               string
+	records                 *requestRecorder
+	coldRequests            *coldRequests
+	budget                  *RequestBudget // shared with the other workers drawing from it, nil when unlimited
+	limiter                 *RateLimiter   // shared by every worker of the process, nil when unlimited
+	stats                   *StatsD        // shared by every worker of the process, nil when not exporting
+	statsTags               string         // suffix of the metrics exported
 }
 
 // NewWorker creates a new Worker with the given options.
 func NewWorker(environmentID, concurrency, requestsPerTask int, httpMethod string, body *json.RawMessage, environment *Environment, log zerolog.Logger, options ...WorkerOption) *Worker {
 	worker := &Worker{
-		EnvironmentID:   environmentID,
-		Concurrency:     concurrency,
-		RequestsPerTask: requestsPerTask,
-		Environment:     environment,
-		HTTPMethod:      httpMethod,
-		Body:            body,
-		Status:          StatusCreated,
-		Metrics:         NewMetrics(),
-		log:             log,
+		EnvironmentID:        environmentID,
+		Concurrency:          concurrency,
+		RequestsPerTask:      requestsPerTask,
+		Environment:          environment,
+		HTTPMethod:           httpMethod,
+		Body:                 body,
+		Mode:                 ModeFixed,
+		OnResourceExhaustion: ExhaustionIgnore,
+		Status3xx:            RedirectNeutral,
+		Status:               StatusCreated,
+		Metrics:              NewMetrics(),
+		Seed:                 newSeed(),
+		log:                  log,
 	}
+	worker.Metrics.TrackRate(DefaultRateWindow)
 
 	for _, option := range options {
 		option(worker)
 	}
 
+	worker.requestLog = log
+	if worker.LogSampleRate > 1 {
+		worker.requestLog = log.Sample(&zerolog.BasicSampler{N: uint32(worker.LogSampleRate)})
+	}
+	worker.ExpectedRequests = worker.expectedRequests()
+	if worker.MeasureColdRequests {
+		// Sized once the options, some of which set the concurrency, are applied.
+		worker.coldRequests = newColdRequests(worker.Concurrency)
+	}
+
 	return worker
 }
 
-func (w *Worker) Start(ctx context.Context, wg *sync.WaitGroup, updateStatusFunc func(id int, status Status) error, updateMetricsFunc func(id int, metrics *Metrics) error) {
-	if err := updateStatusFunc(w.ID, StatusRunning); err != nil {
+func (w *Worker) Start(ctx context.Context, wg *sync.WaitGroup, store WorkerStore) {
+	if err := store.UpdateStatus(w.ID, StatusRunning); err != nil {
 		w.log.Error().Err(err).Msg("Error updating status to running")
 		return
 	}
 	w.SetStatus(StatusRunning)
+	store = w.deletableStore(store)
 
-	var completedSuccessfully bool
+	var completedSuccessfully, finished bool
 
 	defer func() {
-		var finalStatus Status
-		if completedSuccessfully {
-			finalStatus = StatusFinished
-		} else {
-			finalStatus = StatusFailed
+		if finished {
+			return
 		}
-
-		if err := updateStatusFunc(w.ID, finalStatus); err != nil {
-			w.log.Error().Err(err).Msgf("Error updating status to %s", finalStatus)
+		// The run ended without its results being stored, it must not stay running.
+		if err := store.UpdateStatus(w.ID, StatusFailed); err != nil {
+			w.log.Error().Err(err).Msgf("Error updating status to %s", StatusFailed)
 		}
-		w.SetStatus(finalStatus)
+		w.SetStatus(StatusFailed)
 	}()
 
-	requests := make(chan int, w.Concurrency)
-	done := make(chan struct{})
+	// Every request would fail to get a token, the run fails at once instead.
+	if w.TokenManager != nil {
+		if _, err := w.TokenManager.GetToken(); err != nil {
+			w.addWarning(store, fmt.Sprintf("the token couldn't be fetched: %s", err))
+			return
+		}
+	}
+
+	// Every request would miss its data, the run fails before sending any instead.
+	if w.DataSource != nil {
+		data, err := w.loadData(ctx)
+		if err != nil {
+			w.addWarning(store, fmt.Sprintf("the data source couldn't be loaded: %s", err))
+			return
+		}
+		w.data = data
+		w.mu.Lock()
+		w.DataSource.Rows = len(data.rows)
+		w.mu.Unlock()
+		if err := store.UpdateDataSource(w.ID, w.DataSource); err != nil {
+			w.log.Error().Err(err).Msg("Error updating data source")
+		}
+		w.log.Info().Msgf("Worker %d loaded %d rows from its data source", w.ID, len(data.rows))
+	}
+
+	// Every request would fail to read its body, the run fails before sending any instead.
+	if w.BodyStream != nil {
+		w.stream = newBodyProvider(w.BodyStream, w.bodyFilesDir)
+		body, _, err := w.stream.open()
+		if err != nil {
+			w.addWarning(store, fmt.Sprintf("the body stream couldn't be opened: %s", err))
+			return
+		}
+		body.Close()
+	}
+
+	if w.CircuitBreaker != nil {
+		w.breaker = newCircuitBreaker(w.CircuitBreaker, w.log)
+	}
+	if w.Retry != nil {
+		w.retryRands = newGoroutineRands(w, randRetry)
+	}
+	w.statsTags = w.stats.workerTags(w)
+
+	if first, err := w.FirstURL(); err == nil {
+		w.log.Info().Msgf("Worker %d sends its first request to %s", w.ID, RedactURL(first))
+	}
+
+	w.client = w.newHTTPClient()
+	if len(w.CaptureQuotas) > 0 {
+		w.capture = newCaptureBuffer(w.CaptureQuotas)
+	}
+	if w.PersistSamples {
+		w.samples = newSampleReservoir(w.SampleCap, w.newRand(randSamples, 0))
+	}
+	if w.CorrelationHeader != "" {
+		w.requestIDs = newRequestIDs()
+	}
+	if len(w.BodyVariants) > 0 {
+		w.variants = newBodyVariants(w.BodyVariants, w.VariantSelection, newGoroutineRands(w, randVariants))
+	}
+	w.startRecording(store)
+
+	if w.LogSampleRate > 1 && zerolog.GlobalLevel() <= zerolog.DebugLevel {
+		w.log.Info().Msgf("Worker %d logs 1 in %d of its per request debug events, errors are all logged", w.ID, w.LogSampleRate)
+	}
 
 	start := time.Now()
+	w.mu.Lock()
+	w.startedAt = start
+	w.mu.Unlock()
+
+	stopKeepAlive := w.startKeepAlive(ctx)
+	stopMaintenanceWatch := w.watchMaintenance(ctx, store)
+
+	switch w.Mode {
+	case ModeRampToFailure:
+		completedSuccessfully = w.rampToFailure(ctx)
+		if err := store.UpdateRampResult(w.ID, w.RampResult); err != nil {
+			w.log.Error().Err(err).Msg("Error updating ramp result")
+		}
+	case ModeSoak:
+		completedSuccessfully = w.soak(ctx)
+		if err := store.UpdateSoakResult(w.ID, w.SoakResult); err != nil {
+			w.log.Error().Err(err).Msg("Error updating soak result")
+		}
+	case ModeSpike:
+		completedSuccessfully = w.spike(ctx)
+		if err := store.UpdateSpikeResult(w.ID, w.SpikeResult); err != nil {
+			w.log.Error().Err(err).Msg("Error updating spike result")
+		}
+	case ModeAutoTune:
+		completedSuccessfully = w.autoTune(ctx)
+		if err := store.UpdateAutoTuneResult(w.ID, w.AutoTuneResult); err != nil {
+			w.log.Error().Err(err).Msg("Error updating auto tune result")
+		}
+	default:
+		completedSuccessfully = w.runFixed(ctx, wg)
+	}
+	stopKeepAlive()
+	stopMaintenanceWatch()
+	w.finishRecording(store)
+
+	if w.capture != nil {
+		w.CapturedResponses = w.capture.all()
+		if err := store.UpdateCapturedResponses(w.ID, w.CapturedResponses); err != nil {
+			w.log.Error().Err(err).Msg("Error updating captured responses")
+		}
+	}
+
+	w.mu.Lock()
+	firstRequest := w.FirstRequest
+	w.mu.Unlock()
+	if firstRequest != nil {
+		if err := store.UpdateFirstRequest(w.ID, firstRequest); err != nil {
+			w.log.Error().Err(err).Msg("Error updating the first request")
+		}
+	}
+
+	elapsed := time.Since(start)
+	if completedSuccessfully {
+		w.log.Info().Msgf("Worker %d finished in %s", w.ID, elapsed)
+	}
+
+	ranks := []PercentileRank{P50, P95, P99, P999}
+	if err := w.Metrics.CalculatePercentiles(ranks...); err != nil {
+		// Without a single successful request there is nothing to rank, the other metrics are still relevant.
+		w.log.Error().Err(err).Msg("Error calculating Percentiles")
+	}
+
+	w.Metrics.CalculateMaxLatency()
+	w.Metrics.CalculateErrorRate()
+	w.Metrics.CalculateThroughput(elapsed)
+	w.Metrics.CalculateBreakdown()
+	w.calculateVariants(elapsed)
+	w.persistSamples(store)
+	if w.breaker != nil {
+		openFor, openings := w.breaker.stats()
+		w.Metrics.SetCircuitBreakerStats(openFor, openings)
+	}
+	w.diagnose()
+
+	var finalStatus Status
+	switch {
+	case !completedSuccessfully && ctx.Err() != nil:
+		// The run was stopped, its metrics cover what was sent until then.
+		finalStatus = StatusCancelled
+	case !completedSuccessfully:
+		finalStatus = StatusFailed
+	case w.isUnderperforming():
+		finalStatus = StatusUnderperforming
+	default:
+		finalStatus = StatusFinished
+	}
+
+	// The metrics and the final status are stored together, a completed worker never lacks its metrics.
+	if err := store.FinishRun(w.ID, finalStatus, w.Metrics); err != nil {
+		w.log.Error().Err(err).Msgf("Error finishing the run with status %s", finalStatus)
+		return
+	}
+	w.SetStatus(finalStatus)
+	finished = true
+	w.logSummary(finalStatus)
+}
+
+// logSummary logs the outcome of a run, the fields raw and the message readable.
+func (w *Worker) logSummary(status Status) {
+	metrics := w.Metrics
+	w.log.Info().
+		Int("worker_id", w.ID).
+		Str("status", string(status)).
+		Int("requests", metrics.TotalRequests).
+		Int("failed", metrics.FailedRequests).
+		Float64("error_rate", metrics.ErrorRate).
+		Float64("throughput", metrics.Throughput).
+		Float64("p50", metrics.Percentiles[P50]).
+		Float64("p99", metrics.Percentiles[P99]).
+		Float64("max_latency", metrics.MaxLatency).
+		Msgf("Worker %d %s: %d requests at %.2f req/s, error rate %.4f, p50 %s, p99 %s, max %s",
+			w.ID, status, metrics.TotalRequests, metrics.Throughput, metrics.ErrorRate,
+			FormatSeconds(metrics.Percentiles[P50]), FormatSeconds(metrics.Percentiles[P99]), FormatSeconds(metrics.MaxLatency))
+}
+
+// runFixed sends Concurrency*RequestsPerTask requests, followed by the ramp
+// down if any, and reports whether all of them were sent before ctx was cancelled.
+func (w *Worker) runFixed(ctx context.Context, wg *sync.WaitGroup) bool {
+	requests := make(chan int, w.Concurrency)
+	done := make(chan struct{})
+	rampUpEnd := time.Now().Add(time.Duration(w.RampUp))
+	var rampDownStart time.Time // set before requests is closed, zero if the run was cancelled
 
 	for i := 0; i < w.Concurrency; i++ {
 		wg.Add(1)
-		go w.run(wg, requests)
+		go w.run(ctx, wg, requests, i, rampUpEnd, &rampDownStart)
 	}
 
 	go func() {
-		for i := 0; i < w.Concurrency*w.RequestsPerTask; i++ {
-			requests <- i
+		produced := 0
+	produce:
+		for ; produced < w.Concurrency*w.RequestsPerTask; produced++ {
+			select {
+			case requests <- produced:
+			case <-ctx.Done():
+				break produce
+			}
+		}
+		if produced == w.Concurrency*w.RequestsPerTask {
+			rampDownStart = time.Now()
 		}
 		close(requests)
 
@@ -94,99 +397,267 @@ func (w *Worker) Start(ctx context.Context, wg *sync.WaitGroup, updateStatusFunc
 
 	select {
 	case <-done:
-		completedSuccessfully = true
-		w.log.Info().Msgf("Worker %d finished in %s", w.ID, time.Since(start))
+		return true
 	case <-ctx.Done():
-		completedSuccessfully = false
+		// In-flight requests abort with ctx, wait for them to be counted.
+		<-done
+		return false
 	}
+}
+
+func (w *Worker) run(ctx context.Context, wg *sync.WaitGroup, requests <-chan int, index int, rampUpEnd time.Time, rampDownStart *time.Time) {
+	defer wg.Done()
 
-	if err := updateStatusFunc(w.ID, StatusFinished); err != nil {
-		w.log.Error().Err(err).Msg("Error updating status to finished")
+	rng := w.newRand(randPacing, index)
+	if !sleep(ctx, w.rampUpDelay(index, rng)) {
 		return
 	}
-	w.SetStatus(StatusFinished)
 
-	ranks := []PercentileRank{P50, P95, P99, P999}
-	if err := w.Metrics.CalculatePercentiles(ranks...); err != nil {
-		w.log.Error().Err(err).Msg("Error calculating Percentiles")
-		return
+	for range requests {
+		phase := PhaseMain
+		if time.Now().Before(rampUpEnd) {
+			phase = PhaseRampUp
+		}
+		w.send(ctx, index, phase, w.Metrics)
+
+		if w.throttled(index) {
+			return
+		}
+
+		t := w.thinkTime(rng)
+		w.requestLog.Debug().Msgf("Sleeping for %s", t)
+		if !sleep(ctx, t) {
+			return
+		}
 	}
 
-	w.Metrics.CalculateMaxLatency()
-	w.Metrics.CalculateErrorRate()
+	// Closing requests makes rampDownStart visible.
+	if w.RampDown > 0 && !rampDownStart.IsZero() {
+		w.coolDown(ctx, index, rng, *rampDownStart)
+	}
+}
 
-	if err := updateMetricsFunc(w.ID, w.Metrics); err != nil {
-		w.log.Error().Err(err).Msg("Error updating metrics")
-		return
+// send issues a request for the goroutine with the given index, retrying it
+// after a backoff while it fails and the retry policy allows it.
+func (w *Worker) send(ctx context.Context, index int, phase Phase, metrics ...*Metrics) {
+	var delay time.Duration
+	for attempt := 0; ; attempt++ {
+		if w.attempt(ctx, index, phase, metrics...) || w.Retry == nil || attempt == w.Retry.MaxRetries || ctx.Err() != nil {
+			return
+		}
+
+		delay = w.Retry.backoff(attempt, delay, w.retryRands.get(index))
+		w.requestLog.Debug().Msgf("Retrying in %s", delay)
+		if !sleep(ctx, delay) {
+			return
+		}
 	}
 }
 
-func (w *Worker) run(wg *sync.WaitGroup, requests <-chan int) {
-	defer wg.Done()
+// attempt issues a single request for the goroutine with the given index,
+// records its outcome under phase in every given metrics and reports whether
+// it succeeded. It waits first for the process-wide request rate, then while
+// the circuit breaker is open.
+func (w *Worker) attempt(ctx context.Context, index int, phase Phase, metrics ...*Metrics) bool {
+	// Waited on before the breaker, a probe it lets through must be sent.
+	waited, ok := w.limiter.wait(ctx)
+	if !ok {
+		return false
+	}
+	if waited > 0 {
+		w.Metrics.AddGlobalThrottle(waited)
+	}
 
-	for range requests {
-		switch w.HTTPMethod {
-		case http.MethodGet:
-			w.get(w.Environment.Endpoint)
-		case http.MethodPost:
-			w.post(w.Environment.Endpoint)
+	var probe bool
+	if w.breaker != nil {
+		var ok bool
+		if probe, ok = w.breaker.acquire(ctx); !ok {
+			return false
 		}
+	}
 
-		t := time.Duration(rand.Intn(1000)) * time.Millisecond
-		w.log.Debug().Msgf("Sleeping for %s", t)
-		time.Sleep(t)
+	w.lastRequest.Store(time.Now().UnixNano())
+	ctx = withGoroutine(ctx, index)
+	ctx = w.withIdentity(ctx, index)
+	ctx = w.withScenarioStep(ctx, index)
+	start := time.Now()
+	succeeded := w.request(ctx, w.Environment.Endpoint, w.connection(index), phase, metrics...)
+
+	// A request aborted because the run ended says nothing about the target.
+	if ctx.Err() == nil {
+		if w.breaker != nil {
+			w.breaker.record(!succeeded, probe)
+		}
+		w.stats.request(w.statsTags, time.Since(start), !succeeded)
 	}
+	return succeeded
 }
 
-func (w *Worker) get(url string) {
-	client := &http.Client{}
-	req, err := w.createRequest("GET", url)
+// request sends a request with the method and the body of the worker, or
+// of the scenario step, and reports whether a response was received in
+// time, a response over the latency failure threshold failing under
+// SlowRequestFail. Requests aborted because ctx ended are counted as
+// cancelled rather than failed. The outcome is also counted under
+// connection in the metrics of the worker, unless it is empty.
+func (w *Worker) request(ctx context.Context, url string, connection Connection, phase Phase, metrics ...*Metrics) bool {
+	req, err := w.createRequest(ctx, w.HTTPMethod, url, w.requestBody())
 	if err != nil {
-		w.log.Error().Err(err).Msgf("Error creating request with HTTP method %s on the URL %s", w.HTTPMethod, url)
-		return
+		w.log.Error().Err(err).Msgf("Error creating request with HTTP method %s on the URL %s", w.HTTPMethod, RedactURL(url))
+		return false
 	}
+	if m := w.variantMetrics(req); m != nil {
+		// The slice may be shared by several goroutines, it must not be appended to in place.
+		metrics = append(metrics[:len(metrics):len(metrics)], m)
+	}
+	req, timing := w.trace(req, metrics)
+	req, deadline := w.withTimeouts(req)
+	defer deadline.stop()
+	requestID := w.requestID(req)
 
-	w.log.Debug().Msgf("Sending request to: %s", url)
+	w.requestLog.Debug().Msgf("Sending request %s to: %s", requestID, RedactURL(url))
+	w.recordFirstRequest(req)
 
 	start := time.Now()
-	resp, err := client.Do(req)
+	resp, err := w.client.Do(req)
 	latency := time.Since(start)
-	w.Metrics.IncrementTotalRequests()
+	for _, m := range metrics {
+		m.IncrementTotalRequests(phase)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		w.requestLog.Debug().Err(err).Msgf("Request with HTTP method %s on the URL %s cancelled", req.Method, RedactURL(url))
+		for _, m := range metrics {
+			m.IncrementCancelledRequests(phase)
+		}
+		w.recordRequest(requestRecord{sentAt: start, latency: latency, phase: phase, outcome: RecordOutcomeCancelled, requestID: requestID})
+		return false
+	}
 
 	if err != nil {
-		w.log.Error().Err(err).Msgf("Error sending request with HTTP method %s on the URL %s", w.HTTPMethod, url)
-		w.Metrics.IncrementFailedRequests()
-		return
+		w.log.Error().Err(err).Str("request_id", requestID).Msgf("Error sending request with HTTP method %s on the URL %s", req.Method, RedactURL(url))
+		class := deadline.classify(err)
+		for _, m := range metrics {
+			m.IncrementFailedRequests(phase)
+			m.IncrementErrorClass(class)
+		}
+		if connection != "" {
+			w.Metrics.AddConnectionRequest(connection, 0, false)
+		}
+		if class == ErrorClassFileDescriptors {
+			w.onResourceExhaustion()
+		}
+		w.captureError(phase, requestID, err)
+		w.recordRequest(requestRecord{sentAt: start, latency: latency, phase: phase, outcome: string(class), requestID: requestID})
+		return false
 	}
+	resp.Body = deadline.watch(resp.Body)
 	defer resp.Body.Close()
 
-	w.log.Debug().Msgf("Response status code: %s", resp.Status)
+	w.requestLog.Debug().Msgf("Response status code: %s", resp.Status)
 
-	w.Metrics.AddLatency(latency)
-}
+	var body []byte
+	if w.BodyAssertion != nil && responseClass(resp.StatusCode) == ResponseClass2xx {
+		body, _ = io.ReadAll(io.LimitReader(resp.Body, assertionBodyLimit))
+	}
 
-func (w *Worker) post(url string) {
-	client := &http.Client{}
-	req, err := w.createRequest("GET", url)
-	if err != nil {
-		w.log.Error().Err(err).Msgf("Error creating request with HTTP method %s on the URL %s", w.HTTPMethod, url)
-		return
+	var sample *CapturedResponse
+	if w.capture != nil {
+		if class := responseClass(resp.StatusCode); w.capture.wants(class) {
+			if body == nil {
+				body, _ = io.ReadAll(io.LimitReader(resp.Body, captureBodyLimit))
+			}
+			sample = &CapturedResponse{
+				RequestID:  requestID,
+				Class:      class,
+				Phase:      phase,
+				StatusCode: resp.StatusCode,
+				Latency:    latency.Seconds(),
+				Body:       string(body[:min(len(body), captureBodyLimit)]),
+				CapturedAt: time.Now().UTC(),
+			}
+		}
 	}
 
-	w.log.Debug().Msgf("Sending request to: %s", url)
+	// Reading the body measures the transfer stage and lets the connection be reused.
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		w.requestLog.Debug().Err(err).Msgf("Error reading the response body on the URL %s", RedactURL(url))
+		// Unlike other errors of the body, a timeout means the response never came in full.
+		if class, timedOut := deadline.timeoutClass(); timedOut {
+			for _, m := range metrics {
+				m.IncrementFailedRequests(phase)
+				m.IncrementErrorClass(class)
+			}
+			if connection != "" {
+				w.Metrics.AddConnectionRequest(connection, 0, false)
+			}
+			w.captureError(phase, requestID, err)
+			w.recordRequest(requestRecord{sentAt: start, latency: time.Since(start), phase: phase, outcome: string(class), requestID: requestID})
+			return false
+		}
+	}
+	durations := timing.finish()
 
-	resp, err := client.Do(req)
-	if err != nil {
-		w.log.Error().Err(err).Msgf("Error sending request with HTTP method %s on the URL %s", w.HTTPMethod, url)
-		return
+	if sample != nil {
+		w.capture.add(sample)
 	}
-	defer resp.Body.Close()
 
-	w.log.Debug().Msgf("Response status code: %s", resp.Status)
+	for _, m := range metrics {
+		m.AddLatency(latency, phase)
+		m.AddStageDurations(durations)
+		m.IncrementStatusClass(responseClass(resp.StatusCode))
+	}
+	w.countServedBy(resp, metrics)
+	failed := w.countHeader(resp.StatusCode, resp.Header, phase, metrics, w.countSlow(latency, phase, metrics))
+	failed = w.countAssertion(resp.StatusCode, body, phase, metrics, failed)
+	succeeded := !w.countRedirect(resp.StatusCode, phase, metrics, failed)
+	if connection != "" {
+		w.Metrics.AddConnectionRequest(connection, latency, true)
+	}
+	w.recordSample(start, latency, phase)
+	w.recordRequest(requestRecord{sentAt: start, latency: latency, statusCode: resp.StatusCode, phase: phase, outcome: RecordOutcomeResponse, requestID: requestID})
+	if requestID != "" {
+		w.Metrics.AddSlowRequest(requestID, latency, phase)
+	}
+	return succeeded
+}
+
+// requestBody returns the body of the requests of the worker, nil for the
+// methods without one and for the scenarios, whose steps bring their own.
+func (w *Worker) requestBody() io.Reader {
+	if w.Body == nil || w.Scenario != nil || !sendsBody(w.HTTPMethod) {
+		return nil
+	}
+	return bytes.NewReader(*w.Body)
 }
 
-func (w *Worker) createRequest(method, url string) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, nil)
+// sendsBody reports whether the requests of method carry a body, GET and
+// HEAD going without one whatever the worker configures.
+func sendsBody(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead
+}
+
+// createRequest builds a request bound to ctx, cancelling ctx aborts it while in flight.
+// Body is sent unless a scenario step, a variant, a data row or a body stream replaces it,
+// none of them being sent with a GET or a HEAD.
+func (w *Worker) createRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	step, replayed := scenarioStep(ctx)
+	if replayed {
+		var err error
+		if url, err = w.stepURL(step); err != nil {
+			return nil, err
+		}
+		method = step.Method
+	}
+
+	// Keep-alive pings must not take a row of the data source.
+	ping := isPing(ctx)
+	var row []string
+	if w.data != nil && !ping {
+		row = w.data.take()
+		url = w.data.expandURL(url, row)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -200,6 +671,69 @@ func (w *Worker) createRequest(method, url string) (*http.Request, error) {
 		req.Header.Add("Authorization", "Bearer "+token)
 	}
 
-	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Content-Type", w.ContentType())
+	if replayed {
+		step.withStep(req)
+	}
+	if w.requestIDs != nil {
+		req.Header.Set(w.CorrelationHeader, w.requestIDs.next())
+	}
+	// Keep-alive pings must not take a turn of the variants.
+	if w.variants != nil && !ping && sendsBody(method) {
+		req = w.variants.withVariant(req)
+	}
+	if row != nil {
+		if req, err = w.data.withBody(req, row); err != nil {
+			return nil, err
+		}
+	}
+	// Keep-alive pings must not carry the stream.
+	if w.stream != nil && !ping && sendsBody(method) {
+		if req, err = w.withBodyStream(req); err != nil {
+			return nil, err
+		}
+	}
+	// Set last, an identity sent in Authorization replaces the token of the environment.
+	if w.Identities != nil {
+		w.setIdentity(req)
+	}
 	return req, nil
 }
+
+// ContentType returns the content type the body is sent with.
+func (w *Worker) ContentType() string {
+	if w.BodyContentType == "" {
+		return DefaultBodyContentType
+	}
+	return w.BodyContentType
+}
+
+// isUnderperforming reports whether the achieved throughput fell short of the
+// configured share of the target request rate. It is a no-op without an SLA.
+func (w *Worker) isUnderperforming() bool {
+	if w.TargetRPS <= 0 || w.MinThroughputRatio <= 0 {
+		return false
+	}
+
+	required := w.TargetRPS * w.MinThroughputRatio
+	if w.Metrics.Throughput >= required {
+		return false
+	}
+
+	w.log.Warn().Msgf(
+		"Worker %d underperformed: achieved %.2f req/s (effective concurrency %.2f), required at least %.2f req/s (%.0f%% of %.2f)",
+		w.ID, w.Metrics.Throughput, w.Metrics.EffectiveConcurrency, required, w.MinThroughputRatio*100, w.TargetRPS,
+	)
+	return true
+}
+
+// addWarning annotates the running worker with something that makes its results doubtful.
+func (w *Worker) addWarning(store WorkerStore, warning string) {
+	w.log.Warn().Msgf("Worker %d %s", w.ID, warning)
+	w.mu.Lock()
+	w.Warnings = append(w.Warnings, warning)
+	w.mu.Unlock()
+	if err := store.AddWarning(w.ID, warning); err != nil {
+		w.log.Error().Err(err).Msg("Error adding a warning")
+	}
+}
@@ -0,0 +1,193 @@
+package entity
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultAutoTuneMinGain is the throughput increase a step must bring when AutoTuneConfig.MinGain is not set.
+const defaultAutoTuneMinGain = 0.05
+
+// AutoTuneConfig holds the parameters of the auto_tune mode.
+type AutoTuneConfig struct {
+	MinConcurrency int      `json:"min_concurrency"`
+	MaxConcurrency int      `json:"max_concurrency"`
+	Step           int      `json:"step"` // initial concurrency increase, halved on every back off
+	StepDuration   Duration `json:"step_duration"`
+	LatencySLOMs   float64  `json:"latency_slo_ms"`     // p95 bound, 0 disables the check
+	ErrorSLO       float64  `json:"error_slo"`          // max error rate (0-1), 0 disables the check
+	MinGain        float64  `json:"min_gain,omitempty"` // throughput increase (fraction) a step must bring to be kept, defaultAutoTuneMinGain when 0
+	MaxDuration    Duration `json:"max_duration"`       // 0 means no limit
+}
+
+// AutoTuneStep is one point of the concurrency trajectory.
+type AutoTuneStep struct {
+	Concurrency int      `json:"concurrency"`
+	Throughput  float64  `json:"throughput"` // in requests per second
+	P95         float64  `json:"p95"`        // in seconds
+	ErrorRate   float64  `json:"error_rate"`
+	Accepted    bool     `json:"accepted"` // false when the step was backed off from
+	Violated    bool     `json:"violated"` // the latency or error SLO was exceeded
+	Offset      Duration `json:"offset"`   // since the start of the run
+}
+
+type AutoTuneResult struct {
+	SaturationConcurrency int             `json:"saturation_concurrency"` // the best concurrency within the SLOs, 0 if none was
+	SaturationThroughput  float64         `json:"saturation_throughput"`  // in requests per second
+	Steps                 []*AutoTuneStep `json:"steps"`
+}
+
+// autoTune searches the concurrency at which the throughput stops rising.
+// It starts at MinConcurrency and keeps adding Step goroutines while the
+// throughput grows by MinGain and the SLOs hold. On a step that doesn't, it
+// backs off to the best level and halves the step, ending once the step
+// can't be halved anymore. It reports whether the search ended on its own
+// rather than through cancellation.
+func (w *Worker) autoTune(ctx context.Context) bool {
+	config := w.AutoTuneConfig
+	tuneCtx := ctx
+	if config.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		tuneCtx, cancel = context.WithTimeout(ctx, time.Duration(config.MaxDuration))
+		defer cancel()
+	}
+
+	minGain := config.MinGain
+	if minGain <= 0 {
+		minGain = defaultAutoTuneMinGain
+	}
+
+	w.AutoTuneResult = &AutoTuneResult{}
+	pool := newGoroutinePool(tuneCtx, w)
+	defer pool.stop()
+
+	start := time.Now()
+	best, bestThroughput := 0, 0.0
+	step := config.Step
+
+	for concurrency := config.MinConcurrency; ; {
+		stepMetrics := pool.measure(concurrency, time.Duration(config.StepDuration))
+		if tuneCtx.Err() != nil {
+			// A partially executed step says nothing about the target.
+			break
+		}
+
+		result := &AutoTuneStep{
+			Concurrency: concurrency,
+			Throughput:  stepMetrics.Throughput,
+			P95:         stepMetrics.Percentiles[P95],
+			ErrorRate:   stepMetrics.ErrorRate,
+			Violated:    config.violated(stepMetrics),
+			Offset:      Duration(time.Since(start)),
+		}
+		result.Accepted = !result.Violated && (best == 0 || result.Throughput >= bestThroughput*(1+minGain))
+		w.AutoTuneResult.Steps = append(w.AutoTuneResult.Steps, result)
+
+		w.log.Info().Msgf("Worker %d auto tune step at concurrency %d: %.2f req/s, p95 %s, error rate %.4f, accepted: %t",
+			w.ID, concurrency, result.Throughput, FormatSeconds(result.P95), result.ErrorRate, result.Accepted)
+
+		if result.Accepted {
+			best, bestThroughput = concurrency, result.Throughput
+		} else {
+			if best == 0 {
+				// Even the lowest concurrency is beyond the SLOs.
+				break
+			}
+			step /= 2
+		}
+
+		next := min(best+step, config.MaxConcurrency)
+		if step < 1 || next == best {
+			break
+		}
+		concurrency = next
+	}
+
+	w.AutoTuneResult.SaturationConcurrency = best
+	w.AutoTuneResult.SaturationThroughput = bestThroughput
+	return ctx.Err() == nil
+}
+
+func (c *AutoTuneConfig) violated(m *Metrics) bool {
+	if m.allFailed() {
+		return true
+	}
+	if c.LatencySLOMs > 0 && m.Percentiles[P95]*1000 > c.LatencySLOMs {
+		return true
+	}
+	if c.ErrorSLO > 0 && m.ErrorRate > c.ErrorSLO {
+		return true
+	}
+	return false
+}
+
+// goroutinePool runs a resizable number of goroutines sending requests back
+// to back, the requests of each step being recorded in its own metrics.
+type goroutinePool struct {
+	ctx     context.Context
+	worker  *Worker
+	wg      sync.WaitGroup
+	stops   []chan struct{}
+	current atomic.Pointer[Metrics]
+}
+
+func newGoroutinePool(ctx context.Context, worker *Worker) *goroutinePool {
+	return &goroutinePool{ctx: ctx, worker: worker}
+}
+
+// measure resizes the pool to concurrency and returns the metrics of the
+// requests sent during the following duration. In-flight requests aren't
+// interrupted by a resize, they are recorded in the step they were sent in.
+func (p *goroutinePool) measure(concurrency int, duration time.Duration) *Metrics {
+	stepMetrics := NewMetrics()
+	p.current.Store(stepMetrics)
+	p.resize(concurrency)
+
+	start := time.Now()
+	sleep(p.ctx, duration)
+
+	stepMetrics.CalculateMaxLatency()
+	stepMetrics.CalculateErrorRate()
+	stepMetrics.CalculateThroughput(time.Since(start))
+	if err := stepMetrics.CalculatePercentiles(P50, P95, P99); err != nil {
+		p.worker.log.Debug().Err(err).Msg("Error calculating step percentiles")
+	}
+	return stepMetrics
+}
+
+func (p *goroutinePool) resize(concurrency int) {
+	for len(p.stops) < concurrency {
+		stop := make(chan struct{})
+		p.stops = append(p.stops, stop)
+		p.wg.Add(1)
+		go p.run(len(p.stops)-1, stop)
+	}
+	for len(p.stops) > concurrency {
+		last := len(p.stops) - 1
+		close(p.stops[last])
+		p.stops = p.stops[:last]
+	}
+}
+
+func (p *goroutinePool) run(index int, stop <-chan struct{}) {
+	defer p.wg.Done()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-p.ctx.Done():
+			return
+		default:
+		}
+		p.worker.send(p.ctx, index, PhaseMain, p.worker.Metrics, p.current.Load())
+	}
+}
+
+// stop ends every goroutine and waits for their last request.
+func (p *goroutinePool) stop() {
+	p.resize(0)
+	p.wg.Wait()
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAutoTune(t *testing.T) {
+	// The stub answers in 5ms up to 4 concurrent requests and slows down to
+	// 40ms past them, so the throughput saturates at 4.
+	var inFlight atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer inFlight.Add(-1)
+		if inFlight.Add(1) > 4 {
+			time.Sleep(40 * time.Millisecond)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}))
+	defer stub.Close()
+
+	config := &AutoTuneConfig{
+		MinConcurrency: 1,
+		MaxConcurrency: 16,
+		Step:           2,
+		StepDuration:   Duration(150 * time.Millisecond),
+		LatencySLOMs:   20,
+	}
+	worker := newTestWorker(stub.URL, 1, 1, WithWorkerAutoTune(config))
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Errorf("status = %s, want %s", got, StatusFinished)
+	}
+	result := store.autoTuneResult
+	if result == nil {
+		t.Fatal("no auto tune result stored")
+	}
+
+	// The search steps past 4 and backs off from every step beyond it. The
+	// step at 4 itself may be backed off from too, if it doesn't bring enough
+	// throughput over 3.
+	var pastSaturation int
+	for _, step := range result.Steps {
+		if step.Concurrency <= 4 {
+			continue
+		}
+		pastSaturation++
+		if step.Accepted || !step.Violated {
+			t.Errorf("steps = %s, want every step past 4 backed off from for its latency", formatSteps(result.Steps))
+		}
+	}
+	if pastSaturation == 0 {
+		t.Errorf("steps = %s, want the search to step past 4", formatSteps(result.Steps))
+	}
+
+	if result.SaturationConcurrency != 3 && result.SaturationConcurrency != 4 {
+		t.Errorf("saturation concurrency = %d, want 3 or 4, steps = %s", result.SaturationConcurrency, formatSteps(result.Steps))
+	}
+	if result.SaturationThroughput == 0 {
+		t.Error("saturation throughput = 0, want the one of the saturation step")
+	}
+}
+
+func formatSteps(steps []*AutoTuneStep) string {
+	var s string
+	for _, step := range steps {
+		s += fmt.Sprintf("[%d %.0f req/s p95 %s accepted: %t] ", step.Concurrency, step.Throughput, FormatSeconds(step.P95), step.Accepted)
+	}
+	return s
+}
@@ -0,0 +1,123 @@
+package entity
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// assertionBodyLimit bounds the part of a response body parsed for a body
+// assertion, a longer body failing it as it doesn't parse once truncated.
+const assertionBodyLimit = 64 << 10 // in bytes
+
+// BodyAssertion has a worker count a 2xx response as a success only when a
+// field of its JSON body equals the expected value, for the APIs answering
+// 200 with an error in the body.
+type BodyAssertion struct {
+	Path  string `json:"path"`  // of the field, like $.data.items[0].status
+	Value any    `json:"value"` // compared as JSON, null expecting a null field
+}
+
+// Valid reports whether the path of the assertion parses.
+func (a *BodyAssertion) Valid() bool {
+	_, ok := parseJSONPath(a.Path)
+	return ok
+}
+
+// jsonPathStep is a step of a path, the key of an object or, when key is
+// empty, the index of an array.
+type jsonPathStep struct {
+	key   string
+	index int
+}
+
+// parseJSONPath parses the subset of JSONPath the assertions use: $
+// followed by .key and [index] steps. It reports whether path parsed.
+func parseJSONPath(path string) ([]jsonPathStep, bool) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, false
+	}
+
+	var steps []jsonPathStep
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				return nil, false
+			}
+			steps = append(steps, jsonPathStep{key: rest[1:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, false
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil || index < 0 {
+				return nil, false
+			}
+			steps = append(steps, jsonPathStep{index: index})
+			rest = rest[end+1:]
+		default:
+			return nil, false
+		}
+	}
+	return steps, true
+}
+
+// matches reports whether body is JSON holding the expected value at the
+// path of the assertion, steps being the parsed path.
+func (a *BodyAssertion) matches(steps []jsonPathStep, body []byte) bool {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return false
+	}
+
+	for _, step := range steps {
+		switch node := value.(type) {
+		case map[string]any:
+			child, found := node[step.key]
+			if step.key == "" || !found {
+				return false
+			}
+			value = child
+		case []any:
+			if step.key != "" || step.index >= len(node) {
+				return false
+			}
+			value = node[step.index]
+		default:
+			return false
+		}
+	}
+
+	// The expected value went through JSON too, its numbers being float64 alike.
+	return reflect.DeepEqual(value, a.Value)
+}
+
+// countAssertion checks the body of a response with statusCode against the
+// body assertion of the worker and reports whether the request failed,
+// failed telling whether it already failed otherwise, in which case it
+// isn't counted twice. A body that isn't JSON fails the assertion. The
+// other classes of responses keep their own accounting.
+func (w *Worker) countAssertion(statusCode int, body []byte, phase Phase, metrics []*Metrics, failed bool) bool {
+	if w.BodyAssertion == nil || failed || responseClass(statusCode) != ResponseClass2xx {
+		return failed
+	}
+
+	if w.BodyAssertion.matches(w.assertionPath, body) {
+		return false
+	}
+
+	for _, m := range metrics {
+		m.IncrementFailedRequests(phase)
+		m.IncrementErrorClass(ErrorClassAssertionFailed)
+	}
+	return true
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBodyAssertion(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		assertion  *BodyAssertion
+		wantFailed int
+	}{
+		{name: "matching", body: `{"data": {"items": [{"status": "ok"}]}}`, assertion: &BodyAssertion{Path: "$.data.items[0].status", Value: "ok"}},
+		{name: "matching number", body: `{"count": 3}`, assertion: &BodyAssertion{Path: "$.count", Value: 3.0}},
+		{name: "matching null", body: `{"error": null}`, assertion: &BodyAssertion{Path: "$.error", Value: nil}},
+		{name: "mismatching", body: `{"data": {"items": [{"status": "degraded"}]}}`, assertion: &BodyAssertion{Path: "$.data.items[0].status", Value: "ok"}, wantFailed: 4},
+		{name: "missing field", body: `{"data": {"items": []}}`, assertion: &BodyAssertion{Path: "$.data.items[0].status", Value: "ok"}, wantFailed: 4},
+		{name: "missing field isn't null", body: `{}`, assertion: &BodyAssertion{Path: "$.error", Value: nil}, wantFailed: 4},
+		{name: "non-JSON", body: `<html>ok</html>`, assertion: &BodyAssertion{Path: "$.status", Value: "ok"}, wantFailed: 4},
+		{name: "error responses keep their own accounting", status: http.StatusServiceUnavailable, body: `maintenance`, assertion: &BodyAssertion{Path: "$.status", Value: "ok"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.status != 0 {
+					w.WriteHeader(test.status)
+				}
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer stub.Close()
+
+			worker := newTestWorker(stub.URL, 2, 2, WithWorkerBodyAssertion(test.assertion))
+			runWorker(context.Background(), worker)
+
+			metrics := worker.Metrics
+			if metrics.TotalRequests != 4 || metrics.FailedRequests != test.wantFailed {
+				t.Errorf("%d requests of which %d failed, want 4 and %d", metrics.TotalRequests, metrics.FailedRequests, test.wantFailed)
+			}
+			if got := metrics.ErrorClasses[ErrorClassAssertionFailed]; got != test.wantFailed {
+				t.Errorf("%d assertion failures, want %d", got, test.wantFailed)
+			}
+		})
+	}
+}
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   []jsonPathStep
+		wantOK bool
+	}{
+		{path: "$", wantOK: true},
+		{path: "$.status", want: []jsonPathStep{{key: "status"}}, wantOK: true},
+		{path: "$.data.items[2].id", want: []jsonPathStep{{key: "data"}, {key: "items"}, {index: 2}, {key: "id"}}, wantOK: true},
+		{path: "$[0][1]", want: []jsonPathStep{{index: 0}, {index: 1}}, wantOK: true},
+		{path: "status"},
+		{path: "$."},
+		{path: "$..status"},
+		{path: "$.items[-1]"},
+		{path: "$.items[x]"},
+		{path: "$.items[0"},
+	}
+
+	for _, test := range tests {
+		steps, ok := parseJSONPath(test.path)
+		if ok != test.wantOK || len(steps) != len(test.want) {
+			t.Errorf("parseJSONPath(%q) = %v, %t, want %v, %t", test.path, steps, ok, test.want, test.wantOK)
+			continue
+		}
+		for i := range steps {
+			if steps[i] != test.want[i] {
+				t.Errorf("parseJSONPath(%q) = %v, want %v", test.path, steps, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package entity
+
+import (
+	"sync"
+	"time"
+)
+
+// captureBodyLimit bounds the part of a response body kept in a captured sample.
+const captureBodyLimit = 4096 // in bytes
+
+// MaxCaptureQuota bounds the samples kept for a single response class.
+const MaxCaptureQuota = 50
+
+// ResponseClass groups responses by status class, transport errors having their own.
+type ResponseClass string
+
+const (
+	ResponseClass1xx   ResponseClass = "1xx"
+	ResponseClass2xx   ResponseClass = "2xx"
+	ResponseClass3xx   ResponseClass = "3xx"
+	ResponseClass4xx   ResponseClass = "4xx"
+	ResponseClass5xx   ResponseClass = "5xx"
+	ResponseClassError ResponseClass = "error" // no response was received
+)
+
+// CaptureQuotas is the number of samples to keep per response class, e.g.
+// {"2xx": 5, "4xx": 5, "5xx": 5}. Classes without a quota are not captured.
+type CaptureQuotas map[ResponseClass]int
+
+// Valid reports whether every class is known and every quota within MaxCaptureQuota.
+func (q CaptureQuotas) Valid() bool {
+	for class, quota := range q {
+		switch class {
+		case ResponseClass1xx, ResponseClass2xx, ResponseClass3xx, ResponseClass4xx, ResponseClass5xx, ResponseClassError:
+		default:
+			return false
+		}
+		if quota < 0 || quota > MaxCaptureQuota {
+			return false
+		}
+	}
+	return true
+}
+
+// CapturedResponse is a sample of the responses received during a run.
+type CapturedResponse struct {
+	RequestID  string        `json:"request_id,omitempty"` // the value of the correlation header
+	Class      ResponseClass `json:"class"`
+	Phase      Phase         `json:"phase"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Latency    float64       `json:"latency,omitempty"` // in seconds
+	Body       string        `json:"body,omitempty"`    // truncated to captureBodyLimit
+	CapturedAt time.Time     `json:"captured_at"`
+}
+
+func responseClass(statusCode int) ResponseClass {
+	switch {
+	case statusCode < 200:
+		return ResponseClass1xx
+	case statusCode < 300:
+		return ResponseClass2xx
+	case statusCode < 400:
+		return ResponseClass3xx
+	case statusCode < 500:
+		return ResponseClass4xx
+	default:
+		return ResponseClass5xx
+	}
+}
+
+// captureBuffer keeps the first samples of every class up to its quota, so
+// a flood of one class can't crowd the others out.
+type captureBuffer struct {
+	mu      sync.Mutex
+	quotas  CaptureQuotas
+	samples map[ResponseClass][]*CapturedResponse
+}
+
+func newCaptureBuffer(quotas CaptureQuotas) *captureBuffer {
+	return &captureBuffer{
+		quotas:  quotas,
+		samples: make(map[ResponseClass][]*CapturedResponse),
+	}
+}
+
+// wants reports whether a sample of class would still be kept, so the
+// caller only reads the body when needed.
+func (c *captureBuffer) wants(class ResponseClass) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.samples[class]) < c.quotas[class]
+}
+
+func (c *captureBuffer) add(sample *CapturedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.samples[sample.Class]) >= c.quotas[sample.Class] {
+		return
+	}
+	c.samples[sample.Class] = append(c.samples[sample.Class], sample)
+}
+
+// all returns the samples grouped by class, in the class order.
+func (c *captureBuffer) all() []*CapturedResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var samples []*CapturedResponse
+	for _, class := range []ResponseClass{ResponseClass1xx, ResponseClass2xx, ResponseClass3xx, ResponseClass4xx, ResponseClass5xx, ResponseClassError} {
+		samples = append(samples, c.samples[class]...)
+	}
+	return samples
+}
+
+// captureError keeps a sample of a request that got no response.
+func (w *Worker) captureError(phase Phase, requestID string, err error) {
+	if w.capture == nil || !w.capture.wants(ResponseClassError) {
+		return
+	}
+
+	w.capture.add(&CapturedResponse{
+		RequestID:  requestID,
+		Class:      ResponseClassError,
+		Phase:      phase,
+		Error:      err.Error(),
+		CapturedAt: time.Now().UTC(),
+	})
+}
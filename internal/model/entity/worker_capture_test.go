@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCaptureQuotas(t *testing.T) {
+	// Mostly successes, so that they would crowd the failures out of a
+	// buffer keeping the first samples regardless of their class.
+	statuses := []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusNotFound, http.StatusOK, http.StatusBadGateway}
+	var requests atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1) - 1
+		w.WriteHeader(statuses[int(n)%len(statuses)])
+		w.Write([]byte(`{"ok":false}`))
+	}))
+	defer stub.Close()
+
+	quotas := CaptureQuotas{ResponseClass2xx: 2, ResponseClass4xx: 3, ResponseClass5xx: 5}
+	worker := newTestWorker(stub.URL, 3, 12, WithWorkerCaptureQuotas(quotas))
+	store := runWorker(context.Background(), worker)
+
+	// 36 requests: 24 successes, 6 of 4xx and 6 of 5xx.
+	want := map[ResponseClass]int{ResponseClass2xx: 2, ResponseClass4xx: 3, ResponseClass5xx: 5}
+	got := make(map[ResponseClass]int)
+	for _, sample := range store.capturedResponses {
+		got[sample.Class]++
+		if sample.Class != responseClass(sample.StatusCode) {
+			t.Errorf("sample of status %d captured as %s", sample.StatusCode, sample.Class)
+		}
+		if sample.Body != `{"ok":false}` {
+			t.Errorf("body = %q, want the one of the response", sample.Body)
+		}
+	}
+	for class, count := range want {
+		if got[class] != count {
+			t.Errorf("%s samples = %d, want %d", class, got[class], count)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("classes captured = %v, want %v", got, want)
+	}
+}
+
+func TestCaptureQuotasValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		quotas CaptureQuotas
+		want   bool
+	}{
+		{name: "none", quotas: CaptureQuotas{}, want: true},
+		{name: "every class", quotas: CaptureQuotas{ResponseClass1xx: 1, ResponseClass2xx: 1, ResponseClass3xx: 1, ResponseClass4xx: 1, ResponseClass5xx: 1, ResponseClassError: 1}, want: true},
+		{name: "at the bound", quotas: CaptureQuotas{ResponseClass5xx: MaxCaptureQuota}, want: true},
+		{name: "above the bound", quotas: CaptureQuotas{ResponseClass5xx: MaxCaptureQuota + 1}},
+		{name: "negative", quotas: CaptureQuotas{ResponseClass4xx: -1}},
+		{name: "unknown class", quotas: CaptureQuotas{"6xx": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quotas.Valid(); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package entity
+
+import "sync/atomic"
+
+// Connection labels a request by whether it may have paid the connection setup.
+type Connection string
+
+const (
+	ConnectionCold Connection = "cold" // the first request of a goroutine
+	ConnectionWarm Connection = "warm" // every following request of the goroutine
+)
+
+// coldRequests remembers which goroutines sent their first request. The
+// goroutines of a paced run are started anew for every step, so the first
+// request is tracked per goroutine index over the whole run.
+type coldRequests struct {
+	sent []atomic.Bool
+}
+
+func newColdRequests(concurrency int) *coldRequests {
+	return &coldRequests{sent: make([]atomic.Bool, concurrency)}
+}
+
+// connection labels the next request of the goroutine with the given index,
+// none when the worker doesn't measure cold requests.
+func (w *Worker) connection(index int) Connection {
+	if w.coldRequests == nil {
+		return ""
+	}
+	if index >= len(w.coldRequests.sent) || w.coldRequests.sent[index].Swap(true) {
+		return ConnectionWarm
+	}
+	return ConnectionCold
+}
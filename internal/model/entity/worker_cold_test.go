@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type connKey struct{}
+
+func TestColdRequests(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 3, 5, WithWorkerMeasureColdRequests())
+	runWorker(context.Background(), worker)
+
+	cold := worker.Metrics.Connections[ConnectionCold]
+	warm := worker.Metrics.Connections[ConnectionWarm]
+	if cold == nil || warm == nil {
+		t.Fatalf("connections = %v, want both populations", worker.Metrics.Connections)
+	}
+
+	// One cold request per goroutine, the others warm.
+	if cold.TotalRequests != 3 || warm.TotalRequests != 12 {
+		t.Errorf("cold requests = %d and warm requests = %d, want 3 and 12", cold.TotalRequests, warm.TotalRequests)
+	}
+}
+
+func TestColdRequestsLatency(t *testing.T) {
+	// The first request of every connection pays a 40ms setup. A single
+	// goroutine keeps its connection, so only its first request pays it.
+	const setup = 40 * time.Millisecond
+	stub := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served := r.Context().Value(connKey{}).(*atomic.Bool); !served.Swap(true) {
+			time.Sleep(setup)
+		}
+	}))
+	stub.Config.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+		return context.WithValue(ctx, connKey{}, &atomic.Bool{})
+	}
+	stub.Start()
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 1, 5, WithWorkerMeasureColdRequests())
+	runWorker(context.Background(), worker)
+
+	cold := worker.Metrics.Connections[ConnectionCold]
+	warm := worker.Metrics.Connections[ConnectionWarm]
+	if cold == nil || warm == nil {
+		t.Fatalf("connections = %v, want both populations", worker.Metrics.Connections)
+	}
+	if cold.MaxLatency < setup.Seconds() {
+		t.Errorf("cold latency = %s, want the setup of %s in it", FormatSeconds(cold.MaxLatency), setup)
+	}
+	if warm.MaxLatency >= setup.Seconds() {
+		t.Errorf("warm max latency = %s, want no setup in it", FormatSeconds(warm.MaxLatency))
+	}
+}
+
+func TestColdRequestsOff(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 2, 2)
+	runWorker(context.Background(), worker)
+
+	if len(worker.Metrics.Connections) != 0 {
+		t.Errorf("connections = %v, want none without measure_cold_requests", worker.Metrics.Connections)
+	}
+}
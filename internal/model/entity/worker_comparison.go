@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"fmt"
+)
+
+// Regression thresholds used when comparing a run against the environment baseline.
+const (
+	maxThroughputDrop    = 0.10 // fraction of the baseline throughput
+	maxErrorRateIncrease = 0.01 // absolute, i.e. one percentage point
+	maxPercentileGrowth  = 0.10 // fraction of the baseline percentile
+)
+
+// Comparison holds the deltas of a run against the baseline of its
+// environment, each delta being the run value minus the baseline value.
+type Comparison struct {
+	BaselineWorkerID int                        `json:"baseline_worker_id"`
+	ThroughputDelta  float64                    `json:"throughput_delta"` // in requests per second
+	ErrorRateDelta   float64                    `json:"error_rate_delta"`
+	PercentileDeltas map[PercentileRank]float64 `json:"percentile_deltas"` // in seconds
+	Regressions      []string                   `json:"regressions,omitempty"`
+	ServedByChanges  []string                   `json:"served_by_changes,omitempty"` // a different build served the run, which usually explains a regression
+}
+
+// CompareWith computes the deltas of w against baseline and flags the ones
+// beyond the regression thresholds.
+func (w *Worker) CompareWith(baseline *Worker) *Comparison {
+	comparison := &Comparison{
+		BaselineWorkerID: baseline.ID,
+		ThroughputDelta:  w.Metrics.Throughput - baseline.Metrics.Throughput,
+		ErrorRateDelta:   w.Metrics.ErrorRate - baseline.Metrics.ErrorRate,
+		PercentileDeltas: make(map[PercentileRank]float64),
+	}
+
+	if baseline.Metrics.Throughput > 0 && -comparison.ThroughputDelta > baseline.Metrics.Throughput*maxThroughputDrop {
+		comparison.Regressions = append(comparison.Regressions, fmt.Sprintf(
+			"throughput dropped from %.2f to %.2f req/s", baseline.Metrics.Throughput, w.Metrics.Throughput,
+		))
+	}
+
+	if comparison.ErrorRateDelta > maxErrorRateIncrease {
+		comparison.Regressions = append(comparison.Regressions, fmt.Sprintf(
+			"error rate rose from %.2f%% to %.2f%%", baseline.Metrics.ErrorRate*100, w.Metrics.ErrorRate*100,
+		))
+	}
+
+	for _, rank := range []PercentileRank{P50, P95, P99, P999} {
+		baselineValue, ok := baseline.Metrics.Percentiles[rank]
+		if !ok {
+			continue
+		}
+		value, ok := w.Metrics.Percentiles[rank]
+		if !ok {
+			continue
+		}
+
+		delta := value - baselineValue
+		comparison.PercentileDeltas[rank] = delta
+		if baselineValue > 0 && delta > baselineValue*maxPercentileGrowth {
+			comparison.Regressions = append(comparison.Regressions, fmt.Sprintf(
+				"p%s rose from %.2fms to %.2fms", rank, baselineValue*1000, value*1000,
+			))
+		}
+	}
+
+	comparison.ServedByChanges = servedByChanges(baseline.Metrics.ServedBy, w.Metrics.ServedBy)
+	return comparison
+}
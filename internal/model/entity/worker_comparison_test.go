@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// finishedRun returns a worker holding the given metrics, as loaded back once finished.
+func finishedRun(id int, throughput, errorRate, p95 float64) *Worker {
+	metrics := NewMetrics()
+	metrics.Throughput = throughput
+	metrics.ErrorRate = errorRate
+	metrics.Percentiles[P50] = p95 / 2
+	metrics.Percentiles[P95] = p95
+	return &Worker{ID: id, Status: StatusFinished, Metrics: metrics}
+}
+
+func TestCompareWith(t *testing.T) {
+	baseline := finishedRun(1, 100, 0.01, 0.200)
+
+	tests := []struct {
+		name            string
+		run             *Worker
+		wantRegressions []string // a word of every regression flagged, in order
+	}{
+		{name: "same numbers", run: finishedRun(2, 100, 0.01, 0.200)},
+		{name: "within the thresholds", run: finishedRun(2, 91, 0.019, 0.219)},
+		{name: "better", run: finishedRun(2, 150, 0, 0.100)},
+		{name: "throughput dropped", run: finishedRun(2, 89, 0.01, 0.200), wantRegressions: []string{"throughput"}},
+		{name: "error rate rose", run: finishedRun(2, 100, 0.03, 0.200), wantRegressions: []string{"error rate"}},
+		{name: "latency rose", run: finishedRun(2, 100, 0.01, 0.250), wantRegressions: []string{"p50", "p95"}},
+		{name: "everything regressed", run: finishedRun(2, 50, 0.2, 0.400), wantRegressions: []string{"throughput", "error rate", "p50", "p95"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comparison := tt.run.CompareWith(baseline)
+
+			if comparison.BaselineWorkerID != baseline.ID {
+				t.Errorf("baseline = %d, want %d", comparison.BaselineWorkerID, baseline.ID)
+			}
+			if want := tt.run.Metrics.Throughput - 100; comparison.ThroughputDelta != want {
+				t.Errorf("throughput delta = %v, want %v", comparison.ThroughputDelta, want)
+			}
+			if want := tt.run.Metrics.Percentiles[P95] - 0.200; math.Abs(comparison.PercentileDeltas[P95]-want) > 1e-9 {
+				t.Errorf("p95 delta = %v, want %v", comparison.PercentileDeltas[P95], want)
+			}
+
+			if len(comparison.Regressions) != len(tt.wantRegressions) {
+				t.Fatalf("regressions = %q, want %d of them", comparison.Regressions, len(tt.wantRegressions))
+			}
+			for i, word := range tt.wantRegressions {
+				if !strings.HasPrefix(comparison.Regressions[i], word) {
+					t.Errorf("regression %d = %q, want it about %s", i, comparison.Regressions[i], word)
+				}
+			}
+		})
+	}
+}
+
+func TestCompareWithMissingPercentiles(t *testing.T) {
+	baseline := finishedRun(1, 100, 0, 0.200)
+	// Every request of the run failed, it has nothing to rank.
+	run := finishedRun(2, 100, 1, 0)
+	run.Metrics.Percentiles = map[PercentileRank]float64{}
+
+	comparison := run.CompareWith(baseline)
+	if len(comparison.PercentileDeltas) != 0 {
+		t.Errorf("percentile deltas = %v, want none", comparison.PercentileDeltas)
+	}
+	if len(comparison.Regressions) != 1 {
+		t.Errorf("regressions = %q, want the error rate only", comparison.Regressions)
+	}
+}
@@ -0,0 +1,75 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// slowestRequestsKept bounds the slowest requests remembered by their ID.
+const slowestRequestsKept = 10
+
+// SlowRequest identifies one of the slowest requests of a run, so it can be
+// looked up on the target by the ID sent in the correlation header.
+type SlowRequest struct {
+	RequestID string  `json:"request_id"`
+	Phase     Phase   `json:"phase"`
+	Latency   float64 `json:"latency"` // in seconds
+}
+
+// requestIDs hands out UUID shaped request IDs: a random prefix drawn once per
+// run followed by a counter, so no randomness is needed per request.
+type requestIDs struct {
+	prefix string
+	count  atomic.Uint64
+}
+
+func newRequestIDs() *requestIDs {
+	var b [10]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// Only the uniqueness across runs suffers, the counter keeps the IDs of the run apart.
+		copy(b[:], "load-test!")
+	}
+	h := hex.EncodeToString(b[:])
+	return &requestIDs{
+		prefix: fmt.Sprintf("%s-%s-%s-%s-", h[0:8], h[8:12], h[12:16], h[16:20]),
+	}
+}
+
+func (g *requestIDs) next() string {
+	return fmt.Sprintf("%s%012x", g.prefix, g.count.Add(1))
+}
+
+// ValidHeaderName reports whether name is a valid HTTP header field name.
+func ValidHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c < 0x80 && isTokenSymbol(byte(c)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isTokenSymbol(c byte) bool {
+	switch c {
+	case '!', '#', '$', '%', '&', '\'', '*', '+', '-', '.', '^', '_', '`', '|', '~':
+		return true
+	}
+	return false
+}
+
+// requestID returns the ID sent with req, empty without a correlation header.
+func (w *Worker) requestID(req *http.Request) string {
+	if w.CorrelationHeader == "" {
+		return ""
+	}
+	return req.Header.Get(w.CorrelationHeader)
+}
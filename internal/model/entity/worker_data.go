@@ -0,0 +1,228 @@
+package entity
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Bounds of the data source of a worker.
+const (
+	MaxDataSourceRows = 100_000
+	MaxDataSourceCSV  = 1 << 20 // in bytes
+)
+
+// dataSourceTimeout bounds the query loading an SQL data source.
+const dataSourceTimeout = 30 * time.Second
+
+// DataSourceType is where the rows of a data source come from.
+type DataSourceType string
+
+const (
+	DataSourceCSV DataSourceType = "csv"
+	DataSourceSQL DataSourceType = "sql" // a query against a MySQL database
+)
+
+// DataSource is a table loaded once before the run, whose rows are taken in
+// turn by the requests. A {{data.column}} placeholder in the endpoint of the
+// environment or in a body variant is replaced by the value of the column
+// in the row of the request.
+type DataSource struct {
+	Type      DataSourceType `json:"type"`
+	CSV       string         `json:"csv,omitempty"`        // the header line first
+	DSN       string         `json:"dsn,omitempty"`        // never stored, only SealedDSN is
+	SealedDSN string         `json:"sealed_dsn,omitempty"` // set by the server
+	Query     string         `json:"query,omitempty"`
+	Rows      int            `json:"rows,omitempty"` // loaded at the start of the run
+}
+
+var dataPlaceholder = regexp.MustCompile(`\{\{\s*data\.(\w+)\s*\}\}`)
+
+// dataRing hands the rows of a data source out in turn, starting over once
+// they were all taken.
+type dataRing struct {
+	columns map[string]int
+	rows    [][]string
+	next    atomic.Uint64
+}
+
+func (d *dataRing) take() []string {
+	return d.rows[(d.next.Add(1)-1)%uint64(len(d.rows))]
+}
+
+// expand replaces the placeholders of s with the values of row, escaped.
+func (d *dataRing) expand(s string, row []string, escape func(string) string) string {
+	return dataPlaceholder.ReplaceAllStringFunc(s, func(placeholder string) string {
+		column := dataPlaceholder.FindStringSubmatch(placeholder)[1]
+		return escape(row[d.columns[column]])
+	})
+}
+
+// expandURL fills the placeholders of a URL with the path escaped values of row.
+func (d *dataRing) expandURL(rawURL string, row []string) string {
+	return d.expand(rawURL, row, url.PathEscape)
+}
+
+// withBody fills the placeholders of the body of req with the JSON escaped
+// values of row, the placeholders sitting within strings.
+func (d *dataRing) withBody(req *http.Request, row []string) (*http.Request, error) {
+	if req.GetBody == nil {
+		return req, nil
+	}
+
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded := []byte(d.expand(string(body), row, jsonEscape))
+	req.ContentLength = int64(len(expanded))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(expanded)), nil
+	}
+	req.Body, _ = req.GetBody()
+	return req, nil
+}
+
+func jsonEscape(value string) string {
+	quoted, _ := json.Marshal(value)
+	return string(quoted[1 : len(quoted)-1])
+}
+
+// dataColumns lists the columns the placeholders of the endpoint and of the
+// body variants of the worker reference.
+func (w *Worker) dataColumns() []string {
+	texts := []string{w.Environment.Endpoint}
+	for _, variant := range w.BodyVariants {
+		texts = append(texts, string(variant.Body))
+	}
+
+	var columns []string
+	for _, text := range texts {
+		for _, match := range dataPlaceholder.FindAllStringSubmatch(text, -1) {
+			columns = append(columns, match[1])
+		}
+	}
+	return columns
+}
+
+// loadData loads the rows of the data source of the worker and checks that
+// they hold every column its placeholders reference.
+func (w *Worker) loadData(ctx context.Context) (*dataRing, error) {
+	var (
+		columns []string
+		rows    [][]string
+		err     error
+	)
+	switch w.DataSource.Type {
+	case DataSourceCSV:
+		columns, rows, err = loadCSV(w.DataSource.CSV)
+	case DataSourceSQL:
+		columns, rows, err = loadSQL(ctx, w.DataSource.DSN, w.DataSource.Query)
+	default:
+		err = fmt.Errorf("unknown data source type %q", w.DataSource.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the data source has no rows")
+	}
+
+	data := &dataRing{columns: make(map[string]int, len(columns)), rows: rows}
+	for i, column := range columns {
+		data.columns[column] = i
+	}
+	for _, column := range w.dataColumns() {
+		if _, ok := data.columns[column]; !ok {
+			return nil, fmt.Errorf("the data source has no column %q", column)
+		}
+	}
+	return data, nil
+}
+
+func loadCSV(text string) ([]string, [][]string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	columns, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return columns, rows, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rows) == MaxDataSourceRows {
+			return nil, nil, fmt.Errorf("the data source has over %d rows", MaxDataSourceRows)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func loadSQL(ctx context.Context, dsn, query string) ([]string, [][]string, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	ctx, cancel := context.WithTimeout(ctx, dataSourceTimeout)
+	defer cancel()
+
+	result, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func(result *sql.Rows) {
+		_ = result.Close()
+	}(result)
+
+	columns, err := result.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rows [][]string
+	values := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for result.Next() {
+		if len(rows) == MaxDataSourceRows {
+			return nil, nil, fmt.Errorf("the data source has over %d rows", MaxDataSourceRows)
+		}
+		if err := result.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = value.String // NULL is sent as an empty string
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, result.Err()
+}
@@ -0,0 +1,128 @@
+package entity
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// SettingSource is where the value of a setting of a worker came from.
+type SettingSource string
+
+const (
+	SourceRequest     SettingSource = "request"
+	SourceEnvironment SettingSource = "environment" // the default worker settings of the environment
+)
+
+// Provenance tells where every setting of a worker came from, keyed like
+// the worker input. The settings missing from it got the server defaults.
+type Provenance map[string]SettingSource
+
+// WorkerDefaults are the settings every worker of an environment gets
+// unless its input sets them, keyed like the worker input.
+type WorkerDefaults map[string]json.RawMessage
+
+// ErrInvalidWorkerDefaults rejects default worker settings that aren't settings of a worker.
+var ErrInvalidWorkerDefaults = errors.New("invalid default worker settings")
+
+// notDefaultable are the keys of the worker input that describe a single
+// worker rather than a setting its environment may share.
+var notDefaultable = map[string]bool{
+	"id":             true,
+	"environment_id": true,
+	"campaign_id":    true,
+	"status":         true,
+	"metrics":        true,
+	"warnings":       true,
+	"record_file":    true,
+}
+
+// Validate checks that every key is a setting of the worker input whose
+// value decodes like it. Whether the settings make sense together is only
+// known once merged with the input of a worker.
+func (d WorkerDefaults) Validate() error {
+	for key := range d {
+		if notDefaultable[key] {
+			return ErrInvalidWorkerDefaults
+		}
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var worker Worker
+	if err := decoder.Decode(&worker); err != nil {
+		return errors.Join(ErrInvalidWorkerDefaults, err)
+	}
+	return nil
+}
+
+// Apply returns a copy of input whose settings left unset are taken from
+// the defaults, a setting being unset when it is nil or its zero value. The
+// copy records where every setting it has came from in its Provenance.
+func (d WorkerDefaults) Apply(input *Worker) (*Worker, error) {
+	var defaults Worker
+	data, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &defaults); err != nil {
+		return nil, err
+	}
+
+	merged, err := copyInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	merged.Provenance = make(Provenance)
+	value, defaultValue := reflect.ValueOf(merged).Elem(), reflect.ValueOf(&defaults).Elem()
+	for i := 0; i < value.NumField(); i++ {
+		key := jsonKey(value.Type().Field(i))
+		if key == "" || notDefaultable[key] {
+			continue
+		}
+
+		switch _, hasDefault := d[key]; {
+		case !value.Field(i).IsZero():
+			merged.Provenance[key] = SourceRequest
+		case hasDefault:
+			value.Field(i).Set(defaultValue.Field(i))
+			merged.Provenance[key] = SourceEnvironment
+		}
+	}
+	return merged, nil
+}
+
+// copyInput copies the input of a worker through its JSON, the only part of it that is set.
+func copyInput(input *Worker) (*Worker, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	copied := &Worker{}
+	if err := json.Unmarshal(data, copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
+
+// jsonKey returns the key of an exported field in JSON, empty for the fields left out of it.
+func jsonKey(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
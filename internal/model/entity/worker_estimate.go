@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"math"
+	"time"
+)
+
+// legacyMeanThinkTime is the mean of the random pause used when no think time is configured.
+const legacyMeanThinkTime = legacyMaxThinkTime / 2 * time.Millisecond
+
+// EstimatedRequests is an upper bound of the requests a run sends, false
+// when it can't be known upfront, i.e. for a ramp to failure without a
+// maximum duration, an auto tune, whose rate depends on the target, or a
+// fixed run with a ramp down, whose requests depend on the think time.
+func (w *Worker) EstimatedRequests() (int, bool) {
+	switch w.Mode {
+	case ModeRampToFailure:
+		if w.RampConfig == nil || w.RampConfig.MaxDuration <= 0 || w.RampConfig.StepDuration <= 0 {
+			return 0, false
+		}
+		step := time.Duration(w.RampConfig.StepDuration)
+		var requests float64
+		for elapsed, rps := time.Duration(0), w.RampConfig.StartRPS; elapsed < time.Duration(w.RampConfig.MaxDuration); elapsed, rps = elapsed+step, rps+w.RampConfig.StepRPS {
+			requests += rps * min(step, time.Duration(w.RampConfig.MaxDuration)-elapsed).Seconds()
+		}
+		return int(math.Ceil(requests)), true
+	case ModeSoak:
+		if w.SoakConfig == nil {
+			return 0, false
+		}
+		return int(math.Ceil(w.SoakConfig.RPS * time.Duration(w.SoakConfig.Duration+w.RampDown).Seconds())), true
+	case ModeAutoTune:
+		return 0, false
+	case ModeSpike:
+		if w.SpikeConfig == nil {
+			return 0, false
+		}
+		baseline, spike := w.spikeTimeSplit()
+		rampDown := time.Duration(w.RampDown)
+		return int(math.Ceil(w.SpikeConfig.BaselineRPS*(baseline+rampDown).Seconds() + w.SpikeConfig.SpikeRPS*spike.Seconds())), true
+	default:
+		if w.RampDown > 0 {
+			return 0, false
+		}
+		return w.Concurrency * w.RequestsPerTask, true
+	}
+}
+
+// EstimatedDuration is the expected length of a run. For the fixed mode it
+// only accounts for the ramp up, the think time and the ramp down, the
+// latency of the target being unknown upfront.
+func (w *Worker) EstimatedDuration() (time.Duration, bool) {
+	if w.Mode != ModeFixed && w.Mode != "" {
+		return w.plannedDuration()
+	}
+
+	return time.Duration(w.RampUp) + time.Duration(w.RequestsPerTask)*w.MeanThinkTime() + time.Duration(w.RampDown), true
+}
+
+// MeanThinkTime is the mean pause between two requests of a goroutine of a fixed run.
+func (w *Worker) MeanThinkTime() time.Duration {
+	if w.ThinkTime == nil {
+		return legacyMeanThinkTime
+	}
+	return time.Duration(*w.ThinkTime)
+}
+
+// spikeTimeSplit splits the duration of a spike run into the time spent at
+// the baseline rate and at the spike rate. A run starts with a baseline.
+func (w *Worker) spikeTimeSplit() (baseline, spike time.Duration) {
+	config := w.SpikeConfig
+	period := time.Duration(config.Interval + config.SpikeDuration)
+	total := time.Duration(config.Duration)
+	if period <= 0 {
+		return total, 0
+	}
+
+	periods := total / period
+	baseline = periods * time.Duration(config.Interval)
+	spike = periods * time.Duration(config.SpikeDuration)
+
+	rest := total - periods*period
+	baseline += min(rest, time.Duration(config.Interval))
+	spike += max(rest-time.Duration(config.Interval), 0)
+	return baseline, spike
+}
@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+)
+
+type ExhaustionPolicy string
+
+const (
+	// ExhaustionIgnore keeps the configured concurrency when file descriptors run out.
+	ExhaustionIgnore ExhaustionPolicy = "ignore"
+	// ExhaustionThrottle halves the number of active goroutines, at most once per throttleCooldown.
+	ExhaustionThrottle ExhaustionPolicy = "throttle"
+)
+
+const throttleCooldown = time.Second
+
+// onResourceExhaustion reacts to a request that failed because the process
+// ran out of file descriptors.
+func (w *Worker) onResourceExhaustion() {
+	w.exhaustionOnce.Do(func() {
+		w.log.Warn().Msgf("Worker %d ran out of file descriptors (too many open files), raise the open files limit (ulimit -n) or lower the concurrency", w.ID)
+	})
+
+	if w.OnResourceExhaustion != ExhaustionThrottle {
+		return
+	}
+
+	now := time.Now().UnixNano()
+	last := w.lastThrottle.Load()
+	if now-last < int64(throttleCooldown) || !w.lastThrottle.CompareAndSwap(last, now) {
+		return
+	}
+
+	current := int(w.activeLimit.Load())
+	if current == 0 {
+		current = w.Concurrency
+	}
+	next := max(current/2, 1)
+	w.activeLimit.Store(int32(next))
+
+	w.log.Warn().Msgf("Worker %d throttled concurrency from %d to %d after running out of file descriptors", w.ID, current, next)
+}
+
+// throttled reports whether the goroutine with the given index must stop taking requests.
+func (w *Worker) throttled(index int) bool {
+	limit := w.activeLimit.Load()
+	return limit > 0 && index >= int(limit)
+}
+
+// diagnose explains the failure classes that point at a problem on the generator side.
+func (w *Worker) diagnose() {
+	if count := w.Metrics.ErrorClasses[ErrorClassFileDescriptors]; count > 0 {
+		w.Metrics.AddDiagnostic(fmt.Sprintf("%d requests failed because the process ran out of file descriptors (EMFILE), the failures reflect the load generator rather than the target", count))
+	}
+
+	if count := w.Metrics.ErrorClasses[ErrorClassDial]; count > 0 {
+		w.Metrics.AddDiagnostic(fmt.Sprintf("%d requests failed while dialing the target", count))
+	}
+
+	if limit := w.activeLimit.Load(); limit > 0 {
+		w.Metrics.AddDiagnostic(fmt.Sprintf("concurrency was throttled from %d to %d", w.Concurrency, limit))
+	}
+
+	if waited := w.Metrics.GlobalThrottleTime; waited > 0 {
+		w.Metrics.AddDiagnostic(fmt.Sprintf("globally throttled, the requests waited %s in total on the process-wide request rate", FormatSeconds(waited)))
+	}
+}
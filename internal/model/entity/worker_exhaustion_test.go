@@ -0,0 +1,94 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// failingDialer returns a client whose every connection fails with err.
+func failingDialer(err error) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: err}
+		},
+	}}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{name: "EMFILE", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("socket", syscall.EMFILE)}, want: ErrorClassFileDescriptors},
+		{name: "ENFILE", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("socket", syscall.ENFILE)}, want: ErrorClassFileDescriptors},
+		{name: "connection refused", err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, want: ErrorClassDial},
+		{name: "read", err: &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, want: ErrorClassOther},
+		{name: "other", err: errors.New("boom"), want: ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileDescriptorsExhausted(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    ExhaustionPolicy
+		wantLimit int32
+	}{
+		{name: "ignore", policy: ExhaustionIgnore, wantLimit: 0},
+		{name: "throttle", policy: ExhaustionThrottle, wantLimit: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := newTestWorker("http://localhost", 8, 1, WithWorkerExhaustionPolicy(tt.policy))
+			worker.client = failingDialer(os.NewSyscallError("socket", syscall.EMFILE))
+
+			for i := 0; i < 3; i++ {
+				if worker.request(context.Background(), worker.Environment.Endpoint, "", PhaseMain, worker.Metrics) {
+					t.Fatal("request succeeded, want it to fail")
+				}
+			}
+
+			if got := worker.Metrics.ErrorClasses[ErrorClassFileDescriptors]; got != 3 {
+				t.Errorf("%s errors = %d, want 3", ErrorClassFileDescriptors, got)
+			}
+			// The cooldown lets a single throttle through for the burst.
+			if got := worker.activeLimit.Load(); got != tt.wantLimit {
+				t.Errorf("active limit = %d, want %d", got, tt.wantLimit)
+			}
+
+			worker.diagnose()
+			if len(worker.Metrics.Diagnostics) == 0 || !strings.Contains(worker.Metrics.Diagnostics[0], "file descriptors") {
+				t.Errorf("diagnostics = %q, want the file descriptors first", worker.Metrics.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestDialFailure(t *testing.T) {
+	worker := newTestWorker("http://localhost", 1, 1)
+	worker.client = failingDialer(os.NewSyscallError("connect", syscall.ECONNREFUSED))
+
+	worker.request(context.Background(), worker.Environment.Endpoint, "", PhaseMain, worker.Metrics)
+
+	if got := worker.Metrics.ErrorClasses[ErrorClassDial]; got != 1 {
+		t.Errorf("%s errors = %d, want 1", ErrorClassDial, got)
+	}
+	if got := worker.Metrics.FailedRequests; got != 1 {
+		t.Errorf("failed requests = %d, want 1", got)
+	}
+}
@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRenderedBody bounds the part of the body kept in a RenderedRequest.
+const maxRenderedBody = 1024 // in bytes
+
+// RenderedRequest is a request as it was sent, once its templates were
+// expanded and its headers merged, its secrets redacted like in the logs.
+// The headers the transport adds, such as User-Agent, aren't part of it.
+type RenderedRequest struct {
+	Method        string      `json:"method"`
+	URL           string      `json:"url"`
+	Headers       http.Header `json:"headers"`
+	Body          string      `json:"body,omitempty"` // its first maxRenderedBody bytes
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+	SentAt        time.Time   `json:"sent_at"`
+}
+
+// recordFirstRequest keeps req as the first request of the run, once. The
+// calls for the following requests do nothing.
+func (w *Worker) recordFirstRequest(req *http.Request) {
+	if !w.firstSent.CompareAndSwap(false, true) {
+		return
+	}
+
+	rendered := &RenderedRequest{
+		Method:  req.Method,
+		URL:     RedactURL(req.URL.String()),
+		Headers: RedactHeaders(req.Header),
+		SentAt:  time.Now().UTC(),
+	}
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(io.LimitReader(body, maxRenderedBody+1))
+			body.Close()
+			rendered.BodyTruncated = len(data) > maxRenderedBody
+			rendered.Body = strings.ToValidUTF8(string(data[:min(len(data), maxRenderedBody)]), "�")
+		}
+	}
+
+	w.mu.Lock()
+	w.FirstRequest = rendered
+	w.mu.Unlock()
+}
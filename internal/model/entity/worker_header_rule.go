@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// HeaderRule has a worker count a 2xx response as a success only when one
+// of its headers has the expected value, for the APIs signalling the
+// outcome in a header while answering 200 to everything.
+type HeaderRule struct {
+	Name    string `json:"name"`
+	Value   string `json:"value,omitempty"`   // compared as is
+	Pattern string `json:"pattern,omitempty"` // regular expression the value must match, instead of Value
+}
+
+// Valid reports whether the rule names a header and expects either a value or a pattern that compiles.
+func (r *HeaderRule) Valid() bool {
+	if !ValidHeaderName(r.Name) || (r.Value == "") == (r.Pattern == "") {
+		return false
+	}
+	_, err := r.compile()
+	return err == nil
+}
+
+// compile returns the pattern of the rule, nil when it expects a value.
+func (r *HeaderRule) compile() (*regexp.Regexp, error) {
+	if r.Pattern == "" {
+		return nil, nil
+	}
+	return regexp.Compile(r.Pattern)
+}
+
+// countHeader checks a response with statusCode and header against the
+// success header rule of the worker and reports whether the request
+// failed, failed telling whether it already failed otherwise, in which
+// case it isn't counted twice. The other classes of responses keep their
+// own accounting.
+func (w *Worker) countHeader(statusCode int, header http.Header, phase Phase, metrics []*Metrics, failed bool) bool {
+	if w.SuccessHeader == nil || failed || responseClass(statusCode) != ResponseClass2xx {
+		return failed
+	}
+
+	value := header.Get(w.SuccessHeader.Name)
+	if w.headerPattern != nil && w.headerPattern.MatchString(value) || w.SuccessHeader.Pattern == "" && value == w.SuccessHeader.Value {
+		return false
+	}
+
+	for _, m := range metrics {
+		m.IncrementFailedRequests(phase)
+		m.IncrementErrorClass(ErrorClassHeaderMismatch)
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSuccessHeader(t *testing.T) {
+	// The responses are all 200, X-Status tells how they went.
+	statuses := []string{"ok", "degraded", "", "ok"}
+	var received atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := statuses[(received.Add(1)-1)%int64(len(statuses))]; status != "" {
+			w.Header().Set("X-Status", status)
+		}
+	}))
+	defer stub.Close()
+
+	tests := []struct {
+		name       string
+		rule       *HeaderRule
+		wantFailed int
+	}{
+		{name: "no rule"},
+		{name: "matching value", rule: &HeaderRule{Name: "X-Status", Value: "ok"}, wantFailed: 4},
+		{name: "values are case sensitive", rule: &HeaderRule{Name: "X-Status", Value: "OK"}, wantFailed: 8},
+		{name: "header names are not", rule: &HeaderRule{Name: "x-status", Value: "ok"}, wantFailed: 4},
+		{name: "matching pattern", rule: &HeaderRule{Name: "X-Status", Pattern: "^(ok|degraded)$"}, wantFailed: 2},
+		{name: "pattern matching a missing header", rule: &HeaderRule{Name: "X-Status", Pattern: "^(ok)?$"}, wantFailed: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received.Store(0)
+			var options []WorkerOption
+			if test.rule != nil {
+				options = append(options, WithWorkerSuccessHeader(test.rule))
+			}
+			worker := newTestWorker(stub.URL, 1, 8, options...)
+			runWorker(context.Background(), worker)
+
+			metrics := worker.Metrics
+			if metrics.FailedRequests != test.wantFailed {
+				t.Errorf("failed requests = %d, want %d", metrics.FailedRequests, test.wantFailed)
+			}
+			if metrics.ErrorClasses[ErrorClassHeaderMismatch] != test.wantFailed {
+				t.Errorf("header mismatches = %d, want %d", metrics.ErrorClasses[ErrorClassHeaderMismatch], test.wantFailed)
+			}
+		})
+	}
+}
+
+func TestSuccessHeaderIgnoresErrors(t *testing.T) {
+	// Only the 2xx responses have their header checked, a 404 keeps its own accounting.
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Status", "error")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 1, 4, WithWorkerSuccessHeader(&HeaderRule{Name: "X-Status", Value: "ok"}))
+	runWorker(context.Background(), worker)
+
+	if worker.Metrics.StatusClasses[ResponseClass4xx] != 4 || worker.Metrics.ErrorClasses[ErrorClassHeaderMismatch] != 0 {
+		t.Errorf("%d responses of 404 with %d header mismatches, want 4 with none",
+			worker.Metrics.StatusClasses[ResponseClass4xx], worker.Metrics.ErrorClasses[ErrorClassHeaderMismatch])
+	}
+}
+
+func TestHeaderRuleValid(t *testing.T) {
+	tests := []struct {
+		rule HeaderRule
+		want bool
+	}{
+		{rule: HeaderRule{Name: "X-Status", Value: "ok"}, want: true},
+		{rule: HeaderRule{Name: "X-Status", Pattern: "^ok$"}, want: true},
+		{rule: HeaderRule{Name: "X-Status"}},
+		{rule: HeaderRule{Name: "X-Status", Value: "ok", Pattern: "^ok$"}},
+		{rule: HeaderRule{Name: "X-Status", Pattern: "(ok"}},
+		{rule: HeaderRule{Name: "X Status", Value: "ok"}},
+		{rule: HeaderRule{Value: "ok"}},
+	}
+
+	for _, test := range tests {
+		if got := test.rule.Valid(); got != test.want {
+			t.Errorf("%+v.Valid() = %t, want %t", test.rule, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// MaxIdentities bounds the identities listed by a worker.
+const MaxIdentities = 10000
+
+// IdentityConfig gives every goroutine of a worker, i.e. every virtual
+// user, an identity of its own sent in Header, so the target sees as many
+// users as goroutines. The identities are assigned by goroutine index,
+// cycling when there are fewer of them than goroutines.
+type IdentityConfig struct {
+	Header string   `json:"header"`           // e.g. X-User-ID, or Authorization for a token per user
+	Values []string `json:"values,omitempty"` // generated from Seed when empty
+	Seed   int64    `json:"seed,omitempty"`   // picked at random when 0, the same seed generates the same identities
+}
+
+type identityKey struct{}
+
+// withIdentity binds the identity of the goroutine with the given index to
+// the requests created with the returned context.
+func (w *Worker) withIdentity(ctx context.Context, index int) context.Context {
+	if w.Identities == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, identityKey{}, w.Identities.identity(index))
+}
+
+func (c *IdentityConfig) identity(index int) string {
+	if len(c.Values) > 0 {
+		return c.Values[index%len(c.Values)]
+	}
+	return fmt.Sprintf("user-%016x", mix(uint64(c.Seed)+uint64(index)))
+}
+
+// setIdentity sets the header of the identity bound to the context of req, if any.
+func (w *Worker) setIdentity(req *http.Request) {
+	if identity, ok := req.Context().Value(identityKey{}).(string); ok {
+		req.Header.Set(w.Identities.Header, identity)
+	}
+}
+
+// mix is the finalizer of SplitMix64. It is a bijection, so the identities
+// generated for distinct indexes are distinct.
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
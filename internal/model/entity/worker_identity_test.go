@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+// sentIdentities sends a request for each of the goroutines of worker in
+// turn and returns the identity header each of them sent.
+func sentIdentities(t *testing.T, worker *Worker, header string) []string {
+	t.Helper()
+
+	var identities []string
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identities = append(identities, r.Header.Get(header))
+	}))
+	defer stub.Close()
+
+	worker.Environment.Endpoint = stub.URL
+	worker.client = worker.newHTTPClient()
+	for index := 0; index < worker.Concurrency; index++ {
+		worker.send(context.Background(), index, PhaseMain, worker.Metrics)
+	}
+	if len(identities) != worker.Concurrency {
+		t.Fatalf("received %d requests, want %d", len(identities), worker.Concurrency)
+	}
+	return identities
+}
+
+func TestIdentities(t *testing.T) {
+	t.Run("listed", func(t *testing.T) {
+		config := &IdentityConfig{Header: "X-User-ID", Values: []string{"alice", "bob"}}
+		worker := newTestWorker("", 5, 1, WithWorkerIdentities(config))
+
+		// Fewer identities than goroutines are cycled.
+		got := sentIdentities(t, worker, "X-User-ID")
+		if want := []string{"alice", "bob", "alice", "bob", "alice"}; !slices.Equal(got, want) {
+			t.Errorf("identities = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		config := &IdentityConfig{Header: "X-User-ID", Seed: 42}
+		got := sentIdentities(t, newTestWorker("", 4, 1, WithWorkerIdentities(config)), "X-User-ID")
+
+		seen := make(map[string]bool)
+		for _, identity := range got {
+			if identity == "" || seen[identity] {
+				t.Fatalf("identities = %v, want four distinct ones", got)
+			}
+			seen[identity] = true
+		}
+
+		// The same seed gives every goroutine the same identity again.
+		again := sentIdentities(t, newTestWorker("", 4, 1, WithWorkerIdentities(config)), "X-User-ID")
+		if !slices.Equal(got, again) {
+			t.Errorf("identities = %v, then %v with the same seed", got, again)
+		}
+	})
+
+	t.Run("sent in Authorization", func(t *testing.T) {
+		config := &IdentityConfig{Header: "Authorization", Values: []string{"Bearer one", "Bearer two"}}
+		worker := newTestWorker("", 2, 1, WithWorkerIdentities(config))
+
+		got := sentIdentities(t, worker, "Authorization")
+		if want := []string{"Bearer one", "Bearer two"}; !slices.Equal(got, want) {
+			t.Errorf("identities = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("none", func(t *testing.T) {
+		got := sentIdentities(t, newTestWorker("", 2, 1), "X-User-ID")
+		if want := []string{"", ""}; !slices.Equal(got, want) {
+			t.Errorf("identities = %v, want no header", got)
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// KeepAliveConfig keeps idle connections warm during think time by sending
+// a HEAD request on up to Connections pooled connections whenever the
+// worker sent nothing for Interval. Keep-alive requests are not measured.
+type KeepAliveConfig struct {
+	Interval    Duration `json:"interval"`
+	Connections int      `json:"connections,omitempty"` // 1 when 0
+}
+
+// startKeepAlive runs the keep-alive loop until the returned function is called.
+func (w *Worker) startKeepAlive(ctx context.Context) (stop func()) {
+	if w.KeepAlive == nil {
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	interval := time.Duration(w.KeepAlive.Interval)
+	connections := max(w.KeepAlive.Connections, 1)
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if time.Since(time.Unix(0, w.lastRequest.Load())) < interval {
+				continue
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < connections; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					w.ping(ctx)
+				}()
+			}
+			wg.Wait()
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+type pingKey struct{}
+
+// isPing reports whether the request built with ctx is a keep-alive ping,
+// which leaves the data rows, the variants and the body stream alone.
+func isPing(ctx context.Context) bool {
+	return ctx.Value(pingKey{}) != nil
+}
+
+// ping sends a single keep-alive request. A ping going over a reused
+// connection counts as a reconnect avoided for the next measured request.
+func (w *Worker) ping(ctx context.Context) {
+	ctx = context.WithValue(ctx, pingKey{}, true)
+	req, err := w.createRequest(ctx, http.MethodHead, w.Environment.Endpoint, nil)
+	if err != nil {
+		w.log.Debug().Err(err).Msg("Error creating keep-alive request")
+		return
+	}
+
+	var reused bool
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			reused = info.Reused
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		w.log.Debug().Err(err).Msg("Error sending keep-alive request")
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	w.Metrics.AddKeepAlivePing(reused)
+}
@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepAlive(t *testing.T) {
+	tests := []struct {
+		name            string
+		keepAlive       *KeepAliveConfig
+		wantConnections int64
+	}{
+		// Every request after the first finds its connection closed by the stub.
+		{name: "without keep-alive", wantConnections: 3},
+		{name: "with keep-alive", keepAlive: &KeepAliveConfig{Interval: Duration(70 * time.Millisecond)}, wantConnections: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var connections atomic.Int64
+			stub := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			stub.Config.IdleTimeout = 150 * time.Millisecond
+			stub.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					connections.Add(1)
+				}
+			}
+			stub.Start()
+			defer stub.Close()
+
+			// The think time outlasts the idle timeout of the stub. It falls between
+			// two ticks of the keep-alive loop, so that no ping holds the connection
+			// when the next request is sent.
+			options := []WorkerOption{WithWorkerThinkTime(Duration(400*time.Millisecond), 0)}
+			if tt.keepAlive != nil {
+				options = append(options, WithWorkerKeepAlive(tt.keepAlive))
+			}
+			worker := newTestWorker(stub.URL, 1, 3, options...)
+			runWorker(context.Background(), worker)
+
+			if got := connections.Load(); got != tt.wantConnections {
+				t.Errorf("connections = %d, want %d", got, tt.wantConnections)
+			}
+			if tt.keepAlive == nil {
+				return
+			}
+			if worker.Metrics.KeepAlivePings == 0 || worker.Metrics.ReconnectsAvoided == 0 {
+				t.Errorf("pings = %d and reconnects avoided = %d, want both above 0", worker.Metrics.KeepAlivePings, worker.Metrics.ReconnectsAvoided)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package entity
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Mode string
+
+const (
+	// ModeFixed sends Concurrency*RequestsPerTask requests and stops.
+	ModeFixed Mode = "fixed"
+	// ModeRampToFailure increases the request rate step-wise until a step
+	// violates the configured SLOs.
+	ModeRampToFailure Mode = "ramp_to_failure"
+	// ModeSoak holds a fixed modest rate for a long time and reports latency drift.
+	ModeSoak Mode = "soak"
+	// ModeSpike alternates a baseline rate with bursts and reports how fast latency recovers.
+	ModeSpike Mode = "spike"
+	// ModeAutoTune adjusts the concurrency within one run to find the point
+	// where the throughput stops rising.
+	ModeAutoTune Mode = "auto_tune"
+)
+
+// RampConfig holds the parameters of the ramp_to_failure mode.
+type RampConfig struct {
+	StartRPS     float64  `json:"start_rps"`
+	StepRPS      float64  `json:"step_rps"`
+	StepDuration Duration `json:"step_duration"`
+	LatencySLOMs float64  `json:"latency_slo_ms"` // p95 bound, 0 disables the check
+	ErrorSLO     float64  `json:"error_slo"`      // max error rate (0-1), 0 disables the check
+	MaxDuration  Duration `json:"max_duration"`   // 0 means no limit
+}
+
+type RampStep struct {
+	TargetRPS float64  `json:"target_rps"`
+	Violated  bool     `json:"violated"`
+	Metrics   *Metrics `json:"metrics"`
+}
+
+type RampResult struct {
+	SustainableRPS float64     `json:"sustainable_rps"` // rate of the last step within the SLOs
+	Steps          []*RampStep `json:"steps"`
+}
+
+// rampToFailure runs the ramp steps and reports whether the ramp ended on its
+// own (SLO violation or max duration) rather than through cancellation.
+func (w *Worker) rampToFailure(ctx context.Context) bool {
+	rampCtx := ctx
+	if w.RampConfig.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		rampCtx, cancel = context.WithTimeout(ctx, time.Duration(w.RampConfig.MaxDuration))
+		defer cancel()
+	}
+
+	w.RampResult = &RampResult{}
+
+	for rps := w.RampConfig.StartRPS; ; rps += w.RampConfig.StepRPS {
+		stepMetrics := w.runPacedPhase(rampCtx, PhaseMain, rps, time.Duration(w.RampConfig.StepDuration))
+		if rampCtx.Err() != nil {
+			// A partially executed step says nothing about the target.
+			break
+		}
+
+		step := &RampStep{
+			TargetRPS: rps,
+			Violated:  w.RampConfig.violated(stepMetrics),
+			Metrics:   stepMetrics,
+		}
+		w.RampResult.Steps = append(w.RampResult.Steps, step)
+
+		w.log.Info().Msgf("Worker %d ramp step at %.2f req/s: p95 %s, error rate %.4f, violated: %t",
+			w.ID, rps, FormatSeconds(stepMetrics.Percentiles[P95]), stepMetrics.ErrorRate, step.Violated)
+
+		if step.Violated {
+			break
+		}
+		w.RampResult.SustainableRPS = rps
+	}
+
+	return ctx.Err() == nil
+}
+
+// runPacedPhase paces requests at rps for the given duration and returns the
+// metrics of that phase only. The worker-wide metrics and sinks are updated as well.
+func (w *Worker) runPacedPhase(ctx context.Context, phase Phase, rps float64, duration time.Duration, sinks ...*Metrics) *Metrics {
+	stepMetrics := NewMetrics()
+	requests := make(chan int, w.Concurrency)
+	wg := &sync.WaitGroup{}
+
+	metrics := append([]*Metrics{w.Metrics, stepMetrics}, sinks...)
+	for i := 0; i < w.Concurrency; i++ {
+		wg.Add(1)
+		go w.runPaced(ctx, wg, requests, i, phase, metrics)
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rps))
+	defer ticker.Stop()
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	start := time.Now()
+
+pacing:
+	for i := 0; ; {
+		select {
+		case <-ctx.Done():
+			break pacing
+		case <-timer.C:
+			break pacing
+		case <-ticker.C:
+			select {
+			case requests <- i:
+				i++
+			default:
+				// Every goroutine is busy, so the target can't keep up with this rate.
+			}
+		}
+	}
+	close(requests)
+	wg.Wait()
+
+	stepMetrics.CalculateMaxLatency()
+	stepMetrics.CalculateErrorRate()
+	stepMetrics.CalculateThroughput(time.Since(start))
+	if err := stepMetrics.CalculatePercentiles(P50, P95, P99); err != nil {
+		w.log.Debug().Err(err).Msg("Error calculating phase percentiles")
+	}
+
+	return stepMetrics
+}
+
+func (w *Worker) runPaced(ctx context.Context, wg *sync.WaitGroup, requests <-chan int, index int, phase Phase, metrics []*Metrics) {
+	defer wg.Done()
+
+	for range requests {
+		w.send(ctx, index, phase, metrics...)
+	}
+}
+
+func (c *RampConfig) violated(m *Metrics) bool {
+	if m.allFailed() {
+		return true
+	}
+	if c.LatencySLOMs > 0 && m.Percentiles[P95]*1000 > c.LatencySLOMs {
+		return true
+	}
+	if c.ErrorSLO > 0 && m.ErrorRate > c.ErrorSLO {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+package entity
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowAfter returns a stub answering every request at once until after has
+// elapsed since the first one, and in 20ms from then on.
+func slowAfter(t *testing.T, after time.Duration) *httptest.Server {
+	t.Helper()
+	var (
+		once  sync.Once
+		start time.Time
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { start = time.Now() })
+		if after > 0 && time.Since(start) > after {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	return stub
+}
+
+func TestRampSteps(t *testing.T) {
+	// Every step lasts 100ms, the target slows down during the third one.
+	stub := slowAfter(t, 250*time.Millisecond)
+	config := &RampConfig{
+		StartRPS:     50,
+		StepRPS:      25,
+		StepDuration: Duration(100 * time.Millisecond),
+		LatencySLOMs: 10,
+	}
+	worker := newTestWorker(stub.URL, 4, 1, WithWorkerRampToFailure(config))
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Errorf("status = %s, want %s", got, StatusFinished)
+	}
+	result := store.rampResult
+	if result == nil {
+		t.Fatal("no ramp result stored")
+	}
+	steps := result.Steps
+	if len(steps) < 2 {
+		t.Fatalf("steps = %s, want the ramp to get past the first step", formatRampSteps(steps))
+	}
+
+	for i, step := range steps {
+		if want := config.StartRPS + float64(i)*config.StepRPS; step.TargetRPS != want {
+			t.Errorf("step %d targets %.0f req/s, want %.0f, steps = %s", i, step.TargetRPS, want, formatRampSteps(steps))
+		}
+		// The ramp stops at the first step violating the SLOs.
+		if last := i == len(steps)-1; step.Violated != last {
+			t.Errorf("step %d violated: %t, want %t, steps = %s", i, step.Violated, last, formatRampSteps(steps))
+		}
+	}
+	if want := steps[len(steps)-2].TargetRPS; result.SustainableRPS != want {
+		t.Errorf("sustainable rate = %.0f req/s, want %.0f, the one of the step before the violation", result.SustainableRPS, want)
+	}
+}
+
+func TestRampStops(t *testing.T) {
+	config := RampConfig{
+		StartRPS:     50,
+		StepRPS:      25,
+		StepDuration: Duration(100 * time.Millisecond),
+		LatencySLOMs: 10,
+	}
+
+	tests := []struct {
+		name        string
+		cancelAfter time.Duration
+		maxDuration time.Duration
+		want        Status
+	}{
+		{name: "cancelled", cancelAfter: 250 * time.Millisecond, want: StatusCancelled},
+		{name: "max duration", maxDuration: 250 * time.Millisecond, want: StatusFinished},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A target that never slows down, only the deadline stops the ramp.
+			stub := slowAfter(t, 0)
+			config := config
+			config.MaxDuration = Duration(tt.maxDuration)
+
+			ctx := context.Background()
+			if tt.cancelAfter > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.cancelAfter)
+				defer cancel()
+			}
+
+			start := time.Now()
+			worker := newTestWorker(stub.URL, 4, 1, WithWorkerRampToFailure(&config))
+			store := runWorker(ctx, worker)
+
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("ramp took %s, want it stopped at 250ms", elapsed)
+			}
+			if got := store.finalStatus(); got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+			result := store.rampResult
+			if result == nil {
+				t.Fatal("no ramp result stored")
+			}
+			// The step cut short is left out, the ones before it completed within the SLOs.
+			if len(result.Steps) == 0 || len(result.Steps) > 2 {
+				t.Fatalf("steps = %s, want the one or two completed in 250ms", formatRampSteps(result.Steps))
+			}
+			for _, step := range result.Steps {
+				if step.Violated {
+					t.Errorf("steps = %s, want none violated", formatRampSteps(result.Steps))
+				}
+			}
+			if want := result.Steps[len(result.Steps)-1].TargetRPS; result.SustainableRPS != want {
+				t.Errorf("sustainable rate = %.0f req/s, want %.0f, the one of the last completed step", result.SustainableRPS, want)
+			}
+		})
+	}
+}
+
+func formatRampSteps(steps []*RampStep) string {
+	var s string
+	for _, step := range steps {
+		s += fmt.Sprintf("[%.0f req/s p95 %s violated: %t] ", step.TargetRPS, FormatSeconds(step.Metrics.Percentiles[P95]), step.Violated)
+	}
+	return s
+}
+
+func TestSoakDrift(t *testing.T) {
+	// interval returns an interval at offset minutes whose p95 is p95Ms.
+	interval := func(offset int, p95Ms float64) *SoakInterval {
+		return &SoakInterval{Offset: Duration(time.Duration(offset) * time.Minute), TotalRequests: 100, P95: p95Ms / 1000}
+	}
+
+	tests := []struct {
+		name      string
+		intervals []*SoakInterval
+		threshold float64
+		wantDrift float64
+		want      SoakVerdict
+	}{
+		{
+			name:      "flat",
+			intervals: []*SoakInterval{interval(0, 50), interval(30, 50), interval(60, 50)},
+			threshold: 10,
+			want:      SoakStable,
+		},
+		{
+			name:      "rising under the threshold",
+			intervals: []*SoakInterval{interval(0, 50), interval(30, 52), interval(60, 54)},
+			threshold: 10,
+			wantDrift: 4,
+			want:      SoakStable,
+		},
+		{
+			name:      "rising over the threshold",
+			intervals: []*SoakInterval{interval(0, 50), interval(30, 60), interval(60, 70)},
+			threshold: 10,
+			wantDrift: 20,
+			want:      SoakDrifting,
+		},
+		{
+			name:      "no threshold",
+			intervals: []*SoakInterval{interval(0, 50), interval(30, 60), interval(60, 70)},
+			wantDrift: 20,
+			want:      SoakStable,
+		},
+		{
+			name: "intervals without latencies left out",
+			intervals: []*SoakInterval{
+				interval(0, 50),
+				{Offset: Duration(30 * time.Minute)},
+				interval(60, 70),
+			},
+			threshold: 10,
+			wantDrift: 20,
+			want:      SoakDrifting,
+		},
+		{
+			name:      "single interval",
+			intervals: []*SoakInterval{interval(0, 50), {Offset: Duration(30 * time.Minute)}},
+			threshold: 10,
+			want:      SoakInconclusive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &SoakResult{Intervals: tt.intervals}
+			result.evaluateDrift(tt.threshold)
+
+			if result.Verdict != tt.want {
+				t.Errorf("verdict = %s, want %s", result.Verdict, tt.want)
+			}
+			if math.Abs(result.DriftMsPerHour-tt.wantDrift) > 1e-9 {
+				t.Errorf("drift = %v ms/hour, want %v", result.DriftMsPerHour, tt.wantDrift)
+			}
+		})
+	}
+}
+
+func TestSoakRun(t *testing.T) {
+	// The stub answers slower and slower, by 20ms every 100ms.
+	var (
+		once  sync.Once
+		start time.Time
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() { start = time.Now() })
+		time.Sleep(time.Since(start) / 5)
+	}))
+	defer stub.Close()
+
+	config := &SoakConfig{
+		RPS:      40,
+		Duration: Duration(500 * time.Millisecond),
+		Interval: Duration(100 * time.Millisecond),
+		// 20ms every 100ms is 720s an hour, far over the threshold.
+		DriftThresholdMsPerHour: 100_000,
+	}
+	worker := newTestWorker(stub.URL, 4, 1, WithWorkerSoak(config))
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Errorf("status = %s, want %s", got, StatusFinished)
+	}
+	result := store.soakResult
+	if result == nil {
+		t.Fatal("no soak result stored")
+	}
+	if len(result.Intervals) < 4 || len(result.Intervals) > 6 {
+		t.Errorf("%d intervals, want the 5 of 100ms in 500ms", len(result.Intervals))
+	}
+	for i := 1; i < len(result.Intervals); i++ {
+		if result.Intervals[i].Offset <= result.Intervals[i-1].Offset {
+			t.Errorf("interval %d starts at %s, before the one before it", i, time.Duration(result.Intervals[i].Offset))
+		}
+	}
+	if result.Verdict != SoakDrifting {
+		t.Errorf("verdict = %s with a drift of %.0f ms/hour, want %s", result.Verdict, result.DriftMsPerHour, SoakDrifting)
+	}
+}
+
+func TestSpikeRecovery(t *testing.T) {
+	// A 300ms baseline, a 100ms spike, then the baseline again until the end.
+	config := SpikeConfig{
+		BaselineRPS:       100,
+		SpikeRPS:          200,
+		SpikeDuration:     Duration(100 * time.Millisecond),
+		Interval:          Duration(300 * time.Millisecond),
+		Duration:          Duration(700 * time.Millisecond),
+		RecoveryTolerance: 0.5,
+		Window:            Duration(50 * time.Millisecond),
+	}
+
+	tests := []struct {
+		name          string
+		slowUntil     time.Duration // 0 to stay slow
+		wantRecovered bool
+	}{
+		{name: "recovered", slowUntil: 500 * time.Millisecond, wantRecovered: true},
+		{name: "not recovered", wantRecovered: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The stub answers in 10ms and slows down to 40ms from the spike on.
+			var (
+				once  sync.Once
+				start time.Time
+			)
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				once.Do(func() { start = time.Now() })
+				elapsed := time.Since(start)
+				if elapsed > 300*time.Millisecond && (tt.slowUntil == 0 || elapsed < tt.slowUntil) {
+					time.Sleep(40 * time.Millisecond)
+					return
+				}
+				time.Sleep(10 * time.Millisecond)
+			}))
+			defer stub.Close()
+
+			config := config
+			worker := newTestWorker(stub.URL, 8, 1, WithWorkerSpike(&config))
+			store := runWorker(context.Background(), worker)
+
+			if got := store.finalStatus(); got != StatusFinished {
+				t.Errorf("status = %s, want %s", got, StatusFinished)
+			}
+			result := store.spikeResult
+			if result == nil {
+				t.Fatal("no spike result stored")
+			}
+			if got := timelinePhases(result.Timeline); got != "baseline spike baseline" {
+				t.Errorf("timeline phases = %q, want a baseline, a spike and a baseline", got)
+			}
+			if len(result.Recoveries) != 1 {
+				t.Fatalf("%d recoveries, want the one of the single spike", len(result.Recoveries))
+			}
+
+			recovery := result.Recoveries[0]
+			if recovery.Spike != 1 || recovery.Recovered != tt.wantRecovered {
+				t.Errorf("recovery = %+v, want spike 1 recovered: %t", recovery, tt.wantRecovered)
+			}
+			// The stub is back to 10ms 100ms after the spike, seen by the end of the next window.
+			recoveryTime := time.Duration(recovery.RecoveryTime)
+			if tt.wantRecovered && (recoveryTime < 50*time.Millisecond || recoveryTime > 300*time.Millisecond) {
+				t.Errorf("recovery time = %s, want about 150ms", recoveryTime)
+			}
+			if !tt.wantRecovered && recoveryTime != 0 {
+				t.Errorf("recovery time = %s, want none without a recovery", recoveryTime)
+			}
+		})
+	}
+}
+
+// timelinePhases returns the phases of the timeline in order, each run of
+// windows of the same phase counting once.
+func timelinePhases(timeline []*PhaseWindow) string {
+	var phases []string
+	for i, window := range timeline {
+		if i == 0 || window.Phase != timeline[i-1].Phase {
+			phases = append(phases, string(window.Phase))
+		}
+	}
+	return strings.Join(phases, " ")
+}
@@ -1,6 +1,11 @@
 package entity
 
-import "github.com/vladComan0/performance-analyzer/pkg/tokens"
+import (
+	"math/rand"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/pkg/tokens"
+)
 
 type WorkerOption func(*Worker)
 
@@ -15,3 +20,315 @@ func WithWorkerReport(report string) WorkerOption {
 		worker.Report = report
 	}
 }
+
+// WithWorkerThroughputSLA requires the achieved throughput to be at least
+// minThroughputRatio (0-1] of targetRPS, otherwise the run is flagged as underperforming.
+func WithWorkerThroughputSLA(targetRPS, minThroughputRatio float64) WorkerOption {
+	return func(worker *Worker) {
+		worker.TargetRPS = targetRPS
+		worker.MinThroughputRatio = minThroughputRatio
+	}
+}
+
+func WithWorkerRampToFailure(config *RampConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.Mode = ModeRampToFailure
+		worker.RampConfig = config
+	}
+}
+
+func WithWorkerSoak(config *SoakConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.Mode = ModeSoak
+		worker.SoakConfig = config
+	}
+}
+
+func WithWorkerSpike(config *SpikeConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.Mode = ModeSpike
+		worker.SpikeConfig = config
+	}
+}
+
+// WithWorkerAutoTune sets the concurrency to the upper bound of the search,
+// the HTTP client being sized for it.
+func WithWorkerAutoTune(config *AutoTuneConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.Mode = ModeAutoTune
+		worker.AutoTuneConfig = config
+		worker.Concurrency = config.MaxConcurrency
+	}
+}
+
+// WithWorkerRampUp spreads the goroutine starts evenly over rampUp. The gap
+// between two starts is shifted by up to ±jitter of itself, sampled per gap.
+func WithWorkerRampUp(rampUp Duration, jitter float64) WorkerOption {
+	return func(worker *Worker) {
+		worker.RampUp = rampUp
+		worker.RampUpJitter = jitter
+	}
+}
+
+// WithWorkerThinkTime sets the pause between two requests of a goroutine. Each
+// pause is shifted by up to ±jitter of thinkTime, sampled per pause.
+func WithWorkerThinkTime(thinkTime Duration, jitter float64) WorkerOption {
+	return func(worker *Worker) {
+		worker.ThinkTime = &thinkTime
+		worker.ThinkTimeJitter = jitter
+	}
+}
+
+func WithWorkerExhaustionPolicy(policy ExhaustionPolicy) WorkerOption {
+	return func(worker *Worker) {
+		worker.OnResourceExhaustion = policy
+	}
+}
+
+func WithWorkerCircuitBreaker(config *CircuitBreakerConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.CircuitBreaker = config
+	}
+}
+
+// WithWorkerRetry sets the retry policy of the failed requests, validated beforehand.
+func WithWorkerRetry(config *RetryConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.Retry = config
+	}
+}
+
+// WithWorkerSuccessHeader sets the success header rule, validated beforehand.
+func WithWorkerSuccessHeader(rule *HeaderRule) WorkerOption {
+	return func(worker *Worker) {
+		worker.SuccessHeader = rule
+		worker.headerPattern, _ = rule.compile()
+	}
+}
+
+// WithWorkerBodyAssertion sets the body assertion, validated beforehand.
+func WithWorkerBodyAssertion(assertion *BodyAssertion) WorkerOption {
+	return func(worker *Worker) {
+		worker.BodyAssertion = assertion
+		worker.assertionPath, _ = parseJSONPath(assertion.Path)
+	}
+}
+
+func WithWorkerServedByHeaders(headers []string) WorkerOption {
+	return func(worker *Worker) {
+		worker.ServedByHeaders = headers
+	}
+}
+
+func WithWorkerExclusive() WorkerOption {
+	return func(worker *Worker) {
+		worker.Exclusive = true
+	}
+}
+
+func WithWorkerTimeouts(timeouts *RequestTimeouts) WorkerOption {
+	return func(worker *Worker) {
+		worker.Timeouts = timeouts
+	}
+}
+
+func WithWorkerResolver(address string) WorkerOption {
+	return func(worker *Worker) {
+		worker.Resolver = address
+	}
+}
+
+func WithWorkerCaptureQuotas(quotas CaptureQuotas) WorkerOption {
+	return func(worker *Worker) {
+		worker.CaptureQuotas = quotas
+	}
+}
+
+func WithWorkerPersistSamples(sampleCap int) WorkerOption {
+	return func(worker *Worker) {
+		worker.PersistSamples = true
+		worker.SampleCap = sampleCap
+	}
+}
+
+// WithWorkerLogSampleRate logs 1 in rate of the per request debug events.
+func WithWorkerLogSampleRate(rate int) WorkerOption {
+	return func(worker *Worker) {
+		worker.LogSampleRate = rate
+	}
+}
+
+// WithWorkerRampDown winds the load down over rampDown at the end of the run
+// instead of stopping at once.
+func WithWorkerRampDown(rampDown Duration) WorkerOption {
+	return func(worker *Worker) {
+		worker.RampDown = rampDown
+	}
+}
+
+// WithWorkerRecordRequests writes every request of the run to a CSV file in dir.
+func WithWorkerRecordRequests(dir string) WorkerOption {
+	return func(worker *Worker) {
+		worker.RecordRequests = true
+		worker.recordDir = dir
+	}
+}
+
+// WithWorkerMeasureColdRequests reports the first request of every goroutine,
+// which pays the connection setup, apart from the following ones.
+func WithWorkerMeasureColdRequests() WorkerOption {
+	return func(worker *Worker) {
+		worker.MeasureColdRequests = true
+	}
+}
+
+// WithWorkerRequestBudget draws the requests of the worker from a budget
+// shared with other workers.
+func WithWorkerRequestBudget(budget *RequestBudget) WorkerOption {
+	return func(worker *Worker) {
+		worker.budget = budget
+	}
+}
+
+func WithWorkerKeepAlive(config *KeepAliveConfig) WorkerOption {
+	return func(worker *Worker) {
+		worker.KeepAlive = config
+	}
+}
+
+func WithWorkerBodyContentType(contentType string) WorkerOption {
+	return func(worker *Worker) {
+		worker.BodyContentType = contentType
+	}
+}
+
+func WithWorkerBodyVariants(variants []BodyVariant, selection VariantSelection) WorkerOption {
+	return func(worker *Worker) {
+		worker.BodyVariants = variants
+		worker.VariantSelection = selection
+	}
+}
+
+func WithWorkerCorrelationHeader(header string) WorkerOption {
+	return func(worker *Worker) {
+		worker.CorrelationHeader = header
+	}
+}
+
+// WithWorkerCampaign links the worker to the campaign that created it.
+func WithWorkerCampaign(campaignID int) WorkerOption {
+	return func(worker *Worker) {
+		worker.CampaignID = &campaignID
+	}
+}
+
+// WithWorkerLatencyFailureThreshold counts the responses slower than
+// threshold as slow, and as failures too unless policy is SlowRequestCount.
+func WithWorkerLatencyFailureThreshold(threshold Duration, policy SlowRequestPolicy) WorkerOption {
+	return func(worker *Worker) {
+		if policy == "" {
+			policy = SlowRequestFail
+		}
+		worker.LatencyFailureThreshold = threshold
+		worker.SlowRequestPolicy = policy
+	}
+}
+
+// WithWorkerRedirects sets whether the redirects are followed and what the
+// 3xx responses received count as, RedirectNeutral when policy is empty.
+func WithWorkerRedirects(follow *bool, policy RedirectPolicy) WorkerOption {
+	return func(worker *Worker) {
+		worker.FollowRedirects = follow
+		if policy != "" {
+			worker.Status3xx = policy
+		}
+	}
+}
+
+// WithWorkerDataSource fills the placeholders of the requests with the rows
+// of source, loaded when the run starts.
+func WithWorkerDataSource(source *DataSource) WorkerOption {
+	return func(worker *Worker) {
+		data := *source
+		data.Rows = 0
+		worker.DataSource = &data
+	}
+}
+
+// WithWorkerBodyStream streams the body of every request from stream, its
+// file being read from dir.
+func WithWorkerBodyStream(stream *BodyStream, dir string) WorkerOption {
+	return func(worker *Worker) {
+		worker.BodyStream = stream
+		worker.bodyFilesDir = dir
+	}
+}
+
+// WithWorkerProvenance records where every setting of the worker came from.
+func WithWorkerProvenance(provenance Provenance) WorkerOption {
+	return func(worker *Worker) {
+		worker.Provenance = provenance
+	}
+}
+
+// WithWorkerSeed sets the master seed every random choice of the run is
+// derived from, keeping the random one of the worker when 0.
+func WithWorkerSeed(seed int64) WorkerOption {
+	return func(worker *Worker) {
+		if seed != 0 {
+			worker.Seed = seed
+		}
+	}
+}
+
+// WithWorkerIdentities sends a distinct identity per goroutine, generated
+// from a random seed when the config lists none and sets no seed.
+func WithWorkerIdentities(config *IdentityConfig) WorkerOption {
+	return func(worker *Worker) {
+		identities := *config
+		if len(identities.Values) == 0 && identities.Seed == 0 {
+			identities.Seed = rand.Int63()
+		}
+		worker.Identities = &identities
+	}
+}
+
+// WithWorkerRateWindow sets the span of the live request rate of the worker.
+func WithWorkerRateWindow(span time.Duration) WorkerOption {
+	return func(worker *Worker) {
+		worker.Metrics.TrackRate(span)
+	}
+}
+
+// WithWorkerScenario replays the steps of scenario instead of sending
+// requests to the endpoint of the environment.
+func WithWorkerScenario(scenario *Scenario) WorkerOption {
+	return func(worker *Worker) {
+		worker.ScenarioID = &scenario.ID
+		worker.Scenario = scenario
+		worker.scenario = newScenarioCursors(scenario.Shuffle, func(index int) *rand.Rand {
+			return worker.newRand(randScenario, index)
+		})
+	}
+}
+
+// WithWorkerRateLimiter spaces out the requests of the worker along with
+// the ones of every other worker sharing limiter.
+func WithWorkerRateLimiter(limiter *RateLimiter) WorkerOption {
+	return func(worker *Worker) {
+		worker.limiter = limiter
+	}
+}
+
+// WithWorkerStatsD exports the metrics of every request of the worker to stats.
+func WithWorkerStatsD(stats *StatsD) WorkerOption {
+	return func(worker *Worker) {
+		worker.stats = stats
+	}
+}
+
+func WithWorkerTags(tags []string) WorkerOption {
+	return func(worker *Worker) {
+		worker.Tags = tags
+	}
+}
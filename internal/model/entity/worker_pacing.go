@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// legacyMaxThinkTime bounds the random pause used when no think time is configured.
+const legacyMaxThinkTime = 1000 // in milliseconds
+
+// jitter shifts d by a random amount within ±fraction*d. It is sampled anew
+// on every call, so every interval gets its own offset.
+func jitter(d time.Duration, fraction float64, rng *rand.Rand) time.Duration {
+	if fraction <= 0 || d <= 0 {
+		return d
+	}
+	offset := (rng.Float64()*2 - 1) * fraction * float64(d)
+	return d + time.Duration(offset)
+}
+
+// rampUpDelay returns how long the goroutine with the given index waits before
+// sending its first request, spreading the goroutine starts over RampUp.
+func (w *Worker) rampUpDelay(index int, rng *rand.Rand) time.Duration {
+	if w.RampUp <= 0 || w.Concurrency < 2 {
+		return 0
+	}
+
+	gap := time.Duration(w.RampUp) / time.Duration(w.Concurrency)
+	delay := time.Duration(index)*gap + jitter(gap, w.RampUpJitter, rng) - gap
+	return max(delay, 0)
+}
+
+// thinkTime returns the pause between two consecutive requests of a goroutine.
+func (w *Worker) thinkTime(rng *rand.Rand) time.Duration {
+	if w.ThinkTime == nil {
+		return time.Duration(rng.Intn(legacyMaxThinkTime)) * time.Millisecond
+	}
+	return jitter(time.Duration(*w.ThinkTime), w.ThinkTimeJitter, rng)
+}
+
+// sleep pauses for d unless ctx is cancelled first, in which case it reports false.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestThinkTimeJitter(t *testing.T) {
+	const (
+		thinkTime = 100 * time.Millisecond
+		fraction  = 0.2
+		pauses    = 200
+	)
+	worker := newTestWorker("http://localhost", 2, 1, WithWorkerThinkTime(Duration(thinkTime), fraction), WithWorkerSeed(42))
+
+	low := thinkTime - time.Duration(fraction*float64(thinkTime))
+	high := thinkTime + time.Duration(fraction*float64(thinkTime))
+
+	sequences := make([][]time.Duration, worker.Concurrency)
+	for index := range sequences {
+		rng := worker.newRand(randPacing, index)
+		for i := 0; i < pauses; i++ {
+			pause := worker.thinkTime(rng)
+			if pause < low || pause > high {
+				t.Fatalf("goroutine %d paused %s, want within [%s, %s]", index, pause, low, high)
+			}
+			sequences[index] = append(sequences[index], pause)
+		}
+
+		distinct := slices.Clone(sequences[index])
+		slices.Sort(distinct)
+		distinct = slices.Compact(distinct)
+		if len(distinct) < pauses/2 {
+			t.Errorf("goroutine %d paused %d distinct times out of %d, want them to vary", index, len(distinct), pauses)
+		}
+	}
+
+	if slices.Equal(sequences[0], sequences[1]) {
+		t.Error("both goroutines paused the same times, want them de-correlated")
+	}
+}
+
+func TestThinkTimeWithoutJitter(t *testing.T) {
+	worker := newTestWorker("http://localhost", 1, 1, WithWorkerThinkTime(Duration(50*time.Millisecond), 0))
+	rng := worker.newRand(randPacing, 0)
+
+	for i := 0; i < 10; i++ {
+		if pause := worker.thinkTime(rng); pause != 50*time.Millisecond {
+			t.Fatalf("paused %s, want 50ms", pause)
+		}
+	}
+}
+
+func TestRampUpJitter(t *testing.T) {
+	const (
+		rampUp      = time.Second
+		fraction    = 0.5
+		concurrency = 10
+	)
+	worker := newTestWorker("http://localhost", concurrency, 1, WithWorkerRampUp(Duration(rampUp), fraction), WithWorkerSeed(7))
+	gap := rampUp / concurrency
+
+	var identical int
+	for index := 0; index < concurrency; index++ {
+		rng := worker.newRand(randPacing, index)
+		delay := worker.rampUpDelay(index, rng)
+
+		nominal := time.Duration(index) * gap
+		low := max(nominal-time.Duration(fraction*float64(gap)), 0)
+		high := nominal + time.Duration(fraction*float64(gap))
+		if delay < low || delay > high {
+			t.Errorf("goroutine %d started after %s, want within [%s, %s]", index, delay, low, high)
+		}
+		if delay == nominal {
+			identical++
+		}
+	}
+
+	if identical == concurrency {
+		t.Error("every goroutine started on its nominal gap, want them shifted")
+	}
+}
+
+func TestCancelDuringThinkTime(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+
+	worker := newTestWorker(target.URL, 50, 10, WithWorkerThinkTime(Duration(time.Hour), 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan *memoryStore)
+	go func() { done <- runWorker(ctx, worker) }()
+
+	// Every goroutine sends its first request, then pauses for an hour.
+	for worker.Metrics.GetTotalRequests() < worker.Concurrency {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancelled := time.Now()
+	cancel()
+	select {
+	case store := <-done:
+		if elapsed := time.Since(cancelled); elapsed > 100*time.Millisecond {
+			t.Errorf("the run took %s to stop, want it to stop within milliseconds", elapsed)
+		}
+		if got := store.finalStatus(); got != StatusCancelled {
+			t.Errorf("status = %s, want %s", got, StatusCancelled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run didn't stop once cancelled")
+	}
+}
@@ -0,0 +1,59 @@
+package entity
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ProbeResult is the outcome of a single request sent to check that an environment is alive.
+type ProbeResult struct {
+	EnvironmentID int        `json:"environment_id"`
+	Name          string     `json:"name"`
+	Endpoint      string     `json:"endpoint"`
+	Alive         bool       `json:"alive"`             // a response was received, whatever its status
+	Skipped       bool       `json:"skipped,omitempty"` // not sent, the request budget was spent
+	StatusCode    int        `json:"status_code,omitempty"`
+	Latency       float64    `json:"latency,omitempty"` // in seconds
+	ErrorClass    ErrorClass `json:"error_class,omitempty"`
+	Error         string     `json:"error,omitempty"`
+}
+
+// Probe sends a single request with the method of the worker to its
+// environment, built and classified like the requests of a run.
+func (w *Worker) Probe(ctx context.Context) *ProbeResult {
+	result := &ProbeResult{
+		EnvironmentID: w.EnvironmentID,
+		Name:          w.Environment.Name,
+		Endpoint:      w.Environment.Endpoint,
+	}
+
+	if !w.budget.take() {
+		result.Skipped = true
+		return result
+	}
+
+	if w.client == nil {
+		w.client = w.newHTTPClient()
+	}
+
+	req, err := w.createRequest(ctx, w.HTTPMethod, w.Environment.Endpoint, nil)
+	if err != nil {
+		result.ErrorClass, result.Error = ErrorClassOther, err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	result.Latency = time.Since(start).Seconds()
+	if err != nil {
+		result.ErrorClass, result.Error = classifyError(err), err.Error()
+		return result
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	result.Alive = true
+	result.StatusCode = resp.StatusCode
+	return result
+}
@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"time"
+)
+
+// Progress tells a poller how far a worker is. Count based runs report
+// Completed out of Expected requests, duration based runs report Elapsed out
+// of Total instead, Total being unknown for an unbounded ramp to failure.
+type Progress struct {
+	Completed  int       `json:"completed"`
+	Expected   *int      `json:"expected,omitempty"`
+	Elapsed    *Duration `json:"elapsed,omitempty"`
+	Total      *Duration `json:"total,omitempty"`
+	Ratio      float64   `json:"ratio"`                 // between 0 and 1
+	CurrentRPS *float64  `json:"current_rps,omitempty"` // over the rate window, only while running
+}
+
+// expectedRequests is only known upfront for the fixed mode, the other modes run for a duration.
+func (w *Worker) expectedRequests() *int {
+	if w.Mode != ModeFixed {
+		return nil
+	}
+	expected := w.Concurrency * w.RequestsPerTask
+	return &expected
+}
+
+// plannedDuration reports the configured length of a duration based run.
+func (w *Worker) plannedDuration() (time.Duration, bool) {
+	switch w.Mode {
+	case ModeRampToFailure:
+		if w.RampConfig != nil && w.RampConfig.MaxDuration > 0 {
+			return time.Duration(w.RampConfig.MaxDuration), true
+		}
+	case ModeSoak:
+		if w.SoakConfig != nil {
+			return time.Duration(w.SoakConfig.Duration + w.RampDown), true
+		}
+	case ModeSpike:
+		if w.SpikeConfig != nil {
+			return time.Duration(w.SpikeConfig.Duration + w.RampDown), true
+		}
+	case ModeAutoTune:
+		if w.AutoTuneConfig != nil && w.AutoTuneConfig.MaxDuration > 0 {
+			return time.Duration(w.AutoTuneConfig.MaxDuration), true
+		}
+	}
+	return 0, false
+}
+
+// GetProgress computes the progress of a worker, whether it is the running
+// instance or one loaded back from the database.
+func (w *Worker) GetProgress() *Progress {
+	w.mu.Lock()
+	status, startedAt := w.Status, w.startedAt
+	w.mu.Unlock()
+
+	progress := &Progress{
+		Completed: w.Metrics.GetTotalRequests(),
+		Expected:  w.ExpectedRequests,
+	}
+
+	if status != StatusCreated && status != StatusRunning {
+		progress.Ratio = 1
+		return progress
+	}
+
+	if rate, ok := w.Metrics.CurrentRate(); ok && status == StatusRunning {
+		progress.CurrentRPS = &rate
+	}
+
+	if progress.Expected != nil {
+		if *progress.Expected > 0 {
+			progress.Ratio = min(float64(progress.Completed)/float64(*progress.Expected), 1)
+		}
+		return progress
+	}
+
+	if startedAt.IsZero() {
+		return progress
+	}
+
+	elapsed := Duration(time.Since(startedAt))
+	progress.Elapsed = &elapsed
+	if planned, ok := w.plannedDuration(); ok && planned > 0 {
+		total := Duration(planned)
+		progress.Total = &total
+		progress.Ratio = min(float64(elapsed)/float64(total), 1)
+	}
+	return progress
+}
@@ -0,0 +1,57 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExpectedRequests(t *testing.T) {
+	tests := []struct {
+		name   string
+		worker *Worker
+		want   *int
+	}{
+		{name: "fixed", worker: newTestWorker("http://localhost", 4, 25), want: intPointer(100)},
+		{name: "single goroutine", worker: newTestWorker("http://localhost", 1, 7), want: intPointer(7)},
+		{name: "soak", worker: newTestWorker("http://localhost", 4, 25, WithWorkerSoak(&SoakConfig{Duration: Duration(time.Hour)})), want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.worker.ExpectedRequests
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("expected requests = %d, want none", *got)
+			case tt.want != nil && (got == nil || *got != *tt.want):
+				t.Errorf("expected requests = %v, want %d", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressOfFinishedRun(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+
+	worker := newTestWorker(target.URL, 3, 4)
+	if progress := worker.GetProgress(); progress.Completed != 0 || progress.Ratio != 0 {
+		t.Fatalf("progress before the run = %+v, want nothing completed", progress)
+	}
+
+	runWorker(context.Background(), worker)
+
+	progress := worker.GetProgress()
+	if progress.Expected == nil || *progress.Expected != 12 {
+		t.Fatalf("expected = %v, want 12", progress.Expected)
+	}
+	if progress.Completed != *progress.Expected || progress.Ratio != 1 {
+		t.Errorf("progress = %d/%d (%v), want complete", progress.Completed, *progress.Expected, progress.Ratio)
+	}
+}
+
+func intPointer(v int) *int {
+	return &v
+}
@@ -0,0 +1,52 @@
+package entity
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// maxRampDownSteps bounds the steps the rate of a paced run is lowered in during its ramp down.
+const maxRampDownSteps = 10
+
+// rampDownEnd returns when the goroutine with the given index stops during a
+// ramp down starting at start. The goroutines stop one after another, evenly
+// spread over RampDown, the last one started being the first one stopped.
+func (w *Worker) rampDownEnd(index int, start time.Time) time.Time {
+	gap := time.Duration(w.RampDown) / time.Duration(w.Concurrency)
+	return start.Add(time.Duration(w.Concurrency-index) * gap)
+}
+
+// coolDown keeps a goroutine of a fixed run sending requests once the main
+// requests are all sent, until its turn to stop comes.
+func (w *Worker) coolDown(ctx context.Context, index int, rng *rand.Rand, start time.Time) {
+	end := w.rampDownEnd(index, start)
+
+	for time.Now().Before(end) {
+		w.send(ctx, index, PhaseRampDown, w.Metrics)
+
+		if w.throttled(index) {
+			return
+		}
+
+		if !sleep(ctx, min(w.thinkTime(rng), time.Until(end))) {
+			return
+		}
+	}
+}
+
+// rampDownPaced lowers the rate of a paced run from rps to zero over
+// RampDown, in equal steps, once its duration is over.
+func (w *Worker) rampDownPaced(ctx context.Context, rps float64) {
+	if w.RampDown <= 0 || ctx.Err() != nil {
+		return
+	}
+
+	steps := min(w.Concurrency, maxRampDownSteps)
+	stepDuration := time.Duration(w.RampDown) / time.Duration(steps)
+	for step := 0; step < steps && ctx.Err() == nil; step++ {
+		stepRPS := rps * float64(steps-step) / float64(steps)
+		w.log.Debug().Msgf("Worker %d ramping down at %.2f req/s", w.ID, stepRPS)
+		w.runPacedPhase(ctx, PhaseRampDown, stepRPS, stepDuration)
+	}
+}
@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRampDown(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		arrivals []time.Time
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		arrivals = append(arrivals, time.Now())
+		mu.Unlock()
+	}))
+	defer stub.Close()
+
+	// 4 goroutines stopping one after another, 100ms apart.
+	worker := newTestWorker(stub.URL, 4, 1,
+		WithWorkerThinkTime(Duration(10*time.Millisecond), 0),
+		WithWorkerRampDown(Duration(400*time.Millisecond)),
+	)
+	start := time.Now()
+	runWorker(context.Background(), worker)
+	elapsed := time.Since(start)
+
+	if elapsed < 400*time.Millisecond || elapsed > time.Second {
+		t.Errorf("run took %s, want about the 400ms of the ramp down", elapsed)
+	}
+
+	// Requests per 100ms window: each window has one goroutine less sending.
+	var windows [4]int
+	for _, arrival := range arrivals {
+		if window := int(arrival.Sub(start) / (100 * time.Millisecond)); window < len(windows) {
+			windows[window]++
+		}
+	}
+	for i := 1; i < len(windows); i++ {
+		if windows[i] >= windows[i-1] {
+			t.Errorf("requests per 100ms = %v, want them decreasing as the goroutines stop", windows)
+			break
+		}
+	}
+	if windows[len(windows)-1] == 0 {
+		t.Errorf("requests per 100ms = %v, want the last goroutine sending until the end", windows)
+	}
+
+	// The requests of the ramp down count, in a phase of their own.
+	rampDown := worker.Metrics.Phases[PhaseRampDown]
+	if rampDown == nil || rampDown.TotalRequests == 0 {
+		t.Fatalf("phases = %v, want requests in the ramp down", worker.Metrics.Phases)
+	}
+	if worker.Metrics.TotalRequests != len(arrivals) {
+		t.Errorf("total requests = %d, want the %d received", worker.Metrics.TotalRequests, len(arrivals))
+	}
+}
+
+func TestCancelDuringRampDown(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	worker := newTestWorker(stub.URL, 4, 1,
+		WithWorkerThinkTime(Duration(10*time.Millisecond), 0),
+		WithWorkerRampDown(Duration(time.Hour)),
+	)
+	start := time.Now()
+	store := runWorker(ctx, worker)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("run took %s after being cancelled at 100ms", elapsed)
+	}
+	if got := store.finalStatus(); got != StatusCancelled {
+		t.Errorf("status = %s, want %s", got, StatusCancelled)
+	}
+}
@@ -0,0 +1,83 @@
+package entity
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// randFeature names a randomized feature of a worker. Every feature draws
+// from RNGs of its own, so that turning one on doesn't shift the numbers
+// drawn by the others.
+type randFeature uint64
+
+const (
+	randPacing   randFeature = iota + 1 // the ramp-up spread, the think times and their jitter
+	randRetry                           // the jitter of the retry backoffs
+	randScenario                        // the order of the shuffled scenario steps
+	randVariants                        // the body variants picked at random
+	randSamples                         // the latency samples kept by the reservoir
+)
+
+// newSeed returns a random master seed, never 0 which stands for none.
+func newSeed() int64 {
+	for {
+		if seed := rand.Int63(); seed != 0 {
+			return seed
+		}
+	}
+}
+
+// newRand returns the RNG of a feature for the goroutine with the given
+// index, derived from the seed of the worker. Every RNG of a run comes from
+// here, so the same seed draws the same numbers again.
+func (w *Worker) newRand(feature randFeature, index int) *rand.Rand {
+	seed := mix(mix(uint64(w.Seed)^uint64(feature)<<56) + uint64(index))
+	return rand.New(rand.NewSource(int64(seed)))
+}
+
+// goroutineRands holds the RNGs of a feature, one per goroutine, created on
+// first use.
+type goroutineRands struct {
+	mu      sync.Mutex
+	rands   map[int]*rand.Rand // by goroutine index
+	newRand func(index int) *rand.Rand
+}
+
+func newGoroutineRands(w *Worker, feature randFeature) *goroutineRands {
+	return &goroutineRands{
+		rands:   make(map[int]*rand.Rand),
+		newRand: func(index int) *rand.Rand { return w.newRand(feature, index) },
+	}
+}
+
+// get returns the RNG of the goroutine with the given index, only ever
+// used by that goroutine.
+func (r *goroutineRands) get(index int) *rand.Rand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rng := r.rands[index]
+	if rng == nil {
+		rng = r.newRand(index)
+		r.rands[index] = rng
+	}
+	return rng
+}
+
+type goroutineKey struct{}
+
+// withGoroutine returns ctx carrying the index of the goroutine sending the
+// request, for the features drawing from the RNG of the goroutine.
+func withGoroutine(ctx context.Context, index int) context.Context {
+	return context.WithValue(ctx, goroutineKey{}, index)
+}
+
+// goroutineIndex returns the index of the goroutine sending the request,
+// -1 for the requests sent outside the run, such as the probes.
+func goroutineIndex(ctx context.Context) int {
+	if index, ok := ctx.Value(goroutineKey{}).(int); ok {
+		return index
+	}
+	return -1
+}
@@ -0,0 +1,133 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// randomDraws are the random choices of a worker, per goroutine.
+type randomDraws struct {
+	rampUps    []time.Duration
+	thinkTimes [][]time.Duration
+	backoffs   [][]time.Duration
+	variants   [][]string
+}
+
+// drawRandom draws the random choices of a worker with the given seed the
+// way its goroutines do during a run.
+func drawRandom(seed int64) randomDraws {
+	const (
+		concurrency = 3
+		draws       = 50
+	)
+	variants := []BodyVariant{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+	retry := &RetryConfig{MaxRetries: MaxRetries, BaseDelay: Duration(10 * time.Millisecond), MaxDelay: Duration(time.Second), Jitter: JitterDecorrelated}
+	worker := newTestWorker("http://localhost", concurrency, 1,
+		WithWorkerRampUp(Duration(time.Second), 0.5),
+		WithWorkerThinkTime(Duration(100*time.Millisecond), 0.5),
+		WithWorkerSeed(seed),
+	)
+	picker := newBodyVariants(variants, VariantRandom, newGoroutineRands(worker, randVariants))
+	retryRands := newGoroutineRands(worker, randRetry)
+
+	result := randomDraws{
+		thinkTimes: make([][]time.Duration, concurrency),
+		backoffs:   make([][]time.Duration, concurrency),
+		variants:   make([][]string, concurrency),
+	}
+	for index := 0; index < concurrency; index++ {
+		pacing := worker.newRand(randPacing, index)
+		result.rampUps = append(result.rampUps, worker.rampUpDelay(index, pacing))
+
+		var previous time.Duration
+		for i := 0; i < draws; i++ {
+			result.thinkTimes[index] = append(result.thinkTimes[index], worker.thinkTime(pacing))
+			previous = retry.backoff(i%MaxRetries, previous, retryRands.get(index))
+			result.backoffs[index] = append(result.backoffs[index], previous)
+			result.variants[index] = append(result.variants[index], picker.pick(index).Name)
+		}
+	}
+	return result
+}
+
+func TestSeedReproducesDraws(t *testing.T) {
+	first, again := drawRandom(42), drawRandom(42)
+	if !reflect.DeepEqual(first, again) {
+		t.Errorf("the same seed drew %+v, then %+v", first, again)
+	}
+
+	other := drawRandom(43)
+	if reflect.DeepEqual(first.rampUps, other.rampUps) || reflect.DeepEqual(first.thinkTimes, other.thinkTimes) ||
+		reflect.DeepEqual(first.backoffs, other.backoffs) || reflect.DeepEqual(first.variants, other.variants) {
+		t.Error("another seed drew some of the same numbers, want every feature to follow the seed")
+	}
+}
+
+func TestSeedReproducesRun(t *testing.T) {
+	variants := []BodyVariant{
+		{Name: "small", Body: json.RawMessage(`{"size":"small"}`)},
+		{Name: "large", Body: json.RawMessage(`{"size":"large"}`)},
+	}
+
+	// A single goroutine sends the requests in order, so the bodies the
+	// target receives are the variants in the order they were picked.
+	run := func(seed int64) (*Worker, []string) {
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+		stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			mu.Unlock()
+		}))
+		defer stub.Close()
+
+		worker := newTestWorker(stub.URL, 1, 30,
+			WithWorkerThinkTime(Duration(time.Millisecond), 0.5),
+			WithWorkerBodyVariants(variants, VariantRandom),
+			WithWorkerSeed(seed),
+		)
+		runWorker(context.Background(), worker)
+		return worker, bodies
+	}
+
+	worker, first := run(7)
+	_, again := run(7)
+	if len(first) != 30 || !reflect.DeepEqual(first, again) {
+		t.Errorf("the same seed sent %v, then %v", first, again)
+	}
+	if worker.Seed != 7 {
+		t.Errorf("recorded seed %d, want 7", worker.Seed)
+	}
+}
+
+func TestSeedRecorded(t *testing.T) {
+	// Without a seed the worker picks one, recorded to replay the run.
+	worker := newTestWorker("http://localhost", 1, 1, WithWorkerSeed(0))
+	if worker.Seed == 0 {
+		t.Fatal("no seed recorded")
+	}
+
+	var decoded struct {
+		Seed int64 `json:"seed"`
+	}
+	data, err := json.Marshal(worker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Seed != worker.Seed {
+		t.Errorf("result carries seed %d, want %d", decoded.Seed, worker.Seed)
+	}
+}
@@ -0,0 +1,157 @@
+package entity
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// recordBufferSize is the number of request records queued for the file
+// writer. A request waits for room once it is full.
+const recordBufferSize = 8192
+
+// Outcomes of a recorded request besides its error class.
+const (
+	RecordOutcomeResponse  = "response" // whatever its status
+	RecordOutcomeCancelled = "cancelled"
+)
+
+// recordColumns is the header of the request record files.
+var recordColumns = []string{"sent_at", "latency", "status_code", "phase", "outcome", "request_id"}
+
+// requestRecord is one line of the request record file.
+type requestRecord struct {
+	sentAt     time.Time
+	latency    time.Duration
+	statusCode int // 0 without a response
+	phase      Phase
+	outcome    string // RecordOutcomeResponse, RecordOutcomeCancelled or the error class
+	requestID  string
+}
+
+// requestRecorder writes every request of a run to a CSV file, unlike the
+// latency samples which are bounded and kept in memory. The records go
+// through a buffered channel to a single writer.
+type requestRecorder struct {
+	file    *os.File
+	records chan requestRecord
+	done    chan struct{}
+	written int
+	err     error // the first write error, the following records are dropped
+}
+
+// RecordFileName is the name of the request record file of a worker.
+func RecordFileName(workerID int) string {
+	return fmt.Sprintf("worker-%d.csv", workerID)
+}
+
+func newRequestRecorder(dir string, workerID int) (*requestRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(filepath.Join(dir, RecordFileName(workerID)))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &requestRecorder{
+		file:    file,
+		records: make(chan requestRecord, recordBufferSize),
+		done:    make(chan struct{}),
+	}
+	go r.write()
+	return r, nil
+}
+
+func (r *requestRecorder) write() {
+	defer close(r.done)
+
+	writer := csv.NewWriter(r.file)
+	r.err = writer.Write(recordColumns)
+
+	for record := range r.records {
+		if r.err != nil {
+			continue
+		}
+		r.err = writer.Write([]string{
+			record.sentAt.UTC().Format(time.RFC3339Nano),
+			strconv.FormatFloat(record.latency.Seconds(), 'f', -1, 64),
+			strconv.Itoa(record.statusCode),
+			string(record.phase),
+			record.outcome,
+			record.requestID,
+		})
+		if r.err == nil {
+			r.written++
+		}
+	}
+
+	writer.Flush()
+	if r.err == nil {
+		r.err = writer.Error()
+	}
+}
+
+// close waits for the queued records to be written and returns the number of records in the file.
+func (r *requestRecorder) close() (int, error) {
+	close(r.records)
+	<-r.done
+
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	if r.err != nil {
+		_ = os.Remove(r.file.Name())
+	}
+	return r.written, r.err
+}
+
+// recordRequest queues a request for the record file if the worker records its requests.
+func (w *Worker) recordRequest(record requestRecord) {
+	if w.records == nil {
+		return
+	}
+	w.records.records <- record
+}
+
+// startRecording opens the record file of the worker. The run goes on
+// without it if it can't be created.
+func (w *Worker) startRecording(store WorkerStore) {
+	if !w.RecordRequests {
+		return
+	}
+
+	records, err := newRequestRecorder(w.recordDir, w.ID)
+	if err != nil {
+		w.log.Error().Err(err).Msgf("Error creating the request record file of worker %d", w.ID)
+		w.addWarning(store, fmt.Sprintf("the requests aren't recorded: %s", err))
+		return
+	}
+	w.records = records
+}
+
+// finishRecording completes the record file and stores its name on the worker.
+func (w *Worker) finishRecording(store WorkerStore) {
+	if w.records == nil {
+		return
+	}
+
+	written, err := w.records.close()
+	w.records = nil
+	if err != nil {
+		w.log.Error().Err(err).Msgf("Error writing the request record file of worker %d", w.ID)
+		w.addWarning(store, fmt.Sprintf("the request record file was discarded: %s", err))
+		return
+	}
+
+	w.RecordFile = RecordFileName(w.ID)
+	if err := store.UpdateRecordFile(w.ID, w.RecordFile); err != nil {
+		w.log.Error().Err(err).Msg("Error updating the record file")
+		return
+	}
+	w.log.Info().Msgf("Worker %d recorded %d requests to %s", w.ID, written, w.RecordFile)
+}
@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRecordRequests(t *testing.T) {
+	var requests atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1)%5 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer stub.Close()
+
+	dir := t.TempDir()
+	worker := newTestWorker(stub.URL, 3, 7, WithWorkerRecordRequests(dir), WithWorkerCorrelationHeader("X-Request-ID"))
+	store := runWorker(context.Background(), worker)
+
+	if store.recordFile != RecordFileName(worker.ID) {
+		t.Fatalf("record file = %q, want %q", store.recordFile, RecordFileName(worker.ID))
+	}
+	file, err := os.Open(filepath.Join(dir, store.recordFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	lines, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) == 0 || !slices.Equal(lines[0], recordColumns) {
+		t.Fatalf("header = %v, want %v", lines[:min(len(lines), 1)], recordColumns)
+	}
+
+	records := lines[1:]
+	if len(records) != 21 {
+		t.Fatalf("%d records, want one per request: 21", len(records))
+	}
+
+	// Every request is recorded once, with the status it got.
+	requestIDs := make(map[string]bool)
+	var serverErrors int
+	for _, record := range records {
+		requestID := record[5]
+		if requestID == "" || requestIDs[requestID] {
+			t.Errorf("request id %q missing or recorded twice", requestID)
+		}
+		requestIDs[requestID] = true
+
+		if latency, err := strconv.ParseFloat(record[1], 64); err != nil || latency <= 0 {
+			t.Errorf("latency = %q, want a positive number of seconds", record[1])
+		}
+		if record[4] != RecordOutcomeResponse {
+			t.Errorf("outcome = %q, want %q", record[4], RecordOutcomeResponse)
+		}
+		if record[2] == strconv.Itoa(http.StatusInternalServerError) {
+			serverErrors++
+		}
+	}
+	if serverErrors != 4 || worker.Metrics.StatusClasses[ResponseClass5xx] != 4 {
+		t.Errorf("recorded %d responses of 500 and counted %d, want 4", serverErrors, worker.Metrics.StatusClasses[ResponseClass5xx])
+	}
+}
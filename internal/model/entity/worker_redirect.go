@@ -0,0 +1,48 @@
+package entity
+
+import "net/http"
+
+// RedirectPolicy tells what a 3xx response received by a worker counts as.
+// Unless the worker stops following redirects, only the 3xx responses the
+// client doesn't follow, such as a 304 or a redirect without a Location,
+// are concerned.
+type RedirectPolicy string
+
+const (
+	// RedirectNeutral counts 3xx responses in NeutralRequests only, leaving
+	// them out of the error rate.
+	RedirectNeutral RedirectPolicy = "neutral"
+	RedirectSuccess RedirectPolicy = "success"
+	// RedirectFailure counts 3xx responses as failures of the redirect class.
+	RedirectFailure RedirectPolicy = "failure"
+)
+
+// stopRedirects has the client return the 3xx response instead of following it.
+func stopRedirects(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// countRedirect counts a response with statusCode under the 3xx policy of
+// the worker and reports whether the request failed, failed telling whether
+// it already failed otherwise, in which case it isn't counted twice.
+func (w *Worker) countRedirect(statusCode int, phase Phase, metrics []*Metrics, failed bool) bool {
+	if responseClass(statusCode) != ResponseClass3xx || failed {
+		return failed
+	}
+
+	switch w.Status3xx {
+	case RedirectSuccess:
+		return false
+	case RedirectFailure:
+		for _, m := range metrics {
+			m.IncrementFailedRequests(phase)
+			m.IncrementErrorClass(ErrorClassRedirect)
+		}
+		return true
+	default:
+		for _, m := range metrics {
+			m.IncrementNeutralRequests(phase)
+		}
+		return false
+	}
+}
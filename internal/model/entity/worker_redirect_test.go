@@ -0,0 +1,69 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRedirectPolicies(t *testing.T) {
+	// Every other request is redirected to /target, which answers 200.
+	var received atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/" && received.Add(1)%2 == 0 {
+			http.Redirect(w, r, "/target", http.StatusFound)
+		}
+	}))
+	defer stub.Close()
+
+	follow, stop := true, false
+	tests := []struct {
+		name        string
+		follow      *bool
+		policy      RedirectPolicy
+		want3xx     int
+		wantNeutral int
+		wantFailed  int
+		wantRate    float64
+	}{
+		{name: "neutral by default", follow: &stop, want3xx: 3, wantNeutral: 3},
+		{name: "neutral", follow: &stop, policy: RedirectNeutral, want3xx: 3, wantNeutral: 3},
+		{name: "success", follow: &stop, policy: RedirectSuccess, want3xx: 3},
+		{name: "failure", follow: &stop, policy: RedirectFailure, want3xx: 3, wantFailed: 3, wantRate: 0.5},
+		// The redirects followed end on the 200 of the target.
+		{name: "followed", follow: &follow, policy: RedirectFailure},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received.Store(0)
+			worker := newTestWorker(stub.URL, 1, 6, WithWorkerRedirects(test.follow, test.policy))
+			runWorker(context.Background(), worker)
+
+			metrics := worker.Metrics
+			if metrics.TotalRequests != 6 {
+				t.Fatalf("total requests = %d, want 6", metrics.TotalRequests)
+			}
+			if got := metrics.StatusClasses[ResponseClass3xx]; got != test.want3xx {
+				t.Errorf("3xx responses = %d, want %d", got, test.want3xx)
+			}
+			if got := metrics.StatusClasses[ResponseClass2xx]; got != 6-test.want3xx {
+				t.Errorf("2xx responses = %d, want %d", got, 6-test.want3xx)
+			}
+			if metrics.NeutralRequests != test.wantNeutral {
+				t.Errorf("neutral requests = %d, want %d", metrics.NeutralRequests, test.wantNeutral)
+			}
+			if metrics.FailedRequests != test.wantFailed {
+				t.Errorf("failed requests = %d, want %d", metrics.FailedRequests, test.wantFailed)
+			}
+			if metrics.ErrorClasses[ErrorClassRedirect] != test.wantFailed {
+				t.Errorf("redirect errors = %d, want %d", metrics.ErrorClasses[ErrorClassRedirect], test.wantFailed)
+			}
+			if metrics.ErrorRate != test.wantRate {
+				t.Errorf("error rate = %g, want %g", metrics.ErrorRate, test.wantRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package entity
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// resolverDialTimeout bounds the connection to a custom DNS server.
+const resolverDialTimeout = 5 * time.Second
+
+// NewResolver returns a resolver sending every query to the DNS server at
+// address (host:port) instead of the ones configured on the machine.
+func NewResolver(address string) *net.Resolver {
+	dialer := &net.Dialer{Timeout: resolverDialTimeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// CheckResolver makes sure the DNS server at address answers for the host of endpoint.
+func CheckResolver(ctx context.Context, address, endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return err
+	}
+
+	_, err = NewResolver(address).LookupHost(ctx, u.Hostname())
+	return err
+}
+
+// newHTTPClient returns the client used for every request of the run.
+func (w *Worker) newHTTPClient() *http.Client {
+	client := &http.Client{}
+	if w.FollowRedirects != nil && !*w.FollowRedirects {
+		client.CheckRedirect = stopRedirects
+	}
+
+	if w.Resolver == "" && w.KeepAlive == nil {
+		return client
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if w.Resolver != "" {
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Resolver:  NewResolver(w.Resolver),
+		}
+		transport.DialContext = dialer.DialContext
+	}
+
+	if w.KeepAlive != nil {
+		// The default of 2 idle connections per host would close most of the pool kept warm.
+		transport.MaxIdleConnsPerHost = max(w.Concurrency, w.KeepAlive.Connections)
+	}
+
+	client.Transport = transport
+	return client
+}
@@ -0,0 +1,126 @@
+package entity
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// dnsTypeA is the type of the IPv4 address records.
+const dnsTypeA = 1
+
+// startDNSStub serves over UDP the A records of hosts, answering the other
+// names with NXDOMAIN and the other types with no record. It returns the
+// host:port of the server.
+func startDNSStub(t *testing.T, hosts map[string]net.IP) string {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if response := dnsAnswer(buf[:n], hosts); response != nil {
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+// dnsAnswer returns the response to a query holding a single question.
+func dnsAnswer(query []byte, hosts map[string]net.IP) []byte {
+	if len(query) < 12 {
+		return nil
+	}
+
+	// The name is a sequence of length prefixed labels ending with an empty one.
+	var labels []string
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		size := int(query[end])
+		if end+1+size > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[end+1:end+1+size]))
+		end += 1 + size
+	}
+	end += 5 // the empty label, the type and the class
+	if end > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+
+	response := append([]byte(nil), query[:end]...)
+	binary.BigEndian.PutUint16(response[2:], 0x8180) // a response, recursion desired and available
+	binary.BigEndian.PutUint16(response[6:], 0)      // answers
+	binary.BigEndian.PutUint16(response[8:], 0)      // authorities
+	binary.BigEndian.PutUint16(response[10:], 0)     // additional records, the EDNS one of the query dropped
+
+	ip, found := hosts[strings.ToLower(strings.Join(labels, "."))]
+	if !found {
+		response[3] |= 3 // NXDOMAIN
+		return response
+	}
+	if qtype != dnsTypeA {
+		return response
+	}
+
+	binary.BigEndian.PutUint16(response[6:], 1)
+	response = append(response, 0xc0, 12) // the name of the question
+	response = binary.BigEndian.AppendUint16(response, dnsTypeA)
+	response = binary.BigEndian.AppendUint16(response, 1) // IN
+	response = binary.BigEndian.AppendUint32(response, 60)
+	response = binary.BigEndian.AppendUint16(response, 4)
+	return append(response, ip.To4()...)
+}
+
+func TestCustomResolver(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer target.Close()
+
+	resolver := startDNSStub(t, map[string]net.IP{"canary.example.test": net.IPv4(127, 0, 0, 1)})
+
+	// The name only exists on the stub, the system resolver can't find it.
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpoint := "http://canary.example.test:" + u.Port()
+
+	if err := CheckResolver(context.Background(), resolver, endpoint); err != nil {
+		t.Fatalf("CheckResolver() = %v, want the host resolved", err)
+	}
+	if err := CheckResolver(context.Background(), resolver, "http://missing.example.test"); err == nil {
+		t.Fatal("CheckResolver() resolved a host the stub doesn't know")
+	}
+
+	worker := newTestWorker(endpoint, 2, 3, WithWorkerResolver(resolver))
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Fatalf("status = %s, want %s", got, StatusFinished)
+	}
+	if worker.Metrics.FailedRequests != 0 {
+		t.Errorf("failed requests = %d, want 0: %v", worker.Metrics.FailedRequests, worker.Metrics.ErrorClasses)
+	}
+	if worker.Metrics.DNSLookups == 0 {
+		t.Error("no DNS lookup was traced")
+	}
+	if _, found := worker.Metrics.ResolvedAddresses["127.0.0.1:"+u.Port()]; !found {
+		t.Errorf("resolved addresses = %v, want the stub answer", worker.Metrics.ResolvedAddresses)
+	}
+}
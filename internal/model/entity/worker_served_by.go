@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DefaultServedByHeaders are the response headers telling which build of
+// the target served a response, tracked unless the worker names others.
+var DefaultServedByHeaders = []string{"Server", "X-Build-Version"}
+
+// MaxServedByHeaders bounds Worker.ServedByHeaders.
+const MaxServedByHeaders = 10
+
+// maxServedByValues caps the distinct values tracked per header, the
+// others being counted under otherServedBy.
+const (
+	maxServedByValues = 32
+	otherServedBy     = "other"
+)
+
+// servedByHeaders returns the response headers the worker tracks.
+func (w *Worker) servedByHeaders() []string {
+	if len(w.ServedByHeaders) == 0 {
+		return DefaultServedByHeaders
+	}
+	return w.ServedByHeaders
+}
+
+// countServedBy counts the protocol version of resp and the values of the
+// headers the worker tracks, the headers missing from it aside.
+func (w *Worker) countServedBy(resp *http.Response, metrics []*Metrics) {
+	for _, m := range metrics {
+		m.AddProtocolVersion(resp.Proto)
+		for _, header := range w.servedByHeaders() {
+			if value := resp.Header.Get(header); value != "" {
+				m.AddServedBy(http.CanonicalHeaderKey(header), value)
+			}
+		}
+	}
+}
+
+func (m *Metrics) AddProtocolVersion(proto string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ProtocolVersions == nil {
+		m.ProtocolVersions = make(map[string]int)
+	}
+	m.ProtocolVersions[proto]++
+}
+
+// AddServedBy counts a response whose header had value. Past
+// maxServedByValues distinct values of the header, new ones are counted
+// under otherServedBy.
+func (m *Metrics) AddServedBy(header, value string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ServedBy == nil {
+		m.ServedBy = make(map[string]map[string]int)
+	}
+	values := m.ServedBy[header]
+	if values == nil {
+		values = make(map[string]int)
+		m.ServedBy[header] = values
+	}
+
+	if _, exists := values[value]; !exists && len(values) >= maxServedByValues {
+		value = otherServedBy
+	}
+	values[value]++
+}
+
+// servedByChanges describes the tracked headers whose set of values
+// differs between the baseline and the run, the headers only one of them
+// tracked aside.
+func servedByChanges(baseline, run map[string]map[string]int) []string {
+	var changes []string
+	for _, header := range sortedKeys(run) {
+		baselineValues, tracked := baseline[header]
+		if !tracked {
+			continue
+		}
+
+		before, after := sortedKeys(baselineValues), sortedKeys(run[header])
+		if !slices.Equal(before, after) {
+			changes = append(changes, fmt.Sprintf(
+				"%s was %s for the baseline and %s for this run", header, strings.Join(before, ", "), strings.Join(after, ", "),
+			))
+		}
+	}
+	return changes
+}
+
+// sortedKeys returns the keys of counts, sorted.
+func sortedKeys[V any](counts map[string]V) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,32 @@
+package entity
+
+import "time"
+
+// SlowRequestPolicy tells what a response slower than the latency failure
+// threshold of a worker counts as.
+type SlowRequestPolicy string
+
+const (
+	// SlowRequestFail counts slow responses as failures as well, so the
+	// error rate reflects the violations of the latency budget.
+	SlowRequestFail SlowRequestPolicy = "fail"
+	// SlowRequestCount only counts slow responses in SlowRequests.
+	SlowRequestCount SlowRequestPolicy = "count"
+)
+
+// countSlow counts a response received after latency if it is over the
+// latency failure threshold, and reports whether it counts as a failure.
+func (w *Worker) countSlow(latency time.Duration, phase Phase, metrics []*Metrics) bool {
+	if w.LatencyFailureThreshold <= 0 || latency <= time.Duration(w.LatencyFailureThreshold) {
+		return false
+	}
+
+	failed := w.SlowRequestPolicy != SlowRequestCount
+	for _, m := range metrics {
+		m.IncrementSlowRequests(phase)
+		if failed {
+			m.IncrementFailedRequests(phase)
+		}
+	}
+	return failed
+}
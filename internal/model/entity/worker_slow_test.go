@@ -0,0 +1,74 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSlowRequests(t *testing.T) {
+	// Every other response comes 60ms late, the others right away.
+	var received atomic.Int64
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if received.Add(1)%2 == 0 {
+			time.Sleep(60 * time.Millisecond)
+		}
+	}))
+	defer stub.Close()
+
+	threshold := Duration(30 * time.Millisecond)
+	tests := []struct {
+		name       string
+		options    []WorkerOption
+		wantSlow   int
+		wantFailed int
+	}{
+		{name: "no threshold"},
+		{
+			name:       "slow requests fail",
+			options:    []WorkerOption{WithWorkerLatencyFailureThreshold(threshold, SlowRequestFail)},
+			wantSlow:   3,
+			wantFailed: 3,
+		},
+		{
+			name:     "slow requests counted",
+			options:  []WorkerOption{WithWorkerLatencyFailureThreshold(threshold, SlowRequestCount)},
+			wantSlow: 3,
+		},
+		{
+			name:       "policy defaults to fail",
+			options:    []WorkerOption{WithWorkerLatencyFailureThreshold(threshold, "")},
+			wantSlow:   3,
+			wantFailed: 3,
+		},
+		{
+			name:    "threshold above every response",
+			options: []WorkerOption{WithWorkerLatencyFailureThreshold(Duration(time.Second), SlowRequestFail)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			received.Store(0)
+			worker := newTestWorker(stub.URL, 1, 6, test.options...)
+			runWorker(context.Background(), worker)
+
+			metrics := worker.Metrics
+			if metrics.TotalRequests != 6 {
+				t.Fatalf("total requests = %d, want 6", metrics.TotalRequests)
+			}
+			if metrics.SlowRequests != test.wantSlow {
+				t.Errorf("slow requests = %d, want %d", metrics.SlowRequests, test.wantSlow)
+			}
+			if metrics.FailedRequests != test.wantFailed {
+				t.Errorf("failed requests = %d, want %d", metrics.FailedRequests, test.wantFailed)
+			}
+			if want := float64(test.wantFailed) / 6; metrics.ErrorRate != want {
+				t.Errorf("error rate = %g, want %g", metrics.ErrorRate, want)
+			}
+		})
+	}
+}
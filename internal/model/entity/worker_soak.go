@@ -0,0 +1,120 @@
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+type SoakVerdict string
+
+const (
+	SoakStable       SoakVerdict = "stable"
+	SoakDrifting     SoakVerdict = "drifting"
+	SoakInconclusive SoakVerdict = "inconclusive" // fewer than two intervals with latencies
+)
+
+// SoakConfig holds the parameters of the soak mode.
+type SoakConfig struct {
+	RPS                     float64  `json:"rps"`
+	Duration                Duration `json:"duration"`
+	Interval                Duration `json:"interval"`
+	DriftThresholdMsPerHour float64  `json:"drift_threshold_ms_per_hour"`
+}
+
+type SoakInterval struct {
+	Offset        Duration `json:"offset"` // since the start of the run
+	TotalRequests int      `json:"total_requests"`
+	ErrorRate     float64  `json:"error_rate"`
+	P95           float64  `json:"p95"` // in seconds
+}
+
+type SoakResult struct {
+	Intervals      []*SoakInterval `json:"intervals"`
+	DriftMsPerHour float64         `json:"drift_ms_per_hour"` // slope of the interval p95 over time
+	Verdict        SoakVerdict     `json:"verdict"`
+}
+
+// soak runs the soak intervals and reports whether the run lasted for the
+// whole configured duration rather than being cancelled.
+func (w *Worker) soak(ctx context.Context) bool {
+	soakCtx, cancel := context.WithTimeout(ctx, time.Duration(w.SoakConfig.Duration))
+	defer cancel()
+
+	w.SoakResult = &SoakResult{}
+	start := time.Now()
+
+	for soakCtx.Err() == nil {
+		offset := time.Since(start)
+		intervalMetrics := w.runPacedPhase(soakCtx, PhaseMain, w.SoakConfig.RPS, time.Duration(w.SoakConfig.Interval))
+
+		interval := &SoakInterval{
+			Offset:        Duration(offset),
+			TotalRequests: intervalMetrics.TotalRequests,
+			ErrorRate:     intervalMetrics.ErrorRate,
+			P95:           intervalMetrics.Percentiles[P95],
+		}
+		w.SoakResult.Intervals = append(w.SoakResult.Intervals, interval)
+
+		w.log.Debug().Msgf("Worker %d soak interval at %s: p95 %s, error rate %.4f",
+			w.ID, offset.Round(time.Second), FormatSeconds(interval.P95), interval.ErrorRate)
+	}
+
+	// The ramp down is left out of the intervals, it would pass for an improvement.
+	w.rampDownPaced(ctx, w.SoakConfig.RPS)
+
+	w.SoakResult.evaluateDrift(w.SoakConfig.DriftThresholdMsPerHour)
+	w.log.Info().Msgf("Worker %d soak drift: %.3f ms/hour (%s)", w.ID, w.SoakResult.DriftMsPerHour, w.SoakResult.Verdict)
+
+	return ctx.Err() == nil
+}
+
+// evaluateDrift fits a least-squares line through the p95 of every interval
+// that had successful requests and compares its slope with the threshold.
+func (r *SoakResult) evaluateDrift(thresholdMsPerHour float64) {
+	var hours, p95Ms []float64
+	for _, interval := range r.Intervals {
+		if interval.TotalRequests == 0 || interval.P95 == 0 {
+			continue
+		}
+		hours = append(hours, time.Duration(interval.Offset).Hours())
+		p95Ms = append(p95Ms, interval.P95*1000)
+	}
+
+	slope, ok := linearSlope(hours, p95Ms)
+	if !ok {
+		r.Verdict = SoakInconclusive
+		return
+	}
+
+	r.DriftMsPerHour = slope
+	if thresholdMsPerHour > 0 && slope > thresholdMsPerHour {
+		r.Verdict = SoakDrifting
+	} else {
+		r.Verdict = SoakStable
+	}
+}
+
+func linearSlope(xs, ys []float64) (float64, bool) {
+	if len(xs) < 2 {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+
+	return covariance / variance, true
+}
@@ -0,0 +1,121 @@
+package entity
+
+import (
+	"context"
+	"time"
+)
+
+// defaultSpikeWindow is the timeline resolution used when SpikeConfig.Window is not set.
+const defaultSpikeWindow = time.Second
+
+// SpikeConfig holds the parameters of the spike mode.
+type SpikeConfig struct {
+	BaselineRPS       float64  `json:"baseline_rps"`
+	SpikeRPS          float64  `json:"spike_rps"`
+	SpikeDuration     Duration `json:"spike_duration"`
+	Interval          Duration `json:"interval"`           // baseline time between two spikes
+	Duration          Duration `json:"duration"`           // of the whole run
+	RecoveryTolerance float64  `json:"recovery_tolerance"` // p95 within this fraction above the baseline p95 counts as recovered
+	Window            Duration `json:"window,omitempty"`   // resolution of the timeline and of the recovery time
+}
+
+// PhaseWindow is one point of the spike timeline.
+type PhaseWindow struct {
+	Phase         Phase    `json:"phase"`
+	Offset        Duration `json:"offset"` // since the start of the run
+	TotalRequests int      `json:"total_requests"`
+	ErrorRate     float64  `json:"error_rate"`
+	P95           float64  `json:"p95"` // in seconds
+}
+
+type SpikeRecovery struct {
+	Spike        int      `json:"spike"` // 1-based
+	Recovered    bool     `json:"recovered"`
+	RecoveryTime Duration `json:"recovery_time,omitempty"` // from the end of the spike
+}
+
+// SpikeResult holds what is specific to a spike run, the baseline and spike
+// aggregates are the PhaseBaseline and PhaseSpike phases of the worker metrics.
+type SpikeResult struct {
+	Recoveries []*SpikeRecovery `json:"recoveries"`
+	Timeline   []*PhaseWindow   `json:"timeline"`
+}
+
+// spike alternates baseline and spike phases and reports whether the run
+// lasted for the whole configured duration rather than being cancelled.
+func (w *Worker) spike(ctx context.Context) bool {
+	spikeCtx, cancel := context.WithTimeout(ctx, time.Duration(w.SpikeConfig.Duration))
+	defer cancel()
+
+	w.SpikeResult = &SpikeResult{}
+	reference := NewMetrics() // the baseline before the first spike
+	start := time.Now()
+
+	for n := 0; spikeCtx.Err() == nil; n++ {
+		if n == 0 {
+			w.runSpikePhase(spikeCtx, start, PhaseBaseline, nil, reference)
+			if err := reference.CalculatePercentiles(P95); err != nil {
+				w.log.Warn().Err(err).Msgf("Worker %d has no baseline p95, recovery times can't be measured", w.ID)
+			}
+		} else {
+			recovery := &SpikeRecovery{Spike: n}
+			w.SpikeResult.Recoveries = append(w.SpikeResult.Recoveries, recovery)
+
+			spikeEnd := time.Now()
+			referenceP95 := reference.Percentiles[P95]
+			w.runSpikePhase(spikeCtx, start, PhaseBaseline, func(window *PhaseWindow) {
+				if recovery.Recovered || referenceP95 == 0 || window.TotalRequests == 0 {
+					return
+				}
+				if window.P95 <= referenceP95*(1+w.SpikeConfig.RecoveryTolerance) {
+					recovery.Recovered = true
+					recovery.RecoveryTime = Duration(time.Since(spikeEnd))
+				}
+			})
+		}
+
+		w.runSpikePhase(spikeCtx, start, PhaseSpike, nil)
+	}
+
+	w.rampDownPaced(ctx, w.SpikeConfig.BaselineRPS)
+
+	return ctx.Err() == nil
+}
+
+// runSpikePhase runs one baseline or spike phase as a sequence of windows,
+// appending each of them to the timeline.
+func (w *Worker) runSpikePhase(ctx context.Context, start time.Time, phase Phase, onWindow func(window *PhaseWindow), sinks ...*Metrics) {
+	rps, duration := w.SpikeConfig.BaselineRPS, time.Duration(w.SpikeConfig.Interval)
+	if phase == PhaseSpike {
+		rps, duration = w.SpikeConfig.SpikeRPS, time.Duration(w.SpikeConfig.SpikeDuration)
+	}
+
+	resolution := time.Duration(w.SpikeConfig.Window)
+	if resolution <= 0 {
+		resolution = defaultSpikeWindow
+	}
+
+	phaseEnd := time.Now().Add(duration)
+	for ctx.Err() == nil {
+		remaining := time.Until(phaseEnd)
+		if remaining <= 0 {
+			break
+		}
+
+		offset := time.Since(start)
+		windowMetrics := w.runPacedPhase(ctx, phase, rps, min(resolution, remaining), sinks...)
+
+		window := &PhaseWindow{
+			Phase:         phase,
+			Offset:        Duration(offset),
+			TotalRequests: windowMetrics.TotalRequests,
+			ErrorRate:     windowMetrics.ErrorRate,
+			P95:           windowMetrics.Percentiles[P95],
+		}
+		w.SpikeResult.Timeline = append(w.SpikeResult.Timeline, window)
+
+		if onWindow != nil {
+			onWindow(window)
+		}
+	}
+}
@@ -7,13 +7,22 @@ const (
 	StatusRunning  Status = "Running"
 	StatusFinished Status = "Finished"
 	StatusFailed   Status = "Failed"
+	// StatusCancelled marks a run stopped on request before it completed.
+	StatusCancelled Status = "Cancelled"
+	// StatusBlocked marks a worker stored while its environment was
+	// disabled. It sends nothing until it is started.
+	StatusBlocked Status = "Blocked"
+
+	// StatusUnderperforming marks a run that completed but did not sustain
+	// the configured share of its target request rate.
+	StatusUnderperforming Status = "Underperforming"
 )
 
 func (w *Worker) SetStatus(s Status) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 	switch s {
-	case StatusCreated, StatusRunning, StatusFinished, StatusFailed:
+	case StatusCreated, StatusRunning, StatusFinished, StatusFailed, StatusCancelled, StatusBlocked, StatusUnderperforming:
 		w.Status = s
 	default:
 		w.log.Error().Msgf("invalid status: %v", s)
@@ -23,3 +32,13 @@ func (w *Worker) SetStatus(s Status) {
 func (w *Worker) GetStatus() Status {
 	return w.Status
 }
+
+// Over reports whether a run with this status has ended, whatever its outcome.
+func (s Status) Over() bool {
+	switch s {
+	case StatusFinished, StatusFailed, StatusCancelled, StatusUnderperforming:
+		return true
+	default:
+		return false
+	}
+}
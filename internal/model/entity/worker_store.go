@@ -0,0 +1,107 @@
+package entity
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+)
+
+// WorkerStore persists the progress and the results of a running worker.
+type WorkerStore interface {
+	UpdateStatus(id int, status Status) error                // ErrNoRecord if the worker was deleted
+	UpdateMetrics(id int, metrics *Metrics) error            // ErrNoRecord if the worker was deleted
+	FinishRun(id int, status Status, metrics *Metrics) error // the status and the metrics in one transaction
+	UpdateRampResult(id int, result *RampResult) error
+	UpdateSoakResult(id int, result *SoakResult) error
+	UpdateSpikeResult(id int, result *SpikeResult) error
+	UpdateAutoTuneResult(id int, result *AutoTuneResult) error
+	UpdateCapturedResponses(id int, responses []*CapturedResponse) error
+	UpdateFirstRequest(id int, request *RenderedRequest) error
+	InsertSamples(id int, samples []LatencySample) error
+	AddWarning(id int, warning string) error
+	UpdateRecordFile(id int, file string) error
+	UpdateDataSource(id int, source *DataSource) error
+}
+
+// deletableStore wraps the store of a running worker whose row may be
+// pruned or deleted before the run is over. Once the row is found missing,
+// the following writes are dropped instead of failing one after another.
+type deletableStore struct {
+	store   WorkerStore
+	worker  *Worker
+	deleted atomic.Bool
+}
+
+func (w *Worker) deletableStore(store WorkerStore) *deletableStore {
+	return &deletableStore{store: store, worker: w}
+}
+
+// write runs a write unless the row is known to be missing. The first
+// ErrNoRecord is logged, it and the following ones aren't returned.
+func (s *deletableStore) write(fn func() error) error {
+	if s.deleted.Load() {
+		return nil
+	}
+
+	err := fn()
+	if !errors.Is(err, custom_errors.ErrNoRecord) {
+		return err
+	}
+	if s.deleted.CompareAndSwap(false, true) {
+		s.worker.log.Warn().Msgf("Worker %d was deleted while running, its results are no longer stored", s.worker.ID)
+	}
+	return nil
+}
+
+func (s *deletableStore) UpdateStatus(id int, status Status) error {
+	return s.write(func() error { return s.store.UpdateStatus(id, status) })
+}
+
+func (s *deletableStore) UpdateMetrics(id int, metrics *Metrics) error {
+	return s.write(func() error { return s.store.UpdateMetrics(id, metrics) })
+}
+
+func (s *deletableStore) FinishRun(id int, status Status, metrics *Metrics) error {
+	return s.write(func() error { return s.store.FinishRun(id, status, metrics) })
+}
+
+func (s *deletableStore) UpdateRampResult(id int, result *RampResult) error {
+	return s.write(func() error { return s.store.UpdateRampResult(id, result) })
+}
+
+func (s *deletableStore) UpdateSoakResult(id int, result *SoakResult) error {
+	return s.write(func() error { return s.store.UpdateSoakResult(id, result) })
+}
+
+func (s *deletableStore) UpdateSpikeResult(id int, result *SpikeResult) error {
+	return s.write(func() error { return s.store.UpdateSpikeResult(id, result) })
+}
+
+func (s *deletableStore) UpdateAutoTuneResult(id int, result *AutoTuneResult) error {
+	return s.write(func() error { return s.store.UpdateAutoTuneResult(id, result) })
+}
+
+func (s *deletableStore) UpdateCapturedResponses(id int, responses []*CapturedResponse) error {
+	return s.write(func() error { return s.store.UpdateCapturedResponses(id, responses) })
+}
+
+func (s *deletableStore) UpdateFirstRequest(id int, request *RenderedRequest) error {
+	return s.write(func() error { return s.store.UpdateFirstRequest(id, request) })
+}
+
+func (s *deletableStore) InsertSamples(id int, samples []LatencySample) error {
+	return s.write(func() error { return s.store.InsertSamples(id, samples) })
+}
+
+func (s *deletableStore) AddWarning(id int, warning string) error {
+	return s.write(func() error { return s.store.AddWarning(id, warning) })
+}
+
+func (s *deletableStore) UpdateRecordFile(id int, file string) error {
+	return s.write(func() error { return s.store.UpdateRecordFile(id, file) })
+}
+
+func (s *deletableStore) UpdateDataSource(id int, source *DataSource) error {
+	return s.write(func() error { return s.store.UpdateDataSource(id, source) })
+}
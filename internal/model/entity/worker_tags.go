@@ -0,0 +1,14 @@
+package entity
+
+import "regexp"
+
+// MaxTags bounds Worker.Tags.
+const MaxTags = 20
+
+// tagPattern is what a tag looks like, e.g. checkout, team:payments or release-2.4.
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]{0,63}$`)
+
+// ValidTag reports whether tag may label a worker.
+func ValidTag(tag string) bool {
+	return tagPattern.MatchString(tag)
+}
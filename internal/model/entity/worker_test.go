@@ -1,5 +1,319 @@
 package entity
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// memoryStore is the WorkerStore of the workers under test, keeping what
+// they store in memory.
+type memoryStore struct {
+	mu                sync.Mutex
+	statuses          []Status
+	metrics           *Metrics
+	rampResult        *RampResult
+	soakResult        *SoakResult
+	spikeResult       *SpikeResult
+	autoTuneResult    *AutoTuneResult
+	capturedResponses []*CapturedResponse
+	firstRequest      *RenderedRequest
+	samples           []LatencySample
+	warnings          []string
+	recordFile        string
+}
+
+func (s *memoryStore) UpdateStatus(_ int, status Status) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses = append(s.statuses, status)
+	return nil
+}
+
+func (s *memoryStore) UpdateMetrics(_ int, metrics *Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = metrics
+	return nil
+}
+
+func (s *memoryStore) FinishRun(_ int, status Status, metrics *Metrics) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses = append(s.statuses, status)
+	s.metrics = metrics
+	return nil
+}
+
+func (s *memoryStore) UpdateRampResult(_ int, result *RampResult) error {
+	s.rampResult = result
+	return nil
+}
+
+func (s *memoryStore) UpdateSoakResult(_ int, result *SoakResult) error {
+	s.soakResult = result
+	return nil
+}
+
+func (s *memoryStore) UpdateSpikeResult(_ int, result *SpikeResult) error {
+	s.spikeResult = result
+	return nil
+}
+
+func (s *memoryStore) UpdateAutoTuneResult(_ int, result *AutoTuneResult) error {
+	s.autoTuneResult = result
+	return nil
+}
+
+func (s *memoryStore) UpdateCapturedResponses(_ int, responses []*CapturedResponse) error {
+	s.capturedResponses = responses
+	return nil
+}
+
+func (s *memoryStore) UpdateFirstRequest(_ int, request *RenderedRequest) error {
+	s.firstRequest = request
+	return nil
+}
+
+func (s *memoryStore) InsertSamples(_ int, samples []LatencySample) error {
+	s.samples = append(s.samples, samples...)
+	return nil
+}
+
+func (s *memoryStore) AddWarning(_ int, warning string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = append(s.warnings, warning)
+	return nil
+}
+
+func (s *memoryStore) UpdateRecordFile(_ int, file string) error {
+	s.recordFile = file
+	return nil
+}
+
+func (s *memoryStore) UpdateDataSource(int, *DataSource) error {
+	return nil
+}
+
+// finalStatus returns the last status stored.
+func (s *memoryStore) finalStatus() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.statuses) == 0 {
+		return ""
+	}
+	return s.statuses[len(s.statuses)-1]
+}
+
+// newTestWorker returns a worker sending GET requests to endpoint without
+// pausing between them.
+func newTestWorker(endpoint string, concurrency, requestsPerTask int, options ...WorkerOption) *Worker {
+	environment := NewEnvironment("test", endpoint)
+	options = append([]WorkerOption{WithWorkerThinkTime(0, 0)}, options...)
+	return NewWorker(1, concurrency, requestsPerTask, http.MethodGet, nil, environment, zerolog.Nop(), options...)
+}
+
+// runWorker runs worker until its run is over and returns what it stored.
+func runWorker(ctx context.Context, worker *Worker) *memoryStore {
+	store := &memoryStore{}
+	worker.Start(ctx, &sync.WaitGroup{}, store)
+	return store
+}
+
+func TestUnderperformingRun(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	tests := []struct {
+		name      string
+		targetRPS float64
+		want      Status
+	}{
+		// A single goroutine waiting 20ms a response sends at most 50 req/s.
+		{name: "target out of reach", targetRPS: 1000, want: StatusUnderperforming},
+		{name: "target sustained", targetRPS: 5, want: StatusFinished},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := newTestWorker(slow.URL, 1, 5, WithWorkerThroughputSLA(tt.targetRPS, 0.9))
+			store := runWorker(context.Background(), worker)
+
+			if got := store.finalStatus(); got != tt.want {
+				t.Errorf("status = %s, want %s", got, tt.want)
+			}
+			if got := worker.Metrics.TotalRequests; got != 5 {
+				t.Errorf("total requests = %d, want 5", got)
+			}
+		})
+	}
+}
+
+func TestRunWithoutSLA(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer slow.Close()
+
+	worker := newTestWorker(slow.URL, 1, 3)
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Errorf("status = %s, want %s", got, StatusFinished)
+	}
+}
+func TestCancelMidFlight(t *testing.T) {
+	arrived := make(chan struct{}, 4)
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer hanging.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for i := 0; i < 4; i++ {
+			<-arrived
+		}
+		cancel()
+	}()
+
+	worker := newTestWorker(hanging.URL, 4, 3)
+	store := runWorker(ctx, worker)
+
+	if got := store.finalStatus(); got != StatusCancelled {
+		t.Errorf("status = %s, want %s", got, StatusCancelled)
+	}
+	if got := worker.Metrics.CancelledRequests; got != 4 {
+		t.Errorf("cancelled requests = %d, want 4", got)
+	}
+	if worker.Metrics.FailedRequests != 0 || worker.Metrics.ErrorRate != 0 {
+		t.Errorf("failed requests = %d and error rate = %v, want the cancelled requests left out of both",
+			worker.Metrics.FailedRequests, worker.Metrics.ErrorRate)
+	}
+}
+
+func TestCancelAbortsSlowRequest(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(10 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	runWorker(ctx, newTestWorker(slow.URL, 1, 1))
+
+	// The response would take 10s, the request is aborted as soon as the run is cancelled.
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("run took %s after being cancelled at 50ms", elapsed)
+	}
+}
+
+// recordedRequest is the method and the body of a request received by a
+// recordingServer.
+type recordedRequest struct {
+	method string
+	body   string
+}
+
+// recordingServer returns a stub keeping the method and the body of every
+// request it receives.
+func recordingServer(t *testing.T) (*httptest.Server, func() []recordedRequest) {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		requests []recordedRequest
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, recordedRequest{method: r.Method, body: string(body)})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []recordedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedRequest(nil), requests...)
+	}
+}
+
+func TestPostBody(t *testing.T) {
+	server, received := recordingServer(t)
+	body := json.RawMessage(`{"name":"test"}`)
+
+	worker := NewWorker(1, 2, 3, http.MethodPost, &body, NewEnvironment("test", server.URL), zerolog.Nop(), WithWorkerThinkTime(0, 0))
+	store := runWorker(context.Background(), worker)
+
+	if got := store.finalStatus(); got != StatusFinished {
+		t.Errorf("status = %s, want %s", got, StatusFinished)
+	}
+	requests := received()
+	if len(requests) != 6 {
+		t.Fatalf("%d requests received, want 6", len(requests))
+	}
+	for _, request := range requests {
+		if request.method != http.MethodPost || request.body != string(body) {
+			t.Errorf("request = %s %q, want %s %q", request.method, request.body, http.MethodPost, body)
+		}
+	}
+}
+
+// countingWriter counts the bytes logged and drops them.
+type countingWriter struct {
+	n atomic.Int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// BenchmarkRequestLogging sends requests with the debug logs on, every per
+// request event being logged or 1 in rate of them.
+func BenchmarkRequestLogging(b *testing.B) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer stub.Close()
+
+	for _, rate := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("1 in %d", rate), func(b *testing.B) {
+			logged := &countingWriter{}
+			log := zerolog.New(logged).Level(zerolog.DebugLevel).With().Timestamp().Logger()
+			environment := NewEnvironment("bench", stub.URL)
+			worker := NewWorker(1, 4, max(b.N/4, 1), http.MethodGet, nil, environment, log,
+				WithWorkerThinkTime(0, 0), WithWorkerLogSampleRate(rate))
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			worker.Start(context.Background(), &sync.WaitGroup{}, &memoryStore{})
+
+			b.ReportMetric(float64(logged.n.Load())/float64(b.N), "logged-B/op")
+		})
+	}
+}
+
 // func BenchmarkChannelApproach(b *testing.B) {
 // 	env := &Environment{
 // 		ID:             8,
@@ -45,3 +359,48 @@ package entity
 // 		})
 // 	}
 // }
+
+func TestSupportedMethods(t *testing.T) {
+	body := json.RawMessage(`{"name":"test"}`)
+	variants := []BodyVariant{{Name: "only", Body: json.RawMessage(`{"name":"variant"}`)}}
+
+	for _, method := range SupportedHTTPMethods {
+		for _, withVariants := range []bool{false, true} {
+			name := method
+			var options []WorkerOption
+			wantBody := string(body)
+			if withVariants {
+				name += " with variants"
+				options = append(options, WithWorkerBodyVariants(variants, VariantRoundRobin))
+				wantBody = string(variants[0].Body)
+			}
+			// GET and HEAD go without a body, whatever the worker configures.
+			if !sendsBody(method) {
+				wantBody = ""
+			}
+
+			t.Run(name, func(t *testing.T) {
+				server, received := recordingServer(t)
+				options = append([]WorkerOption{WithWorkerThinkTime(0, 0)}, options...)
+				worker := NewWorker(1, 2, 3, method, &body, NewEnvironment("test", server.URL), zerolog.Nop(), options...)
+				store := runWorker(context.Background(), worker)
+
+				if got := store.finalStatus(); got != StatusFinished {
+					t.Errorf("status = %s, want %s", got, StatusFinished)
+				}
+				if worker.Metrics.TotalRequests != 6 || worker.Metrics.FailedRequests != 0 {
+					t.Errorf("total and failed requests = %d and %d, want 6 and 0", worker.Metrics.TotalRequests, worker.Metrics.FailedRequests)
+				}
+				requests := received()
+				if len(requests) != 6 {
+					t.Fatalf("%d requests received, want 6", len(requests))
+				}
+				for _, request := range requests {
+					if request.method != method || request.body != wantBody {
+						t.Errorf("request = %s %q, want %s %q", request.method, request.body, method, wantBody)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+)
+
+// RequestTimeouts bounds every request of a worker, a timeout of 0 not
+// applying. The total timeout catches a response that is slow as a whole,
+// the idle one a response that stalls, however fast it started.
+type RequestTimeouts struct {
+	Total Duration `json:"total,omitempty"` // from sending the request to the end of the response body
+	Idle  Duration `json:"idle,omitempty"`  // without a byte of the response body being received
+}
+
+// WithDefaultTimeout returns a copy of input bounding its requests by the
+// default total timeout, the one of environment or else global, when the
+// input sets none. The timeout the input sets wins over the one of its
+// environment, which wins over the global one. Input is returned as is
+// when it sets a total timeout or no default applies.
+func WithDefaultTimeout(input *Worker, environment *Environment, global time.Duration) (*Worker, error) {
+	if input.Timeouts != nil && input.Timeouts.Total > 0 {
+		return input, nil
+	}
+
+	timeout, source := environment.DefaultTimeout, SourceEnvironment
+	if timeout <= 0 {
+		timeout, source = Duration(global), ""
+	}
+	if timeout <= 0 {
+		return input, nil
+	}
+
+	merged, err := copyInput(input)
+	if err != nil {
+		return nil, err
+	}
+	merged.Provenance = input.Provenance
+	if merged.Timeouts == nil {
+		merged.Timeouts = &RequestTimeouts{}
+		if source != "" {
+			merged.Provenance = maps.Clone(input.Provenance)
+			if merged.Provenance == nil {
+				merged.Provenance = make(Provenance)
+			}
+			merged.Provenance["timeouts"] = source
+		}
+	}
+	merged.Timeouts.Total = timeout
+	return merged, nil
+}
+
+var (
+	errTotalTimeout = errors.New("total request timeout")
+	errIdleTimeout  = errors.New("idle read timeout")
+)
+
+// requestDeadline ends the context of a single request once one of the
+// timeouts of the worker fires, the timeout being its cause.
+type requestDeadline struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	total  *time.Timer
+	idle   time.Duration
+}
+
+// withTimeouts binds req to a context ended by the timeouts of the worker.
+// The returned deadline, nil without timeouts, is stopped once the response
+// body is read.
+func (w *Worker) withTimeouts(req *http.Request) (*http.Request, *requestDeadline) {
+	if w.Timeouts == nil {
+		return req, nil
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	deadline := &requestDeadline{ctx: ctx, cancel: cancel, idle: time.Duration(w.Timeouts.Idle)}
+	if w.Timeouts.Total > 0 {
+		deadline.total = time.AfterFunc(time.Duration(w.Timeouts.Total), func() { cancel(errTotalTimeout) })
+	}
+	return req.WithContext(ctx), deadline
+}
+
+// watch returns body, read under the idle timeout.
+func (d *requestDeadline) watch(body io.ReadCloser) io.ReadCloser {
+	if d == nil || d.idle <= 0 {
+		return body
+	}
+	return &idleReader{
+		ReadCloser: body,
+		timer:      time.AfterFunc(d.idle, func() { d.cancel(errIdleTimeout) }),
+		idle:       d.idle,
+	}
+}
+
+// idleReader pushes the idle timeout back every time it receives bytes.
+type idleReader struct {
+	io.ReadCloser
+	timer *time.Timer
+	idle  time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.timer.Reset(r.idle)
+	}
+	return n, err
+}
+
+func (r *idleReader) Close() error {
+	r.timer.Stop()
+	return r.ReadCloser.Close()
+}
+
+// timeoutClass returns the class of the timeout that ended the request, if one did.
+func (d *requestDeadline) timeoutClass() (ErrorClass, bool) {
+	if d == nil {
+		return "", false
+	}
+
+	switch context.Cause(d.ctx) {
+	case errTotalTimeout:
+		return ErrorClassTotalTimeout, true
+	case errIdleTimeout:
+		return ErrorClassIdleTimeout, true
+	}
+	return "", false
+}
+
+// classify is classifyError, telling apart the timeouts of the worker.
+func (d *requestDeadline) classify(err error) ErrorClass {
+	if class, timedOut := d.timeoutClass(); timedOut {
+		return class
+	}
+	return classifyError(err)
+}
+
+func (d *requestDeadline) stop() {
+	if d == nil {
+		return
+	}
+	if d.total != nil {
+		d.total.Stop()
+	}
+	d.cancel(nil)
+}
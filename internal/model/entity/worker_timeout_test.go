@@ -0,0 +1,161 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// pause waits for d, reporting false if the client went away meanwhile.
+func pause(r *http.Request, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	handlers := map[string]http.HandlerFunc{
+		// Starts its body right away, then stalls in the middle of it.
+		"stall": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(make([]byte, 1024))
+			w.(http.Flusher).Flush()
+			if pause(r, 500*time.Millisecond) {
+				_, _ = w.Write(make([]byte, 1024))
+			}
+		},
+		// Drips its body, never pausing long but slow as a whole.
+		"drip": func(w http.ResponseWriter, r *http.Request) {
+			for i := 0; i < 20; i++ {
+				_, _ = w.Write(make([]byte, 16))
+				w.(http.Flusher).Flush()
+				if !pause(r, 30*time.Millisecond) {
+					return
+				}
+			}
+		},
+		// Slow to answer at all.
+		"slow": func(w http.ResponseWriter, r *http.Request) {
+			pause(r, 500*time.Millisecond)
+		},
+		"fast": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(make([]byte, 1024))
+		},
+	}
+
+	timeouts := &RequestTimeouts{Total: Duration(250 * time.Millisecond), Idle: Duration(100 * time.Millisecond)}
+	tests := []struct {
+		name      string
+		handler   string
+		timeouts  *RequestTimeouts
+		wantClass ErrorClass
+	}{
+		{name: "stall", handler: "stall", timeouts: timeouts, wantClass: ErrorClassIdleTimeout},
+		{name: "drip", handler: "drip", timeouts: timeouts, wantClass: ErrorClassTotalTimeout},
+		{name: "slow headers", handler: "slow", timeouts: timeouts, wantClass: ErrorClassTotalTimeout},
+		{name: "fast", handler: "fast", timeouts: timeouts},
+		// Either timeout alone still fires.
+		{name: "stall with an idle timeout only", handler: "stall", timeouts: &RequestTimeouts{Idle: timeouts.Idle}, wantClass: ErrorClassIdleTimeout},
+		{name: "drip with a total timeout only", handler: "drip", timeouts: &RequestTimeouts{Total: timeouts.Total}, wantClass: ErrorClassTotalTimeout},
+		{name: "drip with an idle timeout only", handler: "drip", timeouts: &RequestTimeouts{Idle: timeouts.Idle}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := httptest.NewServer(handlers[test.handler])
+			defer stub.Close()
+
+			worker := newTestWorker(stub.URL, 1, 2, WithWorkerTimeouts(test.timeouts))
+			runWorker(context.Background(), worker)
+
+			metrics := worker.Metrics
+			wantFailed := 0
+			if test.wantClass != "" {
+				wantFailed = 2
+			}
+			if metrics.FailedRequests != wantFailed {
+				t.Errorf("failed requests = %d, want %d (errors %v)", metrics.FailedRequests, wantFailed, metrics.ErrorClasses)
+			}
+			if test.wantClass != "" && metrics.ErrorClasses[test.wantClass] != 2 {
+				t.Errorf("errors = %v, want 2 of %s", metrics.ErrorClasses, test.wantClass)
+			}
+		})
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	const (
+		own         = Duration(2 * time.Second)
+		environment = Duration(5 * time.Second)
+		global      = 10 * time.Second
+	)
+
+	tests := []struct {
+		name        string
+		timeouts    *RequestTimeouts
+		environment Duration
+		global      time.Duration
+		want        *RequestTimeouts
+		provenance  SettingSource
+	}{
+		{name: "inherited from the environment", environment: environment, global: global, want: &RequestTimeouts{Total: environment}, provenance: SourceEnvironment},
+		{name: "overridden by the worker", timeouts: &RequestTimeouts{Total: own}, environment: environment, global: global, want: &RequestTimeouts{Total: own}},
+		{name: "global fallback", global: global, want: &RequestTimeouts{Total: Duration(global)}},
+		{name: "none", want: nil},
+		{
+			name:        "alongside an idle timeout",
+			timeouts:    &RequestTimeouts{Idle: own},
+			environment: environment,
+			want:        &RequestTimeouts{Total: environment, Idle: own},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := &Worker{Timeouts: test.timeouts}
+			var before RequestTimeouts
+			if test.timeouts != nil {
+				before = *test.timeouts
+			}
+			merged, err := WithDefaultTimeout(input, NewEnvironment("staging", "http://staging.invalid", WithEnvironmentDefaultTimeout(test.environment)), test.global)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if (merged.Timeouts == nil) != (test.want == nil) || merged.Timeouts != nil && *merged.Timeouts != *test.want {
+				t.Errorf("timeouts = %+v, want %+v", merged.Timeouts, test.want)
+			}
+			if got := merged.Provenance["timeouts"]; got != test.provenance {
+				t.Errorf("provenance of timeouts = %q, want %q", got, test.provenance)
+			}
+			// Campaigns reuse the input across environments.
+			if input.Timeouts != test.timeouts || input.Provenance != nil || test.timeouts != nil && *test.timeouts != before {
+				t.Error("the input was modified")
+			}
+		})
+	}
+}
+
+func TestDefaultTimeoutFromWorkerDefaults(t *testing.T) {
+	// A total timeout among the default worker settings counts as the worker's own.
+	environment := NewEnvironment("staging", "http://staging.invalid",
+		WithEnvironmentDefaultTimeout(Duration(5*time.Second)),
+		WithEnvironmentWorkerDefaults(WorkerDefaults{"timeouts": []byte(`{"total": "1s"}`)}),
+	)
+
+	input, err := environment.WorkerDefaults.Apply(&Worker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := WithDefaultTimeout(input, environment, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Timeouts == nil || merged.Timeouts.Total != Duration(time.Second) {
+		t.Errorf("timeouts = %+v, want the 1s of the default worker settings", merged.Timeouts)
+	}
+}
@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Stage is a step of a single request, as reported by httptrace.
+type Stage string
+
+const (
+	StageDNS      Stage = "dns"
+	StageConnect  Stage = "connect"
+	StageTLS      Stage = "tls"
+	StageTTFB     Stage = "ttfb" // from the request being written to the first response byte
+	StageTransfer Stage = "transfer"
+)
+
+// stages lists the stages in the order they happen, which is the order of the breakdown.
+var stages = []Stage{StageDNS, StageConnect, StageTLS, StageTTFB, StageTransfer}
+
+// requestTiming collects the httptrace timestamps of a single request. The
+// callbacks may run on the dialing goroutines, hence the lock.
+type requestTiming struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	bodyRead     time.Time
+}
+
+// trace records the latency of the DNS lookups done while sending req, if
+// any, the address of the backend the request went to and the timestamps
+// of every stage of the request.
+func (w *Worker) trace(req *http.Request, metrics []*Metrics) (*http.Request, *requestTiming) {
+	timing := &requestTiming{}
+	mark := func(field *time.Time) {
+		timing.mu.Lock()
+		defer timing.mu.Unlock()
+		if field.IsZero() {
+			*field = time.Now()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mark(&timing.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mark(&timing.dnsDone)
+			timing.mu.Lock()
+			latency := timing.dnsDone.Sub(timing.dnsStart)
+			timing.mu.Unlock()
+			for _, m := range metrics {
+				m.AddDNSLatency(latency)
+			}
+		},
+		// With several addresses the dialer may race connections, the first start and the first success are kept.
+		ConnectStart: func(string, string) {
+			mark(&timing.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				mark(&timing.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mark(&timing.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				mark(&timing.tlsDone)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			// Reused connections are reported as well, so the counts are per request rather than per connection.
+			address := info.Conn.RemoteAddr().String()
+			for _, m := range metrics {
+				m.AddResolvedAddress(address)
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mark(&timing.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			mark(&timing.firstByte)
+		},
+	}
+
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace)), timing
+}
+
+// finish marks the response body as fully read and returns the duration of
+// every stage, 0 for the ones skipped on a reused connection.
+func (t *requestTiming) finish() map[Stage]time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.bodyRead = time.Now()
+	between := func(start, end time.Time) time.Duration {
+		if start.IsZero() || end.IsZero() || end.Before(start) {
+			return 0
+		}
+		return end.Sub(start)
+	}
+
+	return map[Stage]time.Duration{
+		StageDNS:      between(t.dnsStart, t.dnsDone),
+		StageConnect:  between(t.connectStart, t.connectDone),
+		StageTLS:      between(t.tlsStart, t.tlsDone),
+		StageTTFB:     between(t.wroteRequest, t.firstByte),
+		StageTransfer: between(t.firstByte, t.bodyRead),
+	}
+}
@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBreakdown(t *testing.T) {
+	const (
+		headerDelay = 40 * time.Millisecond
+		bodyDelay   = 30 * time.Millisecond
+	)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(headerDelay)
+		w.Write([]byte("first half,"))
+		w.(http.Flusher).Flush()
+		time.Sleep(bodyDelay)
+		w.Write([]byte("second half"))
+	}))
+	defer stub.Close()
+
+	worker := newTestWorker(stub.URL, 1, 4)
+	runWorker(context.Background(), worker)
+
+	timings := make(map[Stage]StageTiming)
+	for _, timing := range worker.Metrics.Breakdown {
+		timings[timing.Stage] = timing
+	}
+
+	tests := []struct {
+		stage    Stage
+		min, max time.Duration
+	}{
+		{stage: StageTTFB, min: headerDelay, max: headerDelay + bodyDelay},
+		{stage: StageTransfer, min: bodyDelay, max: headerDelay + bodyDelay},
+		// A single connection to a local stub, reused after the first request.
+		{stage: StageConnect, max: 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		timing, ok := timings[tt.stage]
+		if !ok {
+			t.Errorf("stage %s missing from the breakdown %+v", tt.stage, worker.Metrics.Breakdown)
+			continue
+		}
+		mean := time.Duration(timing.Mean * float64(time.Second))
+		if mean < tt.min || mean > tt.max {
+			t.Errorf("mean of %s = %s, want between %s and %s", tt.stage, mean, tt.min, tt.max)
+		}
+		if timing.P95 < timing.Mean {
+			t.Errorf("p95 of %s = %v, below its mean %v", tt.stage, timing.P95, timing.Mean)
+		}
+	}
+
+	// The breakdown keeps the order the stages happen in.
+	for i := 1; i < len(worker.Metrics.Breakdown); i++ {
+		if stageIndex(worker.Metrics.Breakdown[i].Stage) < stageIndex(worker.Metrics.Breakdown[i-1].Stage) {
+			t.Errorf("breakdown out of order: %+v", worker.Metrics.Breakdown)
+			break
+		}
+	}
+}
+
+func stageIndex(stage Stage) int {
+	for i, s := range stages {
+		if s == stage {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// FirstURL returns where the first request of the worker is sent, the
+// first step of its scenario or the endpoint of its environment.
+func (w *Worker) FirstURL() (string, error) {
+	if w.Scenario != nil && len(w.Scenario.Steps) > 0 {
+		return w.stepURL(w.Scenario.Steps[0])
+	}
+	return w.Environment.Endpoint, nil
+}
+
+// URLWarnings describes the URLs of the worker that differ from what
+// pasting the endpoint and the paths together would give, which send the
+// load to a route other than the intended one.
+func (w *Worker) URLWarnings() []string {
+	endpoint, err := url.Parse(w.Environment.Endpoint)
+	if err != nil {
+		return []string{fmt.Sprintf("the endpoint %s of the environment isn't a valid URL: %s", w.Environment.Endpoint, err)}
+	}
+
+	var warnings []string
+	if strings.Contains(endpoint.Path, "//") {
+		warnings = append(warnings, fmt.Sprintf("the path of the endpoint %s has an empty segment, the target may route the double slash elsewhere", w.Environment.Endpoint))
+	}
+
+	if w.Scenario == nil || !w.Scenario.Rebase {
+		return warnings
+	}
+
+	basePath := strings.TrimRight(endpoint.Path, "/")
+	var joined []int
+	for i, step := range w.Scenario.Steps {
+		target, err := url.Parse(step.URL)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("step %d of the scenario has an invalid URL: %s", i+1, err))
+			continue
+		}
+		if basePath != "" && !hasPathPrefix(target.Path, basePath) {
+			joined = append(joined, i)
+		}
+	}
+
+	if len(joined) > 0 {
+		first := w.Scenario.Steps[joined[0]]
+		rebased, _ := rebaseURL(w.Environment.Endpoint, first.URL)
+		warnings = append(warnings, fmt.Sprintf("%d steps of the scenario don't start with the base path %s of the endpoint and are sent under it, e.g. step %d %s is sent to %s", len(joined), basePath, joined[0]+1, first.URL, rebased))
+	}
+	return warnings
+}
@@ -0,0 +1,98 @@
+package entity
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRebaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		target   string
+		want     string
+	}{
+		{name: "root endpoint", endpoint: "https://stg", target: "https://prod/users?page=2", want: "https://stg/users?page=2"},
+		{name: "root endpoint with slash", endpoint: "https://stg/", target: "https://prod/users", want: "https://stg/users"},
+		{name: "base path", endpoint: "https://stg/api", target: "https://prod/users", want: "https://stg/api/users"},
+		{name: "base path with slash", endpoint: "https://stg/api/", target: "https://prod/users", want: "https://stg/api/users"},
+		{name: "already under the base path", endpoint: "https://stg/api", target: "https://prod/api/users", want: "https://stg/api/users"},
+		{name: "the base path itself", endpoint: "https://stg/api", target: "https://prod/api", want: "https://stg/api"},
+		{name: "look-alike prefix", endpoint: "https://stg/api", target: "https://prod/apiv2/users", want: "https://stg/api/apiv2/users"},
+		{name: "empty step path", endpoint: "https://stg/api", target: "https://prod", want: "https://stg/api"},
+		{name: "trailing slash of the step", endpoint: "https://stg/api", target: "https://prod/users/", want: "https://stg/api/users/"},
+		{name: "escaped segment", endpoint: "https://stg/api", target: "https://prod/files/a%2Fb", want: "https://stg/api/files/a%2Fb"},
+		{name: "credentials of the endpoint", endpoint: "https://u:p@stg", target: "https://other@prod/users", want: "https://u:p@stg/users"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := rebaseURL(test.endpoint, test.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("rebaseURL(%q, %q) = %q, want %q", test.endpoint, test.target, got, test.want)
+			}
+		})
+	}
+}
+
+func TestURLWarnings(t *testing.T) {
+	steps := func(urls ...string) *Scenario {
+		scenario := &Scenario{Rebase: true}
+		for _, u := range urls {
+			scenario.Steps = append(scenario.Steps, &ScenarioStep{Method: "GET", URL: u})
+		}
+		return scenario
+	}
+
+	tests := []struct {
+		name     string
+		endpoint string
+		scenario *Scenario
+		want     []string // substrings of the only warning, if any
+	}{
+		{name: "plain endpoint", endpoint: "https://stg/api"},
+		{name: "empty segment", endpoint: "https://stg/api//v1", want: []string{"empty segment"}},
+		{name: "steps under the base path", endpoint: "https://stg/api", scenario: steps("https://prod/api/a", "https://prod/api/b")},
+		{
+			name:     "steps joined to the base path",
+			endpoint: "https://stg/api",
+			scenario: steps("https://prod/api/a", "https://prod/b", "https://prod/c"),
+			want:     []string{"2 steps", "step 2 https://prod/b is sent to https://stg/api/b"},
+		},
+		{
+			name:     "steps not rebased",
+			endpoint: "https://stg/api",
+			scenario: &Scenario{Steps: []*ScenarioStep{{Method: "GET", URL: "https://prod/b"}}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			worker := &Worker{Environment: NewEnvironment("stg", test.endpoint), Scenario: test.scenario}
+			warnings := worker.URLWarnings()
+			if len(warnings) != min(len(test.want), 1) {
+				t.Fatalf("warnings = %q, want %q", warnings, test.want)
+			}
+			for _, want := range test.want {
+				if !strings.Contains(warnings[0], want) {
+					t.Errorf("warning %q doesn't mention %q", warnings[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestFirstURL(t *testing.T) {
+	worker := &Worker{Environment: NewEnvironment("stg", "https://stg/api")}
+	if got, _ := worker.FirstURL(); got != "https://stg/api" {
+		t.Errorf("first URL without a scenario = %q, want the endpoint", got)
+	}
+
+	worker.Scenario = &Scenario{Rebase: true, Steps: []*ScenarioStep{{Method: "GET", URL: "https://prod/users"}}}
+	if got, _ := worker.FirstURL(); got != "https://stg/api/users" {
+		t.Errorf("first URL of a rebased scenario = %q, want https://stg/api/users", got)
+	}
+}
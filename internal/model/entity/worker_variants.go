@@ -0,0 +1,105 @@
+package entity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// MaxBodyVariants bounds the variants a worker cycles through.
+const MaxBodyVariants = 100
+
+// VariantSelection is how the body of every request is picked among the variants.
+type VariantSelection string
+
+const (
+	VariantRoundRobin VariantSelection = "round_robin"
+	VariantRandom     VariantSelection = "random"
+)
+
+// BodyVariant is one of the fixed payloads a worker cycles through.
+type BodyVariant struct {
+	Name string          `json:"name"`
+	Body json.RawMessage `json:"body"`
+}
+
+type variantKey struct{}
+
+// bodyVariants picks the variant of every request and keeps the metrics of each of them.
+type bodyVariants struct {
+	variants  []BodyVariant
+	selection VariantSelection
+	next      atomic.Uint64
+	rands     *goroutineRands // of VariantRandom
+	metrics   map[string]*Metrics
+}
+
+func newBodyVariants(variants []BodyVariant, selection VariantSelection, rands *goroutineRands) *bodyVariants {
+	b := &bodyVariants{
+		variants:  variants,
+		selection: selection,
+		rands:     rands,
+		metrics:   make(map[string]*Metrics, len(variants)),
+	}
+	for _, variant := range variants {
+		b.metrics[variant.Name] = NewMetrics()
+	}
+	return b
+}
+
+// pick returns the variant of the next request of the goroutine with the
+// given index.
+func (b *bodyVariants) pick(index int) *BodyVariant {
+	if b.selection == VariantRandom {
+		return &b.variants[b.rands.get(index).Intn(len(b.variants))]
+	}
+	return &b.variants[(b.next.Add(1)-1)%uint64(len(b.variants))]
+}
+
+// withVariant sets the body of req to the next variant, remembering which
+// one it was so the outcome can be recorded against it.
+func (b *bodyVariants) withVariant(req *http.Request) *http.Request {
+	variant := b.pick(goroutineIndex(req.Context()))
+	req.Body = http.NoBody
+	if len(variant.Body) > 0 {
+		body := []byte(variant.Body)
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		req.Body, _ = req.GetBody()
+	}
+	return req.WithContext(context.WithValue(req.Context(), variantKey{}, variant.Name))
+}
+
+// variantMetrics returns the metrics of the variant req was sent with, nil without variants.
+func (w *Worker) variantMetrics(req *http.Request) *Metrics {
+	if w.variants == nil {
+		return nil
+	}
+	name, _ := req.Context().Value(variantKey{}).(string)
+	return w.variants.metrics[name]
+}
+
+// calculateVariants computes the metrics of every variant once the run is over.
+func (w *Worker) calculateVariants(elapsed time.Duration) {
+	if w.variants == nil {
+		return
+	}
+
+	variants := make(map[string]*Metrics, len(w.variants.metrics))
+	for name, m := range w.variants.metrics {
+		m.CalculateMaxLatency()
+		m.CalculateErrorRate()
+		m.CalculateThroughput(elapsed)
+		if err := m.CalculatePercentiles(P50, P95, P99); err != nil {
+			w.log.Debug().Err(err).Msgf("Error calculating the percentiles of variant %s", name)
+		}
+		variants[name] = m
+	}
+	w.Metrics.SetVariants(variants)
+}
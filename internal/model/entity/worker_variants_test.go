@@ -0,0 +1,68 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestVariantDistribution(t *testing.T) {
+	variants := []BodyVariant{
+		{Name: "small", Body: json.RawMessage(`{"size":"small"}`)},
+		{Name: "medium", Body: json.RawMessage(`{"size":"medium"}`)},
+		{Name: "large", Body: json.RawMessage(`{"size":"large"}`)},
+	}
+
+	tests := []struct {
+		name      string
+		selection VariantSelection
+		tolerance int // of the requests per variant
+	}{
+		{name: "round robin", selection: VariantRoundRobin},
+		// 120 requests drawn from 3 variants, about 5 standard deviations.
+		{name: "random", selection: VariantRandom, tolerance: 25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				mu       sync.Mutex
+				received = make(map[string]int)
+			)
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				received[string(body)]++
+				mu.Unlock()
+			}))
+			defer stub.Close()
+
+			// The variants are bodies, left out of GET requests.
+			environment := NewEnvironment("test", stub.URL)
+			worker := NewWorker(1, 4, 30, http.MethodPost, nil, environment, zerolog.Nop(),
+				WithWorkerThinkTime(0, 0), WithWorkerBodyVariants(variants, tt.selection), WithWorkerSeed(1))
+			runWorker(context.Background(), worker)
+
+			const want = 40 // 120 requests evenly spread over 3 variants
+			for _, variant := range variants {
+				if got := received[string(variant.Body)]; got < want-tt.tolerance || got > want+tt.tolerance {
+					t.Errorf("variant %s received %d times, want %d±%d", variant.Name, got, want, tt.tolerance)
+				}
+				metrics := worker.Metrics.Variants[variant.Name]
+				if metrics == nil {
+					t.Errorf("no metrics for variant %s", variant.Name)
+					continue
+				}
+				if metrics.TotalRequests != received[string(variant.Body)] {
+					t.Errorf("variant %s counted %d requests, received %d", variant.Name, metrics.TotalRequests, received[string(variant.Body)])
+				}
+			}
+		})
+	}
+}
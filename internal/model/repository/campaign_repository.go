@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/tasty-byte/pkg/transactions"
+)
+
+type CampaignRepository interface {
+	Insert() (int, error)
+	Get(id int) (*entity.Campaign, error)
+}
+
+type CampaignRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewCampaignRepositoryDB(db *sql.DB) *CampaignRepositoryDB {
+	return &CampaignRepositoryDB{
+		DB: db,
+	}
+}
+
+// Insert stores an empty campaign, its workers are linked to it as they are inserted.
+func (m *CampaignRepositoryDB) Insert() (int, error) {
+	var campaignID int
+
+	err := withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		INSERT INTO campaigns (created_at)
+		VALUES (UTC_TIMESTAMP())
+		`
+		result, err := tx.Exec(stmt)
+		if err != nil {
+			return err
+		}
+
+		campaignID64, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		campaignID = int(campaignID64)
+		return nil
+	})
+
+	return campaignID, err
+}
+
+// Get returns a campaign along with its workers, ordered by id.
+func (m *CampaignRepositoryDB) Get(id int) (*entity.Campaign, error) {
+	campaign := &entity.Campaign{}
+
+	err := withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		SELECT
+			id,
+			created_at
+		FROM
+			campaigns
+		WHERE id = ?
+		`
+		if err := tx.QueryRow(stmt, id).Scan(&campaign.ID, &campaign.CreatedAt); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return custom_errors.ErrNoRecord
+			}
+			return err
+		}
+
+		stmt = `
+		SELECT` + workerColumns + `
+		FROM
+			workers
+		WHERE campaign_id = ?
+		ORDER BY id
+		`
+		rows, err := tx.Query(stmt, id)
+		if err != nil {
+			return err
+		}
+		defer func(rows *sql.Rows) {
+			_ = rows.Close()
+		}(rows)
+
+		for rows.Next() {
+			worker, err := scanWorker(rows)
+			if err != nil {
+				return err
+			}
+			campaign.Workers = append(campaign.Workers, worker)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// Compression is the algorithm the blob columns are written with. The rows
+// are read whatever algorithm they were written with, the uncompressed
+// ones written before the compression included, by the magic number their
+// data starts with.
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// blobColumns are the columns of the workers that are compressed, the ones
+// holding the report, the results of the runs and the captured responses.
+// The latency samples aren't among them: they are rows of latency_samples
+// holding a few numbers each, nothing a compressed blob would shrink, and
+// their columns stay readable by SQL.
+var blobColumns = []string{"report", "captured_responses", "ramp_result", "soak_result", "spike_result", "auto_tune_result"}
+
+// Magic numbers the compressed data starts with, which no JSON document does.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// zstdEncoder and zstdDecoder are shared, both being safe for concurrent
+// use through EncodeAll and DecodeAll.
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+// ParseCompression returns the algorithm named by the config, gzip when empty.
+func ParseCompression(name string) (Compression, error) {
+	switch compression := Compression(name); compression {
+	case "":
+		return CompressionGzip, nil
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("unknown compression %q", name)
+	}
+}
+
+// compress returns data compressed with the algorithm, nil for nil data.
+func (c Compression) compress(data []byte) ([]byte, error) {
+	if data == nil {
+		return nil, nil
+	}
+
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, nil), nil
+	default:
+		var buf bytes.Buffer
+		writer := gzip.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+// decompress returns the data of a blob column, as is when it isn't
+// compressed. It reports whether it was.
+func decompress(data []byte) ([]byte, bool, error) {
+	switch {
+	case bytes.HasPrefix(data, zstdMagic):
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, false, err
+		}
+		raw, err := decoder.DecodeAll(data, nil)
+		return raw, true, err
+	case bytes.HasPrefix(data, gzipMagic):
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, err
+		}
+		raw, err := io.ReadAll(reader)
+		return raw, true, err
+	default:
+		return data, false, nil
+	}
+}
+
+// compression returns the algorithm of the repository, gzip when unset.
+func (m *WorkerRepositoryDB) compression() Compression {
+	if m.Compression == "" {
+		return CompressionGzip
+	}
+	return m.Compression
+}
+
+// marshalBlob returns the JSON of v for a blob column, compressed with the
+// algorithm of the repository.
+func (m *WorkerRepositoryDB) marshalBlob(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return m.compression().compress(data)
+}
+
+// GetStorageStats returns, for every blob column, the bytes stored against
+// the bytes of the JSON they hold. Every row is read, which is only meant
+// for the admins.
+func (m *WorkerRepositoryDB) GetStorageStats() (*entity.StorageStats, error) {
+	stats := make([]*entity.BlobStats, len(blobColumns))
+	for i, column := range blobColumns {
+		stats[i] = &entity.BlobStats{Column: column}
+	}
+
+	rows, err := m.DB.Query(`SELECT ` + strings.Join(blobColumns, ", ") + ` FROM workers`)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	values := make([][]byte, len(blobColumns))
+	dst := make([]any, len(blobColumns))
+	for i := range values {
+		dst[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dst...); err != nil {
+			return nil, err
+		}
+		for i, data := range values {
+			if len(data) == 0 {
+				continue
+			}
+			raw, compressed, err := decompress(data)
+			if err != nil {
+				return nil, fmt.Errorf("decompressing %s: %w", blobColumns[i], err)
+			}
+			stats[i].Add(len(data), len(raw), compressed)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entity.NewStorageStats(string(m.compression()), stats), nil
+}
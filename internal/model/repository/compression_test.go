@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	data := []byte(`{"responses": [` + strings.Repeat(`{"class": "5xx", "body": "upstream timed out"},`, 100) + `{}]}`)
+
+	tests := []struct {
+		compression    Compression
+		wantMagic      []byte
+		wantCompressed bool
+	}{
+		{compression: CompressionNone, wantMagic: []byte("{")},
+		{compression: CompressionGzip, wantMagic: gzipMagic, wantCompressed: true},
+		{compression: CompressionZstd, wantMagic: zstdMagic, wantCompressed: true},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.compression), func(t *testing.T) {
+			stored, err := test.compression.compress(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasPrefix(stored, test.wantMagic) {
+				t.Errorf("stored data starts with %x, want %x", stored[:min(4, len(stored))], test.wantMagic)
+			}
+			if test.wantCompressed && len(stored) >= len(data)/4 {
+				t.Errorf("compressed %d bytes into %d, want a repetitive document to shrink", len(data), len(stored))
+			}
+
+			raw, compressed, err := decompress(stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if compressed != test.wantCompressed || !bytes.Equal(raw, data) {
+				t.Errorf("read back %d bytes, compressed %t, want the %d bytes written, compressed %t", len(raw), compressed, len(data), test.wantCompressed)
+			}
+		})
+	}
+}
+
+func TestDecompressLegacy(t *testing.T) {
+	// Rows written before the compression hold their JSON as is.
+	for _, legacy := range []string{`[{"class": "2xx"}]`, `null`, ``} {
+		raw, compressed, err := decompress([]byte(legacy))
+		if err != nil || compressed || string(raw) != legacy {
+			t.Errorf("decompress(%q) = %q, %t, %v, want it as is", legacy, raw, compressed, err)
+		}
+	}
+
+	if _, _, err := decompress(append(append([]byte{}, gzipMagic...), 0, 0, 0)); err == nil {
+		t.Error("a corrupt gzip header was read, want an error")
+	}
+
+	if stored, err := CompressionGzip.compress(nil); stored != nil || err != nil {
+		t.Errorf("compressing nil = %v, %v, want nil", stored, err)
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Compression
+		wantErr bool
+	}{
+		{name: "", want: CompressionGzip},
+		{name: "none", want: CompressionNone},
+		{name: "gzip", want: CompressionGzip},
+		{name: "zstd", want: CompressionZstd},
+		{name: "brotli", wantErr: true},
+		{name: "GZIP", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseCompression(test.name)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("ParseCompression(%q) = %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+}
+
+// capturedArg is a sqlmock argument matching anything and keeping it.
+type capturedArg struct {
+	value driver.Value
+}
+
+func (a *capturedArg) Match(value driver.Value) bool {
+	a.value = value
+	return true
+}
+
+// TestCompressedColumns writes captured responses with every algorithm and
+// reads them back, the uncompressed ones being stored like the rows
+// written before the compression.
+func TestCompressedColumns(t *testing.T) {
+	capturedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	responses := []*entity.CapturedResponse{{Class: entity.ResponseClass5xx, StatusCode: 503, Body: "unavailable", CapturedAt: capturedAt}}
+
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd, ""} {
+		t.Run(string(compression), func(t *testing.T) {
+			repo, mock := newWorkerRepository(t)
+			repo.Compression = compression
+
+			written := &capturedArg{}
+			mock.ExpectBegin()
+			mock.ExpectExec(`UPDATE workers\s+SET captured_responses = \?`).
+				WithArgs(written, 7).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectCommit()
+			if err := repo.UpdateCapturedResponses(7, responses); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, _ := written.value.([]byte)
+			if _, compressed, _ := decompress(stored); compressed != (compression != CompressionNone) {
+				t.Errorf("stored %q, compressed %t", stored, compressed)
+			}
+
+			mock.ExpectBegin()
+			mock.ExpectQuery(`FROM\s+workers\s+WHERE id = \?`).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows(workerColumnNames).AddRow(workerRow(7, capturedAt, map[string]driver.Value{"captured_responses": stored})...))
+			mock.ExpectCommit()
+			worker, err := repo.Get(7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(worker.CapturedResponses, responses) {
+				t.Errorf("read back %+v, want %+v", worker.CapturedResponses, responses)
+			}
+		})
+	}
+}
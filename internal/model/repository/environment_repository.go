@@ -2,12 +2,14 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
 	"github.com/vladComan0/performance-analyzer/internal/model/entity"
 	"github.com/vladComan0/tasty-byte/pkg/transactions"
 	"golang.org/x/crypto/bcrypt"
 	"sort"
+	"time"
 )
 
 const COST = 12 // 2^12 bcrypt iterations used to generate the password hash (4-31)
@@ -18,7 +20,13 @@ type EnvironmentRepository interface {
 	Get(id int) (*entity.Environment, error)
 	GetAll() ([]*entity.Environment, error)
 	Update(environment *entity.Environment) error
+	SetBaseline(id, workerID int) error
+	Disable(id int, reason string) error
+	SetAuthVerified(id int, at time.Time) error
+	SetBodySchema(id int, schema *entity.BodySchema) error
+	GetRequestUsage(id int) (string, int, error)
 	Delete(id int) error
+	DeleteDemo() (int, error)
 }
 
 type EnvironmentRepositoryDB struct {
@@ -35,6 +43,8 @@ func (m *EnvironmentRepositoryDB) Ping() error {
 	return m.DB.Ping()
 }
 
+// Insert stores a new environment. When the table has a unique key on the
+// name, a name already taken is reported as ErrDuplicateName.
 func (m *EnvironmentRepositoryDB) Insert(environment *entity.Environment) (int, error) {
 	var (
 		environmentID  int
@@ -49,15 +59,33 @@ func (m *EnvironmentRepositoryDB) Insert(environment *entity.Environment) (int,
 		}
 	}
 
-	err = transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
+	maintenanceWindows, err := marshalMaintenanceWindows(environment.MaintenanceWindows)
+	if err != nil {
+		return 0, err
+	}
+
+	workerDefaults, err := marshalWorkerDefaults(environment.WorkerDefaults)
+	if err != nil {
+		return 0, err
+	}
+
+	autoDisable, err := marshalAutoDisable(environment.AutoDisable)
+	if err != nil {
+		return 0, err
+	}
+
+	err = withTransaction(m.DB, func(tx transactions.Transaction) error {
 		stmt := `
 		INSERT INTO environments 
-			(name, endpoint, token_endpoint, username, password, basic_auth_token, disabled, created_at)
+			(name, endpoint, token_endpoint, username, password, basic_auth_token, disabled, maintenance_windows, maintenance_policy, daily_request_quota, default_worker_settings, default_timeout, demo, auto_disable, created_at)
 		VALUES 
-			(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+			(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
 		`
-		result, err := tx.Exec(stmt, environment.Name, environment.Endpoint, environment.TokenEndpoint, environment.Username, hashedPassword, environment.BasicAuthToken, environment.Disabled)
+		result, err := tx.Exec(stmt, environment.Name, environment.Endpoint, environment.TokenEndpoint, environment.Username, hashedPassword, environment.BasicAuthToken, environment.Disabled, maintenanceWindows, environment.MaintenancePolicy, environment.DailyRequestQuota, workerDefaults, environment.DefaultTimeout, environment.Demo, autoDisable)
 		if err != nil {
+			if isDuplicateEntry(err) {
+				return custom_errors.ErrDuplicateName
+			}
 			return err
 		}
 
@@ -84,6 +112,17 @@ func (m *EnvironmentRepositoryDB) GetAll() ([]*entity.Environment, error) {
 		endpoint,
 		token_endpoint,
 		disabled,
+		disabled_reason,
+		baseline_worker_id,
+		body_schema,
+		maintenance_windows,
+		maintenance_policy,
+		daily_request_quota,
+		default_worker_settings,
+		default_timeout,
+		demo,
+		auto_disable,
+		last_auth_verified_at,
 		created_at
 	FROM
 		environments
@@ -104,6 +143,8 @@ func (m *EnvironmentRepositoryDB) GetAll() ([]*entity.Environment, error) {
 
 	for rows.Next() {
 		var environment = &entity.Environment{}
+		var bodySchema, maintenanceWindows, workerDefaults, autoDisable []byte
+		var disabledReason sql.NullString
 
 		err := rows.Scan(
 			&environment.ID,
@@ -111,12 +152,28 @@ func (m *EnvironmentRepositoryDB) GetAll() ([]*entity.Environment, error) {
 			&environment.Endpoint,
 			&environment.TokenEndpoint,
 			&environment.Disabled,
+			&disabledReason,
+			&environment.BaselineWorkerID,
+			&bodySchema,
+			&maintenanceWindows,
+			&environment.MaintenancePolicy,
+			&environment.DailyRequestQuota,
+			&workerDefaults,
+			&environment.DefaultTimeout,
+			&environment.Demo,
+			&autoDisable,
+			&environment.LastAuthVerifiedAt,
 			&environment.CreatedAt,
 		)
 		if err != nil {
 			return nil, err
 		}
 
+		if err := unmarshalJSONColumns(jsonColumn{bodySchema, &environment.BodySchema}, jsonColumn{maintenanceWindows, &environment.MaintenanceWindows}, jsonColumn{workerDefaults, &environment.WorkerDefaults}, jsonColumn{autoDisable, &environment.AutoDisable}); err != nil {
+			return nil, err
+		}
+		environment.DisabledReason = disabledReason.String
+
 		if _, exists := environments[environment.ID]; !exists {
 			environments[environment.ID] = environment
 		}
@@ -140,7 +197,7 @@ func (m *EnvironmentRepositoryDB) GetAll() ([]*entity.Environment, error) {
 func (m *EnvironmentRepositoryDB) Get(id int) (*entity.Environment, error) {
 	var environment *entity.Environment
 
-	err := transactions.WithTransaction(m.DB, func(tx transactions.Transaction) (err error) {
+	err := withTransaction(m.DB, func(tx transactions.Transaction) (err error) {
 		environment, err = m.getWithTx(tx, id)
 		return err
 	})
@@ -148,8 +205,10 @@ func (m *EnvironmentRepositoryDB) Get(id int) (*entity.Environment, error) {
 	return environment, err
 }
 
+// Update replaces the settings of an environment, a name already taken by
+// another one being reported as ErrDuplicateName, see Insert.
 func (m *EnvironmentRepositoryDB) Update(environment *entity.Environment) error {
-	return transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
 		existingEnvironment, err := m.getWithTx(tx, environment.ID)
 		if err != nil {
 			return err
@@ -164,6 +223,21 @@ func (m *EnvironmentRepositoryDB) Update(environment *entity.Environment) error
 			return err
 		}
 
+		maintenanceWindows, err := marshalMaintenanceWindows(environment.MaintenanceWindows)
+		if err != nil {
+			return err
+		}
+
+		workerDefaults, err := marshalWorkerDefaults(environment.WorkerDefaults)
+		if err != nil {
+			return err
+		}
+
+		autoDisable, err := marshalAutoDisable(environment.AutoDisable)
+		if err != nil {
+			return err
+		}
+
 		stmt := `
 		UPDATE environments
 		SET 
@@ -173,7 +247,15 @@ func (m *EnvironmentRepositoryDB) Update(environment *entity.Environment) error
 			username = ?,
 			password = ?,
 			basic_auth_token = ?,
-			disabled = ?
+			disabled = ?,
+			maintenance_windows = ?,
+			maintenance_policy = ?,
+			daily_request_quota = ?,
+			default_worker_settings = ?,
+			default_timeout = ?,
+			auto_disable = ?,
+			disabled_reason = ?,
+			last_auth_verified_at = ?
 		WHERE 
 			id = ?
 		`
@@ -186,9 +268,20 @@ func (m *EnvironmentRepositoryDB) Update(environment *entity.Environment) error
 			hashedNewPassword,
 			environment.BasicAuthToken,
 			environment.Disabled,
+			maintenanceWindows,
+			environment.MaintenancePolicy,
+			environment.DailyRequestQuota,
+			workerDefaults,
+			environment.DefaultTimeout,
+			autoDisable,
+			environment.DisabledReason,
+			environment.LastAuthVerifiedAt,
 			environment.ID,
 		)
 		if err != nil {
+			if isDuplicateEntry(err) {
+				return custom_errors.ErrDuplicateName
+			}
 			return err
 		}
 
@@ -196,8 +289,108 @@ func (m *EnvironmentRepositoryDB) Update(environment *entity.Environment) error
 	})
 }
 
+func (m *EnvironmentRepositoryDB) SetBaseline(id, workerID int) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE environments
+		SET baseline_worker_id = ?
+		WHERE id = ?
+		`
+		results, err := tx.Exec(stmt, workerID, id)
+		if err != nil {
+			return err
+		}
+
+		return requireRow(tx, results, "environments", id)
+	})
+}
+
+// Disable marks an environment as disabled, with the reason why.
+func (m *EnvironmentRepositoryDB) Disable(id int, reason string) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE environments
+		SET disabled = TRUE, disabled_reason = ?
+		WHERE id = ?
+		`
+		results, err := tx.Exec(stmt, reason, id)
+		if err != nil {
+			return err
+		}
+
+		return requireRow(tx, results, "environments", id)
+	})
+}
+
+// SetAuthVerified records when the credentials of an environment last got a token.
+func (m *EnvironmentRepositoryDB) SetAuthVerified(id int, at time.Time) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE environments
+		SET last_auth_verified_at = ?
+		WHERE id = ?
+		`
+		results, err := tx.Exec(stmt, at, id)
+		if err != nil {
+			return err
+		}
+
+		return requireRow(tx, results, "environments", id)
+	})
+}
+
+// SetBodySchema stores the schema the worker bodies are checked against, a nil schema removes it.
+func (m *EnvironmentRepositoryDB) SetBodySchema(id int, schema *entity.BodySchema) error {
+	var (
+		bodySchema []byte
+		err        error
+	)
+
+	if schema != nil {
+		bodySchema, err = json.Marshal(schema)
+		if err != nil {
+			return err
+		}
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE environments
+		SET body_schema = ?
+		WHERE id = ?
+		`
+		results, err := tx.Exec(stmt, bodySchema, id)
+		if err != nil {
+			return err
+		}
+
+		return requireRow(tx, results, "environments", id)
+	})
+}
+
+// GetRequestUsage returns the current UTC day, as YYYY-MM-DD, and the
+// requests counted for the environment during it. The day is taken from the
+// database, whose clock the runs are counted with.
+func (m *EnvironmentRepositoryDB) GetRequestUsage(id int) (string, int, error) {
+	var (
+		day      string
+		requests int
+	)
+
+	stmt := `
+	SELECT
+		DATE_FORMAT(UTC_DATE(), '%Y-%m-%d'),
+		COALESCE((SELECT requests FROM environment_request_usage WHERE environment_id = ? AND day = UTC_DATE()), 0)
+	`
+	if err := m.DB.QueryRow(stmt, id).Scan(&day, &requests); err != nil {
+		return "", 0, err
+	}
+
+	return day, requests, nil
+}
+
 func (m *EnvironmentRepositoryDB) Delete(id int) error {
-	return transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
 		stmt := `
 		DELETE FROM environments
 		WHERE id = ?
@@ -220,8 +413,46 @@ func (m *EnvironmentRepositoryDB) Delete(id int) error {
 	})
 }
 
+// DeleteDemo deletes the demo environments along with their workers, their
+// latency samples and their request usage, and returns the number of
+// environments deleted.
+func (m *EnvironmentRepositoryDB) DeleteDemo() (int, error) {
+	var deleted int
+
+	err := withTransaction(m.DB, func(tx transactions.Transaction) error {
+		// The baselines point at the workers about to be deleted.
+		stmts := []string{
+			`UPDATE environments SET baseline_worker_id = NULL WHERE demo = TRUE`,
+			`DELETE FROM latency_samples WHERE worker_id IN (SELECT w.id FROM workers w JOIN environments e ON e.id = w.environment_id WHERE e.demo = TRUE)`,
+			`DELETE FROM environment_request_usage WHERE environment_id IN (SELECT id FROM environments WHERE demo = TRUE)`,
+			`DELETE FROM workers WHERE environment_id IN (SELECT id FROM environments WHERE demo = TRUE)`,
+		}
+		for _, stmt := range stmts {
+			if _, err := tx.Exec(stmt); err != nil {
+				return err
+			}
+		}
+
+		results, err := tx.Exec(`DELETE FROM environments WHERE demo = TRUE`)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := results.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = int(rowsAffected)
+
+		return nil
+	})
+
+	return deleted, err
+}
+
 func (m *EnvironmentRepositoryDB) getWithTx(tx transactions.Transaction, id int) (*entity.Environment, error) {
 	environment := &entity.Environment{}
+	var bodySchema, maintenanceWindows, workerDefaults, autoDisable []byte
+	var disabledReason sql.NullString
 
 	stmt := `
     SELECT 
@@ -233,6 +464,17 @@ func (m *EnvironmentRepositoryDB) getWithTx(tx transactions.Transaction, id int)
         password,
         basic_auth_token,
 		disabled,
+		disabled_reason,
+		baseline_worker_id,
+		body_schema,
+		maintenance_windows,
+		maintenance_policy,
+		daily_request_quota,
+		default_worker_settings,
+		default_timeout,
+		demo,
+		auto_disable,
+		last_auth_verified_at,
 		created_at
     FROM 
         environments 
@@ -249,6 +491,17 @@ func (m *EnvironmentRepositoryDB) getWithTx(tx transactions.Transaction, id int)
 		&environment.Password,
 		&environment.BasicAuthToken,
 		&environment.Disabled,
+		&disabledReason,
+		&environment.BaselineWorkerID,
+		&bodySchema,
+		&maintenanceWindows,
+		&environment.MaintenancePolicy,
+		&environment.DailyRequestQuota,
+		&workerDefaults,
+		&environment.DefaultTimeout,
+		&environment.Demo,
+		&autoDisable,
+		&environment.LastAuthVerifiedAt,
 		&environment.CreatedAt,
 	)
 	if err != nil {
@@ -260,5 +513,34 @@ func (m *EnvironmentRepositoryDB) getWithTx(tx transactions.Transaction, id int)
 		}
 	}
 
+	if err := unmarshalJSONColumns(jsonColumn{bodySchema, &environment.BodySchema}, jsonColumn{maintenanceWindows, &environment.MaintenanceWindows}, jsonColumn{workerDefaults, &environment.WorkerDefaults}, jsonColumn{autoDisable, &environment.AutoDisable}); err != nil {
+		return nil, err
+	}
+	environment.DisabledReason = disabledReason.String
+
 	return environment, nil
 }
+
+// marshalWorkerDefaults stores no defaults as NULL.
+func marshalWorkerDefaults(defaults entity.WorkerDefaults) ([]byte, error) {
+	if len(defaults) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(defaults)
+}
+
+// marshalAutoDisable stores no policy as NULL.
+func marshalAutoDisable(policy *entity.AutoDisablePolicy) ([]byte, error) {
+	if policy == nil {
+		return nil, nil
+	}
+	return json.Marshal(policy)
+}
+
+// marshalMaintenanceWindows stores no windows as NULL.
+func marshalMaintenanceWindows(windows []entity.MaintenanceWindow) ([]byte, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(windows)
+}
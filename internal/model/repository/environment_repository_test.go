@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+func TestInsertDuplicateName(t *testing.T) {
+	tooLong := &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'endpoint'"}
+	connectionLost := errors.New("connection lost")
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{name: "name taken", err: &mysql.MySQLError{Number: errDuplicateEntry, Message: "Duplicate entry 'staging' for key 'environments.uq_environments_name'"}, wantErr: custom_errors.ErrDuplicateName},
+		{name: "other MySQL error", err: tooLong, wantErr: tooLong},
+		{name: "not a MySQL error", err: connectionLost, wantErr: connectionLost},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			repo := NewEnvironmentRepositoryDB(db)
+
+			mock.ExpectBegin()
+			mock.ExpectExec(`INSERT INTO environments`).
+				WillReturnError(test.err)
+			mock.ExpectRollback()
+
+			_, err = repo.Insert(entity.NewEnvironment("staging", "http://staging.invalid"))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("Insert() error = %v, want %v", err, test.wantErr)
+			}
+			if errors.Is(test.wantErr, custom_errors.ErrDuplicateName) != errors.Is(err, custom_errors.ErrDuplicateName) {
+				t.Errorf("Insert() error = %v, ErrDuplicateName only for a name taken", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/tasty-byte/pkg/transactions"
+)
+
+type ScenarioRepository interface {
+	Insert(scenario *entity.Scenario) (int, error)
+	Get(id int) (*entity.Scenario, error)
+}
+
+type ScenarioRepositoryDB struct {
+	DB *sql.DB
+}
+
+func NewScenarioRepositoryDB(db *sql.DB) *ScenarioRepositoryDB {
+	return &ScenarioRepositoryDB{
+		DB: db,
+	}
+}
+
+// Insert stores a scenario along with the entries its import left out.
+func (m *ScenarioRepositoryDB) Insert(scenario *entity.Scenario) (int, error) {
+	var scenarioID int
+
+	steps, err := json.Marshal(scenario.Steps)
+	if err != nil {
+		return 0, err
+	}
+
+	var unsupported []byte
+	if len(scenario.Unsupported) > 0 {
+		if unsupported, err = json.Marshal(scenario.Unsupported); err != nil {
+			return 0, err
+		}
+	}
+
+	err = withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		INSERT INTO scenarios (name, rebase, shuffle, steps, unsupported, created_at)
+		VALUES (?, ?, ?, ?, ?, UTC_TIMESTAMP())
+		`
+		result, err := tx.Exec(stmt, scenario.Name, scenario.Rebase, scenario.Shuffle, steps, unsupported)
+		if err != nil {
+			return err
+		}
+
+		scenarioID64, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		scenarioID = int(scenarioID64)
+		return nil
+	})
+
+	return scenarioID, err
+}
+
+func (m *ScenarioRepositoryDB) Get(id int) (*entity.Scenario, error) {
+	scenario := &entity.Scenario{}
+	var steps, unsupported []byte
+
+	stmt := `
+	SELECT
+		id,
+		name,
+		rebase,
+		shuffle,
+		steps,
+		unsupported,
+		created_at
+	FROM
+		scenarios
+	WHERE id = ?
+	`
+	err := m.DB.QueryRow(stmt, id).Scan(&scenario.ID, &scenario.Name, &scenario.Rebase, &scenario.Shuffle, &steps, &unsupported, &scenario.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, custom_errors.ErrNoRecord
+		}
+		return nil, err
+	}
+
+	err = unmarshalJSONColumns(
+		jsonColumn{steps, &scenario.Steps},
+		jsonColumn{unsupported, &scenario.Unsupported},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return scenario, nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/vladComan0/tasty-byte/pkg/transactions"
+)
+
+// MySQL errors aborting a transaction that succeeds once run again.
+const (
+	errLockWaitTimeout = 1205
+	errDeadlock        = 1213
+)
+
+// errDuplicateEntry is the MySQL error of a write violating a unique key.
+const errDuplicateEntry = 1062
+
+const (
+	maxTxRetries   = 3
+	txRetryBackoff = 20 * time.Millisecond // doubled after every retry, plus up to as much jitter
+)
+
+// withTransaction runs fn in a transaction with the default isolation level,
+// see withIsolatedTransaction.
+func withTransaction(db *sql.DB, fn transactions.TxFn) error {
+	return withIsolatedTransaction(db, sql.LevelDefault, fn)
+}
+
+// withIsolatedTransaction runs fn in a transaction with the given isolation
+// level, committed if fn succeeds and rolled back otherwise. A transaction
+// aborted by a deadlock or a lock wait timeout is run again from the start,
+// up to maxTxRetries times, so fn must not have effects outside of it.
+func withIsolatedTransaction(db *sql.DB, isolation sql.IsolationLevel, fn transactions.TxFn) error {
+	return retryTransient(func() error {
+		return runTransaction(db, isolation, fn)
+	})
+}
+
+// retryTransient calls run until it succeeds, fails with an error that
+// isn't transient or maxTxRetries retries were made, waiting longer before
+// every retry.
+func retryTransient(run func() error) error {
+	backoff := txRetryBackoff
+	for retry := 0; ; retry++ {
+		err := run()
+		if err == nil || retry == maxTxRetries || !isTransient(err) {
+			return err
+		}
+
+		// The jitter keeps the transactions that deadlocked together from meeting again.
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether err aborted a transaction that may succeed once run again.
+func isTransient(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return false
+	}
+	return mysqlErr.Number == errDeadlock || mysqlErr.Number == errLockWaitTimeout
+}
+
+// isDuplicateEntry reports whether err is the violation of a unique key.
+func isDuplicateEntry(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == errDuplicateEntry
+}
+
+// runTransaction is transactions.WithTransaction with an isolation level.
+func runTransaction(db *sql.DB, isolation sql.IsolationLevel, fn transactions.TxFn) (err error) {
+	tx, err := db.BeginTx(context.Background(), &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	return fn(tx)
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+func TestRetryTransient(t *testing.T) {
+	deadlock := &mysql.MySQLError{Number: errDeadlock, Message: "Deadlock found when trying to get lock"}
+	lockWaitTimeout := &mysql.MySQLError{Number: errLockWaitTimeout, Message: "Lock wait timeout exceeded"}
+	duplicateEntry := &mysql.MySQLError{Number: errDuplicateEntry, Message: "Duplicate entry"}
+	connectionLost := errors.New("connection lost")
+
+	tests := []struct {
+		name     string
+		failures []error // of the successive attempts, the next one succeeding
+		wantErr  error
+	}{
+		{name: "no failure"},
+		{name: "deadlock", failures: []error{deadlock}},
+		{name: "lock wait timeout", failures: []error{lockWaitTimeout}},
+		{name: "transient failures up to the last retry", failures: []error{deadlock, lockWaitTimeout, deadlock}},
+		{name: "retries exhausted", failures: []error{deadlock, deadlock, deadlock, deadlock}, wantErr: deadlock},
+		{name: "not transient", failures: []error{duplicateEntry}, wantErr: duplicateEntry},
+		{name: "not a MySQL error", failures: []error{connectionLost}, wantErr: connectionLost},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, mock := newWorkerRepository(t)
+
+			// Every attempt runs the whole transaction again.
+			for _, failure := range test.failures {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE workers\s+SET status = \?`).
+					WillReturnError(failure)
+				mock.ExpectRollback()
+			}
+			if test.wantErr == nil {
+				mock.ExpectBegin()
+				mock.ExpectExec(`UPDATE workers\s+SET status = \?`).
+					WithArgs(entity.StatusFinished, true, 7).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			}
+
+			if err := repo.UpdateStatus(7, entity.StatusFinished); !errors.Is(err, test.wantErr) {
+				t.Fatalf("UpdateStatus() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryTransientMetrics(t *testing.T) {
+	repo, mock := newWorkerRepository(t)
+
+	// The metrics flushed by concurrent runs deadlock the most.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE workers\s+SET max_latency = \?`).
+		WillReturnError(&mysql.MySQLError{Number: errDeadlock})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE workers\s+SET max_latency = \?`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.UpdateMetrics(7, entity.NewMetrics()); err != nil {
+		t.Fatal(err)
+	}
+}
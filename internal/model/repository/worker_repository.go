@@ -2,23 +2,156 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
 	"github.com/vladComan0/performance-analyzer/internal/model/entity"
 	"github.com/vladComan0/tasty-byte/pkg/transactions"
 	"sort"
+	"strings"
+	"time"
 )
 
 type WorkerRepository interface {
 	Insert(worker *entity.Worker) (int, error)
 	Get(id int) (*entity.Worker, error)
-	GetAll() ([]*entity.Worker, error)
+	GetAll(strict bool) ([]*entity.Worker, []*RowError, error)
+	GetPage(after *entity.Cursor, limit int) ([]*entity.Worker, error)
+	GetIDsByStatus(status entity.Status) ([]int, error)
+	GetFinishedBetween(tag string, from, to time.Time) ([]*entity.Worker, error)
+	GetLatestOver(environmentID int, since time.Time, limit int) ([]*entity.Worker, error)
 	UpdateStatus(id int, status entity.Status) error
+	UnblockWorker(id int) error
+	FailRunning(id int) (bool, error)
 	UpdateMetrics(id int, metrics *entity.Metrics) error
+	FinishRun(id int, status entity.Status, metrics *entity.Metrics) error
+	UpdateRampResult(id int, result *entity.RampResult) error
+	UpdateSoakResult(id int, result *entity.SoakResult) error
+	UpdateSpikeResult(id int, result *entity.SpikeResult) error
+	UpdateAutoTuneResult(id int, result *entity.AutoTuneResult) error
+	UpdateCapturedResponses(id int, responses []*entity.CapturedResponse) error
+	UpdateFirstRequest(id int, request *entity.RenderedRequest) error
+	InsertSamples(id int, samples []entity.LatencySample) error
+	AddWarning(id int, warning string) error
+	UpdateRecordFile(id int, file string) error
+	UpdateDataSource(id int, source *entity.DataSource) error
+	GetSamples(id int) ([]entity.LatencySample, error)
+	GetStorageStats() (*entity.StorageStats, error)
+}
+
+// sampleBatchSize is the number of latency samples written per INSERT statement.
+const sampleBatchSize = 1000
+
+// workerColumns lists the columns read by scanWorker, in scan order.
+const workerColumns = `
+		id,
+		environment_id,
+		campaign_id,
+		concurrency,
+		requests_per_task,
+		report,
+		http_method,
+		body,
+		body_content_type,
+		body_variants,
+		variant_selection,
+		target_rps,
+		min_throughput_ratio,
+		mode,
+		ramp_config,
+		ramp_result,
+		soak_config,
+		soak_result,
+		spike_config,
+		spike_result,
+		auto_tune_config,
+		auto_tune_result,
+		ramp_up,
+		ramp_up_jitter,
+		ramp_down,
+		think_time,
+		think_time_jitter,
+		on_resource_exhaustion,
+		circuit_breaker,
+		retry,
+		resolver,
+		expected_requests,
+		capture_quotas,
+		captured_responses,
+		first_request,
+		persist_samples,
+		sample_cap,
+		record_requests,
+		record_file,
+		keep_alive,
+		identities,
+		data_source,
+		scenario,
+		body_stream,
+		provenance,
+		timeouts,
+		success_header,
+		body_assertion,
+		served_by_headers,
+		correlation_header,
+		log_sample_rate,
+		measure_cold_requests,
+		latency_failure_threshold,
+		slow_request_policy,
+		follow_redirects,
+		status_3xx,
+		exclusive,
+		tags,
+		seed,
+		warnings,
+		status,
+		max_latency,
+		total_requests,
+		failed_requests,
+		cancelled_requests,
+		slow_requests,
+		neutral_requests,
+		error_rate,
+		throughput,
+		effective_concurrency,
+		error_classes,
+		status_classes,
+		diagnostics,
+		phases,
+		circuit_open_time,
+		circuit_openings,
+		global_throttle_time,
+		dns_lookups,
+		avg_dns_latency,
+		max_dns_latency,
+		resolved_addresses,
+		protocol_versions,
+		served_by,
+		breakdown,
+		samples_seen,
+		samples_stored,
+		keep_alive_pings,
+		reconnects_avoided,
+		slowest_requests,
+		variants,
+		connections,
+		p50,
+		p95,
+		p99,
+		p999,
+		created_at,
+		updated_at,
+		finished_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
 }
 
 type WorkerRepositoryDB struct {
-	DB *sql.DB
+	DB          *sql.DB
+	Compression Compression // of the blob columns written, gzip when empty
 }
 
 func NewWorkerRepositoryDB(db *sql.DB) *WorkerRepositoryDB {
@@ -28,22 +161,217 @@ func NewWorkerRepositoryDB(db *sql.DB) *WorkerRepositoryDB {
 }
 
 func (m *WorkerRepositoryDB) Insert(worker *entity.Worker) (int, error) {
-	var workerID int
+	var (
+		workerID                                                                                                                                                                                                                                          int
+		body, bodyVariants, rampConfig, soakConfig, spikeConfig, autoTuneConfig, circuitBreaker, retry, captureQuotas, keepAlive, identities, dataSource, scenario, bodyStream, provenance, timeouts, successHeader, bodyAssertion, servedByHeaders, tags []byte
+		err                                                                                                                                                                                                                                               error
+	)
+
+	// The report is compressed as is, an empty one being stored empty.
+	report := []byte(worker.Report)
+	if len(report) > 0 {
+		report, err = m.compression().compress(report)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Without a body the column is NULL, whatever the driver makes of a nil *json.RawMessage.
+	if worker.Body != nil {
+		body = *worker.Body
+	}
+
+	if len(worker.BodyVariants) > 0 {
+		bodyVariants, err = json.Marshal(worker.BodyVariants)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.RampConfig != nil {
+		rampConfig, err = json.Marshal(worker.RampConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.SoakConfig != nil {
+		soakConfig, err = json.Marshal(worker.SoakConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.SpikeConfig != nil {
+		spikeConfig, err = json.Marshal(worker.SpikeConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.AutoTuneConfig != nil {
+		autoTuneConfig, err = json.Marshal(worker.AutoTuneConfig)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.CircuitBreaker != nil {
+		circuitBreaker, err = json.Marshal(worker.CircuitBreaker)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.Retry != nil {
+		retry, err = json.Marshal(worker.Retry)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(worker.CaptureQuotas) > 0 {
+		captureQuotas, err = json.Marshal(worker.CaptureQuotas)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.KeepAlive != nil {
+		keepAlive, err = json.Marshal(worker.KeepAlive)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if worker.Identities != nil {
+		identities, err = json.Marshal(worker.Identities)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.DataSource != nil {
+		dataSource, err = marshalDataSource(worker.DataSource)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.Scenario != nil {
+		scenario, err = json.Marshal(worker.Scenario)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.BodyStream != nil {
+		bodyStream, err = json.Marshal(worker.BodyStream)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(worker.Provenance) > 0 {
+		provenance, err = json.Marshal(worker.Provenance)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.Timeouts != nil {
+		timeouts, err = json.Marshal(worker.Timeouts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.SuccessHeader != nil {
+		successHeader, err = json.Marshal(worker.SuccessHeader)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if worker.BodyAssertion != nil {
+		bodyAssertion, err = json.Marshal(worker.BodyAssertion)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(worker.ServedByHeaders) > 0 {
+		servedByHeaders, err = json.Marshal(worker.ServedByHeaders)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(worker.Tags) > 0 {
+		tags, err = json.Marshal(worker.Tags)
+		if err != nil {
+			return 0, err
+		}
+	}
 
-	err := transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
+	err = withTransaction(m.DB, func(tx transactions.Transaction) error {
 		stmt := `
-		INSERT INTO workers (environment_id, concurrency, requests_per_task, report, http_method, body, status, created_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())
+		INSERT INTO workers (environment_id, campaign_id, concurrency, requests_per_task, report, http_method, body, body_content_type, body_variants, variant_selection, target_rps, min_throughput_ratio, mode, ramp_config, soak_config, spike_config, auto_tune_config, ramp_up, ramp_up_jitter, ramp_down, think_time, think_time_jitter, on_resource_exhaustion, circuit_breaker, retry, resolver, expected_requests, capture_quotas, persist_samples, sample_cap, record_requests, keep_alive, identities, data_source, scenario, body_stream, provenance, timeouts, success_header, body_assertion, served_by_headers, correlation_header, log_sample_rate, measure_cold_requests, latency_failure_threshold, slow_request_policy, follow_redirects, status_3xx, exclusive, tags, seed, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP())
 		`
 		result, err := tx.Exec(
 			stmt,
 			worker.EnvironmentID,
+			worker.CampaignID,
 			worker.Concurrency,
 			worker.RequestsPerTask,
-			worker.Report,
+			report,
 			worker.HTTPMethod,
-			worker.Body,
-			entity.StatusCreated,
+			body,
+			worker.BodyContentType,
+			bodyVariants,
+			worker.VariantSelection,
+			worker.TargetRPS,
+			worker.MinThroughputRatio,
+			worker.Mode,
+			rampConfig,
+			soakConfig,
+			spikeConfig,
+			autoTuneConfig,
+			worker.RampUp,
+			worker.RampUpJitter,
+			worker.RampDown,
+			worker.ThinkTime,
+			worker.ThinkTimeJitter,
+			worker.OnResourceExhaustion,
+			circuitBreaker,
+			retry,
+			worker.Resolver,
+			worker.ExpectedRequests,
+			captureQuotas,
+			worker.PersistSamples,
+			worker.SampleCap,
+			worker.RecordRequests,
+			keepAlive,
+			identities,
+			dataSource,
+			scenario,
+			bodyStream,
+			provenance,
+			timeouts,
+			successHeader,
+			bodyAssertion,
+			servedByHeaders,
+			worker.CorrelationHeader,
+			worker.LogSampleRate,
+			worker.MeasureColdRequests,
+			worker.LatencyFailureThreshold,
+			worker.SlowRequestPolicy,
+			worker.FollowRedirects,
+			worker.Status3xx,
+			worker.Exclusive,
+			tags,
+			worker.Seed,
+			worker.Status,
 		)
 		if err != nil {
 			return err
@@ -61,29 +389,31 @@ func (m *WorkerRepositoryDB) Insert(worker *entity.Worker) (int, error) {
 	return workerID, err
 }
 
-func (m *WorkerRepositoryDB) GetAll() ([]*entity.Worker, error) {
+// RowError is a row that couldn't be read, ID being 0 when even its id
+// couldn't be.
+type RowError struct {
+	ID  int
+	Err error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.ID, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// GetAll returns every worker. Unless strict, the rows that can't be read,
+// such as a row with a malformed JSON column, are left out and returned
+// aside instead of failing the whole listing.
+func (m *WorkerRepositoryDB) GetAll(strict bool) ([]*entity.Worker, []*RowError, error) {
 	var results []*entity.Worker
+	var rowErrors []*RowError
 	workers := make(map[int]*entity.Worker)
 
 	stmt := `
-	SELECT
-		id,
-		environment_id,
-		concurrency,
-		requests_per_task,
-		report,
-		http_method,
-		body,
-		status,
-		max_latency,
-		total_requests,
-		failed_requests,
-		error_rate,
-		p50,
-		p95,
-		p99,
-		p999,
-		created_at
+	SELECT` + workerColumns + `
 	FROM 
 	    workers
 	`
@@ -92,9 +422,9 @@ func (m *WorkerRepositoryDB) GetAll() ([]*entity.Worker, error) {
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, custom_errors.ErrNoRecord
+			return nil, nil, custom_errors.ErrNoRecord
 		default:
-			return nil, err
+			return nil, nil, err
 		}
 	}
 	defer func(rows *sql.Rows) {
@@ -102,44 +432,23 @@ func (m *WorkerRepositoryDB) GetAll() ([]*entity.Worker, error) {
 	}(rows)
 
 	for rows.Next() {
-		var worker = &entity.Worker{}
-		var p50, p95, p99, p999, maxLatency, errorRate sql.NullFloat64
-		var totalRequests, failedRequests sql.NullInt64
-		worker.Metrics = &entity.Metrics{}
-		worker.Metrics.Percentiles = make(map[entity.PercentileRank]float64)
-
-		err := rows.Scan(
-			&worker.ID,
-			&worker.EnvironmentID,
-			&worker.Concurrency,
-			&worker.RequestsPerTask,
-			&worker.Report,
-			&worker.HTTPMethod,
-			&worker.Body,
-			&worker.Status,
-			&maxLatency,
-			&totalRequests,
-			&failedRequests,
-			&errorRate,
-			&p50,
-			&p95,
-			&p99,
-			&p999,
-			&worker.CreatedAt,
-		)
+		worker, err := scanWorker(rows)
 		if err != nil {
-			return nil, err
+			var rowErr *RowError
+			if strict || !errors.As(err, &rowErr) {
+				return nil, nil, err
+			}
+			rowErrors = append(rowErrors, rowErr)
+			continue
 		}
 
-		assignValidMetricsFromDB(worker, maxLatency, totalRequests, failedRequests, errorRate, p50, p95, p99, p999)
-
 		if _, exists := workers[worker.ID]; !exists {
 			workers[worker.ID] = worker
 		}
 	}
 
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, worker := range workers {
@@ -150,148 +459,963 @@ func (m *WorkerRepositoryDB) GetAll() ([]*entity.Worker, error) {
 		return results[i].ID < results[j].ID
 	})
 
-	return results, nil
-}
-
-func (m *WorkerRepositoryDB) Get(id int) (*entity.Worker, error) {
-	var worker *entity.Worker
-
-	err := transactions.WithTransaction(m.DB, func(tx transactions.Transaction) (err error) {
-		worker, err = m.getWithTx(tx, id)
-		return err
-	})
-
-	return worker, err
+	return results, rowErrors, nil
 }
 
-func (m *WorkerRepositoryDB) getWithTx(tx transactions.Transaction, id int) (*entity.Worker, error) {
-	worker := &entity.Worker{}
-	worker.Metrics = &entity.Metrics{}
-	worker.Metrics.Percentiles = make(map[entity.PercentileRank]float64)
+// GetPage returns up to limit workers created after the cursor, the first
+// ones when after is nil, ordered by creation time and then by id. Callers
+// page through the table by passing the cursor of the last worker they
+// received, which the rows inserted meanwhile don't shift. The seek relies
+// on the (created_at, id) index of the workers.
+func (m *WorkerRepositoryDB) GetPage(after *entity.Cursor, limit int) ([]*entity.Worker, error) {
+	var results []*entity.Worker
 
-	var p50, p95, p99, p999, maxLatency, errorRate sql.NullFloat64
-	var totalRequests, failedRequests sql.NullInt64
+	where, args := "", []any{limit}
+	if after != nil {
+		where, args = "WHERE (created_at, id) > (?, ?)", []any{after.CreatedAt, after.ID, limit}
+	}
 
 	stmt := `
-	SELECT
-		id,
-		environment_id,
-		concurrency,
-		requests_per_task,
-		report,
-		http_method,
-		body,
-		status,
-		max_latency,
-		total_requests,
-		failed_requests,
-		error_rate,
-		p50,
-		p95,
-		p99,
-		p999,
-		created_at
+	SELECT` + workerColumns + `
 	FROM 
 	    workers
-	WHERE id = ?
+	` + where + `
+	ORDER BY created_at, id
+	LIMIT ?
 	`
 
-	err := tx.QueryRow(stmt, id).Scan(
-		&worker.ID,
-		&worker.EnvironmentID,
-		&worker.Concurrency,
-		&worker.RequestsPerTask,
-		&worker.Report,
-		&worker.HTTPMethod,
-		&worker.Body,
-		&worker.Status,
-		&maxLatency,
-		&totalRequests,
-		&failedRequests,
-		&errorRate,
-		&p50,
-		&p95,
-		&p99,
-		&p999,
-		&worker.CreatedAt,
-	)
+	rows, err := m.DB.Query(stmt, args...)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return nil, custom_errors.ErrNoRecord
-		default:
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		worker, err := scanWorker(rows)
+		if err != nil {
 			return nil, err
 		}
+		results = append(results, worker)
 	}
 
-	assignValidMetricsFromDB(worker, maxLatency, totalRequests, failedRequests, errorRate, p50, p95, p99, p999)
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return worker, nil
+	return results, nil
 }
 
-func (m *WorkerRepositoryDB) UpdateStatus(id int, newStatus entity.Status) error {
-	err := transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
-		stmt := `
-		UPDATE workers
-		SET status = ?
-		WHERE id = ?
-		`
-
-		_, err := tx.Exec(stmt, newStatus, id)
-		if err != nil {
-			return err
-		}
-
-		return nil
-	})
+// GetFinishedBetween returns the workers with tag whose run finished within
+// [from, to), ordered by the time they finished.
+func (m *WorkerRepositoryDB) GetFinishedBetween(tag string, from, to time.Time) ([]*entity.Worker, error) {
+	var results []*entity.Worker
 
-	return err
-}
+	stmt := `
+	SELECT` + workerColumns + `
+	FROM 
+	    workers
+	WHERE finished_at >= ? AND finished_at < ? AND JSON_CONTAINS(tags, JSON_QUOTE(?))
+	ORDER BY finished_at, id
+	`
 
-func (m *WorkerRepositoryDB) UpdateMetrics(id int, metrics *entity.Metrics) error {
-	err := transactions.WithTransaction(m.DB, func(tx transactions.Transaction) error {
-		stmt := `
-        UPDATE workers
-        SET max_latency = ?,
-            total_requests = ?,
-            failed_requests = ?,
-            error_rate = ?,
-            p50 = ?,
-            p95 = ?,
-            p99 = ?,
-            p999 = ?
-        WHERE id = ?
-        `
+	rows, err := m.DB.Query(stmt, from.UTC(), to.UTC(), tag)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
 
-		_, err := tx.Exec(
-			stmt,
-			metrics.MaxLatency,
-			metrics.TotalRequests,
-			metrics.FailedRequests,
-			metrics.ErrorRate,
-			metrics.Percentiles[entity.P50],
-			metrics.Percentiles[entity.P95],
-			metrics.Percentiles[entity.P99],
-			metrics.Percentiles[entity.P999],
-			id,
-		)
+	for rows.Next() {
+		worker, err := scanWorker(rows)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		results = append(results, worker)
+	}
 
-		return nil
-	})
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return err
+	return results, nil
 }
 
-func assignValidMetricsFromDB(worker *entity.Worker, maxLatency sql.NullFloat64, totalRequests, failedRequests sql.NullInt64, errorRate sql.NullFloat64, p50, p95, p99, p999 sql.NullFloat64) {
-	if maxLatency.Valid {
-		worker.Metrics.MaxLatency = maxLatency.Float64
-	}
+// GetLatestOver returns up to limit of the runs of an environment finished
+// since the given time, the most recent first. The cancelled runs are left out.
+func (m *WorkerRepositoryDB) GetLatestOver(environmentID int, since time.Time, limit int) ([]*entity.Worker, error) {
+	var results []*entity.Worker
 
-	if totalRequests.Valid {
-		worker.Metrics.TotalRequests = int(totalRequests.Int64)
+	stmt := `
+	SELECT` + workerColumns + `
+	FROM 
+	    workers
+	WHERE environment_id = ? AND finished_at >= ? AND status IN (?, ?, ?)
+	ORDER BY finished_at DESC, id DESC
+	LIMIT ?
+	`
+
+	rows, err := m.DB.Query(stmt, environmentID, since.UTC(), entity.StatusFinished, entity.StatusFailed, entity.StatusUnderperforming, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		worker, err := scanWorker(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, worker)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetIDsByStatus returns the ids of the workers with the given status, ordered by id.
+func (m *WorkerRepositoryDB) GetIDsByStatus(status entity.Status) ([]int, error) {
+	ids := []int{}
+
+	stmt := `
+	SELECT id
+	FROM
+	    workers
+	WHERE status = ?
+	ORDER BY id
+	`
+
+	rows, err := m.DB.Query(stmt, status)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (m *WorkerRepositoryDB) Get(id int) (*entity.Worker, error) {
+	var worker *entity.Worker
+
+	err := withTransaction(m.DB, func(tx transactions.Transaction) (err error) {
+		worker, err = m.getWithTx(tx, id)
+		return err
+	})
+
+	return worker, err
+}
+
+func (m *WorkerRepositoryDB) getWithTx(tx transactions.Transaction, id int) (*entity.Worker, error) {
+	stmt := `
+	SELECT` + workerColumns + `
+	FROM 
+	    workers
+	WHERE id = ?
+	`
+
+	worker, err := scanWorker(tx.QueryRow(stmt, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, custom_errors.ErrNoRecord
+		default:
+			return nil, err
+		}
+	}
+
+	return worker, nil
+}
+
+func (m *WorkerRepositoryDB) UpdateStatus(id int, newStatus entity.Status) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		return m.updateStatusWithTx(tx, id, newStatus)
+	})
+}
+
+// UnblockWorker moves a blocked worker back to created, ErrNotStartable if it isn't blocked anymore.
+func (m *WorkerRepositoryDB) UnblockWorker(id int) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET status = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ? AND status = ?
+		`
+
+		result, err := tx.Exec(stmt, entity.StatusCreated, id, entity.StatusBlocked)
+		if err != nil {
+			return err
+		}
+
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			return custom_errors.ErrNotStartable
+		}
+		return nil
+	})
+}
+
+// FailRunning moves a running worker to failed. It reports false if the
+// worker isn't running anymore, in which case it is left as it is.
+func (m *WorkerRepositoryDB) FailRunning(id int) (bool, error) {
+	stmt := `
+	UPDATE workers
+	SET status = ?, updated_at = UTC_TIMESTAMP(), finished_at = UTC_TIMESTAMP()
+	WHERE id = ? AND status = ?
+	`
+
+	result, err := m.DB.Exec(stmt, entity.StatusFailed, id, entity.StatusRunning)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateMetrics stores the metrics of a worker. Concurrent runs flush their
+// metrics at the same time, READ COMMITTED takes no gap locks, which lowers
+// the contention between them.
+func (m *WorkerRepositoryDB) UpdateMetrics(id int, metrics *entity.Metrics) error {
+	return withIsolatedTransaction(m.DB, sql.LevelReadCommitted, func(tx transactions.Transaction) error {
+		return m.updateMetricsWithTx(tx, id, metrics)
+	})
+}
+
+// FinishRun stores the final metrics and status of a run in a single
+// transaction, so a crash can't leave a completed worker without metrics.
+// The requests of the run are counted against the daily request quota of
+// its environment in the same transaction.
+func (m *WorkerRepositoryDB) FinishRun(id int, status entity.Status, metrics *entity.Metrics) error {
+	return withIsolatedTransaction(m.DB, sql.LevelReadCommitted, func(tx transactions.Transaction) error {
+		if err := m.updateMetricsWithTx(tx, id, metrics); err != nil {
+			return err
+		}
+		if err := m.updateStatusWithTx(tx, id, status); err != nil {
+			return err
+		}
+		return m.addRequestUsageWithTx(tx, id, metrics.TotalRequests)
+	})
+}
+
+// addRequestUsageWithTx adds requests to the usage of the environment of
+// the worker for the current UTC day.
+func (m *WorkerRepositoryDB) addRequestUsageWithTx(tx transactions.Transaction, id, requests int) error {
+	if requests == 0 {
+		return nil
+	}
+
+	stmt := `
+	INSERT INTO environment_request_usage (environment_id, day, requests)
+	SELECT environment_id, UTC_DATE(), ?
+	FROM workers
+	WHERE id = ?
+	ON DUPLICATE KEY UPDATE requests = requests + VALUES(requests)
+	`
+
+	_, err := tx.Exec(stmt, requests, id)
+	return err
+}
+
+// updateStatusWithTx sets the status of a worker, along with the time its
+// run was over when the status is final.
+func (m *WorkerRepositoryDB) updateStatusWithTx(tx transactions.Transaction, id int, newStatus entity.Status) error {
+	stmt := `
+	UPDATE workers
+	SET status = ?, updated_at = UTC_TIMESTAMP(), finished_at = IF(?, UTC_TIMESTAMP(), NULL)
+	WHERE id = ?
+	`
+
+	result, err := tx.Exec(stmt, newStatus, newStatus.Over(), id)
+	if err != nil {
+		return err
+	}
+	return requireWorker(tx, result, id)
+}
+
+// requireWorker returns ErrNoRecord when an UPDATE of the worker with the
+// given id matched no row.
+func requireWorker(tx transactions.Transaction, result sql.Result, id int) error {
+	return requireRow(tx, result, "workers", id)
+}
+
+// requireRow returns ErrNoRecord when an UPDATE of the row of table with the
+// given id matched no row. MySQL doesn't count the rows an UPDATE leaves
+// unchanged, so the row is looked up before concluding that it is missing.
+func requireRow(tx transactions.Transaction, result sql.Result, table string, id int) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	var exists bool
+	if err := tx.QueryRow("SELECT EXISTS(SELECT 1 FROM "+table+" WHERE id = ?)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return custom_errors.ErrNoRecord
+	}
+	return nil
+}
+
+func (m *WorkerRepositoryDB) updateMetricsWithTx(tx transactions.Transaction, id int, metrics *entity.Metrics) error {
+	errorClasses, err := json.Marshal(metrics.ErrorClasses)
+	if err != nil {
+		return err
+	}
+
+	statusClasses, err := json.Marshal(metrics.StatusClasses)
+	if err != nil {
+		return err
+	}
+
+	diagnostics, err := json.Marshal(metrics.Diagnostics)
+	if err != nil {
+		return err
+	}
+
+	phases, err := json.Marshal(metrics.Phases)
+	if err != nil {
+		return err
+	}
+
+	resolvedAddresses, err := json.Marshal(metrics.ResolvedAddresses)
+	if err != nil {
+		return err
+	}
+
+	protocolVersions, err := json.Marshal(metrics.ProtocolVersions)
+	if err != nil {
+		return err
+	}
+
+	servedBy, err := json.Marshal(metrics.ServedBy)
+	if err != nil {
+		return err
+	}
+
+	breakdown, err := json.Marshal(metrics.Breakdown)
+	if err != nil {
+		return err
+	}
+
+	slowestRequests, err := json.Marshal(metrics.SlowestRequests)
+	if err != nil {
+		return err
+	}
+
+	variants, err := json.Marshal(metrics.Variants)
+	if err != nil {
+		return err
+	}
+
+	connections, err := json.Marshal(metrics.Connections)
+	if err != nil {
+		return err
+	}
+
+	stmt := `
+        UPDATE workers
+        SET max_latency = ?,
+            total_requests = ?,
+            failed_requests = ?,
+            cancelled_requests = ?,
+            slow_requests = ?,
+            neutral_requests = ?,
+            error_rate = ?,
+            throughput = ?,
+            effective_concurrency = ?,
+            error_classes = ?,
+            status_classes = ?,
+            diagnostics = ?,
+            phases = ?,
+            circuit_open_time = ?,
+            circuit_openings = ?,
+            global_throttle_time = ?,
+            dns_lookups = ?,
+            avg_dns_latency = ?,
+            max_dns_latency = ?,
+            resolved_addresses = ?,
+            protocol_versions = ?,
+            served_by = ?,
+            breakdown = ?,
+            samples_seen = ?,
+            samples_stored = ?,
+            keep_alive_pings = ?,
+            reconnects_avoided = ?,
+            slowest_requests = ?,
+            variants = ?,
+            connections = ?,
+            p50 = ?,
+            p95 = ?,
+            p99 = ?,
+            p999 = ?,
+            updated_at = UTC_TIMESTAMP()
+        WHERE id = ?
+        `
+
+	result, err := tx.Exec(
+		stmt,
+		metrics.MaxLatency,
+		metrics.TotalRequests,
+		metrics.FailedRequests,
+		metrics.CancelledRequests,
+		metrics.SlowRequests,
+		metrics.NeutralRequests,
+		metrics.ErrorRate,
+		metrics.Throughput,
+		metrics.EffectiveConcurrency,
+		errorClasses,
+		statusClasses,
+		diagnostics,
+		phases,
+		metrics.CircuitOpenTime,
+		metrics.CircuitOpenings,
+		metrics.GlobalThrottleTime,
+		metrics.DNSLookups,
+		metrics.AvgDNSLatency,
+		metrics.MaxDNSLatency,
+		resolvedAddresses,
+		protocolVersions,
+		servedBy,
+		breakdown,
+		metrics.SamplesSeen,
+		metrics.SamplesStored,
+		metrics.KeepAlivePings,
+		metrics.ReconnectsAvoided,
+		slowestRequests,
+		variants,
+		connections,
+		metrics.Percentiles[entity.P50],
+		metrics.Percentiles[entity.P95],
+		metrics.Percentiles[entity.P99],
+		metrics.Percentiles[entity.P999],
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	return requireWorker(tx, result, id)
+}
+
+func (m *WorkerRepositoryDB) UpdateRampResult(id int, result *entity.RampResult) error {
+	data, err := m.marshalBlob(result)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET ramp_result = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+func (m *WorkerRepositoryDB) UpdateSoakResult(id int, result *entity.SoakResult) error {
+	data, err := m.marshalBlob(result)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET soak_result = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+func (m *WorkerRepositoryDB) UpdateSpikeResult(id int, result *entity.SpikeResult) error {
+	data, err := m.marshalBlob(result)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET spike_result = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+func (m *WorkerRepositoryDB) UpdateAutoTuneResult(id int, result *entity.AutoTuneResult) error {
+	data, err := m.marshalBlob(result)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET auto_tune_result = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+// AddWarning appends a warning to the worker while it runs.
+func (m *WorkerRepositoryDB) AddWarning(id int, warning string) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET warnings = JSON_ARRAY_APPEND(COALESCE(warnings, JSON_ARRAY()), '$', ?), updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, warning, id)
+		return err
+	})
+}
+
+// UpdateRecordFile stores the name of the request record file once it is complete.
+func (m *WorkerRepositoryDB) UpdateRecordFile(id int, file string) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET record_file = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, file, id)
+		return err
+	})
+}
+
+// UpdateDataSource stores the data source of a worker, along with the rows it loaded.
+func (m *WorkerRepositoryDB) UpdateDataSource(id int, source *entity.DataSource) error {
+	data, err := marshalDataSource(source)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET data_source = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+// marshalDataSource leaves the DSN out, only its sealed form is stored.
+func marshalDataSource(source *entity.DataSource) ([]byte, error) {
+	stored := *source
+	stored.DSN = ""
+	return json.Marshal(stored)
+}
+
+func (m *WorkerRepositoryDB) UpdateCapturedResponses(id int, responses []*entity.CapturedResponse) error {
+	data, err := m.marshalBlob(responses)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET captured_responses = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+func (m *WorkerRepositoryDB) UpdateFirstRequest(id int, request *entity.RenderedRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		stmt := `
+		UPDATE workers
+		SET first_request = ?, updated_at = UTC_TIMESTAMP()
+		WHERE id = ?
+		`
+
+		_, err := tx.Exec(stmt, data, id)
+		return err
+	})
+}
+
+// InsertSamples writes the latency samples of a worker in batches, within a single transaction.
+func (m *WorkerRepositoryDB) InsertSamples(id int, samples []entity.LatencySample) error {
+	return withTransaction(m.DB, func(tx transactions.Transaction) error {
+		for start := 0; start < len(samples); start += sampleBatchSize {
+			batch := samples[start:min(start+sampleBatchSize, len(samples))]
+
+			stmt := `INSERT INTO latency_samples (worker_id, sent_at, latency, phase) VALUES ` +
+				strings.TrimSuffix(strings.Repeat("(?, ?, ?, ?), ", len(batch)), ", ")
+			args := make([]any, 0, 4*len(batch))
+			for _, sample := range batch {
+				args = append(args, id, sample.SentAt, sample.Latency, sample.Phase)
+			}
+
+			if _, err := tx.Exec(stmt, args...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *WorkerRepositoryDB) GetSamples(id int) ([]entity.LatencySample, error) {
+	stmt := `
+	SELECT sent_at, latency, phase
+	FROM latency_samples
+	WHERE worker_id = ?
+	ORDER BY sent_at
+	`
+
+	rows, err := m.DB.Query(stmt, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *sql.Rows) {
+		_ = rows.Close()
+	}(rows)
+
+	samples := []entity.LatencySample{}
+	for rows.Next() {
+		var sample entity.LatencySample
+		if err := rows.Scan(&sample.SentAt, &sample.Latency, &sample.Phase); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+func scanWorker(row rowScanner) (*entity.Worker, error) {
+	worker := &entity.Worker{}
+	worker.Metrics = &entity.Metrics{}
+	worker.Metrics.Percentiles = make(map[entity.PercentileRank]float64)
+
+	var p50, p95, p99, p999, maxLatency, errorRate, throughput, effectiveConcurrency sql.NullFloat64
+	var totalRequests, failedRequests sql.NullInt64
+	var recordFile sql.NullString
+	var updatedAt, finishedAt sql.NullTime
+	var circuitOpenTime, globalThrottleTime, avgDNSLatency, maxDNSLatency sql.NullFloat64
+	var cancelledRequests, slowRequests, neutralRequests, circuitOpenings, dnsLookups, samplesSeen, samplesStored, keepAlivePings, reconnectsAvoided sql.NullInt64
+	var report, body, bodyVariants, warnings, rampConfig, rampResult, soakConfig, soakResult, spikeConfig, spikeResult, autoTuneConfig, autoTuneResult, circuitBreaker, retry, captureQuotas, capturedResponses, firstRequest, keepAlive, identities, dataSource, scenario, bodyStream, provenance, timeouts, successHeader, bodyAssertion, servedByHeaders, tags, errorClasses, statusClasses, diagnostics, phases, resolvedAddresses, protocolVersions, servedBy, breakdown, slowestRequests, variants, connections []byte
+
+	err := row.Scan(
+		&worker.ID,
+		&worker.EnvironmentID,
+		&worker.CampaignID,
+		&worker.Concurrency,
+		&worker.RequestsPerTask,
+		&report,
+		&worker.HTTPMethod,
+		&body,
+		&worker.BodyContentType,
+		&bodyVariants,
+		&worker.VariantSelection,
+		&worker.TargetRPS,
+		&worker.MinThroughputRatio,
+		&worker.Mode,
+		&rampConfig,
+		&rampResult,
+		&soakConfig,
+		&soakResult,
+		&spikeConfig,
+		&spikeResult,
+		&autoTuneConfig,
+		&autoTuneResult,
+		&worker.RampUp,
+		&worker.RampUpJitter,
+		&worker.RampDown,
+		&worker.ThinkTime,
+		&worker.ThinkTimeJitter,
+		&worker.OnResourceExhaustion,
+		&circuitBreaker,
+		&retry,
+		&worker.Resolver,
+		&worker.ExpectedRequests,
+		&captureQuotas,
+		&capturedResponses,
+		&firstRequest,
+		&worker.PersistSamples,
+		&worker.SampleCap,
+		&worker.RecordRequests,
+		&recordFile,
+		&keepAlive,
+		&identities,
+		&dataSource,
+		&scenario,
+		&bodyStream,
+		&provenance,
+		&timeouts,
+		&successHeader,
+		&bodyAssertion,
+		&servedByHeaders,
+		&worker.CorrelationHeader,
+		&worker.LogSampleRate,
+		&worker.MeasureColdRequests,
+		&worker.LatencyFailureThreshold,
+		&worker.SlowRequestPolicy,
+		&worker.FollowRedirects,
+		&worker.Status3xx,
+		&worker.Exclusive,
+		&tags,
+		&worker.Seed,
+		&warnings,
+		&worker.Status,
+		&maxLatency,
+		&totalRequests,
+		&failedRequests,
+		&cancelledRequests,
+		&slowRequests,
+		&neutralRequests,
+		&errorRate,
+		&throughput,
+		&effectiveConcurrency,
+		&errorClasses,
+		&statusClasses,
+		&diagnostics,
+		&phases,
+		&circuitOpenTime,
+		&circuitOpenings,
+		&globalThrottleTime,
+		&dnsLookups,
+		&avgDNSLatency,
+		&maxDNSLatency,
+		&resolvedAddresses,
+		&protocolVersions,
+		&servedBy,
+		&breakdown,
+		&samplesSeen,
+		&samplesStored,
+		&keepAlivePings,
+		&reconnectsAvoided,
+		&slowestRequests,
+		&variants,
+		&connections,
+		&p50,
+		&p95,
+		&p99,
+		&p999,
+		&worker.CreatedAt,
+		&updatedAt,
+		&finishedAt,
+	)
+	if err != nil {
+		// The columns are scanned in order, the id is set unless it is the one failing.
+		return nil, &RowError{ID: worker.ID, Err: err}
+	}
+
+	// The report isn't JSON, it is only decompressed.
+	if len(report) > 0 {
+		data, _, err := decompress(report)
+		if err != nil {
+			return nil, &RowError{ID: worker.ID, Err: err}
+		}
+		worker.Report = string(data)
+	}
+
+	// The rows stored before updated_at existed were last updated when created.
+	worker.UpdatedAt = worker.CreatedAt
+	if updatedAt.Valid {
+		worker.UpdatedAt = updatedAt.Time
+	}
+	if finishedAt.Valid {
+		worker.FinishedAt = &finishedAt.Time
+	}
+
+	if body != nil {
+		raw := json.RawMessage(body)
+		worker.Body = &raw
+	}
+
+	if recordFile.Valid {
+		worker.RecordFile = recordFile.String
+	}
+
+	assignValidMetricsFromDB(worker, maxLatency, totalRequests, failedRequests, errorRate, throughput, effectiveConcurrency, p50, p95, p99, p999)
+
+	if circuitOpenTime.Valid {
+		worker.Metrics.CircuitOpenTime = circuitOpenTime.Float64
+	}
+
+	if globalThrottleTime.Valid {
+		worker.Metrics.GlobalThrottleTime = globalThrottleTime.Float64
+	}
+
+	if cancelledRequests.Valid {
+		worker.Metrics.CancelledRequests = int(cancelledRequests.Int64)
+	}
+
+	if slowRequests.Valid {
+		worker.Metrics.SlowRequests = int(slowRequests.Int64)
+	}
+
+	if neutralRequests.Valid {
+		worker.Metrics.NeutralRequests = int(neutralRequests.Int64)
+	}
+
+	if circuitOpenings.Valid {
+		worker.Metrics.CircuitOpenings = int(circuitOpenings.Int64)
+	}
+
+	if dnsLookups.Valid {
+		worker.Metrics.DNSLookups = int(dnsLookups.Int64)
+	}
+
+	if samplesSeen.Valid {
+		worker.Metrics.SamplesSeen = int(samplesSeen.Int64)
+	}
+
+	if samplesStored.Valid {
+		worker.Metrics.SamplesStored = int(samplesStored.Int64)
+	}
+
+	if keepAlivePings.Valid {
+		worker.Metrics.KeepAlivePings = int(keepAlivePings.Int64)
+	}
+
+	if reconnectsAvoided.Valid {
+		worker.Metrics.ReconnectsAvoided = int(reconnectsAvoided.Int64)
+	}
+
+	if avgDNSLatency.Valid {
+		worker.Metrics.AvgDNSLatency = avgDNSLatency.Float64
+	}
+
+	if maxDNSLatency.Valid {
+		worker.Metrics.MaxDNSLatency = maxDNSLatency.Float64
+	}
+
+	err = unmarshalJSONColumns(
+		jsonColumn{bodyVariants, &worker.BodyVariants},
+		jsonColumn{warnings, &worker.Warnings},
+		jsonColumn{rampConfig, &worker.RampConfig},
+		jsonColumn{rampResult, &worker.RampResult},
+		jsonColumn{soakConfig, &worker.SoakConfig},
+		jsonColumn{soakResult, &worker.SoakResult},
+		jsonColumn{spikeConfig, &worker.SpikeConfig},
+		jsonColumn{spikeResult, &worker.SpikeResult},
+		jsonColumn{autoTuneConfig, &worker.AutoTuneConfig},
+		jsonColumn{autoTuneResult, &worker.AutoTuneResult},
+		jsonColumn{circuitBreaker, &worker.CircuitBreaker},
+		jsonColumn{retry, &worker.Retry},
+		jsonColumn{captureQuotas, &worker.CaptureQuotas},
+		jsonColumn{capturedResponses, &worker.CapturedResponses},
+		jsonColumn{firstRequest, &worker.FirstRequest},
+		jsonColumn{keepAlive, &worker.KeepAlive},
+		jsonColumn{identities, &worker.Identities},
+		jsonColumn{dataSource, &worker.DataSource},
+		jsonColumn{scenario, &worker.Scenario},
+		jsonColumn{bodyStream, &worker.BodyStream},
+		jsonColumn{provenance, &worker.Provenance},
+		jsonColumn{timeouts, &worker.Timeouts},
+		jsonColumn{successHeader, &worker.SuccessHeader},
+		jsonColumn{bodyAssertion, &worker.BodyAssertion},
+		jsonColumn{servedByHeaders, &worker.ServedByHeaders},
+		jsonColumn{tags, &worker.Tags},
+		jsonColumn{errorClasses, &worker.Metrics.ErrorClasses},
+		jsonColumn{statusClasses, &worker.Metrics.StatusClasses},
+		jsonColumn{diagnostics, &worker.Metrics.Diagnostics},
+		jsonColumn{phases, &worker.Metrics.Phases},
+		jsonColumn{resolvedAddresses, &worker.Metrics.ResolvedAddresses},
+		jsonColumn{protocolVersions, &worker.Metrics.ProtocolVersions},
+		jsonColumn{servedBy, &worker.Metrics.ServedBy},
+		jsonColumn{breakdown, &worker.Metrics.Breakdown},
+		jsonColumn{slowestRequests, &worker.Metrics.SlowestRequests},
+		jsonColumn{variants, &worker.Metrics.Variants},
+		jsonColumn{connections, &worker.Metrics.Connections},
+	)
+	if err != nil {
+		return nil, &RowError{ID: worker.ID, Err: err}
+	}
+
+	if worker.Scenario != nil {
+		worker.ScenarioID = &worker.Scenario.ID
+	}
+
+	return worker, nil
+}
+
+// jsonColumn pairs the raw content of a nullable JSON column with its destination.
+type jsonColumn struct {
+	data []byte
+	dst  any
+}
+
+// unmarshalJSONColumns decodes every non-null column into its destination,
+// the compressed blob columns being decompressed first.
+func unmarshalJSONColumns(columns ...jsonColumn) error {
+	for _, column := range columns {
+		if len(column.data) == 0 {
+			continue
+		}
+		data, _, err := decompress(column.data)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, column.dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func assignValidMetricsFromDB(worker *entity.Worker, maxLatency sql.NullFloat64, totalRequests, failedRequests sql.NullInt64, errorRate, throughput, effectiveConcurrency sql.NullFloat64, p50, p95, p99, p999 sql.NullFloat64) {
+	if maxLatency.Valid {
+		worker.Metrics.MaxLatency = maxLatency.Float64
+	}
+
+	if totalRequests.Valid {
+		worker.Metrics.TotalRequests = int(totalRequests.Int64)
 	}
 
 	if failedRequests.Valid {
@@ -302,6 +1426,14 @@ func assignValidMetricsFromDB(worker *entity.Worker, maxLatency sql.NullFloat64,
 		worker.Metrics.ErrorRate = errorRate.Float64
 	}
 
+	if throughput.Valid {
+		worker.Metrics.Throughput = throughput.Float64
+	}
+
+	if effectiveConcurrency.Valid {
+		worker.Metrics.EffectiveConcurrency = effectiveConcurrency.Float64
+	}
+
 	if p50.Valid {
 		worker.Metrics.Percentiles[entity.P50] = p50.Float64
 	}
@@ -0,0 +1,373 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// workerColumnNames are the columns of workerColumns, in scan order.
+var workerColumnNames = strings.Fields(strings.ReplaceAll(workerColumns, ",", " "))
+
+// newWorkerRepository returns a repository over a mocked database, checked
+// for unmet expectations at the end of the test.
+func newWorkerRepository(t *testing.T) (*WorkerRepositoryDB, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		_ = db.Close()
+	})
+	return NewWorkerRepositoryDB(db), mock
+}
+
+// workerRow returns the values of the row of a worker created with the
+// defaults of Insert, every column of values replacing its default.
+func workerRow(id int, createdAt time.Time, values map[string]driver.Value) []driver.Value {
+	defaults := map[string]driver.Value{
+		"id":                        int64(id),
+		"environment_id":            int64(1),
+		"concurrency":               int64(1),
+		"requests_per_task":         int64(1),
+		"report":                    []byte{},
+		"http_method":               "GET",
+		"body_content_type":         "",
+		"variant_selection":         "",
+		"target_rps":                0.0,
+		"min_throughput_ratio":      0.0,
+		"mode":                      string(entity.ModeFixed),
+		"ramp_up":                   int64(0),
+		"ramp_up_jitter":            0.0,
+		"ramp_down":                 int64(0),
+		"think_time_jitter":         0.0,
+		"on_resource_exhaustion":    string(entity.ExhaustionIgnore),
+		"resolver":                  "",
+		"persist_samples":           false,
+		"sample_cap":                int64(0),
+		"record_requests":           false,
+		"correlation_header":        "",
+		"log_sample_rate":           int64(0),
+		"measure_cold_requests":     false,
+		"latency_failure_threshold": int64(0),
+		"slow_request_policy":       "",
+		"status_3xx":                string(entity.RedirectNeutral),
+		"exclusive":                 false,
+		"seed":                      int64(0),
+		"status":                    string(entity.StatusCreated),
+		"created_at":                createdAt,
+		"updated_at":                createdAt,
+	}
+
+	row := make([]driver.Value, len(workerColumnNames))
+	for i, column := range workerColumnNames {
+		value, ok := values[column]
+		if !ok {
+			value = defaults[column]
+		}
+		row[i] = value
+	}
+	return row
+}
+
+// workerRows returns the rows of the given workers, all created at createdAt.
+func workerRows(createdAt time.Time, ids ...int) *sqlmock.Rows {
+	rows := sqlmock.NewRows(workerColumnNames)
+	for _, id := range ids {
+		rows.AddRow(workerRow(id, createdAt, nil)...)
+	}
+	return rows
+}
+
+func TestGetPage(t *testing.T) {
+	repo, mock := newWorkerRepository(t)
+
+	// Seven workers, the last four created in the same second, read three at a time.
+	first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	second := first.Add(time.Second)
+	createdAt := map[int]time.Time{1: first, 2: first, 3: first, 4: second, 5: second, 6: second, 7: second}
+	const limit = 3
+
+	mock.ExpectQuery(`FROM\s+workers\s+ORDER BY created_at, id\s+LIMIT \?`).
+		WithArgs(limit).
+		WillReturnRows(workerRows(first, 1, 2, 3))
+	mock.ExpectQuery(`WHERE \(created_at, id\) > \(\?, \?\)\s+ORDER BY created_at, id\s+LIMIT \?`).
+		WithArgs(first, 3, limit).
+		WillReturnRows(workerRows(second, 4, 5, 6))
+	mock.ExpectQuery(`WHERE \(created_at, id\) > \(\?, \?\)\s+ORDER BY created_at, id\s+LIMIT \?`).
+		WithArgs(second, 6, limit).
+		WillReturnRows(workerRows(second, 7))
+
+	var (
+		ids   []int
+		after *entity.Cursor
+	)
+	for {
+		workers, err := repo.GetPage(after, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, worker := range workers {
+			if !worker.CreatedAt.Equal(createdAt[worker.ID]) {
+				t.Errorf("worker %d created at %s, want %s", worker.ID, worker.CreatedAt, createdAt[worker.ID])
+			}
+			ids = append(ids, worker.ID)
+		}
+		if len(workers) < limit {
+			break
+		}
+		after = entity.NewWorkerCursor(workers[len(workers)-1])
+	}
+
+	if want := []int{1, 2, 3, 4, 5, 6, 7}; !slices.Equal(ids, want) {
+		t.Errorf("read workers %v, want %v", ids, want)
+	}
+}
+
+func TestFinishRun(t *testing.T) {
+	repo, mock := newWorkerRepository(t)
+
+	metrics := entity.NewMetrics()
+	metrics.TotalRequests = 40
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE workers\s+SET max_latency = \?`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE workers\s+SET status = \?`).
+		WithArgs(entity.StatusFinished, true, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO environment_request_usage`).
+		WithArgs(40, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := repo.FinishRun(7, entity.StatusFinished, metrics); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFinishRunRollsBack(t *testing.T) {
+	repo, mock := newWorkerRepository(t)
+
+	// The metrics are written, then the status fails: neither is committed.
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE workers\s+SET max_latency = \?`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE workers\s+SET status = \?`).
+		WillReturnError(errors.New("connection lost"))
+	mock.ExpectRollback()
+
+	if err := repo.FinishRun(7, entity.StatusFinished, entity.NewMetrics()); err == nil {
+		t.Fatal("FinishRun succeeded, want the error of the status update")
+	}
+}
+
+func TestUpdateMissingWorker(t *testing.T) {
+	tests := []struct {
+		name   string
+		stmt   string
+		exists bool
+		want   error
+		update func(repo *WorkerRepositoryDB) error
+	}{
+		{
+			name: "status of a deleted worker",
+			stmt: `UPDATE workers\s+SET status = \?`,
+			want: custom_errors.ErrNoRecord,
+			update: func(repo *WorkerRepositoryDB) error {
+				return repo.UpdateStatus(7, entity.StatusFinished)
+			},
+		},
+		{
+			name:   "status left unchanged",
+			stmt:   `UPDATE workers\s+SET status = \?`,
+			exists: true,
+			update: func(repo *WorkerRepositoryDB) error {
+				return repo.UpdateStatus(7, entity.StatusFinished)
+			},
+		},
+		{
+			name: "metrics of a deleted worker",
+			stmt: `UPDATE workers\s+SET max_latency = \?`,
+			want: custom_errors.ErrNoRecord,
+			update: func(repo *WorkerRepositoryDB) error {
+				return repo.UpdateMetrics(7, entity.NewMetrics())
+			},
+		},
+		{
+			name:   "metrics left unchanged",
+			stmt:   `UPDATE workers\s+SET max_latency = \?`,
+			exists: true,
+			update: func(repo *WorkerRepositoryDB) error {
+				return repo.UpdateMetrics(7, entity.NewMetrics())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			repo, mock := newWorkerRepository(t)
+
+			// MySQL reports zero rows both for a missing worker and for one the
+			// UPDATE leaves as it is, only the lookup tells them apart.
+			mock.ExpectBegin()
+			mock.ExpectExec(test.stmt).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM workers WHERE id = \?\)`).
+				WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(test.exists))
+			if test.want != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectCommit()
+			}
+
+			if err := test.update(repo); !errors.Is(err, test.want) {
+				t.Fatalf("error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestNullBodyAndReport(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	nulls := map[string]driver.Value{"body": nil, "report": nil}
+	set := map[string]driver.Value{"body": []byte(`{"name":"test"}`), "report": []byte("slow")}
+
+	check := func(t *testing.T, worker *entity.Worker, wantBody, wantReport string) {
+		t.Helper()
+
+		body := ""
+		if worker.Body != nil {
+			body = string(*worker.Body)
+		}
+		if body != wantBody {
+			t.Errorf("worker %d body = %q, want %q", worker.ID, body, wantBody)
+		}
+		if worker.Report != wantReport {
+			t.Errorf("worker %d report = %q, want %q", worker.ID, worker.Report, wantReport)
+		}
+
+		// A body that was never set is left out rather than sent as null.
+		data, err := json.Marshal(worker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := fields["body"]; ok != (wantBody != "") {
+			t.Errorf("worker %d JSON has body = %t, want %t", worker.ID, ok, wantBody != "")
+		}
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		repo, mock := newWorkerRepository(t)
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(`FROM\s+workers\s+WHERE id = \?`).
+			WithArgs(7).
+			WillReturnRows(sqlmock.NewRows(workerColumnNames).AddRow(workerRow(7, createdAt, nulls)...))
+		mock.ExpectCommit()
+
+		worker, err := repo.Get(7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, worker, "", "")
+	})
+
+	t.Run("GetAll", func(t *testing.T) {
+		repo, mock := newWorkerRepository(t)
+
+		mock.ExpectQuery(`FROM\s+workers`).
+			WillReturnRows(sqlmock.NewRows(workerColumnNames).
+				AddRow(workerRow(1, createdAt, nulls)...).
+				AddRow(workerRow(2, createdAt, set)...))
+
+		workers, rowErrors, err := repo.GetAll(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(workers) != 2 || len(rowErrors) != 0 {
+			t.Fatalf("read %d workers and %d row errors, want 2 and 0", len(workers), len(rowErrors))
+		}
+		check(t, workers[0], "", "")
+		check(t, workers[1], `{"name":"test"}`, "slow")
+	})
+}
+
+func TestGetAllPartial(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	// Row 2 has broken JSON in its tags, row 4 a timestamp that isn't one.
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows(workerColumnNames).
+			AddRow(workerRow(1, createdAt, nil)...).
+			AddRow(workerRow(2, createdAt, map[string]driver.Value{"tags": []byte(`["nightly"`)})...).
+			AddRow(workerRow(3, createdAt, nil)...).
+			AddRow(workerRow(4, createdAt, map[string]driver.Value{"created_at": "yesterday"})...)
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		repo, mock := newWorkerRepository(t)
+		mock.ExpectQuery(`FROM\s+workers`).WillReturnRows(rows())
+
+		workers, rowErrors, err := repo.GetAll(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int
+		for _, worker := range workers {
+			ids = append(ids, worker.ID)
+		}
+		if !slices.Equal(ids, []int{1, 3}) {
+			t.Errorf("read workers %v, want the good rows 1 and 3", ids)
+		}
+
+		var errorIDs []int
+		for _, rowErr := range rowErrors {
+			errorIDs = append(errorIDs, rowErr.ID)
+		}
+		if !slices.Equal(errorIDs, []int{2, 4}) {
+			t.Errorf("row errors of %v, want the bad rows 2 and 4", errorIDs)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		repo, mock := newWorkerRepository(t)
+		mock.ExpectQuery(`FROM\s+workers`).WillReturnRows(rows())
+
+		workers, _, err := repo.GetAll(true)
+		var rowErr *RowError
+		if !errors.As(err, &rowErr) || rowErr.ID != 2 {
+			t.Fatalf("err = %v, want the error of row 2", err)
+		}
+		if workers != nil {
+			t.Errorf("read %d workers, want none", len(workers))
+		}
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		repo, mock := newWorkerRepository(t)
+		mock.ExpectQuery(`FROM\s+workers`).WillReturnError(errors.New("connection lost"))
+
+		if _, _, err := repo.GetAll(false); err == nil {
+			t.Fatal("a failed query gave no error, want it to fail the listing")
+		}
+	})
+}
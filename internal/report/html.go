@@ -0,0 +1,70 @@
+package report
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// htmlTemplate lays the report out for a browser. The whole report is
+// inlined as JSON for the charts, nothing is loaded from elsewhere.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"latency": entity.FormatSeconds,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Worker {{.Report.Worker.ID}} report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: right; }
+th { background: #f4f4f4; }
+</style>
+</head>
+<body>
+<h1>Worker {{.Report.Worker.ID}}, environment {{.Report.Worker.EnvironmentID}}</h1>
+<p>Status {{.Report.Worker.Status}}, {{.Report.Worker.Mode}} mode, concurrency {{.Report.Worker.Concurrency}}.</p>
+{{with .Report.Worker.Metrics}}
+<h2>Summary</h2>
+<table>
+<tr><th>Requests</th><th>Failed</th><th>Error rate</th><th>Throughput (req/s)</th><th>Max latency</th></tr>
+<tr><td>{{.TotalRequests}}</td><td>{{.FailedRequests}}</td><td>{{printf "%.4f" .ErrorRate}}</td><td>{{printf "%.2f" .Throughput}}</td><td>{{latency .MaxLatency}}</td></tr>
+</table>
+{{end}}
+{{with .Report.Comparison}}
+<h2>Baseline comparison</h2>
+<p>Against worker {{.BaselineWorkerID}}: throughput {{printf "%+.2f" .ThroughputDelta}} req/s, error rate {{printf "%+.4f" .ErrorRateDelta}}.</p>
+{{range .Regressions}}<p>Regression: {{.}}</p>{{end}}
+{{end}}
+{{if .Report.TopErrors}}
+<h2>Top errors</h2>
+<table>
+<tr><th>Error</th><th>Count</th></tr>
+{{range .Report.TopErrors}}<tr><td>{{.Error}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+{{end}}
+<h2>Latency histogram</h2>
+<table>
+<tr><th>Up to</th><th>Requests</th></tr>
+{{range .Report.Histogram}}<tr><td>{{with .UpperBound}}{{latency .}}{{else}}slower{{end}}</td><td>{{.Requests}}</td></tr>
+{{end}}</table>
+<script type="application/json" id="report-data">{{.Data}}</script>
+</body>
+</html>
+`))
+
+// HTML renders the report as a single page with the report inlined as JSON.
+func (r *Report) HTML(w io.Writer) error {
+	data, err := r.JSON()
+	if err != nil {
+		return err
+	}
+
+	// encoding/json escapes <, > and &, the JSON can't close the script element.
+	return htmlTemplate.Execute(w, struct {
+		Report *Report
+		Data   template.JS
+	}{r, template.JS(data)})
+}
@@ -0,0 +1,148 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// maxTopErrors bounds the error entries of a report.
+const maxTopErrors = 10
+
+// histogramBounds are the upper bounds of the latency histogram buckets, in
+// seconds, the last bucket holding everything slower.
+var histogramBounds = []float64{0.001, 0.002, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2, 5, 10}
+
+// Report is the self-contained artifact of a finished run. It only depends
+// on the worker and its samples, so the same run always renders the same
+// bytes and two reports can be diffed.
+type Report struct {
+	Worker     *dto.WorkerResponse `json:"worker"` // the configuration and the summary metrics
+	TimeSeries []*TimePoint        `json:"time_series"`
+	Histogram  []*HistogramBucket  `json:"histogram"`
+	TopErrors  []*ErrorCount       `json:"top_errors"`
+	Comparison *entity.Comparison  `json:"comparison,omitempty"` // against the baseline of the environment
+}
+
+// TimePoint sums up the successful requests sent during one second of the run.
+type TimePoint struct {
+	Second      int     `json:"second"` // since the first request
+	Requests    int     `json:"requests"`
+	MeanLatency float64 `json:"mean_latency"` // in seconds
+	MaxLatency  float64 `json:"max_latency"`  // in seconds
+}
+
+// HistogramBucket counts the successful requests up to UpperBound and over
+// the bound of the previous bucket.
+type HistogramBucket struct {
+	UpperBound *float64 `json:"upper_bound"` // in seconds, null for the last bucket
+	Requests   int      `json:"requests"`
+}
+
+// ErrorCount is a transport error class, or a 4xx or 5xx status class.
+type ErrorCount struct {
+	Error string `json:"error"`
+	Count int    `json:"count"`
+}
+
+// New builds the report of a worker whose run is over. The time series and
+// the histogram come from its persisted samples, empty without any.
+func New(worker *entity.Worker, samples []entity.LatencySample) *Report {
+	response := dto.NewWorkerResponse(worker)
+	response.Progress = nil
+	response.Comparison = nil
+
+	return &Report{
+		Worker:     response,
+		TimeSeries: timeSeries(samples),
+		Histogram:  histogram(samples),
+		TopErrors:  topErrors(worker.Metrics),
+		Comparison: worker.Comparison,
+	}
+}
+
+// JSON renders the report indented, keys sorted as encoding/json does.
+func (r *Report) JSON() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func timeSeries(samples []entity.LatencySample) []*TimePoint {
+	points := []*TimePoint{}
+	if len(samples) == 0 {
+		return points
+	}
+
+	sorted := make([]entity.LatencySample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].SentAt.Before(sorted[j].SentAt)
+	})
+
+	start := sorted[0].SentAt
+	var point *TimePoint
+	for _, sample := range sorted {
+		second := int(sample.SentAt.Sub(start) / time.Second)
+		if point == nil || point.Second != second {
+			if point != nil {
+				point.MeanLatency /= float64(point.Requests)
+			}
+			point = &TimePoint{Second: second}
+			points = append(points, point)
+		}
+		point.Requests++
+		point.MeanLatency += sample.Latency
+		point.MaxLatency = math.Max(point.MaxLatency, sample.Latency)
+	}
+	point.MeanLatency /= float64(point.Requests)
+	return points
+}
+
+func histogram(samples []entity.LatencySample) []*HistogramBucket {
+	buckets := make([]*HistogramBucket, 0, len(histogramBounds)+1)
+	for i := range histogramBounds {
+		buckets = append(buckets, &HistogramBucket{UpperBound: &histogramBounds[i]})
+	}
+	buckets = append(buckets, &HistogramBucket{})
+
+	for _, sample := range samples {
+		i := sort.SearchFloat64s(histogramBounds, sample.Latency)
+		buckets[i].Requests++
+	}
+	return buckets
+}
+
+// topErrors lists the most frequent errors first, ties by name.
+func topErrors(metrics *entity.Metrics) []*ErrorCount {
+	entries := []*ErrorCount{}
+	if metrics == nil {
+		return entries
+	}
+
+	for class, count := range metrics.ErrorClasses {
+		entries = append(entries, &ErrorCount{Error: string(class), Count: count})
+	}
+	for _, class := range []entity.ResponseClass{entity.ResponseClass4xx, entity.ResponseClass5xx} {
+		if count := metrics.StatusClasses[class]; count > 0 {
+			entries = append(entries, &ErrorCount{Error: string(class), Count: count})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Error < entries[j].Error
+	})
+	return entries[:min(len(entries), maxTopErrors)]
+}
@@ -0,0 +1,119 @@
+package report
+
+import (
+	"bytes"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the reports")
+
+// finishedWorker returns a finished worker with fixed metrics and its samples,
+// three seconds of requests with a slow tail.
+func finishedWorker() (*entity.Worker, []entity.LatencySample) {
+	environment := entity.NewEnvironment("staging", "http://staging.invalid")
+	worker := entity.NewWorker(3, 4, 10, http.MethodGet, nil, environment, zerolog.Nop())
+	worker.ID = 12
+	worker.Seed = 7
+	worker.Status = entity.StatusFinished
+	worker.CreatedAt = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	worker.UpdatedAt = worker.CreatedAt.Add(5 * time.Second)
+	finishedAt := worker.UpdatedAt
+	worker.FinishedAt = &finishedAt
+
+	metrics := entity.NewMetrics()
+	metrics.TotalRequests = 40
+	metrics.FailedRequests = 3
+	metrics.ErrorRate = 0.075
+	metrics.Throughput = 12.5
+	metrics.MaxLatency = 0.75
+	metrics.Percentiles = map[entity.PercentileRank]float64{entity.P50: 0.012, entity.P95: 0.3, entity.P99: 0.7, entity.P999: 0.75}
+	metrics.ErrorClasses = map[entity.ErrorClass]int{entity.ErrorClassTimeout: 2, entity.ErrorClassDial: 1}
+	metrics.StatusClasses = map[entity.ResponseClass]int{entity.ResponseClass2xx: 34, entity.ResponseClass5xx: 3}
+	worker.Metrics = metrics
+
+	worker.Comparison = &entity.Comparison{
+		BaselineWorkerID: 9,
+		ThroughputDelta:  -1.5,
+		ErrorRateDelta:   0.05,
+		PercentileDeltas: map[entity.PercentileRank]float64{entity.P50: 0.002, entity.P95: 0.1, entity.P99: 0.2, entity.P999: 0.25},
+		Regressions:      []string{"p99 latency up 40.0%"},
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)
+	latencies := []float64{0.011, 0.012, 0.0009, 0.015, 0.3, 0.013, 0.75, 0.012, 0.04, 12}
+	samples := make([]entity.LatencySample, len(latencies))
+	for i, latency := range latencies {
+		samples[i] = entity.LatencySample{SentAt: start.Add(time.Duration(i) * 300 * time.Millisecond), Latency: latency, Phase: entity.PhaseMain}
+	}
+	return worker, samples
+}
+
+// checkGolden compares got with the golden file testdata/name, rewriting it with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the rendered report, run go test -update to review the change:\n%s", path, got)
+	}
+}
+
+func TestJSON(t *testing.T) {
+	worker, samples := finishedWorker()
+
+	got, err := New(worker, samples).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.json", got)
+
+	// The maps of the run are iterated in random order, the bytes must not be.
+	for i := 0; i < 10; i++ {
+		again, err := New(finishedWorker()).JSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, again) {
+			t.Fatalf("rendering the same run twice gave different reports")
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	worker, samples := finishedWorker()
+
+	var got bytes.Buffer
+	if err := New(worker, samples).HTML(&got); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report.html", got.Bytes())
+}
+
+func TestWithoutSamples(t *testing.T) {
+	worker, _ := finishedWorker()
+	worker.Comparison = nil
+
+	got, err := New(worker, nil).JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "report_without_samples.json", got)
+}
@@ -0,0 +1,49 @@
+package service
+
+import "github.com/vladComan0/performance-analyzer/internal/model/entity"
+
+// GetCapabilities describes the features the instance supports and the
+// limits validateWorkerInput and the other checks enforce, from the current
+// config and the compiled-in bounds.
+func (s *WorkerServiceImpl) GetCapabilities() *entity.Capabilities {
+	settings := s.settings.Get()
+
+	dataSources := []entity.DataSourceType{entity.DataSourceCSV}
+	if s.sealer != nil {
+		dataSources = append(dataSources, entity.DataSourceSQL)
+	}
+
+	return &entity.Capabilities{
+		HTTPMethods: entity.SupportedHTTPMethods,
+		Modes:       entity.SupportedModes,
+		AuthModes:   []string{entity.AuthNone, entity.AuthTokenEndpoint},
+		DataSources: dataSources,
+		Features: entity.CapabilityFeatures{
+			BodyFiles:        s.bodyFilesDir != "",
+			SQLDataSources:   s.sealer != nil,
+			StrictValidation: settings.StrictValidation,
+			Admin:            settings.AdminToken != "",
+			DemoData:         settings.DemoData,
+		},
+		Limits: entity.CapabilityLimits{
+			MaxConcurrency:          settings.MaxConcurrency,
+			MaxRequests:             settings.MaxRequests,
+			MaxSyncRequests:         MaxSyncRequests,
+			MaxSyncDuration:         entity.Duration(MaxSyncDuration),
+			GlobalMaxRPS:            settings.GlobalMaxRPS,
+			DefaultRequestTimeout:   entity.Duration(settings.DefaultRequestTimeout),
+			MaxSampleCap:            entity.MaxSampleCap,
+			MaxLogSampleRate:        entity.MaxLogSampleRate,
+			MaxBodyVariants:         entity.MaxBodyVariants,
+			MaxCaptureQuota:         entity.MaxCaptureQuota,
+			MaxIdentities:           entity.MaxIdentities,
+			MaxDataSourceRows:       entity.MaxDataSourceRows,
+			MaxDataSourceCSV:        entity.MaxDataSourceCSV,
+			MaxScenarioSteps:        entity.MaxScenarioSteps,
+			MaxRetries:              entity.MaxRetries,
+			MaxServedByHeaders:      entity.MaxServedByHeaders,
+			MaxCampaignEnvironments: MaxCampaignEnvironments,
+			MaxSweepTimeout:         entity.Duration(MaxSweepTimeout),
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// Bounds of the demo data generated at once.
+const (
+	DefaultDemoEnvironments          = 3
+	MaxDemoEnvironments              = 20
+	DefaultDemoWorkersPerEnvironment = 20
+	MaxDemoWorkersPerEnvironment     = 100
+	DefaultDemoSeed                  = 1
+)
+
+// SeedDemoData inserts demo environments, each with a history of finished
+// workers whose metrics are drawn from a profile of the environment. It
+// goes through the repositories like any environment and run, the same seed
+// generating the same data. The environments are marked as demo, so that
+// PruneDemoData deletes them along with their workers. Generating the data
+// of a seed already generated fails on the names of its environments.
+func (s *EnvironmentServiceImpl) SeedDemoData(input dto.DemoDataInput) (*entity.DemoData, error) {
+	environments, workers := input.Environments, input.WorkersPerEnvironment
+	if environments == 0 {
+		environments = DefaultDemoEnvironments
+	}
+	if workers == 0 {
+		workers = DefaultDemoWorkersPerEnvironment
+	}
+	if environments < 0 || environments > MaxDemoEnvironments || workers < 0 || workers > MaxDemoWorkersPerEnvironment {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	seed := int64(DefaultDemoSeed)
+	if input.Seed != nil {
+		seed = *input.Seed
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	data := &entity.DemoData{Seed: seed, EnvironmentIDs: []int{}}
+	for i := 1; i <= environments; i++ {
+		environment := entity.NewEnvironment(
+			fmt.Sprintf("demo-%d-%d", seed, i),
+			fmt.Sprintf("https://demo-%d.example.com/api", i),
+			entity.WithEnvironmentDemo(),
+		)
+		id, err := s.environmentRepo.Insert(environment)
+		if err != nil {
+			return nil, err
+		}
+		environment.ID = id
+		data.EnvironmentIDs = append(data.EnvironmentIDs, id)
+
+		profile := entity.NewDemoProfile(rng)
+		for j := 0; j < workers; j++ {
+			concurrency, requestsPerTask := entity.DemoRunSize(rng)
+			worker := entity.NewWorker(id, concurrency, requestsPerTask, "GET", nil, environment, zerolog.Nop())
+			workerID, err := s.workerRepo.Insert(worker)
+			if err != nil {
+				return nil, err
+			}
+
+			progress := float64(j) / float64(max(workers-1, 1))
+			metrics := entity.DemoMetrics(rng, profile, concurrency, concurrency*requestsPerTask, progress)
+			if err := s.workerRepo.FinishRun(workerID, entity.StatusFinished, metrics); err != nil {
+				return nil, err
+			}
+			data.Workers++
+		}
+	}
+
+	return data, nil
+}
+
+// PruneDemoData deletes every demo environment along with its workers and
+// returns the number of environments deleted.
+func (s *EnvironmentServiceImpl) PruneDemoData() (int, error) {
+	return s.environmentRepo.DeleteDemo()
+}
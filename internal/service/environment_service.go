@@ -1,9 +1,14 @@
 package service
 
 import (
+	"fmt"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
 	"github.com/vladComan0/performance-analyzer/internal/dto"
 	"github.com/vladComan0/performance-analyzer/internal/model/entity"
 	"github.com/vladComan0/performance-analyzer/internal/model/repository"
+	"github.com/vladComan0/performance-analyzer/pkg/validator"
 )
 
 type EnvironmentService interface {
@@ -13,15 +18,23 @@ type EnvironmentService interface {
 	GetEnvironments() ([]*entity.Environment, error)
 	UpdateEnvironment(id int, input dto.UpdateEnvironmentInput) (*entity.Environment, error)
 	DeleteEnvironment(id int) error
+	SetBaseline(id int, input dto.SetBaselineInput) (*entity.Environment, error)
+	SetBodySchema(id int, input dto.SetBodySchemaInput) (*entity.Environment, error)
+	GetRequestUsage(id int) (*entity.RequestUsage, error)
+	DiffEnvironments(a, b int) (*entity.EnvironmentDiff, error)
+	SeedDemoData(input dto.DemoDataInput) (*entity.DemoData, error)
+	PruneDemoData() (int, error)
 }
 
 type EnvironmentServiceImpl struct {
 	environmentRepo repository.EnvironmentRepository
+	workerRepo      repository.WorkerRepository
 }
 
-func NewEnvironmentService(environmentRepo repository.EnvironmentRepository) *EnvironmentServiceImpl {
+func NewEnvironmentService(environmentRepo repository.EnvironmentRepository, workerRepo repository.WorkerRepository) *EnvironmentServiceImpl {
 	return &EnvironmentServiceImpl{
 		environmentRepo: environmentRepo,
+		workerRepo:      workerRepo,
 	}
 }
 
@@ -30,6 +43,18 @@ func (s *EnvironmentServiceImpl) PingDB() error {
 }
 
 func (s *EnvironmentServiceImpl) CreateEnvironment(input dto.CreateEnvironmentInput) (*entity.Environment, error) {
+	v := validator.New()
+	validateMaintenance(v, input.MaintenanceWindows, input.MaintenancePolicy)
+	v.Check(input.DailyRequestQuota >= 0, "daily_request_quota", "must not be negative")
+	validateWorkerDefaults(v, input.WorkerDefaults)
+	v.Check(input.DefaultTimeout >= 0, "default_timeout", "must not be negative")
+	if input.AutoDisable != nil {
+		validateAutoDisable(v, *input.AutoDisable)
+	}
+	if err := invalid(v); err != nil {
+		return nil, err
+	}
+
 	var options []entity.EnvironmentOption
 	if input.TokenEndpoint != nil {
 		options = append(options, entity.WithEnvironmentTokenEndpoint(*input.TokenEndpoint))
@@ -44,6 +69,21 @@ func (s *EnvironmentServiceImpl) CreateEnvironment(input dto.CreateEnvironmentIn
 		options = append(options, entity.WithEnvironmentDisabled(*input.Disabled))
 
 	}
+	if len(input.MaintenanceWindows) > 0 || input.MaintenancePolicy != "" {
+		options = append(options, entity.WithEnvironmentMaintenance(input.MaintenanceWindows, input.MaintenancePolicy))
+	}
+	if input.DailyRequestQuota > 0 {
+		options = append(options, entity.WithEnvironmentDailyRequestQuota(input.DailyRequestQuota))
+	}
+	if len(input.WorkerDefaults) > 0 {
+		options = append(options, entity.WithEnvironmentWorkerDefaults(input.WorkerDefaults))
+	}
+	if input.DefaultTimeout > 0 {
+		options = append(options, entity.WithEnvironmentDefaultTimeout(input.DefaultTimeout))
+	}
+	if input.AutoDisable != nil {
+		options = append(options, entity.WithEnvironmentAutoDisable(*input.AutoDisable))
+	}
 
 	environment := entity.NewEnvironment(input.Name, input.Endpoint, options...)
 	id, err := s.environmentRepo.Insert(environment)
@@ -87,8 +127,56 @@ func (s *EnvironmentServiceImpl) UpdateEnvironment(id int, input dto.UpdateEnvir
 		environment.Password = *input.Password
 	}
 
+	// Edited credentials haven't got a token yet.
+	if input.TokenEndpoint != nil || input.Username != nil || input.Password != nil {
+		environment.LastAuthVerifiedAt = nil
+	}
+
 	if input.Disabled != nil {
+		// The reason was the one of the last disabling, a manual change replaces it.
 		environment.Disabled = *input.Disabled
+		environment.DisabledReason = ""
+		if !environment.Disabled && environment.AutoDisable != nil {
+			// The runs that disabled it no longer count once re-enabled.
+			environment.AutoDisable.Since = time.Now().UTC()
+		}
+	}
+
+	if input.MaintenanceWindows != nil {
+		environment.MaintenanceWindows = *input.MaintenanceWindows
+	}
+
+	if input.MaintenancePolicy != nil {
+		environment.MaintenancePolicy = *input.MaintenancePolicy
+	}
+
+	v := validator.New()
+	if input.DailyRequestQuota != nil {
+		v.Check(*input.DailyRequestQuota >= 0, "daily_request_quota", "must not be negative")
+		environment.DailyRequestQuota = *input.DailyRequestQuota
+	}
+
+	if input.WorkerDefaults != nil {
+		validateWorkerDefaults(v, *input.WorkerDefaults)
+		environment.WorkerDefaults = *input.WorkerDefaults
+	}
+
+	if input.DefaultTimeout != nil {
+		v.Check(*input.DefaultTimeout >= 0, "default_timeout", "must not be negative")
+		environment.DefaultTimeout = *input.DefaultTimeout
+	}
+
+	if input.AutoDisable != nil {
+		environment.AutoDisable = nil
+		if input.AutoDisable.AfterRuns != 0 {
+			validateAutoDisable(v, *input.AutoDisable)
+			entity.WithEnvironmentAutoDisable(*input.AutoDisable)(environment)
+		}
+	}
+
+	validateMaintenance(v, environment.MaintenanceWindows, environment.MaintenancePolicy)
+	if err := invalid(v); err != nil {
+		return nil, err
 	}
 
 	if err := s.environmentRepo.Update(environment); err != nil {
@@ -98,6 +186,99 @@ func (s *EnvironmentServiceImpl) UpdateEnvironment(id int, input dto.UpdateEnvir
 	return s.environmentRepo.Get(environment.ID) // to get the updated environment without the password
 }
 
+// SetBaseline designates a finished worker of the environment as the run every other run is compared to.
+func (s *EnvironmentServiceImpl) SetBaseline(id int, input dto.SetBaselineInput) (*entity.Environment, error) {
+	environment, err := s.environmentRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	worker, err := s.workerRepo.Get(input.WorkerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if worker.EnvironmentID != environment.ID || worker.Status != entity.StatusFinished {
+		return nil, custom_errors.ErrInvalidBaseline
+	}
+
+	if err := s.environmentRepo.SetBaseline(environment.ID, worker.ID); err != nil {
+		return nil, err
+	}
+
+	return s.environmentRepo.Get(environment.ID)
+}
+
+// SetBodySchema sets the JSON schema the bodies of the new workers of the environment must match.
+func (s *EnvironmentServiceImpl) SetBodySchema(id int, input dto.SetBodySchemaInput) (*entity.Environment, error) {
+	if input.Schema != nil && !input.Schema.Valid() {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	if err := s.environmentRepo.SetBodySchema(id, input.Schema); err != nil {
+		return nil, err
+	}
+
+	return s.environmentRepo.Get(id)
+}
+
+// DiffEnvironments compares the settings of two environments, ErrNoRecord
+// if either is missing.
+func (s *EnvironmentServiceImpl) DiffEnvironments(a, b int) (*entity.EnvironmentDiff, error) {
+	environmentA, err := s.environmentRepo.Get(a)
+	if err != nil {
+		return nil, err
+	}
+	environmentB, err := s.environmentRepo.Get(b)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.DiffEnvironments(environmentA, environmentB)
+}
+
+// GetRequestUsage returns the requests counted for the environment during
+// the current UTC day against its daily request quota. The runs still going
+// on aren't counted yet.
+func (s *EnvironmentServiceImpl) GetRequestUsage(id int) (*entity.RequestUsage, error) {
+	environment, err := s.environmentRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	day, requests, err := s.environmentRepo.GetRequestUsage(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewRequestUsage(environment, day, requests), nil
+}
+
+func validateMaintenance(v *validator.Validator, windows []entity.MaintenanceWindow, policy entity.MaintenancePolicy) {
+	switch policy {
+	case "", entity.MaintenanceReject, entity.MaintenanceDefer:
+	default:
+		v.AddError("maintenance_policy", "is unknown")
+	}
+
+	for i, window := range windows {
+		v.Check(window.Name != "" && window.Valid(), validator.Index("maintenance_windows", i), "must be named and have a valid schedule")
+	}
+}
+
+// validateWorkerDefaults checks the default worker settings on their own,
+// the merged worker being validated again when one is created.
+func validateWorkerDefaults(v *validator.Validator, defaults entity.WorkerDefaults) {
+	if err := defaults.Validate(); err != nil {
+		v.AddError("default_worker_settings", "must be settings of a worker")
+	}
+}
+
+func validateAutoDisable(v *validator.Validator, policy entity.AutoDisablePolicy) {
+	v.Check(policy.AfterRuns >= 1 && policy.AfterRuns <= entity.MaxAutoDisableRuns, "auto_disable.after_runs", fmt.Sprintf("must be between 1 and %d", entity.MaxAutoDisableRuns))
+	v.Check(policy.ErrorRate >= 0 && policy.ErrorRate <= 1, "auto_disable.error_rate", "must be between 0 and 1")
+}
+
 func (s *EnvironmentServiceImpl) DeleteEnvironment(id int) error {
 	return s.environmentRepo.Delete(id)
 }
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/pkg/validator"
+	"time"
+)
+
+// MaxCampaignEnvironments bounds the number of workers a campaign creates.
+const MaxCampaignEnvironments = 20
+
+// CreateCampaign creates a worker from the template for every environment,
+// all of them linked to a new campaign, the default worker settings of each
+// environment applied to the settings the template leaves unset. Every environment is checked before
+// the first worker is created, so a campaign is only rejected as a whole. If
+// a worker still fails to be created, the ones created before it are
+// cancelled.
+func (s *WorkerServiceImpl) CreateCampaign(ctx context.Context, input dto.CampaignInput) (*entity.Campaign, error) {
+	template := input.Template
+	v := validator.New()
+	v.Check(template != nil, "template", "is required")
+	v.Check(template == nil || template.EnvironmentID == 0, "template.environment_id", "is set by the campaign for every environment")
+	v.Check(len(input.EnvironmentIDs) > 0 && len(input.EnvironmentIDs) <= MaxCampaignEnvironments, "environment_ids", fmt.Sprintf("must list between 1 and %d environments", MaxCampaignEnvironments))
+
+	seen := make(map[int]bool, len(input.EnvironmentIDs))
+	for i, environmentID := range input.EnvironmentIDs {
+		v.Check(environmentID >= 1, validator.Index("environment_ids", i), "must be a positive id")
+		v.Check(!seen[environmentID], validator.Index("environment_ids", i), "is listed twice")
+		seen[environmentID] = true
+	}
+	if err := invalid(v); err != nil {
+		return nil, err
+	}
+
+	for _, environmentID := range input.EnvironmentIDs {
+		template.EnvironmentID = environmentID
+		worker, err := s.withEnvironmentDefaults(template)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkCampaignWorker(ctx, worker); err != nil {
+			// The violations are the ones of the template, whatever the environment.
+			var validationErr *validator.Error
+			if errors.As(err, &validationErr) {
+				v.Merge("template", validationErr)
+				return nil, invalid(v)
+			}
+			return nil, err
+		}
+	}
+
+	id, err := s.campaignRepo.Insert()
+	if err != nil {
+		return nil, err
+	}
+
+	template.CampaignID = &id
+	for _, environmentID := range input.EnvironmentIDs {
+		template.EnvironmentID = environmentID
+		worker, err := s.withEnvironmentDefaults(template)
+		if err == nil {
+			_, _, err = s.createWorker(ctx, worker, true, false)
+		}
+		if err != nil {
+			s.log.Error().Err(err).Msgf("Error creating the worker of campaign %d for environment %d, cancelling the campaign", id, environmentID)
+			if _, cancelErr := s.CancelCampaign(id); cancelErr != nil {
+				s.log.Error().Err(cancelErr).Msgf("Error cancelling campaign %d", id)
+			}
+			return nil, err
+		}
+	}
+
+	return s.GetCampaign(id)
+}
+
+// checkCampaignWorker runs the checks of createWorker for the worker of a
+// campaign, without creating it.
+func (s *WorkerServiceImpl) checkCampaignWorker(ctx context.Context, input *entity.Worker) error {
+	if err := s.validateWorkerInput(input); err != nil {
+		return err
+	}
+
+	environment, err := s.targetEnvironment(input)
+	if err != nil {
+		return err
+	}
+
+	if environment.Disabled {
+		return custom_errors.ErrEnvironmentDisabled
+	}
+
+	if err := s.checkPlan(s.plan(s.newWorker(input, environment), environment)); err != nil {
+		return err
+	}
+
+	if !autostart(input) {
+		return nil
+	}
+	_, err = s.checkLaunch(ctx, input, environment, true)
+	return err
+}
+
+// GetCampaign returns a campaign with the progress of its workers, live for
+// the ones being run.
+func (s *WorkerServiceImpl) GetCampaign(id int) (*entity.Campaign, error) {
+	campaign, err := s.campaignRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, worker := range campaign.Workers {
+		s.setProgress(worker)
+	}
+	return campaign, nil
+}
+
+// CancelCampaign stops the workers of a campaign being run or waiting to
+// start and cancels the ones that weren't started. The workers whose run is
+// over are left as they are.
+func (s *WorkerServiceImpl) CancelCampaign(id int) (*entity.Campaign, error) {
+	campaign, err := s.campaignRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	warning := fmt.Sprintf("cancelled with campaign %d at %s", id, time.Now().UTC().Format(time.RFC3339))
+	for _, worker := range campaign.Workers {
+		if worker.Status.Over() || s.stopWorker(worker.ID, warning) {
+			continue
+		}
+		// A running worker missing from the registry belongs to another instance of the server.
+		if worker.Status != entity.StatusCreated && worker.Status != entity.StatusBlocked {
+			continue
+		}
+
+		if err := s.workerRepo.UpdateStatus(worker.ID, entity.StatusCancelled); err != nil {
+			return nil, err
+		}
+		if err := s.workerRepo.AddWarning(worker.ID, warning); err != nil {
+			s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", worker.ID)
+		}
+	}
+
+	return s.GetCampaign(id)
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// ExclusiveError rejects a worker that can't run alongside the workers
+// running against its environment, either side being exclusive.
+type ExclusiveError struct {
+	WorkerIDs []int // of the workers in the way, ordered
+}
+
+func (e *ExclusiveError) Error() string {
+	return fmt.Sprintf("the environment is taken by workers %v, an exclusive worker runs alone", e.WorkerIDs)
+}
+
+// checkExclusive rejects worker when it is exclusive and another worker
+// runs against its environment, or when an exclusive one does. The workers
+// waiting for a maintenance window to end already hold the environment.
+// Only the check made under launchMu by startWorker is authoritative, the
+// earlier ones spare storing a worker bound to be rejected.
+func (s *WorkerServiceImpl) checkExclusive(worker *entity.Worker) error {
+	var blocking []int
+	s.running.Range(func(key, value any) bool {
+		other := value.(*runningWorker).worker
+		if key.(int) == worker.ID || other.EnvironmentID != worker.EnvironmentID {
+			return true
+		}
+		if worker.Exclusive || other.Exclusive {
+			blocking = append(blocking, key.(int))
+		}
+		return true
+	})
+
+	if len(blocking) == 0 {
+		return nil
+	}
+	sort.Ints(blocking)
+	return fmt.Errorf("%w: %w", custom_errors.ErrExclusiveRun, &ExclusiveError{WorkerIDs: blocking})
+}
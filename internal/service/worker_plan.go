@@ -0,0 +1,218 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// minRequestsPerGoroutine is the fewest requests per goroutine below which a
+// run mostly measures the start of its goroutines.
+const minRequestsPerGoroutine = 10
+
+// maxRampUpShare is the largest share of the requests sent during the ramp up.
+const maxRampUpShare = 0.5
+
+// Plan is what a run is expected to do, computed from its configuration
+// before it starts. The latency of the target is unknown upfront, so the
+// figures of a fixed run assume instant responses.
+type Plan struct {
+	Requests        *int             `json:"requests"` // null when it can't be known upfront
+	Duration        *entity.Duration `json:"duration"` // null when it can't be known upfront
+	PeakConcurrency int              `json:"peak_concurrency"`
+	PeakRPS         *float64         `json:"peak_rps"` // null when only the latency of the target bounds it
+	Warnings        []string         `json:"warnings,omitempty"`
+}
+
+// PlanError rejects a run whose plan has warnings when the validation is strict.
+type PlanError struct {
+	Warnings []string
+}
+
+func (e *PlanError) Error() string {
+	return strings.Join(e.Warnings, "; ")
+}
+
+// EstimateWorker validates the input like a creation and returns the plan
+// of the run it describes, without storing nor sending anything.
+func (s *WorkerServiceImpl) EstimateWorker(input *entity.Worker) (*Plan, error) {
+	if err := s.validateWorkerInput(input); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.targetEnvironment(input)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := s.plan(s.newWorker(input, environment), environment)
+	if err := s.checkPlan(plan); err != nil {
+		return nil, err
+	}
+	if environment.Disabled {
+		plan.Warnings = append(plan.Warnings, "the environment is disabled, the worker can only be created blocked")
+	}
+	return plan, nil
+}
+
+// plan estimates the run of a worker built by newWorker, the median latency
+// of the baseline of its environment, if any, standing for the expected
+// response time of the target.
+func (s *WorkerServiceImpl) plan(worker *entity.Worker, environment *entity.Environment) *Plan {
+	var expectedLatency time.Duration
+	if environment.BaselineWorkerID != nil {
+		baseline, err := s.workerRepo.Get(*environment.BaselineWorkerID)
+		switch {
+		case err == nil:
+			if baseline.Metrics != nil {
+				expectedLatency = time.Duration(baseline.Metrics.Percentiles[entity.P50] * float64(time.Second)).Round(time.Millisecond)
+			}
+		case !errors.Is(err, custom_errors.ErrNoRecord):
+			s.log.Error().Err(err).Msgf("Error getting the baseline of environment %d", environment.ID)
+		}
+	}
+
+	return newPlan(worker, expectedLatency)
+}
+
+// checkPlan rejects a plan with warnings when the validation is strict.
+func (s *WorkerServiceImpl) checkPlan(plan *Plan) error {
+	if !s.settings.Get().StrictValidation || len(plan.Warnings) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", custom_errors.ErrDoubtfulPlan, &PlanError{Warnings: plan.Warnings})
+}
+
+// newPlan estimates the run of a worker built by newWorker, expectedLatency
+// being zero when the latency of the target is unknown.
+func newPlan(worker *entity.Worker, expectedLatency time.Duration) *Plan {
+	plan := &Plan{PeakConcurrency: peakConcurrency(worker)}
+
+	if requests, known := worker.EstimatedRequests(); known {
+		plan.Requests = &requests
+	}
+	if duration, known := worker.EstimatedDuration(); known {
+		estimated := entity.Duration(duration)
+		plan.Duration = &estimated
+	}
+	if rps, known := peakRPS(worker, plan.PeakConcurrency); known {
+		plan.PeakRPS = &rps
+	}
+
+	plan.Warnings = planWarnings(worker, plan, expectedLatency)
+	return plan
+}
+
+// peakConcurrency is the most goroutines sending at once. The goroutines of
+// a fixed run start one after another over the ramp up, so the first ones
+// may be done before the last ones start.
+func peakConcurrency(worker *entity.Worker) int {
+	if worker.Mode != entity.ModeFixed || worker.RampUp <= 0 || worker.Concurrency < 2 {
+		return worker.Concurrency
+	}
+
+	gap := time.Duration(worker.RampUp) / time.Duration(worker.Concurrency)
+	lifetime := time.Duration(worker.RequestsPerTask) * worker.MeanThinkTime()
+	return max(min(worker.Concurrency, int(math.Ceil(float64(lifetime)/float64(gap)))), 1)
+}
+
+// goroutines is the number of goroutines sending requests over the run, an
+// auto tune starting up to its maximum concurrency.
+func goroutines(worker *entity.Worker) int {
+	if worker.Mode == entity.ModeAutoTune && worker.AutoTuneConfig != nil {
+		return worker.AutoTuneConfig.MaxConcurrency
+	}
+	return worker.Concurrency
+}
+
+// peakRPS is the highest rate of a run, which only depends on the think
+// time and the goroutines running at once for a fixed run and on the
+// configured rates for the paced ones.
+func peakRPS(worker *entity.Worker, concurrency int) (float64, bool) {
+	switch worker.Mode {
+	case entity.ModeSoak:
+		return worker.SoakConfig.RPS, true
+	case entity.ModeSpike:
+		return max(worker.SpikeConfig.BaselineRPS, worker.SpikeConfig.SpikeRPS), true
+	case entity.ModeRampToFailure:
+		config := worker.RampConfig
+		if config.MaxDuration <= 0 || config.StepDuration <= 0 {
+			return 0, false
+		}
+		steps := math.Ceil(float64(config.MaxDuration) / float64(config.StepDuration))
+		return config.StartRPS + config.StepRPS*(steps-1), true
+	case entity.ModeAutoTune:
+		return 0, false
+	default:
+		thinkTime := worker.MeanThinkTime()
+		if thinkTime <= 0 {
+			return 0, false
+		}
+		return float64(concurrency) / thinkTime.Seconds(), true
+	}
+}
+
+// rampUpShare is the share of the requests of a fixed run sent during its
+// ramp up, false for the runs without one. Every goroutine is assumed to send
+// a request every mean think time from its start.
+func rampUpShare(worker *entity.Worker) (float64, bool) {
+	if worker.Mode != entity.ModeFixed || worker.RampUp <= 0 || worker.Concurrency*worker.RequestsPerTask == 0 {
+		return 0, false
+	}
+
+	rampUp := time.Duration(worker.RampUp)
+	gap := rampUp / time.Duration(worker.Concurrency)
+	thinkTime := worker.MeanThinkTime()
+	requests := 0
+	for i := 0; i < worker.Concurrency; i++ {
+		left := rampUp - time.Duration(i)*gap
+		if thinkTime <= 0 {
+			requests += worker.RequestsPerTask
+			continue
+		}
+		requests += min(worker.RequestsPerTask, int(math.Ceil(float64(left)/float64(thinkTime))))
+	}
+	return float64(requests) / float64(worker.Concurrency*worker.RequestsPerTask), true
+}
+
+// planWarnings lists what makes the configuration of a run doubtful.
+func planWarnings(worker *entity.Worker, plan *Plan, expectedLatency time.Duration) []string {
+	var warnings []string
+
+	if worker.TargetRPS > 0 && plan.PeakRPS != nil && worker.TargetRPS > *plan.PeakRPS {
+		if worker.Mode == entity.ModeFixed {
+			warnings = append(warnings, fmt.Sprintf("target_rps %.2f unreachable with concurrency %d and think time %s, at most %.2f req/s", worker.TargetRPS, plan.PeakConcurrency, worker.MeanThinkTime(), *plan.PeakRPS))
+		} else {
+			warnings = append(warnings, fmt.Sprintf("target_rps %.2f unreachable, the rate of the run peaks at %.2f req/s", worker.TargetRPS, *plan.PeakRPS))
+		}
+	}
+
+	if plan.PeakConcurrency < worker.Concurrency {
+		warnings = append(warnings, fmt.Sprintf("the goroutines are done before ramp_up %s ends, at most %d of the %d run at once", time.Duration(worker.RampUp), plan.PeakConcurrency, worker.Concurrency))
+	}
+
+	if plan.Requests != nil && *plan.Requests < minRequestsPerGoroutine*goroutines(worker) {
+		warnings = append(warnings, fmt.Sprintf("%d requests for %d goroutines, under %d per goroutine the run mostly measures their start", *plan.Requests, goroutines(worker), minRequestsPerGoroutine))
+	}
+
+	if worker.TargetRPS > 0 && expectedLatency > 0 && worker.Mode == entity.ModeFixed && worker.MeanThinkTime() > expectedLatency {
+		warnings = append(warnings, fmt.Sprintf("think time %s exceeds the expected response time %s with target_rps set, the rate depends on the think time rather than on the target", worker.MeanThinkTime(), expectedLatency))
+	}
+
+	if share, known := rampUpShare(worker); known && share > maxRampUpShare {
+		warnings = append(warnings, fmt.Sprintf("%.0f%% of the requests are sent during ramp_up %s, over %.0f%% of the run is a warm up", share*100, time.Duration(worker.RampUp), maxRampUpShare*100))
+	}
+
+	if identities := worker.Identities; identities != nil && len(identities.Values) > 0 && len(identities.Values) < goroutines(worker) {
+		warnings = append(warnings, fmt.Sprintf("%d identities for %d goroutines, the identities are cycled and some of them shared", len(identities.Values), goroutines(worker)))
+	}
+
+	warnings = append(warnings, worker.URLWarnings()...)
+
+	return warnings
+}
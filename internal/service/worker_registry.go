@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// RegistryEntry is a worker tracked in memory by this instance of the server.
+type RegistryEntry struct {
+	WorkerID    int           `json:"worker_id"`
+	Status      entity.Status `json:"status"` // as seen in memory
+	TrackedAt   time.Time     `json:"tracked_at"`
+	Cancellable bool          `json:"cancellable"`
+	Stopping    bool          `json:"stopping"` // cancelled, its run not over yet
+}
+
+// Reconciliation lists what ReconcileRegistry changed.
+type Reconciliation struct {
+	Removed []int `json:"removed"` // tracked, while the database has them deleted or over
+	Failed  []int `json:"failed"`  // running in the database, while nothing tracked them
+}
+
+// GetRegistry lists the workers tracked in memory, ordered by id.
+func (s *WorkerServiceImpl) GetRegistry() []*RegistryEntry {
+	entries := []*RegistryEntry{}
+	s.running.Range(func(key, value any) bool {
+		running := value.(*runningWorker)
+		entries = append(entries, &RegistryEntry{
+			WorkerID:    key.(int),
+			Status:      running.worker.GetStatus(),
+			TrackedAt:   running.trackedAt,
+			Cancellable: running.cancel != nil,
+			Stopping:    running.ctx.Err() != nil,
+		})
+		return true
+	})
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].WorkerID < entries[j].WorkerID
+	})
+	return entries
+}
+
+// ReconcileRegistry repairs the drift between the registry and the
+// database. The tracked workers deleted from the database or whose run is
+// over there are stopped and untracked, and the workers running in the
+// database that nothing tracks are marked as failed, their run having been
+// lost. The latter assumes a single instance of the server runs workers
+// against the database.
+func (s *WorkerServiceImpl) ReconcileRegistry() (*Reconciliation, error) {
+	reconciliation := &Reconciliation{Removed: []int{}, Failed: []int{}}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	for _, entry := range s.GetRegistry() {
+		stored, err := s.workerRepo.Get(entry.WorkerID)
+		if err != nil && !errors.Is(err, custom_errors.ErrNoRecord) {
+			return nil, err
+		}
+		if err == nil && !stored.Status.Over() {
+			continue
+		}
+
+		value, ok := s.running.LoadAndDelete(entry.WorkerID)
+		if !ok {
+			// Its run ended meanwhile.
+			continue
+		}
+		value.(*runningWorker).cancel()
+		s.log.Warn().Msgf("Worker %d was removed from the registry by a reconciliation", entry.WorkerID)
+		reconciliation.Removed = append(reconciliation.Removed, entry.WorkerID)
+	}
+
+	ids, err := s.workerRepo.GetIDsByStatus(entity.StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if _, tracked := s.running.Load(id); tracked {
+			continue
+		}
+
+		// A run ending meanwhile has its status stored before it is untracked, it is left as it is.
+		failed, err := s.workerRepo.FailRunning(id)
+		if err != nil {
+			return nil, err
+		}
+		if !failed {
+			continue
+		}
+
+		s.log.Warn().Msgf("Worker %d was running without being tracked, marked as failed by a reconciliation", id)
+		if err := s.workerRepo.AddWarning(id, fmt.Sprintf("marked as failed by a registry reconciliation at %s, its run was lost", now)); err != nil {
+			s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", id)
+		}
+		reconciliation.Failed = append(reconciliation.Failed, id)
+	}
+
+	return reconciliation, nil
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// maxScenarioName bounds the name of a scenario.
+const maxScenarioName = 255
+
+// ImportError rejects a HAR file without a single entry that can be replayed.
+type ImportError struct {
+	Unsupported []string
+}
+
+func (e *ImportError) Error() string {
+	return strings.Join(e.Unsupported, "; ")
+}
+
+// ImportHAR stores the scenario replaying the entries of a HAR file, the
+// entries that can't be replayed being listed in it. With rebase, the
+// workers send the steps to the scheme and host of their environment
+// rather than to the ones captured. With shuffle, every goroutine replays
+// the steps in a new order on every pass, for steps that don't depend on
+// one another.
+func (s *WorkerServiceImpl) ImportHAR(name string, rebase, shuffle bool, data []byte) (*entity.Scenario, error) {
+	if name == "" || len(name) > maxScenarioName {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	steps, unsupported, err := entity.ParseHAR(data)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case errors.Is(err, entity.ErrEmptyScenario):
+			return nil, fmt.Errorf("%w: %w", custom_errors.ErrInvalidInput, &ImportError{Unsupported: unsupported})
+		case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+			return nil, custom_errors.ErrInvalidInput
+		default:
+			return nil, err
+		}
+	}
+
+	scenario := &entity.Scenario{
+		Name:        name,
+		Rebase:      rebase,
+		Shuffle:     shuffle,
+		Steps:       steps,
+		Unsupported: unsupported,
+	}
+	if scenario.ID, err = s.scenarioRepo.Insert(scenario); err != nil {
+		return nil, err
+	}
+	s.log.Info().Msgf("Scenario %d imported with %d steps, %d entries left out", scenario.ID, len(steps), len(unsupported))
+
+	return s.scenarioRepo.Get(scenario.ID)
+}
+
+func (s *WorkerServiceImpl) GetScenario(id int) (*entity.Scenario, error) {
+	return s.scenarioRepo.Get(id)
+}
+
+// attachScenario copies the scenario the input refers to into it, a
+// missing scenario being invalid input.
+func (s *WorkerServiceImpl) attachScenario(input *entity.Worker) error {
+	if input.ScenarioID == nil || input.Scenario != nil && input.Scenario.ID == *input.ScenarioID {
+		return nil
+	}
+
+	scenario, err := s.scenarioRepo.Get(*input.ScenarioID)
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrNoRecord) {
+			return custom_errors.ErrInvalidInput
+		}
+		return err
+	}
+	input.Scenario = scenario
+	return nil
+}
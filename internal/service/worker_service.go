@@ -1,71 +1,473 @@
 package service
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/config"
 	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/dto"
 	"github.com/vladComan0/performance-analyzer/internal/model/entity"
 	"github.com/vladComan0/performance-analyzer/internal/model/repository"
+	"github.com/vladComan0/performance-analyzer/internal/report"
+	"github.com/vladComan0/performance-analyzer/pkg/secrets"
 	"github.com/vladComan0/performance-analyzer/pkg/tokens"
+	"github.com/vladComan0/performance-analyzer/pkg/validator"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 type WorkerService interface {
-	CreateWorker(ctx context.Context, input *entity.Worker) (*entity.Worker, error)
+	CreateWorker(ctx context.Context, input *entity.Worker, allowBlocked bool) (*entity.Worker, error)
+	StartWorker(ctx context.Context, id int) (*entity.Worker, error)
+	RunWorker(ctx context.Context, input *entity.Worker) (*entity.Worker, error)
+	ValidateWorker(input *entity.Worker) (*entity.Worker, error)
+	EstimateWorker(input *entity.Worker) (*Plan, error)
 	GetWorker(id int) (*entity.Worker, error)
-	GetWorkers() ([]*entity.Worker, error)
+	GetWorkerSummary(id int) (*entity.Worker, error)
+	GetWorkers(strict bool) ([]*entity.Worker, []string, error)
+	GetWorkerPage(cursor string, limit int) ([]*entity.Worker, string, error)
+	GetBreakdown(id int) ([]entity.StageTiming, error)
+	GetSamples(id int) (*LatencySamples, error)
+	GetCDF(id, points int) ([]entity.CDFPoint, error)
+	GetReport(id int) (*report.Report, error)
+	GetRecordFile(id int) (string, error)
+	ExportWorkers(fn func(workers []*entity.Worker) error) error
+	SweepEnvironments(ctx context.Context, input dto.SweepInput) ([]*entity.ProbeResult, *entity.BudgetUsage, error)
+	VerifyEnvironmentAuth(ctx context.Context, id int) (*entity.AuthCheck, error)
+	StopAll() []int
+	BeginShutdown()
+	GetStorageStats() (*entity.StorageStats, error)
+	GetRegistry() []*RegistryEntry
+	ReconcileRegistry() (*Reconciliation, error)
+	CreateCampaign(ctx context.Context, input dto.CampaignInput) (*entity.Campaign, error)
+	GetCampaign(id int) (*entity.Campaign, error)
+	CancelCampaign(id int) (*entity.Campaign, error)
+	ImportHAR(name string, rebase, shuffle bool, data []byte) (*entity.Scenario, error)
+	GetScenario(id int) (*entity.Scenario, error)
+	GetCapabilities() *entity.Capabilities
+	GetTrend(tag string, from, to time.Time, bucket time.Duration) (*entity.TrendReport, error)
 }
 
+// exportPageSize is the number of workers loaded at once by ExportWorkers.
+const exportPageSize = 500
+
+// Bounds of a page of the workers listed with a cursor.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = exportPageSize
+)
+
+// resolverCheckTimeout bounds the lookup made to validate a custom resolver.
+const resolverCheckTimeout = 5 * time.Second
+
+// authCheckTimeout bounds the token fetch verifying the credentials of an environment.
+const authCheckTimeout = 10 * time.Second
+
+// Bounds of the timeout of a single sweep request.
+const (
+	DefaultSweepTimeout = 3 * time.Second
+	MaxSweepTimeout     = 30 * time.Second
+)
+
+// Synchronous runs are reserved to smoke tests, anything bigger must be polled.
+const (
+	MaxSyncRequests = 100
+	MaxSyncDuration = 30 * time.Second
+)
+
 type WorkerServiceImpl struct {
 	workerRepo      repository.WorkerRepository
 	environmentRepo repository.EnvironmentRepository
+	campaignRepo    repository.CampaignRepository
+	scenarioRepo    repository.ScenarioRepository
+	recordsDir      string              // where the request record files are written
+	bodyFilesDir    string              // where the streamed body files are read from, none are accepted when empty
+	settings        *config.Holder      // read anew every time, the config may be reloaded
+	sealer          *secrets.Sealer     // of the DSN of the data sources, nil when no key is configured
+	limiter         *entity.RateLimiter // shared by every worker, nil when unlimited
+	stats           *entity.StatsD      // shared by every worker, nil when not exporting
 	log             zerolog.Logger
+	running         sync.Map   // worker id to its *runningWorker
+	launchMu        sync.Mutex // makes checking the exclusive runs and tracking a worker a single step
+	shuttingDown    bool       // guarded by launchMu, no worker is started once set
+}
+
+// runningWorker is a worker tracked from its creation to the end of its
+// run, for its live progress and to stop it.
+type runningWorker struct {
+	worker    *entity.Worker
+	ctx       context.Context
+	cancel    context.CancelFunc
+	trackedAt time.Time
 }
 
-func NewWorkerService(workerRepo repository.WorkerRepository, environmentRepo repository.EnvironmentRepository, log zerolog.Logger) *WorkerServiceImpl {
+func NewWorkerService(workerRepo repository.WorkerRepository, environmentRepo repository.EnvironmentRepository, campaignRepo repository.CampaignRepository, scenarioRepo repository.ScenarioRepository, recordsDir, bodyFilesDir string, settings *config.Holder, sealer *secrets.Sealer, limiter *entity.RateLimiter, stats *entity.StatsD, log zerolog.Logger) *WorkerServiceImpl {
 	return &WorkerServiceImpl{
 		workerRepo:      workerRepo,
 		environmentRepo: environmentRepo,
+		campaignRepo:    campaignRepo,
+		scenarioRepo:    scenarioRepo,
+		recordsDir:      recordsDir,
+		bodyFilesDir:    bodyFilesDir,
+		settings:        settings,
+		sealer:          sealer,
+		limiter:         limiter,
+		stats:           stats,
 		log:             log,
 	}
 }
 
-func (s *WorkerServiceImpl) CreateWorker(ctx context.Context, input *entity.Worker) (*entity.Worker, error) {
+// CreateWorker inserts and starts a worker, unless its input turns
+// autostart off, in which case it is started with StartWorker. With
+// allowBlocked, a worker targeting a disabled environment is stored as
+// blocked instead of being rejected, to be started with StartWorker once
+// the environment is enabled.
+func (s *WorkerServiceImpl) CreateWorker(ctx context.Context, input *entity.Worker, allowBlocked bool) (*entity.Worker, error) {
+	input, err := s.withEnvironmentDefaults(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateWorkerInput(input); err != nil {
+		return nil, err
+	}
+
+	worker, done, err := s.createWorker(ctx, input, true, allowBlocked)
+	if err != nil || done == nil {
+		return worker, err
+	}
+	// The worker is being run, the caller gets it read back rather than shared with the run.
+	return s.GetWorkerSummary(worker.ID)
+}
+
+// RunWorker creates a worker and blocks until its run is over, returning it
+// with its final metrics. Only runs within MaxSyncRequests and MaxSyncDuration
+// are accepted. If ctx is cancelled first the run carries on in the background.
+func (s *WorkerServiceImpl) RunWorker(ctx context.Context, input *entity.Worker) (*entity.Worker, error) {
+	input, err := s.withEnvironmentDefaults(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateWorkerInput(input); err != nil {
+		return nil, err
+	}
+
+	if !autostart(input) {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	requests, knownRequests := input.EstimatedRequests()
+	duration, knownDuration := input.EstimatedDuration()
+	if !knownRequests || !knownDuration || requests >= MaxSyncRequests || duration >= MaxSyncDuration {
+		return nil, custom_errors.ErrTooLargeForSync
+	}
+
+	// Waiting for a maintenance window to end would outlast any synchronous run.
+	worker, done, err := s.createWorker(ctx, input, false, false)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	worker.Progress = worker.GetProgress()
+	return worker, nil
+}
+
+// ValidateWorker runs every check of the worker creation that neither
+// writes nor sends anything and returns the worker the input describes,
+// its defaults applied. The resolver isn't queried and the maintenance
+// windows, which only depend on the time of the creation, aren't checked.
+func (s *WorkerServiceImpl) ValidateWorker(input *entity.Worker) (*entity.Worker, error) {
+	input, err := s.withEnvironmentDefaults(input)
+	if err != nil {
+		return nil, err
+	}
 	if err := s.validateWorkerInput(input); err != nil {
 		return nil, err
 	}
 
+	environment, err := s.targetEnvironment(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if environment.Disabled {
+		return nil, custom_errors.ErrEnvironmentDisabled
+	}
+
+	worker := s.newWorker(input, environment)
+	worker.BodyContentType = worker.ContentType()
+	worker.Metrics = nil
+	if worker.ResolvedURL, err = worker.FirstURL(); err != nil {
+		return nil, custom_errors.ErrInvalidInput
+	}
+	return worker, nil
+}
+
+// withEnvironmentDefaults returns the input with the default worker settings
+// of its environment applied to the settings it leaves unset, then with the
+// default request timeout when it still has no total timeout. An input
+// targeting an unknown environment is returned as is, to be rejected by
+// targetEnvironment.
+func (s *WorkerServiceImpl) withEnvironmentDefaults(input *entity.Worker) (*entity.Worker, error) {
+	environment, err := s.environmentRepo.Get(input.EnvironmentID)
+	if errors.Is(err, custom_errors.ErrNoRecord) {
+		return input, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(environment.WorkerDefaults) > 0 {
+		if input, err = environment.WorkerDefaults.Apply(input); err != nil {
+			return nil, err
+		}
+	}
+	return entity.WithDefaultTimeout(input, environment, s.settings.Get().DefaultRequestTimeout)
+}
+
+// targetEnvironment loads the environment of the input and checks the body
+// of the input against it, the scenario the input replays being attached to
+// it too. Whether the environment is disabled is left to the caller.
+func (s *WorkerServiceImpl) targetEnvironment(input *entity.Worker) (*entity.Environment, error) {
 	environment, err := s.environmentRepo.Get(input.EnvironmentID)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := s.attachScenario(input); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateBody(input, environment); err != nil {
+		return nil, err
+	}
+
+	return environment, nil
+}
+
+// createWorker inserts and starts a worker from a validated input. The
+// returned channel is closed once the run is over. A worker without
+// autostart is only inserted, without a channel. During a maintenance
+// window of the environment the worker is rejected, unless the policy of
+// the environment defers it and deferrable allows it. Against a disabled
+// environment the worker is rejected too, unless allowBlocked has it
+// stored as blocked, without a run nor a channel, to be started later.
+func (s *WorkerServiceImpl) createWorker(ctx context.Context, input *entity.Worker, deferrable, allowBlocked bool) (*entity.Worker, <-chan struct{}, error) {
+	// Checked again by startWorker, this only spares storing a worker that would never run.
+	if s.isShuttingDown() {
+		return nil, nil, custom_errors.ErrShuttingDown
+	}
+
+	environment, err := s.targetEnvironment(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.sealDataSource(input.DataSource); err != nil {
+		return nil, nil, err
+	}
+
+	worker := s.newWorker(input, environment)
+	plan := s.plan(worker, environment)
+	if err := s.checkPlan(plan); err != nil {
+		return nil, nil, err
+	}
+
+	if environment.Disabled {
+		if !allowBlocked {
+			return nil, nil, custom_errors.ErrEnvironmentDisabled
+		}
+		worker, err = s.insertWorker(worker, plan, entity.StatusBlocked)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.log.Info().Msgf("Worker %d is blocked until environment %d is enabled", worker.ID, environment.ID)
+		return worker, nil, nil
+	}
+
+	// The maintenance windows and the resolver are checked when the worker is started.
+	if !autostart(input) {
+		worker, err = s.insertWorker(worker, plan, entity.StatusCreated)
+		if err != nil {
+			return nil, nil, err
+		}
+		return worker, nil, nil
+	}
+
+	startAt, err := s.checkLaunch(ctx, input, environment, deferrable)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	worker, err = s.insertWorker(worker, plan, entity.StatusCreated)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done, err := s.startWorker(ctx, worker, startAt)
+	if err != nil {
+		// Another worker took the environment since checkLaunch, or the shutdown began, the stored one never runs.
+		if errors.Is(err, custom_errors.ErrExclusiveRun) || errors.Is(err, custom_errors.ErrShuttingDown) {
+			s.cancelUnstarted(worker.ID, err)
+		}
+		return nil, nil, err
+	}
+	return worker, done, nil
+}
+
+func autostart(input *entity.Worker) bool {
+	return input.Autostart == nil || *input.Autostart
+}
+
+// StartWorker runs a worker created without autostart, or a blocked one
+// provided its environment was enabled since. Workers that are running or
+// whose run is over can't be started, ErrNotStartable.
+func (s *WorkerServiceImpl) StartWorker(ctx context.Context, id int) (*entity.Worker, error) {
+	stored, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored.Status != entity.StatusBlocked && stored.Status != entity.StatusCreated {
+		return nil, custom_errors.ErrNotStartable
+	}
+	// A created worker may have been started already, deferred by a maintenance window.
+	if _, running := s.running.Load(id); running {
+		return nil, custom_errors.ErrNotStartable
+	}
+
+	environment, err := s.environmentRepo.Get(stored.EnvironmentID)
+	if err != nil {
+		return nil, err
+	}
+
 	if environment.Disabled {
 		return nil, custom_errors.ErrEnvironmentDisabled
 	}
 
-	var options []entity.WorkerOption
+	startAt, err := s.checkLaunch(ctx, stored, environment, true)
+	if err != nil {
+		return nil, err
+	}
 
-	if environment.TokenEndpoint != "" {
-		credentials := tokens.Credentials{
-			Username:       &environment.Username,
-			Password:       &environment.Password,
-			BasicAuthToken: &environment.BasicAuthToken,
+	// Two concurrent starts both saw the worker blocked, only one of them may unblock it.
+	if stored.Status == entity.StatusBlocked {
+		if err := s.workerRepo.UnblockWorker(id); err != nil {
+			return nil, err
 		}
-		tokenManager := tokens.NewTokenManager(credentials, environment.TokenEndpoint, s.log)
-		options = append(options, entity.WithWorkerTokenManager(tokenManager))
 	}
 
-	worker := entity.NewWorker(
-		input.EnvironmentID,
-		input.Concurrency,
-		input.RequestsPerTask,
-		input.HTTPMethod,
-		input.Body,
-		environment,
-		s.log,
-		options...,
-	)
+	if err := s.openDataSource(stored.DataSource); err != nil {
+		return nil, err
+	}
+
+	worker := s.newWorker(stored, environment)
+	worker.ID = stored.ID
+	worker.Status = entity.StatusCreated
+	worker.CreatedAt = stored.CreatedAt
+	worker.Warnings = stored.Warnings
+
+	if _, err := s.startWorker(ctx, worker, startAt); err != nil {
+		return nil, err
+	}
+	return s.GetWorkerSummary(worker.ID)
+}
+
+// checkLaunch checks what depends on the time the worker is started, the
+// exclusive runs, the maintenance windows, the daily request quota and the
+// resolver, and returns when it may start.
+func (s *WorkerServiceImpl) checkLaunch(ctx context.Context, input *entity.Worker, environment *entity.Environment, deferrable bool) (time.Time, error) {
+	if err := s.checkExclusive(input); err != nil {
+		return time.Time{}, err
+	}
+
+	var startAt time.Time
+	if window, until, active := environment.ActiveMaintenanceWindow(time.Now()); active {
+		if environment.MaintenancePolicy != entity.MaintenanceDefer || !deferrable {
+			return time.Time{}, fmt.Errorf("%w: %w", custom_errors.ErrMaintenanceWindow, &entity.MaintenanceError{Window: *window, Until: until})
+		}
+		startAt = until
+	}
+
+	if err := s.checkQuota(input, environment); err != nil {
+		return time.Time{}, err
+	}
+
+	if input.Resolver != "" {
+		lookupCtx, cancel := context.WithTimeout(ctx, resolverCheckTimeout)
+		defer cancel()
+		if err := entity.CheckResolver(lookupCtx, input.Resolver, environment.Endpoint); err != nil {
+			s.log.Warn().Err(err).Msgf("Resolver %s did not resolve %s", input.Resolver, environment.Endpoint)
+			return time.Time{}, custom_errors.ErrResolverUnreachable
+		}
+	}
+
+	return startAt, nil
+}
+
+// checkQuota rejects a worker whose planned requests exceed what remains of
+// the daily request quota of its environment. The requests of a run are
+// only counted once it ends, so the workers of the environment being run
+// hold their planned requests, or the ones sent so far when they can't be
+// planned. A worker whose requests can't be planned is accepted as long as
+// some of the quota remains.
+func (s *WorkerServiceImpl) checkQuota(input *entity.Worker, environment *entity.Environment) error {
+	if environment.DailyRequestQuota <= 0 {
+		return nil
+	}
+
+	_, used, err := s.environmentRepo.GetRequestUsage(environment.ID)
+	if err != nil {
+		return err
+	}
+
+	s.running.Range(func(_, value any) bool {
+		worker := value.(*runningWorker).worker
+		if worker.EnvironmentID != environment.ID {
+			return true
+		}
+		if planned, known := worker.EstimatedRequests(); known {
+			used += planned
+		} else {
+			used += worker.Metrics.GetTotalRequests()
+		}
+		return true
+	})
+
+	remaining := max(environment.DailyRequestQuota-used, 0)
+	requests, known := input.EstimatedRequests()
+	if known && requests <= remaining || !known && remaining > 0 {
+		return nil
+	}
+
+	quotaErr := &entity.QuotaError{Quota: environment.DailyRequestQuota, Remaining: remaining}
+	if known {
+		quotaErr.Requested = &requests
+	}
+	return fmt.Errorf("%w: %w", custom_errors.ErrQuotaExceeded, quotaErr)
+}
+
+// insertWorker stores a new worker with the given status, along with the
+// warnings of its plan, which are logged too.
+func (s *WorkerServiceImpl) insertWorker(worker *entity.Worker, plan *Plan, status entity.Status) (*entity.Worker, error) {
+	worker.Status = status
 
 	id, err := s.workerRepo.Insert(worker)
 	if err != nil {
@@ -83,23 +485,1124 @@ func (s *WorkerServiceImpl) CreateWorker(ctx context.Context, input *entity.Work
 	worker.Status = workerFromDB.Status
 	worker.CreatedAt = workerFromDB.CreatedAt
 
-	wg := &sync.WaitGroup{}
-	go worker.Start(ctx, wg, s.workerRepo.UpdateStatus, s.workerRepo.UpdateMetrics)
+	for _, warning := range plan.Warnings {
+		s.log.Warn().Msgf("Worker %d: %s", worker.ID, warning)
+		worker.Warnings = append(worker.Warnings, warning)
+		if err := s.workerRepo.AddWarning(worker.ID, warning); err != nil {
+			s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", worker.ID)
+		}
+	}
 
 	return worker, nil
 }
 
-func (s *WorkerServiceImpl) GetWorker(id int) (*entity.Worker, error) {
-	return s.workerRepo.Get(id)
+// startWorker runs a stored worker in the background, from startAt if it
+// is set, and returns a channel closed once the run is over. Every path
+// starting a worker goes through it. ErrNotStartable if the worker is
+// already running, ErrExclusiveRun if it can't run alongside the workers
+// running against its environment, ErrShuttingDown once BeginShutdown was called.
+func (s *WorkerServiceImpl) startWorker(ctx context.Context, worker *entity.Worker, startAt time.Time) (<-chan struct{}, error) {
+	// Two workers racing for the environment must not both pass the check before either is tracked.
+	s.launchMu.Lock()
+	if s.shuttingDown {
+		s.launchMu.Unlock()
+		return nil, custom_errors.ErrShuttingDown
+	}
+	if err := s.checkExclusive(worker); err != nil {
+		s.launchMu.Unlock()
+		return nil, err
+	}
+
+	// The worker outlives the request that created it, so it must not inherit its cancellation.
+	workerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	_, running := s.running.LoadOrStore(worker.ID, &runningWorker{worker: worker, ctx: workerCtx, cancel: cancel, trackedAt: time.Now().UTC()})
+	s.launchMu.Unlock()
+	if running {
+		cancel()
+		return nil, custom_errors.ErrNotStartable
+	}
+
+	if !startAt.IsZero() {
+		warning := fmt.Sprintf("start deferred to %s by a maintenance window", startAt.UTC().Format(time.RFC3339))
+		worker.Warnings = append(worker.Warnings, warning)
+		if err := s.workerRepo.AddWarning(worker.ID, warning); err != nil {
+			s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", worker.ID)
+		}
+	}
+
+	wg := &sync.WaitGroup{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer s.running.Delete(worker.ID)
+		defer cancel()
+		if wait := time.Until(startAt); wait > 0 {
+			s.log.Info().Msgf("Worker %d waits %s for the maintenance window to end", worker.ID, wait.Round(time.Second))
+			if !s.waitForStart(workerCtx, wait) {
+				s.log.Info().Msgf("Worker %d was stopped before it started", worker.ID)
+				if err := s.workerRepo.UpdateStatus(worker.ID, entity.StatusCancelled); err != nil {
+					s.log.Error().Err(err).Msgf("Error updating the status of worker %d to %s", worker.ID, entity.StatusCancelled)
+				}
+				worker.SetStatus(entity.StatusCancelled)
+				return
+			}
+		}
+		worker.Start(workerCtx, wg, s.workerRepo)
+		s.checkAutoDisable(worker)
+	}()
+
+	return done, nil
 }
 
-func (s *WorkerServiceImpl) GetWorkers() ([]*entity.Worker, error) {
-	return s.workerRepo.GetAll()
+// checkAutoDisable disables the environment of a finished worker when its
+// auto disable policy trips on the latest runs, this one included.
+func (s *WorkerServiceImpl) checkAutoDisable(worker *entity.Worker) {
+	if worker.GetStatus() == entity.StatusCancelled {
+		return
+	}
+
+	environment, err := s.environmentRepo.Get(worker.EnvironmentID)
+	if err != nil {
+		s.log.Error().Err(err).Msgf("Error getting the environment of worker %d", worker.ID)
+		return
+	}
+	policy := environment.AutoDisable
+	if environment.Disabled || policy == nil {
+		return
+	}
+
+	latest, err := s.workerRepo.GetLatestOver(environment.ID, policy.Since, policy.AfterRuns)
+	if err != nil {
+		s.log.Error().Err(err).Msgf("Error getting the latest runs of environment %d", environment.ID)
+		return
+	}
+
+	reason, ok := policy.Trips(latest)
+	if !ok {
+		return
+	}
+	if err := s.environmentRepo.Disable(environment.ID, reason); err != nil {
+		s.log.Error().Err(err).Msgf("Error disabling environment %d", environment.ID)
+		return
+	}
+	s.log.Warn().Int("environment_id", environment.ID).Msgf("Environment disabled: %s", reason)
 }
 
-func (s *WorkerServiceImpl) validateWorkerInput(input *entity.Worker) error {
-	if input.EnvironmentID < 1 || input.Concurrency < 1 || input.RequestsPerTask < 1 {
-		return custom_errors.ErrInvalidInput
+// cancelUnstarted cancels a stored worker that was refused its start,
+// leaving the reason on it.
+func (s *WorkerServiceImpl) cancelUnstarted(id int, reason error) {
+	if err := s.workerRepo.UpdateStatus(id, entity.StatusCancelled); err != nil {
+		s.log.Error().Err(err).Msgf("Error updating the status of worker %d to %s", id, entity.StatusCancelled)
 	}
-	return nil
+	if err := s.workerRepo.AddWarning(id, "not started: "+reason.Error()); err != nil {
+		s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", id)
+	}
+}
+
+// waitForStart waits for a deferred start, reporting false if the worker was stopped first.
+func (s *WorkerServiceImpl) waitForStart(ctx context.Context, wait time.Duration) bool {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// BeginShutdown rejects every worker started from now on with
+// ErrShuttingDown, so that the workers being run or waiting to start only
+// go away while the server shuts down.
+func (s *WorkerServiceImpl) BeginShutdown() {
+	s.launchMu.Lock()
+	defer s.launchMu.Unlock()
+	s.shuttingDown = true
+}
+
+// isShuttingDown reports whether BeginShutdown was called.
+func (s *WorkerServiceImpl) isShuttingDown() bool {
+	s.launchMu.Lock()
+	defer s.launchMu.Unlock()
+	return s.shuttingDown
+}
+
+// StopAll cancels every worker being run or waiting to start and returns
+// their ids. The workers already stopping aren't returned again, so
+// calling it twice is harmless.
+func (s *WorkerServiceImpl) StopAll() []int {
+	stopped := []int{}
+	warning := fmt.Sprintf("stopped by an emergency stop at %s", time.Now().UTC().Format(time.RFC3339))
+	s.running.Range(func(key, _ any) bool {
+		if s.stopWorker(key.(int), warning) {
+			stopped = append(stopped, key.(int))
+		}
+		return true
+	})
+	sort.Ints(stopped)
+	return stopped
+}
+
+// stopWorker cancels a worker being run or waiting to start and leaves
+// warning on it. It reports false if the worker isn't running or is
+// already stopping.
+func (s *WorkerServiceImpl) stopWorker(id int, warning string) bool {
+	value, ok := s.running.Load(id)
+	if !ok {
+		return false
+	}
+
+	running := value.(*runningWorker)
+	if running.ctx.Err() != nil {
+		return false
+	}
+	running.cancel()
+
+	if err := s.workerRepo.AddWarning(id, warning); err != nil {
+		s.log.Error().Err(err).Msgf("Error adding a warning to worker %d", id)
+	}
+	return true
+}
+
+// newWorker builds the worker described by a validated input, its defaults applied.
+func (s *WorkerServiceImpl) newWorker(input *entity.Worker, environment *entity.Environment) *entity.Worker {
+	var options []entity.WorkerOption
+
+	if tokenManager := s.tokenManager(environment); tokenManager != nil {
+		options = append(options, entity.WithWorkerTokenManager(tokenManager))
+	}
+
+	if input.TargetRPS > 0 {
+		options = append(options, entity.WithWorkerThroughputSLA(input.TargetRPS, input.MinThroughputRatio))
+	}
+
+	if input.RampUp > 0 {
+		options = append(options, entity.WithWorkerRampUp(input.RampUp, input.RampUpJitter))
+	}
+
+	if input.RampDown > 0 {
+		options = append(options, entity.WithWorkerRampDown(input.RampDown))
+	}
+
+	if input.ThinkTime != nil {
+		options = append(options, entity.WithWorkerThinkTime(*input.ThinkTime, input.ThinkTimeJitter))
+	}
+
+	if input.OnResourceExhaustion != "" {
+		options = append(options, entity.WithWorkerExhaustionPolicy(input.OnResourceExhaustion))
+	}
+
+	if input.CircuitBreaker != nil {
+		options = append(options, entity.WithWorkerCircuitBreaker(input.CircuitBreaker))
+	}
+
+	if input.Retry != nil {
+		options = append(options, entity.WithWorkerRetry(input.Retry))
+	}
+
+	if input.Resolver != "" {
+		options = append(options, entity.WithWorkerResolver(input.Resolver))
+	}
+
+	if len(input.CaptureQuotas) > 0 {
+		options = append(options, entity.WithWorkerCaptureQuotas(input.CaptureQuotas))
+	}
+
+	if input.KeepAlive != nil {
+		options = append(options, entity.WithWorkerKeepAlive(input.KeepAlive))
+	}
+
+	if input.Identities != nil {
+		options = append(options, entity.WithWorkerIdentities(input.Identities))
+	}
+
+	if input.DataSource != nil {
+		options = append(options, entity.WithWorkerDataSource(input.DataSource))
+	}
+
+	if input.BodyStream != nil {
+		options = append(options, entity.WithWorkerBodyStream(input.BodyStream, s.bodyFilesDir))
+	}
+
+	if len(input.Provenance) > 0 {
+		options = append(options, entity.WithWorkerProvenance(input.Provenance))
+	}
+
+	if input.CorrelationHeader != "" {
+		options = append(options, entity.WithWorkerCorrelationHeader(input.CorrelationHeader))
+	}
+
+	if input.BodyContentType != "" {
+		options = append(options, entity.WithWorkerBodyContentType(input.BodyContentType))
+	}
+
+	if len(input.BodyVariants) > 0 {
+		selection := input.VariantSelection
+		if selection == "" {
+			selection = entity.VariantRoundRobin
+		}
+		options = append(options, entity.WithWorkerBodyVariants(input.BodyVariants, selection))
+	}
+
+	if input.PersistSamples {
+		sampleCap := input.SampleCap
+		if sampleCap == 0 {
+			sampleCap = entity.DefaultSampleCap
+		}
+		options = append(options, entity.WithWorkerPersistSamples(sampleCap))
+	}
+
+	if input.RecordRequests {
+		options = append(options, entity.WithWorkerRecordRequests(s.recordsDir))
+	}
+
+	if input.MeasureColdRequests {
+		options = append(options, entity.WithWorkerMeasureColdRequests())
+	}
+
+	if input.LatencyFailureThreshold > 0 {
+		options = append(options, entity.WithWorkerLatencyFailureThreshold(input.LatencyFailureThreshold, input.SlowRequestPolicy))
+	}
+
+	if input.FollowRedirects != nil || input.Status3xx != "" {
+		options = append(options, entity.WithWorkerRedirects(input.FollowRedirects, input.Status3xx))
+	}
+
+	if input.Timeouts != nil {
+		options = append(options, entity.WithWorkerTimeouts(input.Timeouts))
+	}
+
+	if input.Exclusive {
+		options = append(options, entity.WithWorkerExclusive())
+	}
+
+	if input.SuccessHeader != nil {
+		options = append(options, entity.WithWorkerSuccessHeader(input.SuccessHeader))
+	}
+
+	if input.BodyAssertion != nil {
+		options = append(options, entity.WithWorkerBodyAssertion(input.BodyAssertion))
+	}
+
+	if len(input.ServedByHeaders) > 0 {
+		options = append(options, entity.WithWorkerServedByHeaders(input.ServedByHeaders))
+	}
+
+	if len(input.Tags) > 0 {
+		options = append(options, entity.WithWorkerTags(input.Tags))
+	}
+
+	if input.CampaignID != nil {
+		options = append(options, entity.WithWorkerCampaign(*input.CampaignID))
+	}
+
+	settings := s.settings.Get()
+	options = append(options, entity.WithWorkerSeed(cmp.Or(input.Seed, settings.DefaultSeed)))
+
+	logSampleRate := input.LogSampleRate
+	if logSampleRate == 0 {
+		logSampleRate = settings.Log.RequestSampleRate
+	}
+	if logSampleRate > 1 {
+		options = append(options, entity.WithWorkerLogSampleRate(logSampleRate))
+	}
+
+	if input.Scenario != nil {
+		options = append(options, entity.WithWorkerScenario(input.Scenario))
+	}
+
+	if s.limiter != nil {
+		options = append(options, entity.WithWorkerRateLimiter(s.limiter))
+	}
+
+	if s.stats != nil {
+		options = append(options, entity.WithWorkerStatsD(s.stats))
+	}
+
+	if settings.RateWindow > 0 {
+		options = append(options, entity.WithWorkerRateWindow(settings.RateWindow))
+	}
+
+	switch input.Mode {
+	case entity.ModeRampToFailure:
+		options = append(options, entity.WithWorkerRampToFailure(input.RampConfig))
+	case entity.ModeSoak:
+		options = append(options, entity.WithWorkerSoak(input.SoakConfig))
+	case entity.ModeSpike:
+		options = append(options, entity.WithWorkerSpike(input.SpikeConfig))
+	case entity.ModeAutoTune:
+		options = append(options, entity.WithWorkerAutoTune(input.AutoTuneConfig))
+	}
+
+	return entity.NewWorker(
+		input.EnvironmentID,
+		input.Concurrency,
+		input.RequestsPerTask,
+		input.HTTPMethod,
+		input.Body,
+		environment,
+		s.log,
+		options...,
+	)
+}
+
+func (s *WorkerServiceImpl) GetWorker(id int) (*entity.Worker, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setProgress(worker)
+
+	if err := s.compareWithBaseline(worker); err != nil {
+		return nil, err
+	}
+
+	return worker, nil
+}
+
+// GetWorkerSummary returns a worker with its progress, without comparing it
+// with the baseline of its environment, for the summary of its metrics.
+func (s *WorkerServiceImpl) GetWorkerSummary(id int) (*entity.Worker, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setProgress(worker)
+	return worker, nil
+}
+
+// setProgress sets the progress of a worker loaded from the database. The
+// metrics are only persisted at the end of the run, a running worker
+// reports its live progress.
+func (s *WorkerServiceImpl) setProgress(worker *entity.Worker) {
+	if running, ok := s.running.Load(worker.ID); ok {
+		worker.Progress = running.(*runningWorker).worker.GetProgress()
+	} else {
+		worker.Progress = worker.GetProgress()
+	}
+}
+
+// compareWithBaseline attaches the comparison against the baseline of the
+// environment to a completed worker, if the environment has one.
+func (s *WorkerServiceImpl) compareWithBaseline(worker *entity.Worker) error {
+	if worker.Status != entity.StatusFinished && worker.Status != entity.StatusUnderperforming {
+		return nil
+	}
+
+	environment, err := s.environmentRepo.Get(worker.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	if environment.BaselineWorkerID == nil || *environment.BaselineWorkerID == worker.ID {
+		return nil
+	}
+
+	baseline, err := s.workerRepo.Get(*environment.BaselineWorkerID)
+	if err != nil {
+		if errors.Is(err, custom_errors.ErrNoRecord) {
+			// The baseline was deleted, there is nothing to compare to anymore.
+			return nil
+		}
+		return err
+	}
+
+	worker.Comparison = worker.CompareWith(baseline)
+	return nil
+}
+
+// GetBreakdown returns where the request time of a finished run was spent, stage by stage.
+func (s *WorkerServiceImpl) GetBreakdown(id int) ([]entity.StageTiming, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return worker.Metrics.Breakdown, nil
+}
+
+// LatencySamples are the raw samples persisted for a worker. Sampled tells
+// that the cap was hit and the samples are a uniform random subset, so the
+// percentiles computed from them are approximate.
+type LatencySamples struct {
+	Samples []entity.LatencySample `json:"samples"`
+	Seen    int                    `json:"seen"`
+	Stored  int                    `json:"stored"`
+	Sampled bool                   `json:"sampled"`
+}
+
+func (s *WorkerServiceImpl) GetSamples(id int) (*LatencySamples, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	samples, err := s.workerRepo.GetSamples(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LatencySamples{
+		Samples: samples,
+		Seen:    worker.Metrics.SamplesSeen,
+		Stored:  worker.Metrics.SamplesStored,
+		Sampled: worker.Metrics.SamplesSeen > worker.Metrics.SamplesStored,
+	}, nil
+}
+
+// GetCDF returns the latency CDF of a worker over the given number of points,
+// computed from its persisted samples. ErrNoRecord if the run isn't over or
+// the worker didn't persist its samples, ErrInvalidInput for points outside
+// 1 to MaxCDFPoints.
+func (s *WorkerServiceImpl) GetCDF(id, points int) ([]entity.CDFPoint, error) {
+	if points < 1 || points > entity.MaxCDFPoints {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !worker.Status.Over() || !worker.PersistSamples {
+		return nil, custom_errors.ErrNoRecord
+	}
+
+	samples, err := s.workerRepo.GetSamples(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return entity.NewCDF(samples, points), nil
+}
+
+// GetReport returns the report of a worker whose run is over, its time
+// series and histogram left empty unless the samples were persisted.
+func (s *WorkerServiceImpl) GetReport(id int) (*report.Report, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !worker.Status.Over() {
+		return nil, custom_errors.ErrNoRecord
+	}
+
+	if err := s.compareWithBaseline(worker); err != nil {
+		return nil, err
+	}
+
+	var samples []entity.LatencySample
+	if worker.PersistSamples {
+		if samples, err = s.workerRepo.GetSamples(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return report.New(worker, samples), nil
+}
+
+// GetRecordFile returns the path of the request record file of a worker,
+// ErrNoRecord if the worker didn't record its requests or its run isn't over.
+func (s *WorkerServiceImpl) GetRecordFile(id int) (string, error) {
+	worker, err := s.workerRepo.Get(id)
+	if err != nil {
+		return "", err
+	}
+
+	if worker.RecordFile == "" {
+		return "", custom_errors.ErrNoRecord
+	}
+
+	return filepath.Join(s.recordsDir, worker.RecordFile), nil
+}
+
+// tokenManager returns the token manager authenticating the requests to environment, nil without a token endpoint.
+func (s *WorkerServiceImpl) tokenManager(environment *entity.Environment) *tokens.TokenManager {
+	if environment.TokenEndpoint == "" {
+		return nil
+	}
+
+	credentials := tokens.Credentials{
+		Username:       &environment.Username,
+		Password:       &environment.Password,
+		BasicAuthToken: &environment.BasicAuthToken,
+	}
+	return tokens.NewTokenManager(credentials, environment.TokenEndpoint, s.log)
+}
+
+// VerifyEnvironmentAuth fetches a token with the credentials of an
+// environment, sending nothing to its endpoint, and records when they got
+// one. Credentials rejected or an auth server unreachable are reported in
+// the check, only an environment without a token endpoint failing it.
+func (s *WorkerServiceImpl) VerifyEnvironmentAuth(ctx context.Context, id int) (*entity.AuthCheck, error) {
+	environment, err := s.environmentRepo.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validator.New()
+	v.Check(environment.TokenEndpoint != "", "token_endpoint", "must be set to verify the credentials")
+	if err := invalid(v); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, authCheckTimeout)
+	defer cancel()
+
+	token, err := s.tokenManager(environment).Verify(ctx)
+	check := entity.NewAuthCheck(environment.ID, token, err)
+	if !check.Verified {
+		s.log.Warn().Err(err).Msgf("Credentials of environment %d got no token: %s", id, check.Failure)
+		return check, nil
+	}
+
+	verifiedAt := time.Now().UTC()
+	if err := s.environmentRepo.SetAuthVerified(id, verifiedAt); err != nil {
+		return nil, err
+	}
+	check.VerifiedAt = &verifiedAt
+	return check, nil
+}
+
+// SweepEnvironments sends a single request to every enabled environment at
+// once. An environment that can't be reached is reported in its result, only
+// failing to list the environments fails the sweep.
+func (s *WorkerServiceImpl) SweepEnvironments(ctx context.Context, input dto.SweepInput) ([]*entity.ProbeResult, *entity.BudgetUsage, error) {
+	method := http.MethodHead
+	if input.Method != "" {
+		method = strings.ToUpper(input.Method)
+	}
+	switch method {
+	case http.MethodHead, http.MethodOptions, http.MethodGet:
+	default:
+		return nil, nil, custom_errors.ErrInvalidInput
+	}
+
+	timeout := DefaultSweepTimeout
+	if input.Timeout != nil {
+		timeout = time.Duration(*input.Timeout)
+	}
+	if timeout <= 0 || timeout > MaxSweepTimeout {
+		return nil, nil, custom_errors.ErrInvalidInput
+	}
+
+	var budget *entity.RequestBudget
+	if input.Budget != nil {
+		if *input.Budget < 1 {
+			return nil, nil, custom_errors.ErrInvalidInput
+		}
+		budget = entity.NewRequestBudget(*input.Budget)
+	}
+
+	environments, err := s.environmentRepo.GetAll()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var enabled []*entity.Environment
+	for _, environment := range environments {
+		if !environment.Disabled {
+			enabled = append(enabled, environment)
+		}
+	}
+
+	results := make([]*entity.ProbeResult, len(enabled))
+	wg := &sync.WaitGroup{}
+	for i, environment := range enabled {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = s.probe(ctx, environment, method, timeout, budget)
+		}()
+	}
+	wg.Wait()
+
+	if budget == nil {
+		return results, nil, nil
+	}
+	return results, budget.Usage(), nil
+}
+
+func (s *WorkerServiceImpl) probe(ctx context.Context, environment *entity.Environment, method string, timeout time.Duration, budget *entity.RequestBudget) *entity.ProbeResult {
+	// The listing leaves the credentials out, the token manager needs them.
+	full, err := s.environmentRepo.Get(environment.ID)
+	if err != nil {
+		return &entity.ProbeResult{
+			EnvironmentID: environment.ID,
+			Name:          environment.Name,
+			Endpoint:      environment.Endpoint,
+			ErrorClass:    entity.ErrorClassOther,
+			Error:         err.Error(),
+		}
+	}
+
+	options := []entity.WorkerOption{entity.WithWorkerRequestBudget(budget)}
+	if tokenManager := s.tokenManager(full); tokenManager != nil {
+		options = append(options, entity.WithWorkerTokenManager(tokenManager))
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	worker := entity.NewWorker(full.ID, 1, 1, method, nil, full, s.log, options...)
+	return worker.Probe(probeCtx)
+}
+
+// GetWorkers returns every worker. Unless strict, the workers whose row
+// can't be read are left out, logged and reported in the returned warnings.
+func (s *WorkerServiceImpl) GetWorkers(strict bool) ([]*entity.Worker, []string, error) {
+	workers, rowErrors, err := s.workerRepo.GetAll(strict)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var warnings []string
+	for _, rowErr := range rowErrors {
+		s.log.Error().Err(rowErr.Err).Int("worker_id", rowErr.ID).Msg("Error reading a worker, left out of the list")
+		warnings = append(warnings, fmt.Sprintf("worker %d couldn't be read and was left out", rowErr.ID))
+	}
+	return workers, warnings, nil
+}
+
+// GetWorkerPage returns up to limit workers after the cursor handed out
+// with the previous page, the first ones when empty, and the cursor of the
+// next page, empty once there are no more. Limit is DefaultPageSize when 0.
+func (s *WorkerServiceImpl) GetWorkerPage(cursor string, limit int) ([]*entity.Worker, string, error) {
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+
+	v := validator.New()
+	v.Check(limit >= 1 && limit <= MaxPageSize, "limit", fmt.Sprintf("must be between 1 and %d", MaxPageSize))
+
+	var after *entity.Cursor
+	if cursor != "" {
+		var err error
+		after, err = entity.ParseCursor(cursor)
+		v.Check(err == nil, "cursor", "is invalid")
+	}
+	if err := invalid(v); err != nil {
+		return nil, "", err
+	}
+
+	workers, err := s.workerRepo.GetPage(after, limit)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// A full page may be followed by an empty one, which ends the listing all the same.
+	next := ""
+	if len(workers) == limit {
+		next = entity.NewWorkerCursor(workers[len(workers)-1]).String()
+	}
+	return workers, next, nil
+}
+
+// ExportWorkers walks all the workers page by page, calling fn with every page,
+// so the whole table is never held in memory. The pages are read with a
+// cursor, so the workers created meanwhile are neither repeated nor skipped.
+func (s *WorkerServiceImpl) ExportWorkers(fn func(workers []*entity.Worker) error) error {
+	var after *entity.Cursor
+
+	for {
+		workers, err := s.workerRepo.GetPage(after, exportPageSize)
+		if err != nil {
+			return err
+		}
+
+		if len(workers) > 0 {
+			if err := fn(workers); err != nil {
+				return err
+			}
+		}
+
+		if len(workers) < exportPageSize {
+			return nil
+		}
+		after = entity.NewWorkerCursor(workers[len(workers)-1])
+	}
+}
+
+// validateWorkerInput checks every field of a worker input, along with the
+// rules across fields, and reports all the violations at once.
+func (s *WorkerServiceImpl) validateWorkerInput(input *entity.Worker) error {
+	v := validator.New()
+	v.Check(input.EnvironmentID >= 1, "environment_id", "must be a positive id")
+	v.Check(input.Concurrency >= 1, "concurrency", "must be at least 1")
+
+	// Kept in sync with the limits reported by GetCapabilities.
+	settings := s.settings.Get()
+	v.Check(settings.MaxConcurrency == 0 || input.Concurrency <= settings.MaxConcurrency, "concurrency", fmt.Sprintf("must be at most %d", settings.MaxConcurrency))
+
+	// The steps of a scenario bring their own method.
+	v.Check(input.ScenarioID != nil || slices.Contains(entity.SupportedHTTPMethods, input.HTTPMethod), "http_method", fmt.Sprintf("must be one of %s", strings.Join(entity.SupportedHTTPMethods, ", ")))
+
+	// Only a campaign links the workers it creates to itself.
+	v.Check(input.CampaignID == nil, "campaign_id", "is set by campaigns only")
+
+	switch input.Mode {
+	case "", entity.ModeFixed:
+		v.Check(input.RequestsPerTask >= 1, "requests_per_task", "must be at least 1")
+		v.Check(settings.MaxRequests == 0 || input.Concurrency*input.RequestsPerTask <= settings.MaxRequests, "requests_per_task", fmt.Sprintf("the run must send at most %d requests", settings.MaxRequests))
+	case entity.ModeRampToFailure:
+		s.validateRampConfig(v, input.RampConfig)
+	case entity.ModeSoak:
+		s.validateSoakConfig(v, input.SoakConfig)
+	case entity.ModeSpike:
+		s.validateSpikeConfig(v, input.SpikeConfig)
+	case entity.ModeAutoTune:
+		s.validateAutoTuneConfig(v, input.AutoTuneConfig)
+		if input.AutoTuneConfig != nil {
+			v.Check(settings.MaxConcurrency == 0 || input.AutoTuneConfig.MaxConcurrency <= settings.MaxConcurrency, "auto_tune_config.max_concurrency", fmt.Sprintf("must be at most %d", settings.MaxConcurrency))
+		}
+	default:
+		v.AddError("mode", "is unknown")
+	}
+
+	// The other modes run for a duration and the paced ones send at the rate
+	// of their config, the settings they would ignore are rejected. The
+	// defaults of the environment apply to workers of every mode, they are
+	// left alone.
+	fromRequest := func(key string) bool { return input.Provenance[key] != entity.SourceEnvironment }
+	if input.Mode != "" && input.Mode != entity.ModeFixed && fromRequest("requests_per_task") {
+		v.Check(input.RequestsPerTask == 0, "requests_per_task", "only applies to the fixed mode, the other modes run for a duration")
+	}
+	if input.Mode == entity.ModeRampToFailure || input.Mode == entity.ModeSoak || input.Mode == entity.ModeSpike {
+		v.Check(input.ThinkTime == nil || !fromRequest("think_time"), "think_time", "doesn't apply to a mode sending at the rate of its config")
+		v.Check(input.ThinkTimeJitter == 0 || !fromRequest("think_time_jitter"), "think_time_jitter", "doesn't apply to a mode sending at the rate of its config")
+	}
+
+	v.Check(input.TargetRPS >= 0, "target_rps", "must not be negative")
+	v.Check(isFraction(input.MinThroughputRatio), "min_throughput_ratio", "must be between 0 and 1")
+	v.Check(input.MinThroughputRatio == 0 || input.TargetRPS > 0, "min_throughput_ratio", "requires target_rps")
+	v.Check(input.RampUp >= 0, "ramp_up", "must not be negative")
+	v.Check(input.RampDown >= 0, "ramp_down", "must not be negative")
+	v.Check(input.ThinkTime == nil || *input.ThinkTime >= 0, "think_time", "must not be negative")
+	// A ramp to failure or an auto tune ends at the limit of the target, there is no load to wind down.
+	v.Check(input.RampDown <= 0 || input.Mode != entity.ModeRampToFailure && input.Mode != entity.ModeAutoTune, "ramp_down", "doesn't apply to a mode ending at the limit of the target")
+	v.Check(isFraction(input.RampUpJitter), "ramp_up_jitter", "must be between 0 and 1")
+	v.Check(isFraction(input.ThinkTimeJitter), "think_time_jitter", "must be between 0 and 1")
+
+	switch input.OnResourceExhaustion {
+	case "", entity.ExhaustionIgnore, entity.ExhaustionThrottle:
+	default:
+		v.AddError("on_resource_exhaustion", "is unknown")
+	}
+
+	v.Check(input.LatencyFailureThreshold >= 0, "latency_failure_threshold", "must not be negative")
+	switch input.SlowRequestPolicy {
+	case "":
+	case entity.SlowRequestFail, entity.SlowRequestCount:
+		v.Check(input.LatencyFailureThreshold != 0, "slow_request_policy", "requires latency_failure_threshold")
+	default:
+		v.AddError("slow_request_policy", "is unknown")
+	}
+
+	switch input.Status3xx {
+	case "", entity.RedirectNeutral, entity.RedirectSuccess, entity.RedirectFailure:
+	default:
+		v.AddError("status_3xx", "is unknown")
+	}
+
+	v.Check(len(input.Tags) <= entity.MaxTags, "tags", fmt.Sprintf("must be at most %d", entity.MaxTags))
+	for i, tag := range input.Tags {
+		v.Check(entity.ValidTag(tag), validator.Index("tags", i), "must be letters, digits, '_', '.', ':' or '-', up to 64")
+	}
+
+	if input.Resolver != "" {
+		_, _, err := net.SplitHostPort(input.Resolver)
+		v.Check(err == nil, "resolver", "must be host:port")
+	}
+
+	v.Check(input.CaptureQuotas.Valid(), "capture_quotas", "are invalid")
+	v.Check(input.SampleCap >= 0 && input.SampleCap <= entity.MaxSampleCap, "sample_cap", fmt.Sprintf("must be between 0 and %d", entity.MaxSampleCap))
+	v.Check(input.LogSampleRate >= 0 && input.LogSampleRate <= entity.MaxLogSampleRate, "log_sample_rate", fmt.Sprintf("must be between 0 and %d", entity.MaxLogSampleRate))
+	v.Check(input.CorrelationHeader == "" || entity.ValidHeaderName(input.CorrelationHeader), "correlation_header", "must be a header name")
+	v.Check(input.SuccessHeader == nil || input.SuccessHeader.Valid(), "success_header", "is invalid")
+	v.Check(input.BodyAssertion == nil || input.BodyAssertion.Valid(), "body_assertion.path", "must be $ followed by .key and [index] steps")
+
+	v.Check(len(input.ServedByHeaders) <= entity.MaxServedByHeaders, "served_by_headers", fmt.Sprintf("must be at most %d", entity.MaxServedByHeaders))
+	for i, header := range input.ServedByHeaders {
+		v.Check(entity.ValidHeaderName(header), validator.Index("served_by_headers", i), "must be a header name")
+	}
+
+	s.validateIdentities(v, input.Identities)
+	s.validateDataSource(v, input.DataSource)
+
+	if input.BodyContentType != "" {
+		_, _, err := mime.ParseMediaType(input.BodyContentType)
+		v.Check(err == nil, "body_content_type", "must be a media type")
+	}
+
+	s.validateBodyVariants(v, input)
+
+	// The steps of a scenario bring their own URL and body.
+	if input.ScenarioID != nil {
+		v.Check(*input.ScenarioID >= 1, "scenario_id", "must be a positive id")
+		v.Check(len(input.BodyVariants) == 0, "body_variants", "don't apply to a scenario")
+		v.Check(input.DataSource == nil, "data_source", "doesn't apply to a scenario")
+	}
+
+	s.validateBodyStream(v, input)
+
+	if keepAlive := input.KeepAlive; keepAlive != nil {
+		v.Check(keepAlive.Interval > 0, "keep_alive.interval", "must be positive")
+		v.Check(keepAlive.Connections >= 0 && keepAlive.Connections <= input.Concurrency, "keep_alive.connections", "must be between 0 and the concurrency")
+	}
+
+	if timeouts := input.Timeouts; timeouts != nil {
+		v.Check(timeouts.Total >= 0, "timeouts.total", "must not be negative")
+		v.Check(timeouts.Idle >= 0, "timeouts.idle", "must not be negative")
+		v.Check(timeouts.Total != 0 || timeouts.Idle != 0, "timeouts", "must set total or idle")
+	}
+
+	if breaker := input.CircuitBreaker; breaker != nil {
+		v.Check(breaker.Window >= 1, "circuit_breaker.window", "must be at least 1")
+		v.Check(breaker.FailureRate > 0 && breaker.FailureRate <= 1, "circuit_breaker.failure_rate", "must be above 0 and at most 1")
+		v.Check(breaker.Cooldown > 0, "circuit_breaker.cooldown", "must be positive")
+		v.Check(breaker.HalfOpenProbes >= 1, "circuit_breaker.half_open_probes", "must be at least 1")
+	}
+
+	if retry := input.Retry; retry != nil {
+		v.Check(retry.MaxRetries >= 1 && retry.MaxRetries <= entity.MaxRetries, "retry.max_retries", fmt.Sprintf("must be between 1 and %d", entity.MaxRetries))
+		v.Check(retry.BaseDelay > 0, "retry.base_delay", "must be positive")
+		v.Check(retry.MaxDelay >= retry.BaseDelay, "retry.max_delay", "must be at least base_delay")
+		v.Check(retry.Jitter == "" || slices.Contains(entity.JitterStrategies, retry.Jitter), "retry.jitter", "is unknown")
+	}
+	return invalid(v)
+}
+
+// validateIdentities rejects duplicated identities, which would merge the
+// virtual users the target sees. Fewer identities than goroutines are
+// accepted, they are shared and the plan warns about it.
+func (s *WorkerServiceImpl) validateIdentities(v *validator.Validator, config *entity.IdentityConfig) {
+	if config == nil {
+		return
+	}
+
+	v.Check(entity.ValidHeaderName(config.Header), "identities.header", "must be a header name")
+	v.Check(len(config.Values) <= entity.MaxIdentities, "identities.values", fmt.Sprintf("must be at most %d", entity.MaxIdentities))
+
+	seen := make(map[string]bool, len(config.Values))
+	for i, value := range config.Values {
+		v.Check(value != "" && !seen[value], validator.Index("identities.values", i), "must be set and unique")
+		seen[value] = true
+	}
+}
+
+// validateDataSource checks the data source of a worker. An SQL one is only
+// accepted when a secrets key is configured to seal its DSN.
+func (s *WorkerServiceImpl) validateDataSource(v *validator.Validator, source *entity.DataSource) {
+	if source == nil {
+		return
+	}
+
+	v.Check(source.SealedDSN == "", "data_source.sealed_dsn", "can't be set")
+
+	switch source.Type {
+	case entity.DataSourceCSV:
+		v.Check(source.CSV != "" && len(source.CSV) <= entity.MaxDataSourceCSV, "data_source.csv", fmt.Sprintf("must be set, up to %d bytes", entity.MaxDataSourceCSV))
+		v.Check(source.DSN == "", "data_source.dsn", "doesn't apply to a CSV data source")
+		v.Check(source.Query == "", "data_source.query", "doesn't apply to a CSV data source")
+	case entity.DataSourceSQL:
+		v.Check(s.sealer != nil, "data_source.type", "requires a secrets key to be configured")
+		v.Check(source.DSN != "", "data_source.dsn", "must be set")
+		v.Check(source.Query != "", "data_source.query", "must be set")
+		v.Check(source.CSV == "", "data_source.csv", "doesn't apply to an SQL data source")
+	default:
+		v.AddError("data_source.type", "is unknown")
+	}
+}
+
+// validateBodyStream checks the body stream of a worker, which replaces any
+// other body. A file is only accepted when a body files directory is
+// configured, and must be a regular file within it.
+func (s *WorkerServiceImpl) validateBodyStream(v *validator.Validator, input *entity.Worker) {
+	stream := input.BodyStream
+	if stream == nil {
+		return
+	}
+
+	v.Check(len(input.BodyVariants) == 0 && input.DataSource == nil && input.ScenarioID == nil, "body_stream", "replaces the body variants, the data source and the scenario")
+
+	switch stream.Type {
+	case entity.BodyStreamFile:
+		v.Check(stream.Size == 0, "body_stream.size", "doesn't apply to a file")
+		if s.bodyFilesDir == "" || !filepath.IsLocal(stream.File) {
+			v.AddError("body_stream.file", "must be within the body files directory")
+			break
+		}
+		info, err := os.Stat(filepath.Join(s.bodyFilesDir, stream.File))
+		v.Check(err == nil && info.Mode().IsRegular(), "body_stream.file", "must be a regular file")
+	case entity.BodyStreamGenerated:
+		v.Check(stream.File == "", "body_stream.file", "doesn't apply to a generated body")
+		v.Check(stream.Size >= 1 && stream.Size <= entity.MaxGeneratedBodySize, "body_stream.size", fmt.Sprintf("must be between 1 and %d", entity.MaxGeneratedBodySize))
+	default:
+		v.AddError("body_stream.type", "is unknown")
+	}
+}
+
+// sealDataSource seals the DSN of an SQL data source, which is only stored sealed.
+func (s *WorkerServiceImpl) sealDataSource(source *entity.DataSource) error {
+	if source == nil || source.Type != entity.DataSourceSQL {
+		return nil
+	}
+
+	sealed, err := s.sealer.Seal(source.DSN)
+	if err != nil {
+		return err
+	}
+	source.SealedDSN = sealed
+	return nil
+}
+
+// openDataSource restores the DSN of the SQL data source of a stored worker.
+func (s *WorkerServiceImpl) openDataSource(source *entity.DataSource) error {
+	if source == nil || source.Type != entity.DataSourceSQL {
+		return nil
+	}
+
+	if s.sealer == nil {
+		return errors.New("no secrets key is configured to open the DSN of the data source")
+	}
+	dsn, err := s.sealer.Open(source.SealedDSN)
+	if err != nil {
+		return err
+	}
+	source.DSN = dsn
+	return nil
+}
+
+func (s *WorkerServiceImpl) validateBodyVariants(v *validator.Validator, input *entity.Worker) {
+	v.Check(len(input.BodyVariants) <= entity.MaxBodyVariants, "body_variants", fmt.Sprintf("must be at most %d", entity.MaxBodyVariants))
+
+	switch input.VariantSelection {
+	case "", entity.VariantRoundRobin, entity.VariantRandom:
+	default:
+		v.AddError("variant_selection", "is unknown")
+	}
+
+	names := make(map[string]bool, len(input.BodyVariants))
+	for i, variant := range input.BodyVariants {
+		v.Check(variant.Name != "" && !names[variant.Name], validator.Field(validator.Index("body_variants", i), "name"), "must be set and unique")
+		names[variant.Name] = true
+	}
+}
+
+// validateBody catches a JSON body the target can only reject before the run
+// produces nothing but client errors. It is only checked for JSON content
+// types, every body variant being checked as well.
+func (s *WorkerServiceImpl) validateBody(input *entity.Worker, environment *entity.Environment) error {
+	if !entity.IsJSONContentType(input.ContentType()) {
+		return nil
+	}
+
+	if input.Body != nil {
+		if err := s.validateJSONBody(*input.Body, "", environment, input.SkipSchemaValidation); err != nil {
+			return err
+		}
+	}
+
+	for _, variant := range input.BodyVariants {
+		if err := s.validateJSONBody(variant.Body, fmt.Sprintf("body_variants[%s]", variant.Name), environment, input.SkipSchemaValidation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateJSONBody prefixes the path of a schema violation with label, which names the body in the worker.
+func (s *WorkerServiceImpl) validateJSONBody(body []byte, label string, environment *entity.Environment, skipSchema bool) error {
+	if !json.Valid(body) {
+		return custom_errors.ErrInvalidInput
+	}
+
+	if environment.BodySchema == nil || skipSchema {
+		return nil
+	}
+
+	if err := environment.BodySchema.Validate(body); err != nil {
+		var schemaErr *entity.SchemaError
+		if errors.As(err, &schemaErr) {
+			schemaErr.Path = label + schemaErr.Path
+		}
+		return fmt.Errorf("%w: %w", custom_errors.ErrSchemaViolation, err)
+	}
+	return nil
+}
+
+func (s *WorkerServiceImpl) validateRampConfig(v *validator.Validator, config *entity.RampConfig) {
+	if config == nil {
+		v.AddError("ramp_config", "is required by the mode")
+		return
+	}
+	v.Check(config.StartRPS > 0, "ramp_config.start_rps", "must be positive")
+	v.Check(config.StepRPS > 0, "ramp_config.step_rps", "must be positive")
+	v.Check(config.StepDuration > 0, "ramp_config.step_duration", "must be positive")
+	v.Check(config.MaxDuration >= 0, "ramp_config.max_duration", "must not be negative")
+	v.Check(config.LatencySLOMs >= 0, "ramp_config.latency_slo_ms", "must not be negative")
+	v.Check(isFraction(config.ErrorSLO), "ramp_config.error_slo", "must be between 0 and 1")
+	v.Check(config.LatencySLOMs != 0 || config.ErrorSLO != 0, "ramp_config", "must set latency_slo_ms or error_slo")
+}
+
+// invalid returns the violations of v as an ErrInvalidInput wrapping the
+// *validator.Error listing them, nil when there is none.
+func invalid(v *validator.Validator) error {
+	if err := v.Err(); err != nil {
+		return fmt.Errorf("%w: %w", custom_errors.ErrInvalidInput, err)
+	}
+	return nil
+}
+
+func isFraction(value float64) bool {
+	return value >= 0 && value <= 1
+}
+
+func (s *WorkerServiceImpl) validateSoakConfig(v *validator.Validator, config *entity.SoakConfig) {
+	if config == nil {
+		v.AddError("soak_config", "is required by the mode")
+		return
+	}
+	v.Check(config.RPS > 0, "soak_config.rps", "must be positive")
+	v.Check(config.Duration > 0, "soak_config.duration", "must be positive")
+	v.Check(config.Interval > 0 && config.Interval <= config.Duration, "soak_config.interval", "must be positive and at most the duration")
+	v.Check(config.DriftThresholdMsPerHour >= 0, "soak_config.drift_threshold_ms_per_hour", "must not be negative")
+}
+
+func (s *WorkerServiceImpl) validateSpikeConfig(v *validator.Validator, config *entity.SpikeConfig) {
+	if config == nil {
+		v.AddError("spike_config", "is required by the mode")
+		return
+	}
+	v.Check(config.BaselineRPS > 0, "spike_config.baseline_rps", "must be positive")
+	v.Check(config.SpikeRPS > config.BaselineRPS, "spike_config.spike_rps", "must be above baseline_rps")
+	v.Check(config.SpikeDuration > 0, "spike_config.spike_duration", "must be positive")
+	v.Check(config.Interval > 0, "spike_config.interval", "must be positive")
+	v.Check(config.Duration > 0, "spike_config.duration", "must be positive")
+	v.Check(config.Window >= 0, "spike_config.window", "must not be negative")
+	v.Check(config.RecoveryTolerance >= 0, "spike_config.recovery_tolerance", "must not be negative")
+}
+
+func (s *WorkerServiceImpl) validateAutoTuneConfig(v *validator.Validator, config *entity.AutoTuneConfig) {
+	if config == nil {
+		v.AddError("auto_tune_config", "is required by the mode")
+		return
+	}
+	v.Check(config.MinConcurrency >= 1, "auto_tune_config.min_concurrency", "must be at least 1")
+	v.Check(config.MaxConcurrency >= config.MinConcurrency, "auto_tune_config.max_concurrency", "must be at least min_concurrency")
+	v.Check(config.Step >= 1, "auto_tune_config.step", "must be at least 1")
+	v.Check(config.StepDuration > 0, "auto_tune_config.step_duration", "must be positive")
+	v.Check(config.MaxDuration >= 0, "auto_tune_config.max_duration", "must not be negative")
+	v.Check(config.MinGain >= 0, "auto_tune_config.min_gain", "must not be negative")
+	v.Check(config.LatencySLOMs >= 0, "auto_tune_config.latency_slo_ms", "must not be negative")
+	v.Check(isFraction(config.ErrorSLO), "auto_tune_config.error_slo", "must be between 0 and 1")
+}
+
+// GetStorageStats returns the sizes of the compressed columns of the workers.
+func (s *WorkerServiceImpl) GetStorageStats() (*entity.StorageStats, error) {
+	return s.workerRepo.GetStorageStats()
 }
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// Bounds of a trend report.
+const (
+	MinTrendBucket  = time.Hour
+	MaxTrendBuckets = 1000
+)
+
+// GetTrend aggregates the runs of the workers with tag finished within
+// [from, to) per bucket, the latencies of a bucket being the weighted merge
+// of the samples its runs persisted.
+func (s *WorkerServiceImpl) GetTrend(tag string, from, to time.Time, bucket time.Duration) (*entity.TrendReport, error) {
+	if !entity.ValidTag(tag) || !from.Before(to) || bucket < MinTrendBucket || to.Sub(from)/bucket >= MaxTrendBuckets {
+		return nil, custom_errors.ErrInvalidInput
+	}
+
+	workers, err := s.workerRepo.GetFinishedBetween(tag, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	report := entity.NewTrendReport(tag, from, to, bucket)
+	for _, worker := range workers {
+		samples, err := s.workerRepo.GetSamples(worker.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var histogram *entity.LatencyHistogram
+		if len(samples) > 0 {
+			histogram = entity.NewLatencyHistogram(samples, worker.Metrics.SamplesSeen)
+		}
+		report.Add(worker, histogram)
+	}
+	report.Finish()
+
+	return report, nil
+}
@@ -0,0 +1,578 @@
+package testutil
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/internal/model/repository"
+)
+
+// Repositories keeps every table in memory, for the services to run without
+// a database. The rows are copied in and out, like they would be by the
+// database, so that a caller modifying what it got back changes nothing
+// stored. The passwords are stored as given rather than hashed.
+type Repositories struct {
+	Environments repository.EnvironmentRepository
+	Workers      repository.WorkerRepository
+	Campaigns    repository.CampaignRepository
+	Scenarios    repository.ScenarioRepository
+}
+
+// NewRepositories returns empty in-memory repositories sharing their tables.
+func NewRepositories() *Repositories {
+	db := &memoryDB{
+		environments: make(map[int]*entity.Environment),
+		workers:      make(map[int]*entity.Worker),
+		samples:      make(map[int][]entity.LatencySample),
+		usage:        make(map[int]map[string]int),
+		campaigns:    make(map[int]time.Time),
+		scenarios:    make(map[int]*entity.Scenario),
+	}
+	return &Repositories{
+		Environments: &environmentRepository{db},
+		Workers:      &workerRepository{db},
+		Campaigns:    &campaignRepository{db},
+		Scenarios:    &scenarioRepository{db},
+	}
+}
+
+// memoryDB holds the tables, guarded by mu.
+type memoryDB struct {
+	mu           sync.Mutex
+	lastID       int // shared by every table, an id is never reused
+	environments map[int]*entity.Environment
+	workers      map[int]*entity.Worker
+	samples      map[int][]entity.LatencySample
+	usage        map[int]map[string]int // environment id to the requests of every UTC day
+	campaigns    map[int]time.Time      // id to the time the campaign was created
+	scenarios    map[int]*entity.Scenario
+}
+
+func (db *memoryDB) nextID() int {
+	db.lastID++
+	return db.lastID
+}
+
+// today is the current UTC day, the day the requests are counted under.
+func today() string {
+	return time.Now().UTC().Format(time.DateOnly)
+}
+
+// sortedIDs returns the ids of a table in ascending order.
+func sortedIDs[T any](table map[int]T) []int {
+	ids := make([]int, 0, len(table))
+	for id := range table {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// copyJSON copies src into dst through their JSON, the way their columns
+// would be written and read back.
+func copyJSON(dst, src any) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		panic(err)
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		panic(err)
+	}
+}
+
+func cloneEnvironment(environment *entity.Environment) *entity.Environment {
+	clone := &entity.Environment{}
+	copyJSON(clone, environment)
+	clone.CreatedAt = environment.CreatedAt
+	return clone
+}
+
+// cloneWorker copies the stored fields of a worker, a copy of its JSON along
+// with the columns its JSON leaves out.
+func cloneWorker(worker *entity.Worker) *entity.Worker {
+	clone := &entity.Worker{}
+	copyJSON(clone, worker)
+	clone.Scenario = worker.Scenario
+	clone.Provenance = maps.Clone(worker.Provenance)
+	clone.CreatedAt, clone.UpdatedAt = worker.CreatedAt, worker.UpdatedAt
+	if worker.FinishedAt != nil {
+		finishedAt := *worker.FinishedAt
+		clone.FinishedAt = &finishedAt
+	}
+	if clone.Metrics == nil {
+		clone.Metrics = entity.NewMetrics()
+	}
+	return clone
+}
+
+// sortedWorkers returns a copy of the workers kept by keep, ordered by id.
+func (db *memoryDB) sortedWorkers(keep func(worker *entity.Worker) bool) []*entity.Worker {
+	var workers []*entity.Worker
+	for _, id := range sortedIDs(db.workers) {
+		if worker := db.workers[id]; keep(worker) {
+			workers = append(workers, cloneWorker(worker))
+		}
+	}
+	return workers
+}
+
+type environmentRepository struct {
+	db *memoryDB
+}
+
+func (r *environmentRepository) Ping() error {
+	return nil
+}
+
+func (r *environmentRepository) Insert(environment *entity.Environment) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.nameTaken(environment.Name, 0) {
+		return 0, custom_errors.ErrDuplicateName
+	}
+	stored := cloneEnvironment(environment)
+	stored.ID = r.db.nextID()
+	stored.CreatedAt = time.Now().UTC()
+	r.db.environments[stored.ID] = stored
+	return stored.ID, nil
+}
+
+// nameTaken reports whether an environment other than the one with the given id has name.
+func (r *environmentRepository) nameTaken(name string, id int) bool {
+	for _, environment := range r.db.environments {
+		if environment.Name == name && environment.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *environmentRepository) Get(id int) (*entity.Environment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	environment, ok := r.db.environments[id]
+	if !ok {
+		return nil, custom_errors.ErrNoRecord
+	}
+	return cloneEnvironment(environment), nil
+}
+
+func (r *environmentRepository) GetAll() ([]*entity.Environment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var environments []*entity.Environment
+	for _, id := range sortedIDs(r.db.environments) {
+		environments = append(environments, cloneEnvironment(r.db.environments[id]))
+	}
+	return environments, nil
+}
+
+func (r *environmentRepository) Update(environment *entity.Environment) error {
+	return r.update(environment.ID, func(stored *entity.Environment) error {
+		if r.nameTaken(environment.Name, environment.ID) {
+			return custom_errors.ErrDuplicateName
+		}
+		// The baseline and the body schema have their own setters.
+		updated := cloneEnvironment(environment)
+		updated.BaselineWorkerID, updated.BodySchema, updated.Demo, updated.CreatedAt = stored.BaselineWorkerID, stored.BodySchema, stored.Demo, stored.CreatedAt
+		*stored = *updated
+		return nil
+	})
+}
+
+func (r *environmentRepository) SetBaseline(id, workerID int) error {
+	return r.update(id, func(stored *entity.Environment) error {
+		stored.BaselineWorkerID = &workerID
+		return nil
+	})
+}
+
+func (r *environmentRepository) Disable(id int, reason string) error {
+	return r.update(id, func(stored *entity.Environment) error {
+		stored.Disabled, stored.DisabledReason = true, reason
+		return nil
+	})
+}
+
+func (r *environmentRepository) SetAuthVerified(id int, at time.Time) error {
+	return r.update(id, func(stored *entity.Environment) error {
+		stored.LastAuthVerifiedAt = &at
+		return nil
+	})
+}
+
+func (r *environmentRepository) SetBodySchema(id int, schema *entity.BodySchema) error {
+	return r.update(id, func(stored *entity.Environment) error {
+		stored.BodySchema = nil
+		if schema != nil {
+			stored.BodySchema = &entity.BodySchema{}
+			copyJSON(stored.BodySchema, schema)
+		}
+		return nil
+	})
+}
+
+// update applies fn to the stored environment with the given id, ErrNoRecord if there is none.
+func (r *environmentRepository) update(id int, fn func(stored *entity.Environment) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.environments[id]
+	if !ok {
+		return custom_errors.ErrNoRecord
+	}
+	return fn(stored)
+}
+
+func (r *environmentRepository) GetRequestUsage(id int) (string, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	day := today()
+	return day, r.db.usage[id][day], nil
+}
+
+func (r *environmentRepository) Delete(id int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.environments[id]; !ok {
+		return custom_errors.ErrNoRecord
+	}
+	delete(r.db.environments, id)
+	return nil
+}
+
+func (r *environmentRepository) DeleteDemo() (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deleted int
+	for id, environment := range r.db.environments {
+		if !environment.Demo {
+			continue
+		}
+		for workerID, worker := range r.db.workers {
+			if worker.EnvironmentID == id {
+				delete(r.db.workers, workerID)
+				delete(r.db.samples, workerID)
+			}
+		}
+		delete(r.db.usage, id)
+		delete(r.db.environments, id)
+		deleted++
+	}
+	return deleted, nil
+}
+
+type workerRepository struct {
+	db *memoryDB
+}
+
+func (r *workerRepository) Insert(worker *entity.Worker) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored := cloneWorker(worker)
+	stored.ID = r.db.nextID()
+	stored.CreatedAt = time.Now().UTC()
+	stored.UpdatedAt = stored.CreatedAt
+	r.db.workers[stored.ID] = stored
+	return stored.ID, nil
+}
+
+func (r *workerRepository) Get(id int) (*entity.Worker, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	worker, ok := r.db.workers[id]
+	if !ok {
+		return nil, custom_errors.ErrNoRecord
+	}
+	return cloneWorker(worker), nil
+}
+
+func (r *workerRepository) GetAll(bool) ([]*entity.Worker, []*repository.RowError, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.db.sortedWorkers(func(*entity.Worker) bool { return true }), nil, nil
+}
+
+func (r *workerRepository) GetPage(after *entity.Cursor, limit int) ([]*entity.Worker, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	workers := r.db.sortedWorkers(func(worker *entity.Worker) bool {
+		return after == nil || after.After(worker.CreatedAt, worker.ID)
+	})
+	slices.SortStableFunc(workers, func(a, b *entity.Worker) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return workers[:min(limit, len(workers))], nil
+}
+
+func (r *workerRepository) GetIDsByStatus(status entity.Status) ([]int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ids := []int{}
+	for _, worker := range r.db.sortedWorkers(func(worker *entity.Worker) bool { return worker.Status == status }) {
+		ids = append(ids, worker.ID)
+	}
+	return ids, nil
+}
+
+func (r *workerRepository) GetFinishedBetween(tag string, from, to time.Time) ([]*entity.Worker, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	workers := r.db.sortedWorkers(func(worker *entity.Worker) bool {
+		return worker.FinishedAt != nil && !worker.FinishedAt.Before(from) && worker.FinishedAt.Before(to) && slices.Contains(worker.Tags, tag)
+	})
+	slices.SortStableFunc(workers, func(a, b *entity.Worker) int {
+		return a.FinishedAt.Compare(*b.FinishedAt)
+	})
+	return workers, nil
+}
+
+func (r *workerRepository) GetLatestOver(environmentID int, since time.Time, limit int) ([]*entity.Worker, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	workers := r.db.sortedWorkers(func(worker *entity.Worker) bool {
+		over := worker.Status == entity.StatusFinished || worker.Status == entity.StatusFailed || worker.Status == entity.StatusUnderperforming
+		return worker.EnvironmentID == environmentID && over && worker.FinishedAt != nil && !worker.FinishedAt.Before(since)
+	})
+	slices.Reverse(workers)
+	slices.SortStableFunc(workers, func(a, b *entity.Worker) int {
+		return b.FinishedAt.Compare(*a.FinishedAt)
+	})
+	return workers[:min(limit, len(workers))], nil
+}
+
+func (r *workerRepository) UpdateStatus(id int, status entity.Status) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		setStatus(stored, status)
+		return nil
+	})
+}
+
+// setStatus sets the status of a stored worker, along with the time its run
+// was over when the status is final.
+func setStatus(stored *entity.Worker, status entity.Status) {
+	now := time.Now().UTC()
+	stored.Status, stored.UpdatedAt, stored.FinishedAt = status, now, nil
+	if status.Over() {
+		stored.FinishedAt = &now
+	}
+}
+
+func (r *workerRepository) UnblockWorker(id int) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		if stored.Status != entity.StatusBlocked {
+			return custom_errors.ErrNotStartable
+		}
+		setStatus(stored, entity.StatusCreated)
+		return nil
+	})
+}
+
+func (r *workerRepository) FailRunning(id int) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.workers[id]
+	if !ok || stored.Status != entity.StatusRunning {
+		return false, nil
+	}
+	setStatus(stored, entity.StatusFailed)
+	return true, nil
+}
+
+func (r *workerRepository) UpdateMetrics(id int, metrics *entity.Metrics) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		stored.Metrics = &entity.Metrics{}
+		copyJSON(stored.Metrics, metrics)
+		return nil
+	})
+}
+
+func (r *workerRepository) FinishRun(id int, status entity.Status, metrics *entity.Metrics) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		stored.Metrics = &entity.Metrics{}
+		copyJSON(stored.Metrics, metrics)
+		setStatus(stored, status)
+
+		if metrics.TotalRequests > 0 {
+			if r.db.usage[stored.EnvironmentID] == nil {
+				r.db.usage[stored.EnvironmentID] = make(map[string]int)
+			}
+			r.db.usage[stored.EnvironmentID][today()] += metrics.TotalRequests
+		}
+		return nil
+	})
+}
+
+func (r *workerRepository) UpdateRampResult(id int, result *entity.RampResult) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.RampResult }, result)
+}
+
+func (r *workerRepository) UpdateSoakResult(id int, result *entity.SoakResult) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.SoakResult }, result)
+}
+
+func (r *workerRepository) UpdateSpikeResult(id int, result *entity.SpikeResult) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.SpikeResult }, result)
+}
+
+func (r *workerRepository) UpdateAutoTuneResult(id int, result *entity.AutoTuneResult) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.AutoTuneResult }, result)
+}
+
+func (r *workerRepository) UpdateCapturedResponses(id int, responses []*entity.CapturedResponse) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.CapturedResponses }, responses)
+}
+
+func (r *workerRepository) UpdateFirstRequest(id int, request *entity.RenderedRequest) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.FirstRequest }, request)
+}
+
+func (r *workerRepository) UpdateDataSource(id int, source *entity.DataSource) error {
+	return r.setJSON(id, func(stored *entity.Worker) any { return &stored.DataSource }, source)
+}
+
+func (r *workerRepository) AddWarning(id int, warning string) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		stored.Warnings = append(stored.Warnings, warning)
+		return nil
+	})
+}
+
+func (r *workerRepository) UpdateRecordFile(id int, file string) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		stored.RecordFile = file
+		return nil
+	})
+}
+
+func (r *workerRepository) InsertSamples(id int, samples []entity.LatencySample) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.samples[id] = append(r.db.samples[id], samples...)
+	return nil
+}
+
+func (r *workerRepository) GetSamples(id int) ([]entity.LatencySample, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return slices.Clone(r.db.samples[id]), nil
+}
+
+// GetStorageStats counts the JSON of the blob columns, which are kept uncompressed.
+func (r *workerRepository) GetStorageStats() (*entity.StorageStats, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stats := []*entity.BlobStats{{Column: "captured_responses"}, {Column: "ramp_result"}, {Column: "soak_result"}, {Column: "spike_result"}, {Column: "auto_tune_result"}}
+	for _, worker := range r.db.workers {
+		values := []any{worker.CapturedResponses, worker.RampResult, worker.SoakResult, worker.SpikeResult, worker.AutoTuneResult}
+		for i, value := range values {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			if string(data) != "null" {
+				stats[i].Add(len(data), len(data), false)
+			}
+		}
+	}
+	return entity.NewStorageStats(string(repository.CompressionNone), stats), nil
+}
+
+// update applies fn to the stored worker with the given id, ErrNoRecord if there is none.
+func (r *workerRepository) update(id int, fn func(stored *entity.Worker) error) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.workers[id]
+	if !ok {
+		return custom_errors.ErrNoRecord
+	}
+	return fn(stored)
+}
+
+// setJSON stores a copy of value in the field of the stored worker field points to.
+func (r *workerRepository) setJSON(id int, field func(stored *entity.Worker) any, value any) error {
+	return r.update(id, func(stored *entity.Worker) error {
+		copyJSON(field(stored), value)
+		return nil
+	})
+}
+
+type campaignRepository struct {
+	db *memoryDB
+}
+
+func (r *campaignRepository) Insert() (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	id := r.db.nextID()
+	r.db.campaigns[id] = time.Now().UTC()
+	return id, nil
+}
+
+func (r *campaignRepository) Get(id int) (*entity.Campaign, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	createdAt, ok := r.db.campaigns[id]
+	if !ok {
+		return nil, custom_errors.ErrNoRecord
+	}
+	workers := r.db.sortedWorkers(func(worker *entity.Worker) bool {
+		return worker.CampaignID != nil && *worker.CampaignID == id
+	})
+	return &entity.Campaign{ID: id, CreatedAt: createdAt, Workers: workers}, nil
+}
+
+type scenarioRepository struct {
+	db *memoryDB
+}
+
+func (r *scenarioRepository) Insert(scenario *entity.Scenario) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored := &entity.Scenario{}
+	copyJSON(stored, scenario)
+	stored.ID = r.db.nextID()
+	stored.CreatedAt = time.Now().UTC()
+	r.db.scenarios[stored.ID] = stored
+	return stored.ID, nil
+}
+
+func (r *scenarioRepository) Get(id int) (*entity.Scenario, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	scenario, ok := r.db.scenarios[id]
+	if !ok {
+		return nil, custom_errors.ErrNoRecord
+	}
+	clone := &entity.Scenario{}
+	copyJSON(clone, scenario)
+	clone.ID, clone.CreatedAt = scenario.ID, scenario.CreatedAt
+	return clone, nil
+}
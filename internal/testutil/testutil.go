@@ -0,0 +1,70 @@
+// Package testutil wires the services and the API over in-memory
+// repositories, for the integration tests to run the whole flow of a worker
+// without a database.
+package testutil
+
+import (
+	"net/http"
+	"os"
+
+	"github.com/rs/zerolog"
+	"github.com/vladComan0/performance-analyzer/internal/config"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+	"github.com/vladComan0/performance-analyzer/internal/service"
+	"github.com/vladComan0/performance-analyzer/pkg/helpers"
+)
+
+// HandlerFunc builds the handler of the API from its services, the way the
+// api command does. The routes live in the command, which can't be
+// imported, so its tests pass their constructor in.
+type HandlerFunc func(environmentService service.EnvironmentService, workerService service.WorkerService, settings *config.Holder, helper *helpers.Helper, log zerolog.Logger) http.Handler
+
+// Config returns the config of the tests: every limit and optional feature
+// off, as when the config file leaves them out.
+func Config() config.Config {
+	return config.Config{Environment: "test"}
+}
+
+// Stack is the API over in-memory repositories.
+type Stack struct {
+	Repositories       *Repositories
+	EnvironmentService *service.EnvironmentServiceImpl
+	WorkerService      *service.WorkerServiceImpl
+	Settings           *config.Holder
+	Handler            http.Handler
+}
+
+// NewStack wires the services and the handler built by newHandler over
+// empty in-memory repositories, with cfg and a logger discarding
+// everything. The request record files are written to a directory of
+// os.TempDir.
+func NewStack(newHandler HandlerFunc, cfg config.Config) *Stack {
+	repositories := NewRepositories()
+	settings := config.NewHolder(cfg)
+	log := zerolog.Nop()
+
+	environmentService := service.NewEnvironmentService(repositories.Environments, repositories.Workers)
+	workerService := service.NewWorkerService(
+		repositories.Workers,
+		repositories.Environments,
+		repositories.Campaigns,
+		repositories.Scenarios,
+		os.TempDir(),
+		cfg.BodyFiles.Dir,
+		settings,
+		nil,
+		entity.NewRateLimiter(cfg.GlobalMaxRPS),
+		nil,
+		log,
+	)
+	helper := helpers.NewHelper(log, cfg.DebugEnabled, cfg.TrustForwardedHeaders)
+	helper.JSONLimits = helpers.JSONLimits{MaxDepth: cfg.JSON.MaxDepth, MaxElements: cfg.JSON.MaxElements}
+
+	return &Stack{
+		Repositories:       repositories,
+		EnvironmentService: environmentService,
+		WorkerService:      workerService,
+		Settings:           settings,
+		Handler:            newHandler(environmentService, workerService, settings, helper, log),
+	}
+}
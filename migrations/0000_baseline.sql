@@ -0,0 +1,34 @@
+-- The schema the migrations start from.
+
+CREATE TABLE environments (
+    id               INT AUTO_INCREMENT PRIMARY KEY,
+    name             VARCHAR(255)  NOT NULL,
+    endpoint         VARCHAR(2048) NOT NULL,
+    token_endpoint   VARCHAR(2048) NOT NULL DEFAULT '',
+    username         VARCHAR(255)  NOT NULL DEFAULT '',
+    password         VARBINARY(60) NULL,
+    basic_auth_token VARCHAR(1024) NOT NULL DEFAULT '',
+    disabled         BOOLEAN       NOT NULL DEFAULT FALSE,
+    created_at       DATETIME      NOT NULL
+);
+
+CREATE TABLE workers (
+    id                INT AUTO_INCREMENT PRIMARY KEY,
+    environment_id    INT         NOT NULL,
+    concurrency       INT         NOT NULL,
+    requests_per_task INT         NOT NULL,
+    report            MEDIUMTEXT  NULL,
+    http_method       VARCHAR(16) NOT NULL,
+    body              JSON        NULL,
+    status            VARCHAR(32) NOT NULL,
+    max_latency       DOUBLE      NULL,
+    total_requests    INT         NULL,
+    failed_requests   INT         NULL,
+    error_rate        DOUBLE      NULL,
+    p50               DOUBLE      NULL,
+    p95               DOUBLE      NULL,
+    p99               DOUBLE      NULL,
+    p999              DOUBLE      NULL,
+    created_at        DATETIME    NOT NULL,
+    KEY idx_workers_environment (environment_id)
+);
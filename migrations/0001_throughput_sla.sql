@@ -0,0 +1,7 @@
+-- The target rate of a worker and the throughput its runs reached.
+
+ALTER TABLE workers
+    ADD COLUMN target_rps            DOUBLE NOT NULL DEFAULT 0 AFTER body,
+    ADD COLUMN min_throughput_ratio  DOUBLE NOT NULL DEFAULT 0 AFTER target_rps,
+    ADD COLUMN throughput            DOUBLE NULL AFTER error_rate,
+    ADD COLUMN effective_concurrency DOUBLE NULL AFTER throughput;
@@ -0,0 +1,6 @@
+-- The mode of a worker, and the config and result of its ramp to failure.
+
+ALTER TABLE workers
+    ADD COLUMN mode        VARCHAR(32) NOT NULL DEFAULT '' AFTER min_throughput_ratio,
+    ADD COLUMN ramp_config JSON NULL AFTER mode,
+    ADD COLUMN ramp_result JSON NULL AFTER ramp_config;
@@ -0,0 +1,8 @@
+-- The ramp-up and think time of a worker, with their jitter. The
+-- durations are in nanoseconds, a NULL think time keeping the random one.
+
+ALTER TABLE workers
+    ADD COLUMN ramp_up           BIGINT NOT NULL DEFAULT 0 AFTER ramp_result,
+    ADD COLUMN ramp_up_jitter    DOUBLE NOT NULL DEFAULT 0 AFTER ramp_up,
+    ADD COLUMN think_time        BIGINT NULL AFTER ramp_up_jitter,
+    ADD COLUMN think_time_jitter DOUBLE NOT NULL DEFAULT 0 AFTER think_time;
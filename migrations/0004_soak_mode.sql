@@ -0,0 +1,5 @@
+-- The config and result of the soak runs.
+
+ALTER TABLE workers
+    ADD COLUMN soak_config JSON NULL AFTER ramp_result,
+    ADD COLUMN soak_result JSON NULL AFTER soak_config;
@@ -0,0 +1,7 @@
+-- The policy of a worker running out of sockets, and the error classes
+-- and diagnostics of its runs.
+
+ALTER TABLE workers
+    ADD COLUMN on_resource_exhaustion VARCHAR(32) NOT NULL DEFAULT 'ignore' AFTER think_time_jitter,
+    ADD COLUMN error_classes          JSON NULL AFTER effective_concurrency,
+    ADD COLUMN diagnostics            JSON NULL AFTER error_classes;
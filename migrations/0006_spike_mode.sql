@@ -0,0 +1,5 @@
+-- The config and result of the spike runs.
+
+ALTER TABLE workers
+    ADD COLUMN spike_config JSON NULL AFTER soak_result,
+    ADD COLUMN spike_result JSON NULL AFTER spike_config;
@@ -0,0 +1,4 @@
+-- The metrics of every phase of a run.
+
+ALTER TABLE workers
+    ADD COLUMN phases JSON NULL AFTER diagnostics;
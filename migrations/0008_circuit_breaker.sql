@@ -0,0 +1,6 @@
+-- The circuit breaker of a worker, and how long and how often it opened.
+
+ALTER TABLE workers
+    ADD COLUMN circuit_breaker   JSON NULL AFTER on_resource_exhaustion,
+    ADD COLUMN circuit_open_time DOUBLE NULL AFTER phases,
+    ADD COLUMN circuit_openings  INT NULL AFTER circuit_open_time;
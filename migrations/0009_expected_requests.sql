@@ -0,0 +1,5 @@
+-- The number of requests a run is expected to send, NULL for the runs
+-- bounded by a duration.
+
+ALTER TABLE workers
+    ADD COLUMN expected_requests INT NULL AFTER circuit_breaker;
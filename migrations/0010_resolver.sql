@@ -0,0 +1,7 @@
+-- The DNS server of a worker, and the lookups of its runs.
+
+ALTER TABLE workers
+    ADD COLUMN resolver        VARCHAR(255) NOT NULL DEFAULT '' AFTER circuit_breaker,
+    ADD COLUMN dns_lookups     INT NULL AFTER circuit_openings,
+    ADD COLUMN avg_dns_latency DOUBLE NULL AFTER dns_lookups,
+    ADD COLUMN max_dns_latency DOUBLE NULL AFTER avg_dns_latency;
@@ -0,0 +1,4 @@
+-- The run every other run of an environment is compared to.
+
+ALTER TABLE environments
+    ADD COLUMN baseline_worker_id INT NULL AFTER disabled;
@@ -0,0 +1,4 @@
+-- The requests sent to every backend address.
+
+ALTER TABLE workers
+    ADD COLUMN resolved_addresses JSON NULL AFTER max_dns_latency;
@@ -0,0 +1,4 @@
+-- The latency of a run broken down by stage.
+
+ALTER TABLE workers
+    ADD COLUMN breakdown JSON NULL AFTER resolved_addresses;
@@ -0,0 +1,5 @@
+-- The capture quotas of a worker, and the responses its runs captured.
+
+ALTER TABLE workers
+    ADD COLUMN capture_quotas     JSON NULL AFTER expected_requests,
+    ADD COLUMN captured_responses JSON NULL AFTER capture_quotas;
@@ -0,0 +1,17 @@
+-- The raw latency samples of the runs persisting them, and how many of
+-- them were seen and stored.
+
+ALTER TABLE workers
+    ADD COLUMN persist_samples BOOLEAN NOT NULL DEFAULT FALSE AFTER captured_responses,
+    ADD COLUMN sample_cap      INT NOT NULL DEFAULT 0 AFTER persist_samples,
+    ADD COLUMN samples_seen    INT NULL AFTER breakdown,
+    ADD COLUMN samples_stored  INT NULL AFTER samples_seen;
+
+CREATE TABLE latency_samples (
+    id        BIGINT AUTO_INCREMENT PRIMARY KEY,
+    worker_id INT         NOT NULL,
+    sent_at   DATETIME(6) NOT NULL,
+    latency   DOUBLE      NOT NULL,
+    phase     VARCHAR(32) NOT NULL,
+    KEY idx_latency_samples_worker (worker_id, sent_at)
+);
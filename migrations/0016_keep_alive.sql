@@ -0,0 +1,6 @@
+-- The keep-alive settings of a worker, and the pings of its runs.
+
+ALTER TABLE workers
+    ADD COLUMN keep_alive         JSON NULL AFTER sample_cap,
+    ADD COLUMN keep_alive_pings   INT NULL AFTER samples_stored,
+    ADD COLUMN reconnects_avoided INT NULL AFTER keep_alive_pings;
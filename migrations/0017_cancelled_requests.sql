@@ -0,0 +1,4 @@
+-- The requests aborted when a run ended.
+
+ALTER TABLE workers
+    ADD COLUMN cancelled_requests INT NULL AFTER failed_requests;
@@ -0,0 +1,5 @@
+-- The correlation header of a worker, and the slowest requests of its runs.
+
+ALTER TABLE workers
+    ADD COLUMN correlation_header VARCHAR(255) NOT NULL DEFAULT '' AFTER keep_alive,
+    ADD COLUMN slowest_requests   JSON NULL AFTER reconnects_avoided;
@@ -0,0 +1,8 @@
+-- The content type of the worker bodies, and the JSON Schema they are
+-- checked against per environment.
+
+ALTER TABLE workers
+    ADD COLUMN body_content_type VARCHAR(255) NOT NULL DEFAULT '' AFTER body;
+
+ALTER TABLE environments
+    ADD COLUMN body_schema JSON NULL AFTER baseline_worker_id;
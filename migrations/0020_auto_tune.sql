@@ -0,0 +1,5 @@
+-- The config and result of the auto-tune runs.
+
+ALTER TABLE workers
+    ADD COLUMN auto_tune_config JSON NULL AFTER spike_result,
+    ADD COLUMN auto_tune_result JSON NULL AFTER auto_tune_config;
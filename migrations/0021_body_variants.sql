@@ -0,0 +1,6 @@
+-- The body variants of a worker, and the metrics of each of them.
+
+ALTER TABLE workers
+    ADD COLUMN body_variants     JSON NULL AFTER body_content_type,
+    ADD COLUMN variant_selection VARCHAR(32) NOT NULL DEFAULT '' AFTER body_variants,
+    ADD COLUMN variants          JSON NULL AFTER slowest_requests;
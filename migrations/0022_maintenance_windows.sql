@@ -0,0 +1,9 @@
+-- The maintenance windows of an environment, and the warnings of the
+-- runs started during one.
+
+ALTER TABLE environments
+    ADD COLUMN maintenance_windows JSON NULL AFTER body_schema,
+    ADD COLUMN maintenance_policy  VARCHAR(32) NOT NULL DEFAULT '' AFTER maintenance_windows;
+
+ALTER TABLE workers
+    ADD COLUMN warnings JSON NULL AFTER correlation_header;
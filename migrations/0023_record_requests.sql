@@ -0,0 +1,5 @@
+-- Whether a worker records its requests, and the file they went to.
+
+ALTER TABLE workers
+    ADD COLUMN record_requests BOOLEAN NOT NULL DEFAULT FALSE AFTER sample_cap,
+    ADD COLUMN record_file     VARCHAR(255) NULL AFTER record_requests;
@@ -0,0 +1,4 @@
+-- The cool down at the end of a run, in nanoseconds.
+
+ALTER TABLE workers
+    ADD COLUMN ramp_down BIGINT NOT NULL DEFAULT 0 AFTER ramp_up_jitter;
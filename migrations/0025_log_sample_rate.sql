@@ -0,0 +1,4 @@
+-- The sampling of the per request debug logs.
+
+ALTER TABLE workers
+    ADD COLUMN log_sample_rate INT NOT NULL DEFAULT 0 AFTER correlation_header;
@@ -0,0 +1,6 @@
+-- Whether the first request of every goroutine is measured apart, and
+-- the metrics of the cold and warm requests.
+
+ALTER TABLE workers
+    ADD COLUMN measure_cold_requests BOOLEAN NOT NULL DEFAULT FALSE AFTER log_sample_rate,
+    ADD COLUMN connections           JSON NULL AFTER variants;
@@ -0,0 +1,10 @@
+-- The campaigns, and the workers they created.
+
+CREATE TABLE campaigns (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    created_at DATETIME NOT NULL
+);
+
+ALTER TABLE workers
+    ADD COLUMN campaign_id INT NULL AFTER environment_id,
+    ADD KEY idx_workers_campaign (campaign_id);
@@ -0,0 +1,7 @@
+-- The latency over which a response is slow, in nanoseconds, what to make
+-- of the slow ones, and how many there were.
+
+ALTER TABLE workers
+    ADD COLUMN latency_failure_threshold BIGINT NOT NULL DEFAULT 0 AFTER measure_cold_requests,
+    ADD COLUMN slow_request_policy       VARCHAR(32) NOT NULL DEFAULT '' AFTER latency_failure_threshold,
+    ADD COLUMN slow_requests             INT NULL AFTER cancelled_requests;
@@ -0,0 +1,12 @@
+-- The daily request quota of an environment, and the requests sent
+-- against it every UTC day.
+
+ALTER TABLE environments
+    ADD COLUMN daily_request_quota INT NOT NULL DEFAULT 0 AFTER maintenance_policy;
+
+CREATE TABLE environment_request_usage (
+    environment_id INT    NOT NULL,
+    day            DATE   NOT NULL,
+    requests       BIGINT NOT NULL,
+    PRIMARY KEY (environment_id, day)
+);
@@ -0,0 +1,4 @@
+-- The identities sent by the goroutines of a worker.
+
+ALTER TABLE workers
+    ADD COLUMN identities JSON NULL AFTER keep_alive;
@@ -0,0 +1,4 @@
+-- The data source filling the request placeholders of a worker.
+
+ALTER TABLE workers
+    ADD COLUMN data_source JSON NULL AFTER identities;
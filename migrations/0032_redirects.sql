@@ -0,0 +1,8 @@
+-- The redirect policy of a worker, and the responses of its runs per
+-- status class.
+
+ALTER TABLE workers
+    ADD COLUMN follow_redirects BOOLEAN NULL AFTER slow_request_policy,
+    ADD COLUMN status_3xx       VARCHAR(32) NOT NULL DEFAULT 'neutral' AFTER follow_redirects,
+    ADD COLUMN neutral_requests INT NULL AFTER slow_requests,
+    ADD COLUMN status_classes   JSON NULL AFTER error_classes;
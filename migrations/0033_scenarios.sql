@@ -0,0 +1,13 @@
+-- The scenarios imported from HAR files, and the one a worker replays.
+
+CREATE TABLE scenarios (
+    id          INT AUTO_INCREMENT PRIMARY KEY,
+    name        VARCHAR(255) NOT NULL,
+    rebase      BOOLEAN      NOT NULL DEFAULT FALSE,
+    steps       JSON         NOT NULL,
+    unsupported JSON         NULL,
+    created_at  DATETIME     NOT NULL
+);
+
+ALTER TABLE workers
+    ADD COLUMN scenario JSON NULL AFTER data_source;
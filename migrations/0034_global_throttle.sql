@@ -0,0 +1,4 @@
+-- The time the runs waited on the process-wide request rate.
+
+ALTER TABLE workers
+    ADD COLUMN global_throttle_time DOUBLE NULL AFTER circuit_openings;
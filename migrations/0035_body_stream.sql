@@ -0,0 +1,4 @@
+-- The source the request bodies of a worker are streamed from.
+
+ALTER TABLE workers
+    ADD COLUMN body_stream JSON NULL AFTER scenario;
@@ -0,0 +1,4 @@
+-- Whether the steps of a scenario are shuffled on every pass.
+
+ALTER TABLE scenarios
+    ADD COLUMN shuffle BOOLEAN NOT NULL DEFAULT FALSE AFTER rebase;
@@ -0,0 +1,8 @@
+-- When a worker last changed and when its run ended, in UTC like
+-- created_at. The workers already there count as updated when created.
+
+ALTER TABLE workers
+    ADD COLUMN updated_at  DATETIME NULL AFTER created_at,
+    ADD COLUMN finished_at DATETIME NULL AFTER updated_at;
+
+UPDATE workers SET updated_at = created_at;
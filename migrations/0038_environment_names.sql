@@ -0,0 +1,5 @@
+-- Environment names are unique, a duplicate being reported as a conflict.
+-- Rename the duplicates, if any, before running it.
+
+ALTER TABLE environments
+    ADD UNIQUE KEY environments_name (name);
@@ -0,0 +1,8 @@
+-- The default worker settings of an environment, and where every setting
+-- of a worker came from.
+
+ALTER TABLE environments
+    ADD COLUMN default_worker_settings JSON NULL AFTER daily_request_quota;
+
+ALTER TABLE workers
+    ADD COLUMN provenance JSON NULL AFTER body_stream;
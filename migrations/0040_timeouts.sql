@@ -0,0 +1,4 @@
+-- The total and idle request timeouts of a worker.
+
+ALTER TABLE workers
+    ADD COLUMN timeouts JSON NULL AFTER provenance;
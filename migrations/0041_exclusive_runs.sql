@@ -0,0 +1,4 @@
+-- Whether a worker runs alone against its environment.
+
+ALTER TABLE workers
+    ADD COLUMN exclusive BOOLEAN NOT NULL DEFAULT FALSE AFTER status_3xx;
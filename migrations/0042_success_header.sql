@@ -0,0 +1,4 @@
+-- The header a response needs to count as a success.
+
+ALTER TABLE workers
+    ADD COLUMN success_header JSON NULL AFTER timeouts;
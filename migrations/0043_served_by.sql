@@ -0,0 +1,7 @@
+-- The headers tracked per response, and the HTTP versions and header
+-- values the responses came with.
+
+ALTER TABLE workers
+    ADD COLUMN served_by_headers JSON NULL AFTER success_header,
+    ADD COLUMN protocol_versions JSON NULL AFTER resolved_addresses,
+    ADD COLUMN served_by         JSON NULL AFTER protocol_versions;
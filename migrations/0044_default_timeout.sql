@@ -0,0 +1,5 @@
+-- The total timeout of the workers of an environment setting none, in
+-- nanoseconds.
+
+ALTER TABLE environments
+    ADD COLUMN default_timeout BIGINT NOT NULL DEFAULT 0 AFTER default_worker_settings;
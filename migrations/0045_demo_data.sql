@@ -0,0 +1,4 @@
+-- The demo environments, pruned along with their runs.
+
+ALTER TABLE environments
+    ADD COLUMN demo BOOLEAN NOT NULL DEFAULT FALSE AFTER default_timeout;
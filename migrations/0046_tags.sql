@@ -0,0 +1,5 @@
+-- The tags of a worker, and the finished runs the trend reports read.
+
+ALTER TABLE workers
+    ADD COLUMN tags JSON NULL AFTER exclusive,
+    ADD KEY idx_workers_finished_at (finished_at);
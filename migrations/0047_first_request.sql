@@ -0,0 +1,4 @@
+-- The first rendered request of a run.
+
+ALTER TABLE workers
+    ADD COLUMN first_request JSON NULL AFTER captured_responses;
@@ -0,0 +1,4 @@
+-- The retry policy of the failed requests.
+
+ALTER TABLE workers
+    ADD COLUMN retry JSON NULL AFTER circuit_breaker;
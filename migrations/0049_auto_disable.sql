@@ -0,0 +1,5 @@
+-- The failed runs disabling an environment, and why it was disabled.
+
+ALTER TABLE environments
+    ADD COLUMN auto_disable    JSON NULL AFTER demo,
+    ADD COLUMN disabled_reason VARCHAR(255) NULL AFTER disabled;
@@ -0,0 +1,10 @@
+-- The report, the results of the runs and the captured responses are
+-- stored compressed. The rows already there stay readable as they are.
+
+ALTER TABLE workers
+    MODIFY report             MEDIUMBLOB NULL,
+    MODIFY ramp_result        MEDIUMBLOB NULL,
+    MODIFY soak_result        MEDIUMBLOB NULL,
+    MODIFY spike_result       MEDIUMBLOB NULL,
+    MODIFY auto_tune_result   MEDIUMBLOB NULL,
+    MODIFY captured_responses MEDIUMBLOB NULL;
@@ -0,0 +1,4 @@
+-- The JSON field a response needs to count as a success.
+
+ALTER TABLE workers
+    ADD COLUMN body_assertion JSON NULL AFTER success_header;
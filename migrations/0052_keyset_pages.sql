@@ -0,0 +1,4 @@
+-- The pages of workers are read in (created_at, id) order.
+
+ALTER TABLE workers
+    ADD KEY idx_workers_created_at_id (created_at, id);
@@ -0,0 +1,4 @@
+-- The master seed of the random choices of a run.
+
+ALTER TABLE workers
+    ADD COLUMN seed BIGINT NOT NULL DEFAULT 0 AFTER tags;
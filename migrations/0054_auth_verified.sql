@@ -0,0 +1,4 @@
+-- When the credentials of an environment last got a token.
+
+ALTER TABLE environments
+    ADD COLUMN last_auth_verified_at DATETIME NULL AFTER auto_disable;
@@ -0,0 +1,279 @@
+// Package client drives a performance analyzer over its HTTP API.
+//
+//	c := client.NewClient("http://localhost:4000", "")
+//	environment, err := c.CreateEnvironment(ctx, client.CreateEnvironmentInput{
+//		Name:     "staging",
+//		Endpoint: "https://staging.example.com/api",
+//	})
+//	...
+//	worker, err := c.CreateWorker(ctx, &client.WorkerInput{
+//		EnvironmentID:   environment.ID,
+//		Concurrency:     10,
+//		RequestsPerTask: 100,
+//		HTTPMethod:      "GET",
+//	})
+//	...
+//	summary, err := c.WaitForWorker(ctx, worker.ID, time.Second)
+//
+// The errors answered by the server unwrap to the errors of this package,
+// so that errors.Is(err, client.ErrNoRecord) tells a missing worker apart.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/internal/dto"
+	"github.com/vladComan0/performance-analyzer/internal/model/entity"
+)
+
+// Defaults of a Client, which may be changed before its first use.
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxRetries   = 3
+	DefaultRetryDelay   = 200 * time.Millisecond
+	DefaultPollInterval = time.Second
+)
+
+// The types of the API, shared with the server.
+type (
+	CreateEnvironmentInput = dto.CreateEnvironmentInput
+	Environment            = dto.EnvironmentResponse
+	WorkerInput            = entity.Worker
+	Worker                 = dto.WorkerResponse
+	WorkerSummary          = dto.WorkerSummaryResponse
+	Comparison             = entity.Comparison
+	Duration               = entity.Duration
+)
+
+// Client sends the requests of a program to an analyzer. It is safe for
+// concurrent use.
+type Client struct {
+	BaseURL    string
+	APIKey     string // sent as a bearer token, none when empty
+	HTTPClient *http.Client
+	MaxRetries int           // of a GET answered with a 5xx, none when 0
+	RetryDelay time.Duration // before the first retry, doubled for every other one
+}
+
+// NewClient returns a client of the analyzer listening at baseURL. The key
+// is only needed by the admin endpoints so far.
+func NewClient(baseURL, apiKey string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: DefaultTimeout},
+		MaxRetries: DefaultMaxRetries,
+		RetryDelay: DefaultRetryDelay,
+	}
+}
+
+// CreateEnvironment creates an environment. A name already taken fails
+// with ErrDuplicateName.
+func (c *Client) CreateEnvironment(ctx context.Context, input CreateEnvironmentInput) (*Environment, error) {
+	var environment *Environment
+	if err := c.do(ctx, http.MethodPost, "/v1/environments", input, "environment", &environment); err != nil {
+		return nil, err
+	}
+	return environment, nil
+}
+
+// CreateWorker creates a worker, which starts running unless its Autostart
+// is false. It doesn't wait for the run, see WaitForWorker.
+func (c *Client) CreateWorker(ctx context.Context, input *WorkerInput) (*Worker, error) {
+	var worker *Worker
+	if err := c.do(ctx, http.MethodPost, "/v1/workers", input, "worker", &worker); err != nil {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// GetWorker returns a worker along with its metrics so far.
+func (c *Client) GetWorker(ctx context.Context, id int) (*Worker, error) {
+	var worker *Worker
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/workers/%d", id), nil, "worker", &worker); err != nil {
+		return nil, err
+	}
+	return worker, nil
+}
+
+// ListWorkers returns a page of up to limit workers, the server default
+// when 0, and the cursor of the next page, empty once there are no more.
+// The first page is read with an empty cursor. The workers are in the
+// order they were created, those created while paging coming last rather
+// than shifting the pages.
+func (c *Client) ListWorkers(ctx context.Context, cursor string, limit int) ([]*Worker, string, error) {
+	query := url.Values{"cursor": {cursor}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+
+	var page struct {
+		Workers    []*Worker `json:"workers"`
+		NextCursor string    `json:"next_cursor"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/v1/workers?"+query.Encode(), nil, "", &page); err != nil {
+		return nil, "", err
+	}
+	return page.Workers, page.NextCursor, nil
+}
+
+// GetMetrics returns the summary of the metrics of a worker, final once
+// its run is over.
+func (c *Client) GetMetrics(ctx context.Context, id int) (*WorkerSummary, error) {
+	var summary *WorkerSummary
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/v1/workers/%d/summary", id), nil, "summary", &summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// WaitForWorker polls the metrics of a worker every interval until its run
+// is over, DefaultPollInterval when 0, and returns the final ones. It gives
+// up once ctx is done, the run going on.
+func (c *Client) WaitForWorker(ctx context.Context, id int, interval time.Duration) (*WorkerSummary, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		summary, err := c.GetMetrics(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if summary.Final {
+			return summary, nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Compare returns the comparison of a worker against the baseline of its
+// environment, nil while its run isn't over or when there is no baseline.
+func (c *Client) Compare(ctx context.Context, id int) (*Comparison, error) {
+	worker, err := c.GetWorker(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return worker.Comparison, nil
+}
+
+// do sends a request with the JSON of in, nil for none, and decodes the
+// value under key of the envelope answered into out, the whole envelope
+// when key is empty. The GET requests
+// answered with a 5xx are retried, the others having created something
+// the server may have kept.
+func (c *Client) do(ctx context.Context, method, path string, in any, key string, out any) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	retries := 0
+	if method == http.MethodGet {
+		retries = max(c.MaxRetries, 0)
+	}
+
+	delay := c.RetryDelay
+	for attempt := 0; ; attempt++ {
+		status, data, err := c.send(ctx, method, path, body)
+		if err != nil {
+			return err
+		}
+
+		if status >= http.StatusInternalServerError && attempt < retries {
+			if err := sleep(ctx, delay); err != nil {
+				return err
+			}
+			delay *= 2
+			continue
+		}
+
+		if status < 200 || status > 299 {
+			return newAPIError(method, path, status, data)
+		}
+
+		if key == "" {
+			return json.Unmarshal(data, out)
+		}
+
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return fmt.Errorf("decoding the response of %s %s: %w", method, path, err)
+		}
+		value, ok := envelope[key]
+		if !ok {
+			return fmt.Errorf("the response of %s %s has no %q", method, path, key)
+		}
+		return json.Unmarshal(value, out)
+	}
+}
+
+// send sends a single request and returns the status and the body of its response.
+func (c *Client) send(ctx context.Context, method, path string, body []byte) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// sleep waits for d, returning early with the error of ctx once it is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
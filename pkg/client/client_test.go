@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer answers the first failures requests with a 503 and the
+// others with a summary, counting them all.
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var received atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if received.Add(1) <= failures {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"summary": {"id": 7, "final": true, "total_requests": 10}}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"worker": {"id": 7}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		post         bool
+		wantReceived int32
+		wantErr      bool
+	}{
+		{name: "GET recovers", failures: 2, wantReceived: 3},
+		{name: "GET retries exhausted", failures: 10, wantReceived: DefaultMaxRetries + 1, wantErr: true},
+		{name: "POST never retried", failures: 1, post: true, wantReceived: 1, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, received := flakyServer(t, test.failures)
+			c := NewClient(server.URL, "")
+			c.RetryDelay = time.Millisecond
+
+			var err error
+			if test.post {
+				_, err = c.CreateWorker(context.Background(), &WorkerInput{})
+			} else {
+				_, err = c.GetMetrics(context.Background(), 7)
+			}
+
+			if (err != nil) != test.wantErr {
+				t.Errorf("err = %v, want an error %t", err, test.wantErr)
+			}
+			var apiErr *APIError
+			if test.wantErr && (!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable) {
+				t.Errorf("err = %v, want the 503 answered", err)
+			}
+			if got := received.Load(); got != test.wantReceived {
+				t.Errorf("server received %d requests, want %d", got, test.wantReceived)
+			}
+		})
+	}
+}
+
+func TestRetriesCancelled(t *testing.T) {
+	server, received := flakyServer(t, 10)
+	c := NewClient(server.URL, "")
+	c.RetryDelay = time.Hour
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetMetrics(ctx, 7); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want the deadline of the context", err)
+	}
+	if got := received.Load(); got != 1 {
+		t.Errorf("server received %d requests, want 1", got)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+		want   error
+	}{
+		{name: "bad request", method: http.MethodGet, path: "/v1/workers/abc", status: http.StatusBadRequest, body: "Bad Request", want: ErrInvalidInput},
+		{name: "not found", method: http.MethodGet, path: "/v1/workers/9", status: http.StatusNotFound, body: "Not Found", want: ErrNoRecord},
+		{name: "violations", method: http.MethodPost, path: "/v1/workers", status: http.StatusUnprocessableEntity, body: `{"errors": {"concurrency": "must be positive"}}`, want: ErrInvalidInput},
+		{name: "doubtful plan", method: http.MethodPost, path: "/v1/workers", status: http.StatusUnprocessableEntity, body: `{"error": "doubtful plan", "warnings": ["too slow"]}`, want: ErrDoubtfulPlan},
+		{name: "exclusive run", method: http.MethodPost, path: "/v1/workers", status: http.StatusConflict, body: `{"error": "exclusive", "worker_ids": [3]}`, want: ErrExclusiveRun},
+		{name: "duplicate name", method: http.MethodPost, path: "/v1/environments", status: http.StatusConflict, body: `{"error": "taken"}`, want: ErrDuplicateName},
+		{name: "maintenance window", method: http.MethodPost, path: "/v1/workers", status: http.StatusConflict, body: `{"error": "maintenance"}`, want: ErrMaintenanceWindow},
+		{name: "disabled environment", method: http.MethodPost, path: "/v1/workers", status: http.StatusForbidden, body: "Forbidden", want: ErrEnvironmentDisabled},
+		{name: "quota", method: http.MethodPost, path: "/v1/workers", status: http.StatusTooManyRequests, body: `{"error": "quota"}`, want: ErrQuotaExceeded},
+		{name: "server error", method: http.MethodGet, path: "/v1/workers/9", status: http.StatusInternalServerError, body: "Internal Server Error"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newAPIError(test.method, test.path, test.status, []byte(test.body))
+			if test.want == nil {
+				if err.Unwrap() != nil {
+					t.Errorf("%v unwraps to %v, want nothing", err, err.Unwrap())
+				}
+				return
+			}
+			if !errors.Is(err, test.want) {
+				t.Errorf("%v isn't %v", err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vladComan0/performance-analyzer/internal/custom_errors"
+)
+
+// The errors of the server an *APIError unwraps to.
+var (
+	ErrNoRecord            = custom_errors.ErrNoRecord
+	ErrInvalidInput        = custom_errors.ErrInvalidInput
+	ErrDuplicateName       = custom_errors.ErrDuplicateName
+	ErrEnvironmentDisabled = custom_errors.ErrEnvironmentDisabled
+	ErrMaintenanceWindow   = custom_errors.ErrMaintenanceWindow
+	ErrExclusiveRun        = custom_errors.ErrExclusiveRun
+	ErrQuotaExceeded       = custom_errors.ErrQuotaExceeded
+	ErrDoubtfulPlan        = custom_errors.ErrDoubtfulPlan
+)
+
+// APIError is a request the server answered with an error status. It
+// unwraps to the error of the server the status stands for, if any.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+	Fields     map[string]string // the violations of a rejected input, keyed by the path of their field
+	Warnings   []string          // of a doubtful plan
+	WorkerIDs  []int             // of the exclusive runs the worker conflicts with
+	err        error
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if len(e.Fields) > 0 {
+		violations := make([]string, 0, len(e.Fields))
+		for field, violation := range e.Fields {
+			violations = append(violations, field+" "+violation)
+		}
+		slices.Sort(violations)
+		message = strings.Join(violations, ", ")
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// newAPIError reads the body of an error response, either the plain status
+// text or a JSON envelope, and finds the error of the server it stands for.
+func newAPIError(method, path string, status int, data []byte) *APIError {
+	apiErr := &APIError{Method: method, Path: path, StatusCode: status}
+
+	var envelope struct {
+		Error     string            `json:"error"`
+		Errors    map[string]string `json:"errors"`
+		Warnings  []string          `json:"warnings"`
+		WorkerIDs []int             `json:"worker_ids"`
+	}
+	if json.Unmarshal(data, &envelope) == nil {
+		apiErr.Message, apiErr.Fields, apiErr.Warnings, apiErr.WorkerIDs = envelope.Error, envelope.Errors, envelope.Warnings, envelope.WorkerIDs
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(status)
+	}
+
+	switch {
+	case status == http.StatusBadRequest:
+		apiErr.err = ErrInvalidInput
+	case status == http.StatusNotFound:
+		apiErr.err = ErrNoRecord
+	case status == http.StatusTooManyRequests:
+		apiErr.err = ErrQuotaExceeded
+	case status == http.StatusUnprocessableEntity && len(apiErr.Fields) > 0:
+		apiErr.err = ErrInvalidInput
+	case status == http.StatusUnprocessableEntity && len(apiErr.Warnings) > 0:
+		apiErr.err = ErrDoubtfulPlan
+	case status == http.StatusConflict && len(apiErr.WorkerIDs) > 0:
+		apiErr.err = ErrExclusiveRun
+	case status == http.StatusConflict && path == "/v1/environments":
+		apiErr.err = ErrDuplicateName
+	case status == http.StatusConflict && path == "/v1/workers":
+		apiErr.err = ErrMaintenanceWindow
+	case status == http.StatusForbidden && path == "/v1/workers":
+		apiErr.err = ErrEnvironmentDisabled
+	}
+	return apiErr
+}
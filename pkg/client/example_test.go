@@ -0,0 +1,52 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladComan0/performance-analyzer/pkg/client"
+)
+
+func Example() {
+	ctx := context.Background()
+	c := client.NewClient("http://localhost:4000", "")
+
+	environment, err := c.CreateEnvironment(ctx, client.CreateEnvironmentInput{
+		Name:     "staging",
+		Endpoint: "https://staging.example.com/api",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	worker, err := c.CreateWorker(ctx, &client.WorkerInput{
+		EnvironmentID:   environment.ID,
+		Concurrency:     10,
+		RequestsPerTask: 100,
+		HTTPMethod:      "GET",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	summary, err := c.WaitForWorker(ctx, worker.ID, time.Second)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d requests, p95 %.3fs\n", summary.TotalRequests, summary.Percentiles["95"])
+}
+
+func ExampleAPIError() {
+	c := client.NewClient("http://localhost:4000", "")
+
+	_, err := c.CreateWorker(context.Background(), &client.WorkerInput{HTTPMethod: "FETCH"})
+	var apiErr *client.APIError
+	if errors.Is(err, client.ErrInvalidInput) && errors.As(err, &apiErr) {
+		for field, violation := range apiErr.Fields {
+			fmt.Println(field, violation)
+		}
+	}
+}
@@ -1,25 +1,36 @@
 package helpers
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"io"
+	"net"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"reflect"
 	"runtime/debug"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 type Helper struct {
-	Log          zerolog.Logger
-	DebugEnabled bool
+	Log                   zerolog.Logger
+	DebugEnabled          bool
+	TrustForwardedHeaders bool           // the server runs behind a proxy setting X-Forwarded-Proto and X-Forwarded-Host
+	TrustedProxies        []netip.Prefix // peers whose X-Forwarded-Proto and X-Forwarded-Host headers are honored
+	JSONLimits            JSONLimits     // of the bodies read by ReadJSON
 }
 
-func NewHelper(log zerolog.Logger, debugEnabled bool) *Helper {
+func NewHelper(log zerolog.Logger, debugEnabled, trustForwardedHeaders bool) *Helper {
 	return &Helper{
-		Log:          log,
-		DebugEnabled: debugEnabled,
+		Log:                   log,
+		DebugEnabled:          debugEnabled,
+		TrustForwardedHeaders: trustForwardedHeaders,
 	}
 }
 
@@ -29,6 +40,14 @@ func (h *Helper) ClientError(w http.ResponseWriter, status int) {
 	http.Error(w, http.StatusText(status), status)
 }
 
+// FailedValidation answers a request whose input has violations with 422
+// and every violation, keyed by the path of its field.
+func (h *Helper) FailedValidation(w http.ResponseWriter, violations map[string]string) {
+	if err := h.WriteJSON(w, http.StatusUnprocessableEntity, Envelope{"errors": violations}, nil); err != nil {
+		h.ServerError(w, err)
+	}
+}
+
 func (h *Helper) ServerError(w http.ResponseWriter, err error) {
 	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
 	h.Log.Err(errors.New(trace)).Send()
@@ -38,11 +57,20 @@ func (h *Helper) ServerError(w http.ResponseWriter, err error) {
 	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 }
 
+// ReadJSON decodes the body of r into dst. A body exceeding the JSONLimits
+// of the helper is rejected with a *JSONLimitError before being decoded.
 func (h *Helper) ReadJSON(w http.ResponseWriter, r *http.Request, dst any) error {
 	const maxBytes = 1_048_576
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		return err
+	}
 
-	dec := json.NewDecoder(r.Body)
+	if err := checkJSONLimits(data, h.JSONLimits); err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
 	dec.DisallowUnknownFields()
 
 	if err := dec.Decode(dst); err != nil {
@@ -80,12 +108,179 @@ func (h *Helper) WriteJSON(w http.ResponseWriter, status int, data Envelope, hea
 	return nil
 }
 
+// WriteJSONStream encodes data straight to w instead of buffering it like
+// WriteJSON, for payloads too large to be held twice in memory. The output
+// isn't indented and has no Content-Length, so it is sent chunked. Arrays
+// are encoded element by element, the response being flushed every
+// flushEvery elements when it is positive. Once the status is sent an error
+// can't be reported to the client anymore, it is up to the caller to log it.
+func (h *Helper) WriteJSONStream(w http.ResponseWriter, status int, data Envelope, headers http.Header, flushEvery int) error {
+	for key, value := range headers {
+		w.Header()[key] = value
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	stream := &jsonStream{
+		w:          w,
+		encoder:    json.NewEncoder(w),
+		controller: http.NewResponseController(w),
+		flushEvery: flushEvery,
+	}
+
+	// Same key order as json.Marshal.
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	if err := stream.write("{"); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if i > 0 {
+			if err := stream.write(","); err != nil {
+				return err
+			}
+		}
+		if err := stream.encode(key); err != nil {
+			return err
+		}
+		if err := stream.write(":"); err != nil {
+			return err
+		}
+		if err := stream.value(data[key]); err != nil {
+			return err
+		}
+	}
+	if err := stream.write("}\n"); err != nil {
+		return err
+	}
+
+	return stream.flush()
+}
+
+type jsonStream struct {
+	w          io.Writer
+	encoder    *json.Encoder
+	controller *http.ResponseController
+	flushEvery int
+	written    int
+}
+
+func (s *jsonStream) write(token string) error {
+	_, err := io.WriteString(s.w, token)
+	return err
+}
+
+func (s *jsonStream) encode(value any) error {
+	return s.encoder.Encode(value)
+}
+
+// value encodes a slice one element at a time, anything else at once.
+func (s *jsonStream) value(value any) error {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array ||
+		v.Kind() == reflect.Slice && (v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8) {
+		// nil slices are null and byte slices base64 strings, as with json.Marshal.
+		return s.encode(value)
+	}
+
+	if err := s.write("["); err != nil {
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			if err := s.write(","); err != nil {
+				return err
+			}
+		}
+		if err := s.encode(v.Index(i).Interface()); err != nil {
+			return err
+		}
+		s.written++
+		if s.flushEvery > 0 && s.written%s.flushEvery == 0 {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return s.write("]")
+}
+
+func (s *jsonStream) flush() error {
+	if err := s.controller.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+	return nil
+}
+
+// AbsoluteURL returns the absolute URL of path on the host r was sent to.
+// The scheme and host set by a proxy are only used when TrustForwardedHeaders
+// is set and the peer is one of the TrustedProxies, a client could spoof them
+// otherwise.
+func (h *Helper) AbsoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if h.TrustForwardedHeaders && h.fromTrustedProxy(r) {
+		// A chain of proxies appends its values, the first one is what the client used.
+		if proto := firstHeaderValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if forwardedHost := firstHeaderValue(r, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   host,
+		Path:   "/" + strings.TrimPrefix(path, "/"),
+	}
+	return u.String()
+}
+
+// fromTrustedProxy reports whether r was received from one of the TrustedProxies.
+func (h *Helper) fromTrustedProxy(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	return err == nil && IsTrusted(peer, h.TrustedProxies)
+}
+
+// IsTrusted reports whether addr is in one of the trusted ranges.
+func IsTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func firstHeaderValue(r *http.Request, key string) string {
+	value, _, _ := strings.Cut(r.Header.Get(key), ",")
+	return strings.ToLower(strings.TrimSpace(value))
+}
+
+// ErrInvalidID is returned by GetID when the id path value isn't a positive integer.
+var ErrInvalidID = errors.New("invalid id")
+
 func (h *Helper) GetID(r *http.Request) (int, error) {
 	// fetch the ID knowing that I use stdlib mux
 	idString := r.PathValue("id")
 	id, err := strconv.Atoi(idString)
-	if err != nil {
-		return 0, err
+	if err != nil || id < 1 {
+		return 0, ErrInvalidID
 	}
 
 	return id, nil
@@ -0,0 +1,172 @@
+package helpers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// listedWorker is the shape of a worker of the list endpoints, with a time
+// series making it a few hundred bytes.
+type listedWorker struct {
+	ID          int       `json:"id"`
+	Status      string    `json:"status"`
+	Concurrency int       `json:"concurrency"`
+	Throughput  float64   `json:"throughput"`
+	Series      []float64 `json:"series"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func listedWorkers(n int) []listedWorker {
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	workers := make([]listedWorker, n)
+	for i := range workers {
+		workers[i] = listedWorker{
+			ID:          i + 1,
+			Status:      "finished",
+			Concurrency: 10,
+			Throughput:  123.45,
+			Series:      []float64{0.012, 0.015, 0.011, 0.020, 0.013, 0.018, 0.014, 0.016},
+			CreatedAt:   createdAt.Add(time.Duration(i) * time.Second),
+		}
+	}
+	return workers
+}
+
+func TestWriteJSONStream(t *testing.T) {
+	helper := NewHelper(zerolog.Nop(), false, false)
+	data := Envelope{"workers": listedWorkers(250), "count": 250, "empty": []int{}, "none": []int(nil), "raw": []byte("hi")}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := helper.WriteJSONStream(w, http.StatusOK, data, http.Header{"X-Total": {"250"}}, 100); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if !slices.Contains(resp.TransferEncoding, "chunked") || resp.ContentLength != -1 {
+		t.Errorf("transfer encoding = %v and content length = %d, want a chunked response", resp.TransferEncoding, resp.ContentLength)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" || resp.Header.Get("X-Total") != "250" {
+		t.Errorf("headers = %v, want the JSON content type and the given headers", resp.Header)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same document as the one WriteJSON buffers.
+	want, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, expected any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("invalid JSON streamed: %s", err)
+	}
+	_ = json.Unmarshal(want, &expected)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("streamed %s, want %s", body, want)
+	}
+}
+
+// The allocations of the list of 10k workers, buffered then streamed.
+func BenchmarkWriteJSON(b *testing.B) {
+	helper := NewHelper(zerolog.Nop(), false, false)
+	data := Envelope{"workers": listedWorkers(10_000)}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := helper.WriteJSON(discardWriter{httptest.NewRecorder()}, http.StatusOK, data, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteJSONStream(b *testing.B) {
+	helper := NewHelper(zerolog.Nop(), false, false)
+	data := Envelope{"workers": listedWorkers(10_000)}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := helper.WriteJSONStream(discardWriter{httptest.NewRecorder()}, http.StatusOK, data, nil, 1000); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// discardWriter is a ResponseWriter dropping the body, as a client reading
+// the stream would, rather than keeping it all like a ResponseRecorder.
+type discardWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (discardWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestAbsoluteURL(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name         string
+		trustProxy   bool
+		peer         string
+		tls          bool
+		forwardProto string
+		forwardHost  string
+		path         string
+		want         string
+	}{
+		{name: "direct", path: "/v1/workers/7", want: "http://api.local/v1/workers/7"},
+		{name: "direct over TLS", tls: true, path: "/v1/workers/7", want: "https://api.local/v1/workers/7"},
+		{name: "path without a leading slash", path: "v1/environments/7", want: "http://api.local/v1/environments/7"},
+		{name: "proxy trusted", trustProxy: true, peer: "10.0.0.1:4321", forwardProto: "https", forwardHost: "perf.example.com", path: "/v1/workers/7", want: "https://perf.example.com/v1/workers/7"},
+		{name: "proxy chain", trustProxy: true, peer: "10.0.0.1:4321", forwardProto: "https, http", forwardHost: "perf.example.com, internal:8080", path: "/v1/workers/7", want: "https://perf.example.com/v1/workers/7"},
+		{name: "proxy trusted without headers", trustProxy: true, peer: "10.0.0.1:4321", path: "/v1/workers/7", want: "http://api.local/v1/workers/7"},
+		{name: "unknown forwarded scheme", trustProxy: true, peer: "10.0.0.1:4321", forwardProto: "gopher", path: "/v1/workers/7", want: "http://api.local/v1/workers/7"},
+		{name: "proxy not trusted", peer: "10.0.0.1:4321", forwardProto: "https", forwardHost: "evil.example.com", path: "/v1/workers/7", want: "http://api.local/v1/workers/7"},
+		{name: "peer not a trusted proxy", trustProxy: true, peer: "203.0.113.7:4321", forwardProto: "https", forwardHost: "evil.example.com", path: "/v1/workers/7", want: "http://api.local/v1/workers/7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://api.local/v1/workers", nil)
+			if tt.tls {
+				r = httptest.NewRequest(http.MethodPost, "https://api.local/v1/workers", nil)
+			}
+			if tt.peer != "" {
+				r.RemoteAddr = tt.peer
+			}
+			if tt.forwardProto != "" {
+				r.Header.Set("X-Forwarded-Proto", tt.forwardProto)
+			}
+			if tt.forwardHost != "" {
+				r.Header.Set("X-Forwarded-Host", tt.forwardHost)
+			}
+
+			helper := NewHelper(zerolog.Nop(), false, tt.trustProxy)
+			helper.TrustedProxies = proxies
+			if got := helper.AbsoluteURL(r, tt.path); got != tt.want {
+				t.Errorf("AbsoluteURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
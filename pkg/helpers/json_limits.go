@@ -0,0 +1,131 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Defaults of JSONLimits, applied to the limits left at 0.
+const (
+	DefaultJSONMaxDepth    = 64
+	DefaultJSONMaxElements = 10_000
+)
+
+// Limits a JSONLimitError may report.
+const (
+	JSONLimitDepth        = "max_depth"
+	JSONLimitElements     = "max_elements"
+	JSONLimitDuplicateKey = "duplicate_key"
+)
+
+// JSONLimits bounds the shape of the documents read by ReadJSON. The raw
+// parts of a document, such as the body of a worker, are only parsed when
+// used, so they are checked up front along with the rest of it.
+type JSONLimits struct {
+	MaxDepth    int // nested objects and arrays, DefaultJSONMaxDepth when 0
+	MaxElements int // elements of a single array, DefaultJSONMaxElements when 0
+}
+
+// JSONLimitError rejects a document that exceeds one of the JSONLimits or
+// sets a key of its top-level object twice.
+type JSONLimitError struct {
+	Limit string // one of the JSONLimit constants
+	Max   int
+	Key   string // the duplicated key
+}
+
+func (e *JSONLimitError) Error() string {
+	if e.Limit == JSONLimitDuplicateKey {
+		return fmt.Sprintf("body sets the key %q twice", e.Key)
+	}
+	return fmt.Sprintf("body exceeds the %s of %d", e.Limit, e.Max)
+}
+
+// jsonFrame is an object or an array being walked by checkJSONLimits.
+type jsonFrame struct {
+	array    bool
+	elements int
+	keyNext  bool            // the next token of an object is a key
+	keys     map[string]bool // of the top-level object only
+}
+
+// checkJSONLimits walks data token by token, without building it, and
+// returns a *JSONLimitError for the first limit it exceeds. Syntax errors
+// are left to the decoding.
+func checkJSONLimits(data []byte, limits JSONLimits) error {
+	maxDepth, maxElements := limits.MaxDepth, limits.MaxElements
+	if maxDepth <= 0 {
+		maxDepth = DefaultJSONMaxDepth
+	}
+	if maxElements <= 0 {
+		maxElements = DefaultJSONMaxElements
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var stack []*jsonFrame
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			// The end of the document, or a syntax error the decoding reports.
+			return nil
+		}
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			switch {
+			case parent.array:
+				parent.elements++
+				if parent.elements > maxElements {
+					return &JSONLimitError{Limit: JSONLimitElements, Max: maxElements}
+				}
+			case parent.keyNext:
+				parent.keyNext = false
+				key, _ := token.(string)
+				if parent.keys != nil {
+					if parent.keys[key] {
+						return &JSONLimitError{Limit: JSONLimitDuplicateKey, Key: key}
+					}
+					parent.keys[key] = true
+				}
+				continue
+			default:
+				parent.keyNext = true
+			}
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			if len(stack) == maxDepth {
+				return &JSONLimitError{Limit: JSONLimitDepth, Max: maxDepth}
+			}
+			frame := &jsonFrame{array: delim == '[', keyNext: delim == '{'}
+			if delim == '{' && len(stack) == 0 {
+				frame.keys = make(map[string]bool)
+			}
+			stack = append(stack, frame)
+		}
+	}
+}
+
+// BadJSON answers a request whose body ReadJSON rejected, with 422 and the
+// limit hit for a *JSONLimitError, with 400 otherwise.
+func (h *Helper) BadJSON(w http.ResponseWriter, err error) {
+	var limitErr *JSONLimitError
+	if !errors.As(err, &limitErr) {
+		h.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := Envelope{"error": limitErr.Error(), "limit": limitErr.Limit}
+	if err := h.WriteJSON(w, http.StatusUnprocessableEntity, data, nil); err != nil {
+		h.ServerError(w, err)
+	}
+}
@@ -0,0 +1,154 @@
+package helpers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+// nested returns depth arrays nested in each other.
+func nested(depth int) string {
+	return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+}
+
+// array returns an array of n elements.
+func array(n int) string {
+	return "[" + strings.TrimSuffix(strings.Repeat("1,", n), ",") + "]"
+}
+
+func TestCheckJSONLimits(t *testing.T) {
+	limits := JSONLimits{MaxDepth: 4, MaxElements: 5}
+
+	tests := []struct {
+		name string
+		data string
+		want string // the limit exceeded, none when empty
+	}{
+		{name: "depth at the limit", data: `{"a": [[{"b": 1}]]}`},
+		{name: "depth past the limit", data: `{"a": [[{"b": [1]}]]}`, want: JSONLimitDepth},
+		{name: "nested arrays at the limit", data: nested(4)},
+		{name: "nested arrays past the limit", data: nested(5), want: JSONLimitDepth},
+		{name: "elements at the limit", data: `{"bodies": ` + array(5) + `}`},
+		{name: "elements past the limit", data: `{"bodies": ` + array(6) + `}`, want: JSONLimitElements},
+		{name: "nested elements past the limit", data: `[[` + array(6) + `]]`, want: JSONLimitElements},
+		{name: "object keys aren't elements", data: `{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5, "f": 6}`},
+		{name: "duplicate top-level key", data: `{"body": 1, "concurrency": 2, "body": 3}`, want: JSONLimitDuplicateKey},
+		{name: "duplicate nested key", data: `{"body": {"a": 1, "a": 2}}`},
+		{name: "key repeated as a value", data: `{"a": "a", "b": "a"}`},
+		{name: "syntax error", data: `{"a": [1,`},
+		{name: "empty", data: ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := checkJSONLimits([]byte(test.data), limits)
+			if test.want == "" {
+				if err != nil {
+					t.Fatalf("rejected with %v", err)
+				}
+				return
+			}
+
+			var limitErr *JSONLimitError
+			if !errors.As(err, &limitErr) || limitErr.Limit != test.want {
+				t.Fatalf("err = %v, want the %s exceeded", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckJSONLimitsDefaults(t *testing.T) {
+	// Deep enough to overflow a recursive walk, rejected without one.
+	var limitErr *JSONLimitError
+	if err := checkJSONLimits([]byte(nested(100_000)), JSONLimits{}); !errors.As(err, &limitErr) || limitErr.Max != DefaultJSONMaxDepth {
+		t.Errorf("err = %v, want the default depth exceeded", err)
+	}
+	if err := checkJSONLimits([]byte(array(DefaultJSONMaxElements+1)), JSONLimits{}); !errors.As(err, &limitErr) || limitErr.Max != DefaultJSONMaxElements {
+		t.Errorf("err = %v, want the default element count exceeded", err)
+	}
+}
+
+func TestReadJSONLimits(t *testing.T) {
+	helper := NewHelper(zerolog.Nop(), false, false)
+	helper.JSONLimits = JSONLimits{MaxDepth: 2}
+
+	tests := []struct {
+		body       string
+		wantStatus int
+		wantLimit  string
+	}{
+		{body: `{"body": [[1]]}`, wantStatus: http.StatusUnprocessableEntity, wantLimit: JSONLimitDepth},
+		{body: `{"body": 1, "body": 2}`, wantStatus: http.StatusUnprocessableEntity, wantLimit: JSONLimitDuplicateKey},
+		{body: `{"body": [1`, wantStatus: http.StatusBadRequest},
+		{body: `{"body": [1]}`, wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		w := httptest.NewRecorder()
+		var dst struct {
+			Body json.RawMessage `json:"body"`
+		}
+		if err := helper.ReadJSON(w, r, &dst); err != nil {
+			helper.BadJSON(w, err)
+		}
+
+		if w.Code != test.wantStatus {
+			t.Errorf("%s answered %d, want %d", test.body, w.Code, test.wantStatus)
+			continue
+		}
+		if test.wantLimit == "" {
+			continue
+		}
+		var answer map[string]any
+		if err := json.Unmarshal(w.Body.Bytes(), &answer); err != nil || answer["limit"] != test.wantLimit {
+			t.Errorf("%s answered %s, want the %s reported", test.body, w.Body, test.wantLimit)
+		}
+	}
+}
+
+// shape returns the depth of value and the largest element count of its arrays.
+func shape(value any) (depth, elements int) {
+	switch value := value.(type) {
+	case []any:
+		elements = len(value)
+		for _, element := range value {
+			d, e := shape(element)
+			depth, elements = max(depth, d), max(elements, e)
+		}
+		return depth + 1, elements
+	case map[string]any:
+		for _, element := range value {
+			d, e := shape(element)
+			depth, elements = max(depth, d), max(elements, e)
+		}
+		return depth + 1, elements
+	default:
+		return 0, 0
+	}
+}
+
+func FuzzCheckJSONLimits(f *testing.F) {
+	for _, seed := range []string{`{"a": [1, 2, {"b": null}]}`, nested(6), array(8), `{"a": 1, "a": 2}`, `[{"a": [[]]}, "x"]`, `{"a": [`} {
+		f.Add([]byte(seed))
+	}
+	limits := JSONLimits{MaxDepth: 4, MaxElements: 5}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		err := checkJSONLimits(data, limits)
+		var value any
+		if err != nil || json.Unmarshal(data, &value) != nil {
+			return
+		}
+
+		// A document accepted is within the limits.
+		if depth, elements := shape(value); depth > limits.MaxDepth || elements > limits.MaxElements {
+			t.Errorf("%q of depth %d and %d elements accepted", data, depth, elements)
+		}
+	})
+}
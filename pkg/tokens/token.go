@@ -1,9 +1,12 @@
 package tokens
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
+	"mime"
 	"net/http"
 	"net/url"
 	"strings"
@@ -11,6 +14,20 @@ import (
 	"time"
 )
 
+// ErrInvalidTokenResponse is returned when the token endpoint answers 200
+// with something that isn't a usable token.
+var ErrInvalidTokenResponse = errors.New("tokens: invalid token response")
+
+// StatusError is returned when the token endpoint answers with another
+// status than 200.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
 type Credentials struct {
 	Username       *string `json:"username"`
 	Password       *string `json:"password"`
@@ -18,9 +35,11 @@ type Credentials struct {
 }
 
 type Token struct {
-	Value     string
-	ExpiresIn time.Duration
-	FetchedAt time.Time
+	Value        string
+	RefreshToken string // empty when the token endpoint doesn't issue one
+	ExpiresIn    time.Duration
+	Scopes       []string // granted, empty when the token endpoint doesn't tell
+	FetchedAt    time.Time
 }
 
 type TokenManager struct {
@@ -54,14 +73,49 @@ func (tm *TokenManager) GetToken() (string, error) {
 	return tm.Token.Value, nil
 }
 
+// Verify fetches a new token with the credentials, neither refreshing nor
+// replacing the token in use, to check them. The token is returned for its
+// expiry and scopes, not to be sent.
+func (tm *TokenManager) Verify(ctx context.Context) (Token, error) {
+	return tm.requestToken(ctx, tm.passwordGrant())
+}
+
+// requestNewToken renews the token with the refresh token if there is one,
+// falling back to the password grant if the refresh is rejected.
 func (tm *TokenManager) requestNewToken() (Token, error) {
-	urlStr := tm.BaseURL + "/v2/oauth/token"
+	if refreshToken := tm.Token.RefreshToken; refreshToken != "" {
+		data := url.Values{}
+		data.Set("grant_type", "refresh_token")
+		data.Set("refresh_token", refreshToken)
+
+		token, err := tm.requestToken(context.Background(), data)
+		if err == nil {
+			// The refresh token stays valid unless a new one is issued.
+			if token.RefreshToken == "" {
+				token.RefreshToken = refreshToken
+			}
+			return token, nil
+		}
+		tm.Log.Warn().Err(err).Msg("Error refreshing token, requesting a new one with the credentials")
+	}
+
+	return tm.requestToken(context.Background(), tm.passwordGrant())
+}
+
+// passwordGrant returns the grant of a token for the credentials.
+func (tm *TokenManager) passwordGrant() url.Values {
 	data := url.Values{}
 	data.Set("grant_type", "password")
 	data.Set("username", *tm.Credentials.Username)
 	data.Set("password", *tm.Credentials.Password)
+	return data
+}
 
-	req, err := http.NewRequest("POST", urlStr, strings.NewReader(data.Encode()))
+// requestToken posts a grant to the token endpoint and reads the token of the response.
+func (tm *TokenManager) requestToken(ctx context.Context, data url.Values) (Token, error) {
+	urlStr := tm.BaseURL + "/v2/oauth/token"
+
+	req, err := http.NewRequestWithContext(ctx, "POST", urlStr, strings.NewReader(data.Encode()))
 	if err != nil {
 		return Token{}, err
 	}
@@ -76,25 +130,44 @@ func (tm *TokenManager) requestNewToken() (Token, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return Token{}, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return Token{}, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	// A token that can't be read must not be returned, its zero expiry would have it fetched again on every call.
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return Token{}, fmt.Errorf("%w: content type %q isn't JSON", ErrInvalidTokenResponse, contentType)
+		}
 	}
 
 	type response struct {
-		Token     string        `json:"access_token"`
-		ExpiresIn time.Duration `json:"expires_in"`
+		Token        string        `json:"access_token"`
+		RefreshToken string        `json:"refresh_token"`
+		ExpiresIn    time.Duration `json:"expires_in"`
+		Scope        string        `json:"scope"`
 	}
 
 	var res response
 	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		tm.Log.Error().Err(err).Msg("Error decoding response")
+		return Token{}, fmt.Errorf("%w: %w", ErrInvalidTokenResponse, err)
+	}
+
+	if res.Token == "" {
+		return Token{}, fmt.Errorf("%w: empty access_token", ErrInvalidTokenResponse)
+	}
+	if res.ExpiresIn <= 0 {
+		return Token{}, fmt.Errorf("%w: expires_in %d isn't positive", ErrInvalidTokenResponse, res.ExpiresIn)
 	}
 
 	expiresIn := res.ExpiresIn * time.Second
-	tm.Log.Debug().Msgf("Fetched new token, expires in: %s", expiresIn)
+	tm.Log.Debug().Msgf("Fetched new token with grant %s, expires in: %s", data.Get("grant_type"), expiresIn)
 
 	return Token{
-		Value:     res.Token,
-		ExpiresIn: expiresIn,
-		FetchedAt: time.Now(),
+		Value:        res.Token,
+		RefreshToken: res.RefreshToken,
+		ExpiresIn:    expiresIn,
+		Scopes:       strings.Fields(res.Scope),
+		FetchedAt:    time.Now(),
 	}, nil
 }
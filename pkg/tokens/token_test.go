@@ -0,0 +1,190 @@
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+func newTestTokenManager(baseURL string) *TokenManager {
+	username, password, basicAuthToken := "user", "secret", "dGVzdDp0ZXN0"
+	credentials := Credentials{Username: &username, Password: &password, BasicAuthToken: &basicAuthToken}
+	return NewTokenManager(credentials, baseURL, zerolog.Nop())
+}
+
+func TestInvalidTokenResponse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{name: "malformed JSON", contentType: "application/json", body: `{"access_token": "abc", `},
+		{name: "HTML login page", contentType: "text/html; charset=utf-8", body: `<html>Sign in</html>`},
+		{name: "empty access token", contentType: "application/json", body: `{"access_token": "", "expires_in": 3600}`},
+		{name: "missing access token", contentType: "application/json", body: `{"expires_in": 3600}`},
+		{name: "zero expiry", contentType: "application/json", body: `{"access_token": "abc", "expires_in": 0}`},
+		{name: "negative expiry", contentType: "application/json", body: `{"access_token": "abc", "expires_in": -60}`},
+		{name: "empty body", contentType: "application/json", body: ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", test.contentType)
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer stub.Close()
+
+			tm := newTestTokenManager(stub.URL)
+			for i := 0; i < 2; i++ {
+				token, err := tm.GetToken()
+				if !errors.Is(err, ErrInvalidTokenResponse) {
+					t.Fatalf("GetToken() error = %v, want ErrInvalidTokenResponse", err)
+				}
+				if token != "" {
+					t.Errorf("GetToken() = %q, want no token", token)
+				}
+			}
+			if tm.Token.Value != "" {
+				t.Errorf("token in use = %q, want none kept", tm.Token.Value)
+			}
+			if calls != 2 {
+				t.Errorf("token endpoint calls = %d, want one per GetToken", calls)
+			}
+		})
+	}
+}
+
+func TestValidTokenResponse(t *testing.T) {
+	calls := 0
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, _ = w.Write([]byte(`{"access_token": "abc", "expires_in": 3600, "scope": "read write"}`))
+	}))
+	defer stub.Close()
+
+	tm := newTestTokenManager(stub.URL)
+	for i := 0; i < 3; i++ {
+		token, err := tm.GetToken()
+		if err != nil {
+			t.Fatalf("GetToken() error = %v", err)
+		}
+		if token != "abc" {
+			t.Errorf("GetToken() = %q, want %q", token, "abc")
+		}
+	}
+
+	// A valid token is cached until it expires, not fetched on every call.
+	if calls != 1 {
+		t.Errorf("token endpoint calls = %d, want 1", calls)
+	}
+	if len(tm.Token.Scopes) != 2 {
+		t.Errorf("scopes = %v, want [read write]", tm.Token.Scopes)
+	}
+}
+
+func TestTokenStatusError(t *testing.T) {
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer stub.Close()
+
+	_, err := newTestTokenManager(stub.URL).GetToken()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("GetToken() error = %v, want a StatusError of 401", err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	tests := []struct {
+		name          string
+		rejectRefresh bool
+		issueRefresh  bool
+		wantGrants    []string
+		wantToken     string
+		wantRefresh   string
+	}{
+		{
+			name:         "refreshed with a new refresh token",
+			issueRefresh: true,
+			wantGrants:   []string{"password", "refresh_token"},
+			wantToken:    "access-2",
+			wantRefresh:  "refresh-2",
+		},
+		{
+			name:        "refreshed keeping the refresh token",
+			wantGrants:  []string{"password", "refresh_token"},
+			wantToken:   "access-2",
+			wantRefresh: "refresh-1",
+		},
+		{
+			name:          "refresh rejected",
+			rejectRefresh: true,
+			wantGrants:    []string{"password", "refresh_token", "password"},
+			wantToken:     "access-3",
+			wantRefresh:   "refresh-3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var grants []string
+			stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = r.ParseForm()
+				grant := r.PostForm.Get("grant_type")
+				grants = append(grants, grant)
+				n := len(grants)
+
+				if grant == "refresh_token" {
+					if r.PostForm.Get("refresh_token") != "refresh-1" {
+						t.Errorf("refresh_token = %q, want the one issued with the first token", r.PostForm.Get("refresh_token"))
+					}
+					if test.rejectRefresh {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+				}
+
+				refreshToken := ""
+				if grant == "password" || test.issueRefresh {
+					refreshToken = fmt.Sprintf("refresh-%d", n)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `{"access_token": "access-%d", "refresh_token": %q, "expires_in": 3600}`, n, refreshToken)
+			}))
+			defer stub.Close()
+
+			tm := newTestTokenManager(stub.URL)
+			if _, err := tm.GetToken(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Expire the first token rather than waiting for it.
+			tm.Token.FetchedAt = time.Now().Add(-2 * time.Hour)
+
+			token, err := tm.GetToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token != test.wantToken {
+				t.Errorf("GetToken() = %q, want %q", token, test.wantToken)
+			}
+			if tm.Token.RefreshToken != test.wantRefresh {
+				t.Errorf("refresh token = %q, want %q", tm.Token.RefreshToken, test.wantRefresh)
+			}
+			if !slices.Equal(grants, test.wantGrants) {
+				t.Errorf("grants = %v, want %v", grants, test.wantGrants)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// Package validator accumulates the violations of an input, so that all of
+// them are reported at once, keyed by the path of the field they concern.
+package validator
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Validator collects violations by field path, such as "retry.max_delay" or
+// "body_variants[2].name". A field keeps the first violation added for it.
+type Validator struct {
+	Errors map[string]string
+}
+
+func New() *Validator {
+	return &Validator{Errors: make(map[string]string)}
+}
+
+// Valid reports whether no violation was added.
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError adds a violation of field, unless the field already has one.
+func (v *Validator) AddError(field, message string) {
+	if _, exists := v.Errors[field]; !exists {
+		v.Errors[field] = message
+	}
+}
+
+// Check adds a violation of field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.AddError(field, message)
+	}
+}
+
+// Merge adds the violations of err under prefix: merged under "template",
+// a violation of "concurrency" becomes one of "template.concurrency".
+func (v *Validator) Merge(prefix string, err *Error) {
+	for field, message := range err.Fields {
+		v.AddError(Field(prefix, field), message)
+	}
+}
+
+// Err returns the violations as an *Error, nil when there is none.
+func (v *Validator) Err() error {
+	if v.Valid() {
+		return nil
+	}
+	return &Error{Fields: v.Errors}
+}
+
+// Field joins the parts of a field path, skipping the empty ones:
+// Field("retry", "max_delay") is "retry.max_delay".
+func Field(parts ...string) string {
+	nonEmpty := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, ".")
+}
+
+// Index returns the path of the element i of the list field: Index("targets", 2)
+// is "targets[2]".
+func Index(field string, i int) string {
+	return fmt.Sprintf("%s[%d]", field, i)
+}
+
+// Error reports every violation of an input.
+type Error struct {
+	Fields map[string]string // the violation of every invalid field, by path
+}
+
+func (e *Error) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	slices.Sort(fields)
+
+	violations := make([]string, 0, len(fields))
+	for _, field := range fields {
+		violations = append(violations, fmt.Sprintf("%s: %s", field, e.Fields[field]))
+	}
+	return "validator: " + strings.Join(violations, "; ")
+}
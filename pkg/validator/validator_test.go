@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"errors"
+	"maps"
+	"testing"
+)
+
+func TestValidator(t *testing.T) {
+	v := New()
+	if !v.Valid() || v.Err() != nil {
+		t.Fatal("a new validator isn't valid")
+	}
+
+	v.Check(true, "concurrency", "must be positive")
+	if !v.Valid() {
+		t.Fatal("a passing check added a violation")
+	}
+
+	v.Check(false, "concurrency", "must be positive")
+	v.Check(false, "concurrency", "must be at most 100")
+	v.AddError(Index("targets", 2), "must have a weight")
+	v.Check(false, Field(Index("body_variants", 1), "name"), "must be set and unique")
+
+	want := map[string]string{
+		"concurrency":           "must be positive", // the first violation of a field is kept
+		"targets[2]":            "must have a weight",
+		"body_variants[1].name": "must be set and unique",
+	}
+	if v.Valid() || !maps.Equal(v.Errors, want) {
+		t.Fatalf("violations = %v, want %v", v.Errors, want)
+	}
+
+	var err *Error
+	if !errors.As(v.Err(), &err) || !maps.Equal(err.Fields, want) {
+		t.Fatalf("err = %v, want every violation", v.Err())
+	}
+	if got := err.Error(); got != "validator: body_variants[1].name: must be set and unique; concurrency: must be positive; targets[2]: must have a weight" {
+		t.Errorf("message = %q, want the violations sorted by field", got)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	template := New()
+	template.AddError("concurrency", "must be positive")
+	template.AddError("retry.max_delay", "must be at least the base delay")
+
+	v := New()
+	v.AddError("template.concurrency", "is already invalid")
+	v.AddError("environment_ids", "must not be empty")
+	v.Merge("template", template.Err().(*Error))
+
+	want := map[string]string{
+		"template.concurrency":     "is already invalid",
+		"template.retry.max_delay": "must be at least the base delay",
+		"environment_ids":          "must not be empty",
+	}
+	if !maps.Equal(v.Errors, want) {
+		t.Errorf("violations = %v, want %v", v.Errors, want)
+	}
+}
+
+func TestField(t *testing.T) {
+	tests := []struct {
+		parts []string
+		want  string
+	}{
+		{parts: []string{"retry", "max_delay"}, want: "retry.max_delay"},
+		{parts: []string{"", "concurrency"}, want: "concurrency"},
+		{parts: []string{"template", "", "retry"}, want: "template.retry"},
+		{parts: []string{Index("targets", 0), "weight"}, want: "targets[0].weight"},
+		{parts: nil, want: ""},
+	}
+
+	for _, test := range tests {
+		if got := Field(test.parts...); got != test.want {
+			t.Errorf("Field(%q) = %q, want %q", test.parts, got, test.want)
+		}
+	}
+}